@@ -0,0 +1,103 @@
+package activity
+
+import (
+	"archive-lite/config"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Result is the outcome of an archive operation
+type Result string
+
+const (
+	ResultOK        Result = "ok"
+	ResultFailed    Result = "failed"
+	ResultUnchanged Result = "unchanged" // Checked, but no snapshot was stored because the content hadn't changed
+)
+
+// Event records one archive operation
+type Event struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Action     string    `json:"action"` // archive or refetch
+	URL        string    `json:"url"`
+	Result     Result    `json:"result"`
+	EntryID    string    `json:"entryId,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"durationMs"`
+}
+
+// defaultCapacity is the number of events kept unless ARCHIVE_ACTIVITY_SIZE is set
+const defaultCapacity = 200
+
+var (
+	mu     sync.Mutex
+	events = make([]Event, capacity())
+	next   int // Index the next event is written to
+	count  int // Number of valid events, at most len(events)
+)
+
+// capacity reads ARCHIVE_ACTIVITY_SIZE
+func capacity() int {
+	if n, err := strconv.Atoi(config.Getenv("ARCHIVE_ACTIVITY_SIZE")); err == nil && n > 0 {
+		return n
+	}
+	return defaultCapacity
+}
+
+// Record adds an event to the ring buffer, overwriting the oldest when full
+func Record(action, url, entryID string, started time.Time, err error) {
+	e := Event{
+		Timestamp:  time.Now(),
+		Action:     action,
+		URL:        url,
+		Result:     ResultOK,
+		EntryID:    entryID,
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+	if err != nil {
+		e.Result = ResultFailed
+		e.Error = err.Error()
+	}
+	add(e)
+}
+
+// RecordUnchanged adds an event for an operation that found the content of
+// entryID unchanged
+func RecordUnchanged(action, url, entryID string, started time.Time) {
+	add(Event{
+		Timestamp:  time.Now(),
+		Action:     action,
+		URL:        url,
+		Result:     ResultUnchanged,
+		EntryID:    entryID,
+		DurationMs: time.Since(started).Milliseconds(),
+	})
+}
+
+// add writes e to the ring buffer, overwriting the oldest event when full
+func add(e Event) {
+	mu.Lock()
+	defer mu.Unlock()
+	events[next] = e
+	next = (next + 1) % len(events)
+	if count < len(events) {
+		count++
+	}
+}
+
+// Recent returns up to limit events, newest first. A limit of 0 or less
+// returns every event kept.
+func Recent(limit int) []Event {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if limit <= 0 || limit > count {
+		limit = count
+	}
+	recent := make([]Event, 0, limit)
+	for i := 1; i <= limit; i++ {
+		recent = append(recent, events[(next-i+len(events))%len(events)])
+	}
+	return recent
+}
@@ -0,0 +1,147 @@
+// Package config reads archive-lite's settings. Every setting is named by
+// its environment variable (ARCHIVE_*, CHROME_*); an optional JSON file
+// named by ARCHIVE_CONFIG provides values for settings whose variable is
+// unset, so deployments can keep their configuration in one reviewable file.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+var (
+	// Path is the config file named by ARCHIVE_CONFIG, or "" if none is used
+	Path = os.Getenv("ARCHIVE_CONFIG")
+	// values holds the config file's settings as environment variable strings.
+	// It is loaded during package initialization, before the packages that
+	// import config read their settings into package-level variables.
+	values = mustLoad(Path)
+)
+
+// mustLoad reads the config file at path, exiting when it can't be used:
+// starting with part of a deployment's configuration would be worse
+func mustLoad(path string) map[string]string {
+	if path == "" {
+		return nil
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		log.Fatalf("Failed to load config file: %v", err)
+	}
+	log.Printf("Loaded %d settings from config file '%s'", len(loaded), path)
+	return loaded
+}
+
+// Load reads a JSON config file: an object mapping setting names (the
+// environment variable names, e.g. ARCHIVE_SCREENSHOTS) to strings,
+// numbers, booleans or arrays of those. Only known settings may be set, with
+// values of their kind: booleans, integers and numbers may be given as JSON
+// values or strings, and arrays only for list settings, which joins them with
+// the setting's separator. null leaves a setting unset.
+func Load(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]json.RawMessage
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid config file '%s': %w", path, err)
+	}
+
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	loaded := make(map[string]string, len(raw))
+	for _, key := range keys {
+		if key == "ARCHIVE_CONFIG" {
+			return nil, fmt.Errorf("invalid config file '%s': ARCHIVE_CONFIG can't be set in the config file", path)
+		}
+		s, ok := known[key]
+		if !ok {
+			return nil, fmt.Errorf("invalid config file '%s': unknown setting '%s'", path, key)
+		}
+		value, set, err := settingValue(s, raw[key])
+		if err != nil {
+			return nil, fmt.Errorf("invalid config file '%s': %s: %w", path, key, err)
+		}
+		if set {
+			loaded[key] = value
+		}
+	}
+	return loaded, nil
+}
+
+// settingValue converts one JSON value of the config file to the string its
+// environment variable would hold, checking it suits the setting's kind; set
+// is false for null
+func settingValue(s setting, raw json.RawMessage) (value string, set bool, err error) {
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return "", false, err
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return "", false, nil
+	case []interface{}:
+		if s.kind != kindList {
+			return "", false, fmt.Errorf("expected %s, not an array", s.kind)
+		}
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, ok := scalarString(item)
+			if !ok {
+				return "", false, fmt.Errorf("array items must be strings, numbers or booleans")
+			}
+			items[i] = s
+		}
+		return strings.Join(items, s.sep), true, nil
+	default:
+		str, ok := scalarString(v)
+		if !ok {
+			return "", false, fmt.Errorf("expected %s", s.kind)
+		}
+		if !s.kind.accepts(str) {
+			return "", false, fmt.Errorf("expected %s, got %s", s.kind, raw)
+		}
+		return str, true, nil
+	}
+}
+
+// scalarString formats a decoded JSON string, number or boolean
+func scalarString(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		if v {
+			return "true", true
+		}
+		return "false", true
+	}
+	return "", false
+}
+
+// Getenv returns the value of the setting key: its environment variable
+// when set to a non-empty value, otherwise the config file's value, or ""
+func Getenv(key string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return values[key]
+}
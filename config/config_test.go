@@ -0,0 +1,203 @@
+package config
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	content := `{
+		"ARCHIVE_SCREENSHOTS": false,
+		"ARCHIVE_BATCH_CONCURRENCY": 4,
+		"ARCHIVE_NODELAY_HOSTS": ["a.example", "localhost:8080"],
+		"ARCHIVE_DISMISS_SELECTORS": ["#banner", ".consent, .modal"],
+		"ARCHIVE_USER_AGENTS": ["Mozilla/5.0 (X11; Linux x86_64)", "curl/8.0"],
+		"ARCHIVE_SCREENSHOT_DPR": "1.5",
+		"ARCHIVE_API_KEY": null
+	}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := map[string]string{
+		"ARCHIVE_SCREENSHOTS":       "false",
+		"ARCHIVE_BATCH_CONCURRENCY": "4",
+		"ARCHIVE_NODELAY_HOSTS":     "a.example,localhost:8080",
+		"ARCHIVE_DISMISS_SELECTORS": "#banner;.consent, .modal",
+		"ARCHIVE_USER_AGENTS":       "Mozilla/5.0 (X11; Linux x86_64)|curl/8.0",
+		"ARCHIVE_SCREENSHOT_DPR":    "1.5",
+	}
+	if len(loaded) != len(want) {
+		t.Errorf("loaded %d settings, want %d: %v", len(loaded), len(want), loaded)
+	}
+	for key, value := range want {
+		if loaded[key] != value {
+			t.Errorf("%s = %q, want %q", key, loaded[key], value)
+		}
+	}
+
+	for _, invalid := range []string{
+		`[1, 2]`,
+		`{"ARCHIVE_SCREENSHOTS": {"enabled": true}}`,
+		`{"ARCHIVE_CONFIG": "other.json"}`,
+		`{"ARCHIVE_SCRENSHOTS": true}`,
+		`{"PORT": 8080}`,
+		`{"ARCHIVE_SCREENSHOTS": "yes please"}`,
+		`{"ARCHIVE_VIEW_MOUNT": "yes please"}`,
+		`{"ARCHIVE_EXPOSE_DATA_DIR": 1.5}`,
+		`{"ARCHIVE_ACTIVITY_SIZE": "many"}`,
+		`{"ARCHIVE_BATCH_CONCURRENCY": 2.5}`,
+		`{"ARCHIVE_BATCH_CONCURRENCY": "four"}`,
+		`{"ARCHIVE_SCREENSHOT_DPR": true}`,
+		`{"ARCHIVE_API_KEY": ["a", "b"]}`,
+	} {
+		if err := os.WriteFile(path, []byte(invalid), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("Load(%s) succeeded, want error", invalid)
+		}
+	}
+}
+
+// settingName matches a setting name passed to a function, e.g.
+// envBool("ARCHIVE_SCREENSHOTS", false) or config.Getenv("CHROME_BIN_PATH")
+var settingName = regexp.MustCompile(`\(\s*"((?:ARCHIVE|CHROME)[A-Z0-9_]*)"`)
+
+// TestKnownSettings checks that every setting read in the source tree is
+// known, so it can be set in the config file, and that every known setting
+// is still read somewhere
+func TestKnownSettings(t *testing.T) {
+	read := map[string]bool{}
+	err := filepath.WalkDir("..", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != ".." && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, m := range settingName.FindAllStringSubmatch(string(src), -1) {
+			read[m[1]] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(read, "ARCHIVE_CONFIG")
+
+	for key := range read {
+		if _, ok := known[key]; !ok {
+			t.Errorf("%s is read but not a known setting", key)
+		}
+	}
+	for key := range known {
+		if !read[key] {
+			t.Errorf("%s is a known setting but never read", key)
+		}
+	}
+}
+
+// settingParsers matches a setting read with a parser of a particular kind,
+// e.g. envBool("ARCHIVE_SCREENSHOTS", false) or
+// strconv.Atoi(config.Getenv("ARCHIVE_RATE_LIMIT"))
+var settingParsers = map[kind]*regexp.Regexp{
+	kindBool:  regexp.MustCompile(`(?:envBool|strconv\.ParseBool\(config\.Getenv)\(\s*"([A-Z0-9_]+)"`),
+	kindInt:   regexp.MustCompile(`(?:envInt64|strconv\.Atoi\(config\.Getenv)\(\s*"([A-Z0-9_]+)"`),
+	kindFloat: regexp.MustCompile(`envDPR\(\s*"([A-Z0-9_]+)"`),
+}
+
+// TestKnownSettingKinds checks that the kind of each known setting matches
+// the parser its read site uses, so the config file rejects values that
+// parser would silently treat as unset
+func TestKnownSettingKinds(t *testing.T) {
+	checked := 0
+	err := filepath.WalkDir("..", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != ".." && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for want, parser := range settingParsers {
+			for _, m := range parser.FindAllStringSubmatch(string(src), -1) {
+				checked++
+				if s, ok := known[m[1]]; ok && s.kind != want {
+					t.Errorf("%s is read as %s in %s but known as %s", m[1], want, path, s.kind)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked == 0 {
+		t.Error("no typed read sites found")
+	}
+}
+
+func TestGetenvPrefersEnvironment(t *testing.T) {
+	orig := values
+	defer func() { values = orig }()
+	values = map[string]string{"ARCHIVE_TEST_SETTING": "file", "ARCHIVE_TEST_OTHER": "file"}
+
+	t.Setenv("ARCHIVE_TEST_SETTING", "env")
+	t.Setenv("ARCHIVE_TEST_OTHER", "")
+	if got := Getenv("ARCHIVE_TEST_SETTING"); got != "env" {
+		t.Errorf("Getenv with variable set = %q, want env", got)
+	}
+	if got := Getenv("ARCHIVE_TEST_OTHER"); got != "file" {
+		t.Errorf("Getenv with empty variable = %q, want file", got)
+	}
+	if got := Getenv("ARCHIVE_TEST_UNSET"); got != "" {
+		t.Errorf("Getenv of unknown setting = %q, want empty", got)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("ARCHIVE_DB_PATH", "")
+	t.Setenv("ARCHIVE_DATA_DIR", "")
+	t.Setenv("ARCHIVE_REQUEST_DELAY_MS", "")
+	if cfg := FromEnv(); cfg != Default() {
+		t.Errorf("FromEnv without settings = %+v, want Default %+v", cfg, Default())
+	}
+
+	t.Setenv("ARCHIVE_DB_PATH", "/srv/archive/archive.db")
+	t.Setenv("ARCHIVE_DATA_DIR", "/srv/archive/data")
+	t.Setenv("ARCHIVE_REQUEST_DELAY_MS", "0")
+	cfg := FromEnv()
+	want := Config{
+		DatabasePath:   "/srv/archive/archive.db",
+		DataDir:        "/srv/archive/data",
+		RawHTMLDir:     "/srv/archive/data/raw",
+		AssetsDir:      "/srv/archive/data/assets",
+		ScreenshotsDir: "/srv/archive/data/screenshots",
+	}
+	if cfg != want {
+		t.Errorf("FromEnv = %+v, want %+v", cfg, want)
+	}
+}
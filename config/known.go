@@ -0,0 +1,150 @@
+package config
+
+import "strconv"
+
+// kind is the type of value a setting holds
+type kind int
+
+const (
+	kindString kind = iota
+	kindBool
+	kindInt
+	kindFloat
+	kindList // Separated by the setting's separator; arrays are joined with it
+)
+
+// setting describes a known setting
+type setting struct {
+	kind kind
+	sep  string // List separator, for kindList
+}
+
+var (
+	stringSetting = setting{kind: kindString}
+	boolSetting   = setting{kind: kindBool}
+	intSetting    = setting{kind: kindInt}
+	floatSetting  = setting{kind: kindFloat}
+	listSetting   = setting{kind: kindList, sep: ","}
+)
+
+// known lists every setting some package reads. The config file may only
+// set these, so a misspelled name fails at startup instead of being ignored.
+var known = map[string]setting{
+	"ARCHIVE_ACTIVITY_SIZE":                intSetting,
+	"ARCHIVE_ALLOW_NON_200":                boolSetting,
+	"ARCHIVE_ALLOWED_MAIN_TYPES":           listSetting,
+	"ARCHIVE_API_KEY":                      stringSetting,
+	"ARCHIVE_ASSET_ACCEPT_ENCODING":        listSetting,
+	"ARCHIVE_ASSET_CONTENT_TYPE_NAMES":     boolSetting,
+	"ARCHIVE_ASSET_DISPOSITION_NAMES":      boolSetting,
+	"ARCHIVE_ASSET_MAX_REDIRECTS":          intSetting,
+	"ARCHIVE_ASSET_PRIORITY":               listSetting,
+	"ARCHIVE_ASSET_REDIRECTS":              stringSetting,
+	"ARCHIVE_ATTACHMENT_POLICY":            stringSetting,
+	"ARCHIVE_AUDIT_LOG":                    boolSetting,
+	"ARCHIVE_BATCH_CONCURRENCY":            intSetting,
+	"ARCHIVE_BATCH_PER_HOST":               intSetting,
+	"ARCHIVE_BUNDLE_ASSETS":                boolSetting,
+	"ARCHIVE_CHALLENGE_WAIT_SEC":           intSetting,
+	"ARCHIVE_CHROME_WS_URL":                stringSetting,
+	"ARCHIVE_COOKIE_JAR_PATH":              stringSetting,
+	"ARCHIVE_CRAWL_BUDGET_SEC":             intSetting,
+	"ARCHIVE_CRAWL_BYTE_BUDGET":            intSetting,
+	"ARCHIVE_DATA_DIR":                     stringSetting,
+	"ARCHIVE_DB_PATH":                      stringSetting,
+	"ARCHIVE_DEBUG_CAPTURE":                boolSetting,
+	"ARCHIVE_DETECT_SOFT_404":              boolSetting,
+	"ARCHIVE_DIFF_IGNORE":                  listSetting,
+	"ARCHIVE_DISMISS_SELECTORS":            {kind: kindList, sep: ";"}, // Commas are part of selector syntax
+	"ARCHIVE_EXPOSE_DATA_DIR":              boolSetting,
+	"ARCHIVE_FEED_MAX_ITEMS":               intSetting,
+	"ARCHIVE_FETCH_STRATEGIES":             listSetting,
+	"ARCHIVE_HTML_OUTPUT":                  stringSetting,
+	"ARCHIVE_HTTP_CACHE_DIR":               stringSetting,
+	"ARCHIVE_HTTP_CACHE_MAX_BYTES":         intSetting,
+	"ARCHIVE_IDEMPOTENCY_TTL_SEC":          intSetting,
+	"ARCHIVE_IMPORT_BATCH_SIZE":            intSetting,
+	"ARCHIVE_IMPORT_WORKERS":               intSetting,
+	"ARCHIVE_JOB_TTL_SEC":                  intSetting,
+	"ARCHIVE_KEEP_EXTERNAL_HOSTS":          listSetting,
+	"ARCHIVE_LANGUAGE":                     stringSetting,
+	"ARCHIVE_MAX_LINKS_PER_PAGE":           intSetting,
+	"ARCHIVE_MAX_SNAPSHOTS_PER_URL":        intSetting,
+	"ARCHIVE_MHTML":                        boolSetting,
+	"ARCHIVE_MIME_OVERRIDES":               listSetting,
+	"ARCHIVE_MIN_BODY_BYTES":               intSetting,
+	"ARCHIVE_MIN_FREE_BYTES":               intSetting,
+	"ARCHIVE_MIN_HTML_BYTES":               intSetting,
+	"ARCHIVE_NODELAY_HOSTS":                listSetting,
+	"ARCHIVE_NODELAY_PRIVATE":              boolSetting,
+	"ARCHIVE_NORMALIZE_LINE_ENDINGS":       boolSetting,
+	"ARCHIVE_PARAM_RULES":                  {kind: kindList, sep: ";"}, // Rules hold comma-separated names
+	"ARCHIVE_PASS_JS_CHALLENGES":           boolSetting,
+	"ARCHIVE_PREFER_CANONICAL":             boolSetting,
+	"ARCHIVE_PROMOTE_NOSCRIPT":             boolSetting,
+	"ARCHIVE_PROVENANCE":                   boolSetting,
+	"ARCHIVE_RATE_LIMIT":                   intSetting,
+	"ARCHIVE_RATE_LIMIT_WINDOW_SEC":        intSetting,
+	"ARCHIVE_RECORD_ASSETS":                boolSetting,
+	"ARCHIVE_REFETCH_NEGOTIATION":          stringSetting,
+	"ARCHIVE_REJECT_SOFT_404":              boolSetting,
+	"ARCHIVE_RENDER_DOM":                   boolSetting,
+	"ARCHIVE_RENDER_SMALL_PAGES":           boolSetting,
+	"ARCHIVE_REQUEST_DELAY_MS":             intSetting,
+	"ARCHIVE_RETRY_HTML_ACCEPT":            boolSetting,
+	"ARCHIVE_SCREENSHOT_DPR":               floatSetting,
+	"ARCHIVE_SCREENSHOT_MAX_DIM":           intSetting,
+	"ARCHIVE_SCREENSHOT_MAX_HEIGHT":        intSetting,
+	"ARCHIVE_SCREENSHOT_NORMALIZE":         boolSetting,
+	"ARCHIVE_SCREENSHOT_REFRESH_CHECK_SEC": intSetting,
+	"ARCHIVE_SCREENSHOT_TIMEOUT_SEC":       intSetting,
+	"ARCHIVE_SCREENSHOT_WAIT_FONTS":        boolSetting,
+	"ARCHIVE_SCREENSHOTS":                  boolSetting,
+	"ARCHIVE_SET_COOKIE":                   stringSetting,
+	"ARCHIVE_SOFT_404_MIN_TEXT":            intSetting,
+	"ARCHIVE_SOFT_404_PATTERNS":            listSetting,
+	"ARCHIVE_SRI":                          stringSetting,
+	"ARCHIVE_STORE_ASSET_ERROR_BODIES":     boolSetting,
+	"ARCHIVE_STORE_FAVICON":                boolSetting,
+	"ARCHIVE_STORE_TEXT":                   boolSetting,
+	"ARCHIVE_THUMBNAIL_MAX_DIM":            intSetting,
+	"ARCHIVE_TRIM_TRAILING_NEWLINE":        boolSetting,
+	"ARCHIVE_USER_AGENTS":                  {kind: kindList, sep: "|"}, // User-Agents contain commas
+	"ARCHIVE_VIEW_MOUNT":                   boolSetting,
+	"CHROME_BIN_PATH":                      stringSetting,
+	"CHROMEDP_EXTRA_FLAGS":                 listSetting,
+}
+
+// String describes the values of a kind, for error messages
+func (k kind) String() string {
+	switch k {
+	case kindBool:
+		return "a boolean"
+	case kindInt:
+		return "an integer"
+	case kindFloat:
+		return "a number"
+	case kindList:
+		return "a string or an array"
+	}
+	return "a string"
+}
+
+// accepts reports whether the environment variable string v is a valid value
+// of the kind. Empty strings leave a setting at its default, as in the
+// environment.
+func (k kind) accepts(v string) bool {
+	if v == "" {
+		return true
+	}
+	var err error
+	switch k {
+	case kindBool:
+		_, err = strconv.ParseBool(v)
+	case kindInt:
+		_, err = strconv.ParseInt(v, 10, 64)
+	case kindFloat:
+		_, err = strconv.ParseFloat(v, 64)
+	}
+	return err == nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"log"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Config holds the settings that locate archive-lite's data and pace its
+// requests. It is read once at startup with FromEnv and passed to the
+// packages that use it (storage.Configure, database.Init).
+type Config struct {
+	DatabasePath   string        // SQLite database file (ARCHIVE_DB_PATH)
+	DataDir        string        // Root of the stored files, served under /data (ARCHIVE_DATA_DIR)
+	RawHTMLDir     string        // Stored HTML, text, MHTML and debug files: DataDir/raw
+	AssetsDir      string        // Downloaded assets: DataDir/assets
+	ScreenshotsDir string        // Screenshots: DataDir/screenshots
+	RequestDelay   time.Duration // Minimum gap between requests to the same host (ARCHIVE_REQUEST_DELAY_MS)
+}
+
+// Default returns the configuration used when no setting is given
+func Default() Config {
+	return withDataDir(Config{
+		DatabasePath: "archive.db",
+		RequestDelay: 500 * time.Millisecond,
+	}, "data")
+}
+
+// FromEnv returns Default overridden by the ARCHIVE_DB_PATH,
+// ARCHIVE_DATA_DIR and ARCHIVE_REQUEST_DELAY_MS settings (see Getenv)
+func FromEnv() Config {
+	cfg := Default()
+	if v := Getenv("ARCHIVE_DB_PATH"); v != "" {
+		cfg.DatabasePath = v
+	}
+	if v := Getenv("ARCHIVE_DATA_DIR"); v != "" {
+		cfg = withDataDir(cfg, v)
+	}
+	if v := Getenv("ARCHIVE_REQUEST_DELAY_MS"); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms < 0 {
+			log.Printf("Invalid ARCHIVE_REQUEST_DELAY_MS '%s', using %s", v, cfg.RequestDelay)
+		} else {
+			cfg.RequestDelay = time.Duration(ms) * time.Millisecond
+		}
+	}
+	return cfg
+}
+
+// withDataDir returns cfg with its data directories under dir
+func withDataDir(cfg Config, dir string) Config {
+	cfg.DataDir = dir
+	cfg.RawHTMLDir = filepath.Join(dir, "raw")
+	cfg.AssetsDir = filepath.Join(dir, "assets")
+	cfg.ScreenshotsDir = filepath.Join(dir, "screenshots")
+	return cfg
+}
@@ -1,8 +1,11 @@
 package database
 
 import (
+	"archive-lite/config"
 	"archive-lite/models"
 	"log"
+	"os"
+	"path/filepath"
 	"sync"
 
 	"gorm.io/driver/sqlite"
@@ -15,10 +18,25 @@ var (
 	err  error
 )
 
-// Init initializes the database connection and auto-migrates schemas.
-func Init() (*gorm.DB, error) {
+// SQLiteDSN returns the data source name for the SQLite database at path.
+// WAL mode lets reads proceed during writes, and the busy timeout makes
+// concurrent writers (e.g. parallel imports) wait for the lock instead of
+// failing with "database is locked".
+func SQLiteDSN(path string) string {
+	return path + "?_journal_mode=WAL&_busy_timeout=5000"
+}
+
+// Init initializes the database connection to cfg.DatabasePath and
+// auto-migrates schemas.
+func Init(cfg config.Config) (*gorm.DB, error) {
 	once.Do(func() {
-		DB, err = gorm.Open(sqlite.Open("archive.db"), &gorm.Config{})
+		if dir := filepath.Dir(cfg.DatabasePath); dir != "." {
+			if err = os.MkdirAll(dir, 0755); err != nil {
+				log.Printf("Failed to create database directory: %v", err)
+				return
+			}
+		}
+		DB, err = gorm.Open(sqlite.Open(SQLiteDSN(cfg.DatabasePath)), &gorm.Config{})
 		if err != nil {
 			log.Printf("Failed to connect to database: %v", err)
 			return
@@ -27,7 +45,7 @@ func Init() (*gorm.DB, error) {
 		log.Println("Database connection established.")
 
 		// Auto-migrate the schema
-		err = DB.AutoMigrate(&models.ArchiveEntry{})
+		err = DB.AutoMigrate(&models.ArchiveEntry{}, &models.Asset{}, &models.AuditRecord{})
 		if err != nil {
 			log.Printf("Failed to auto-migrate database schema: %v", err)
 			return
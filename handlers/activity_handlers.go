@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"archive-lite/activity"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const defaultActivityLimit = 50
+
+// GetActivity handles the request to list recent archive operations, newest
+// first. The limit query parameter caps the number of events (default 50).
+func GetActivity(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", defaultActivityLimit)
+	if limit < 1 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "limit must be at least 1",
+		})
+	}
+	return c.JSON(activity.Recent(limit))
+}
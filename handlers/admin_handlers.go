@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"archive-lite/jobs"
+	"archive-lite/storage"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// cacheReport is the number of items evicted from an in-memory cache and
+// the number that remain
+type cacheReport struct {
+	Evicted   int `json:"evicted"`
+	Remaining int `json:"remaining"`
+}
+
+// gcReport is the response of RunGC
+type gcReport struct {
+	// Cookies is the number of cookies in the jar before the call; null when
+	// the jar is kept in memory only and can't be counted
+	Cookies         *int        `json:"cookies"`
+	CookiesCleared  bool        `json:"cookiesCleared"`
+	UserAgentHosts  int         `json:"userAgentHosts"` // Hosts whose User-Agent rotation was reset
+	RateLimitHosts  cacheReport `json:"rateLimitHosts"` // Per-host request delay state of idle hosts
+	Jobs            cacheReport `json:"jobs"`
+	IdempotencyKeys cacheReport `json:"idempotencyKeys"`
+	HeapBefore      uint64      `json:"heapBytesBefore"`
+	HeapAfter       uint64      `json:"heapBytesAfter"`
+}
+
+// defaultGCJobAge is how long a batch must have been finished before RunGC
+// drops it when neither ?jobsOlderThanSec nor ARCHIVE_JOB_TTL_SEC sets an age
+const defaultGCJobAge = time.Hour
+
+// RunGC handles the request to compact the server's in-memory caches and
+// return freed memory to the OS. Finished batches older than ?jobsOlderThanSec
+// (default ARCHIVE_JOB_TTL_SEC, or defaultGCJobAge when batches are kept until
+// restart) are dropped, so clients still polling a batch that just finished
+// don't lose it; ?cookies=true also clears the shared cookie jar.
+func RunGC(c *fiber.Ctx) error {
+	olderThan := jobs.FinishedTTL()
+	if olderThan <= 0 {
+		olderThan = defaultGCJobAge
+	}
+	if c.Query("jobsOlderThanSec") != "" {
+		sec, err := strconv.Atoi(c.Query("jobsOlderThanSec"))
+		if err != nil || sec < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "jobsOlderThanSec must be a non-negative number of seconds",
+			})
+		}
+		olderThan = time.Duration(sec) * time.Second
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	report := gcReport{HeapBefore: mem.HeapAlloc}
+
+	if n, ok := storage.CookieJarSize(); ok {
+		report.Cookies = &n
+	}
+	if c.QueryBool("cookies") {
+		if err := storage.ClearCookieJar(); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to clear cookie jar: %s", err.Error()),
+			})
+		}
+		report.CookiesCleared = true
+	}
+	report.UserAgentHosts = storage.ResetUserAgentRotation()
+	report.RateLimitHosts.Evicted, report.RateLimitHosts.Remaining = storage.EvictIdleHosts()
+	report.Jobs.Evicted, report.Jobs.Remaining = jobs.EvictFinished(olderThan)
+	report.IdempotencyKeys.Evicted, report.IdempotencyKeys.Remaining = createIdempotency.compact()
+
+	debug.FreeOSMemory()
+	runtime.ReadMemStats(&mem)
+	report.HeapAfter = mem.HeapAlloc
+	return c.JSON(report)
+}
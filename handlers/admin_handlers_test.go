@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"archive-lite/jobs"
+	"archive-lite/models"
+	"archive-lite/tests"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRunGCKeepsRecentlyFinishedBatches(t *testing.T) {
+	batch := jobs.StartBatch([]string{"https://example.com/gc"}, 1, func(string) (*models.ArchiveEntry, bool, error) {
+		return &models.ArchiveEntry{ID: "gc-entry"}, false, nil
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for !batch.Status().Done {
+		if time.Now().After(deadline) {
+			t.Fatal("batch did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	app := tests.CreateTestApp()
+	app.Post("/api/admin/gc", RunGC)
+	gc := func(query string) int {
+		resp, err := app.Test(httptest.NewRequest("POST", "/api/admin/gc"+query, nil))
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	// Without jobsOlderThanSec, batches are kept for ARCHIVE_JOB_TTL_SEC
+	if status := gc(""); status != fiber.StatusOK {
+		t.Fatalf("gc: status %d", status)
+	}
+	if _, ok := jobs.Get(batch.ID); !ok {
+		t.Error("gc without jobsOlderThanSec dropped a batch that just finished")
+	}
+
+	if status := gc("?jobsOlderThanSec=-1"); status != fiber.StatusBadRequest {
+		t.Errorf("negative jobsOlderThanSec: status %d, want 400", status)
+	}
+
+	if status := gc("?jobsOlderThanSec=0"); status != fiber.StatusOK {
+		t.Fatalf("gc: status %d", status)
+	}
+	if _, ok := jobs.Get(batch.ID); ok {
+		t.Error("gc with jobsOlderThanSec=0 kept a finished batch")
+	}
+}
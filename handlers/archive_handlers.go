@@ -2,17 +2,109 @@ package handlers
 
 import (
 	"archive-lite/database"
+	"archive-lite/jobs"
 	"archive-lite/models"
 	"archive-lite/storage"
+	"bufio"
+	"encoding/csv"
+	"errors"
 	"fmt"
+	"log"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"gorm.io/gorm"
 )
 
 // CreateArchivePayload is the expected payload for the CreateArchive handler
 type CreateArchivePayload struct {
 	URL string `json:"url"`
+	// Canonicalize defaults to true; set false to keep query-string variants distinct
+	Canonicalize *bool `json:"canonicalize"`
+	// FollowFeed enqueues the items of the page's RSS/Atom feed as separate archives
+	FollowFeed bool `json:"followFeed"`
+	// FeedLimit caps the number of feed items followed (bounded by ARCHIVE_FEED_MAX_ITEMS)
+	FeedLimit int `json:"feedLimit"`
+	// PathPrefix restricts followed links to the page's origin under this path (e.g. "/docs/")
+	PathPrefix string `json:"pathPrefix"`
+	// ArchiveNon200 archives error pages instead of failing; defaults to ARCHIVE_ALLOW_NON_200
+	ArchiveNon200 *bool `json:"archiveNon200"`
+	// Render archives the DOM rendered by headless Chrome; defaults to ARCHIVE_RENDER_DOM
+	Render *bool `json:"render"`
+	// DismissSelectors adds overlays to hide (or click, with a "click:" prefix) before capturing
+	DismissSelectors []string `json:"dismissSelectors"`
+	// OnlyIfChanged returns the latest snapshot instead of archiving when the page's text is unchanged
+	OnlyIfChanged bool `json:"onlyIfChanged"`
+	// ScreenshotTTLSec regenerates the screenshot this many seconds after it was taken (0 = never)
+	ScreenshotTTLSec int64 `json:"screenshotTtlSec"`
+	// MHTML also stores the page as an MHTML file rendered by headless Chrome; defaults to ARCHIVE_MHTML
+	MHTML *bool `json:"mhtml"`
+	// StoreAssetErrors keeps the bodies of assets answered with a non-200 status; defaults to ARCHIVE_STORE_ASSET_ERROR_BODIES
+	StoreAssetErrors *bool `json:"storeAssetErrors"`
+	// Accept replaces the Accept header of the page request (e.g. "text/html")
+	Accept string `json:"accept"`
+	// Referer is sent with the page request, for pages that depend on where visitors come from
+	Referer string `json:"referer"`
+	// AllowedTypes replaces ARCHIVE_ALLOWED_MAIN_TYPES for this request (e.g. ["*/*"] to archive any type)
+	AllowedTypes []string `json:"allowedTypes"`
+	// Login performs a form login in headless Chrome before capturing; credentials are not logged or stored
+	Login *storage.LoginConfig `json:"login"`
+	// Device selects a device profile (desktop, mobile or tablet; default desktop)
+	Device string `json:"device"`
+	// Width, Height, UserAgent and DPR override individual settings of the device profile
+	Width     int64   `json:"width"`
+	Height    int64   `json:"height"`
+	UserAgent string  `json:"userAgent"`
+	DPR       float64 `json:"dpr"`
+}
+
+// archiveOptions converts the payload's optional settings into storage options
+func (p *CreateArchivePayload) archiveOptions() (storage.ArchiveOptions, error) {
+	opts := storage.DefaultArchiveOptions()
+	if p.Canonicalize != nil {
+		opts.Canonicalize = *p.Canonicalize
+	}
+	if p.ArchiveNon200 != nil {
+		opts.ArchiveNon200 = *p.ArchiveNon200
+	}
+	if p.Render != nil {
+		opts.RenderDOM = *p.Render
+	}
+	if p.MHTML != nil {
+		opts.MHTML = *p.MHTML
+	}
+	if p.StoreAssetErrors != nil {
+		opts.StoreAssetErrorBodies = *p.StoreAssetErrors
+	}
+	if len(p.AllowedTypes) > 0 {
+		opts.AllowedMainTypes = p.AllowedTypes
+	}
+	if len(p.DismissSelectors) > 0 {
+		opts.DismissSelectors = append(append([]string(nil), opts.DismissSelectors...), p.DismissSelectors...)
+	}
+	device, err := storage.ResolveDeviceProfile(p.Device, p.Width, p.Height, p.UserAgent, p.DPR)
+	if err != nil {
+		return opts, err
+	}
+	opts.Device = device
+	opts.Accept = p.Accept
+	opts.ScreenshotTTL = p.ScreenshotTTLSec
+	opts.Referer = p.Referer
+	opts.Login = p.Login
+	opts.OnlyIfChanged = p.OnlyIfChanged
+	return opts, nil
+}
+
+// isAbsoluteHTTPURL reports whether s is an http or https URL with a host
+func isAbsoluteHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
 }
 
 // CreateArchive handles the request to archive a new URL
@@ -30,20 +122,199 @@ func CreateArchive(c *fiber.Ctx) error {
 		})
 	}
 
-	entry, err := storage.ArchiveURL(database.DB, payload.URL)
+	if payload.Referer != "" && !isAbsoluteHTTPURL(payload.Referer) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Invalid referer '%s': expected an absolute http or https URL", payload.Referer),
+		})
+	}
+	if payload.ScreenshotTTLSec < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "screenshotTtlSec must not be negative",
+		})
+	}
+	if strings.ContainsAny(payload.Accept, "\r\n") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid accept: header values cannot contain line breaks",
+		})
+	}
+	opts, err := payload.archiveOptions()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Invalid device settings: %s", err.Error()),
+		})
+	}
+
+	if err := storage.ValidatePathPrefix(payload.PathPrefix); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Invalid pathPrefix: %s", err.Error()),
+		})
+	}
+
+	if payload.Login != nil {
+		if err := payload.Login.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Invalid login: %s", err.Error()),
+			})
+		}
+	}
+
+	idempotencyKey := c.Get("Idempotency-Key")
+	completed := false
+	if idempotencyKey != "" {
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLength),
+			})
+		}
+		if record, claimed := createIdempotency.begin(idempotencyKey, payload.URL); !claimed {
+			if replayed, err := replayCreate(c, idempotencyKey, record, payload.URL); replayed {
+				return err
+			}
+		}
+		// The key is claimed by this request: release it unless the request
+		// completes, so failures and panics don't consume it
+		defer func() {
+			if !completed {
+				createIdempotency.forget(idempotencyKey)
+			}
+		}()
+	}
+
+	entry, err := storage.ArchiveURLWithOptions(database.DB, payload.URL, opts)
+	status := fiber.StatusCreated
+	var unchanged *storage.UnchangedError
+	if errors.As(err, &unchanged) {
+		entry, err, status = unchanged.Latest, nil, fiber.StatusOK
+		c.Set("X-Archive-Unchanged", "true")
+	}
 	if err != nil {
+		var storageErr *storage.InsufficientStorageError
+		if errors.As(err, &storageErr) {
+			return c.Status(fiber.StatusInsufficientStorage).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to archive URL: %s", err.Error()),
+			})
+		}
+		var locationErr *storage.MissingLocationError
+		if errors.As(err, &locationErr) || errors.Is(err, storage.ErrLoginFailed) {
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to archive URL: %s", err.Error()),
+			})
+		}
+		if errors.Is(err, storage.ErrAttachmentRejected) || errors.Is(err, storage.ErrSoft404) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to archive URL: %s", err.Error()),
+			})
+		}
+		var typeErr *storage.UnsupportedMainTypeError
+		if errors.As(err, &typeErr) {
+			return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to archive URL: %s", err.Error()),
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": fmt.Sprintf("Failed to archive URL: %s", err.Error()),
 		})
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(entry)
+	if payload.FollowFeed {
+		if batch := followFeed(entry, opts, payload.PathPrefix, payload.FeedLimit); batch != nil {
+			c.Set("X-Feed-Batch-Id", batch.ID)
+		}
+	}
+
+	if idempotencyKey != "" {
+		headers := make(map[string]string)
+		for _, name := range replayedHeaders {
+			if value := c.GetRespHeader(name); value != "" {
+				headers[name] = value
+			}
+		}
+		createIdempotency.complete(idempotencyKey, entry.ID, status, headers)
+		completed = true
+	}
+	return c.Status(status).JSON(entry)
+}
+
+// replayCreate answers a create request whose Idempotency-Key was already
+// used, returning the original entry with the original status and headers
+// instead of archiving again. It returns false when that entry has since been
+// deleted, after re-claiming the key so the request is archived afresh.
+func replayCreate(c *fiber.Ctx, key string, record idempotencyRecord, url string) (bool, error) {
+	if record.URL != url {
+		return true, c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": "Idempotency-Key was already used for a different URL",
+		})
+	}
+	if record.InProgress {
+		return true, c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "A request with this Idempotency-Key is still in progress",
+		})
+	}
+
+	var entry models.ArchiveEntry
+	if err := database.DB.Where("id = ?", record.EntryID).First(&entry).Error; err != nil {
+		createIdempotency.forget(key)
+		if _, claimed := createIdempotency.begin(key, url); claimed {
+			return false, nil
+		}
+		return true, c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "A request with this Idempotency-Key is still in progress",
+		})
+	}
+
+	c.Set("Idempotent-Replayed", "true")
+	for name, value := range record.Headers {
+		c.Set(name, value)
+	}
+	return true, c.Status(record.Status).JSON(entry)
+}
+
+// followFeed enqueues up to limit feed items linked from entry (restricted to
+// pathPrefix when set) as a batch archived with opts, whose entries share
+// entry's ID as their SeriesID. Feed problems are logged rather than failing
+// the archive that was already created.
+func followFeed(entry *models.ArchiveEntry, opts storage.ArchiveOptions, pathPrefix string, limit int) *jobs.Batch {
+	itemURLs, err := storage.FeedItemURLs(entry.StoragePath, entry.URL, pathPrefix, limit)
+	if err != nil {
+		log.Printf("Failed to follow feed for archive %s: %v", entry.ID, err)
+		return nil
+	}
+	if len(itemURLs) == 0 {
+		log.Printf("No feed items found for archive %s (%s)", entry.ID, entry.URL)
+		return nil
+	}
+
+	entry.SeriesID = entry.ID
+	if err := database.DB.Model(entry).Update("series_id", entry.SeriesID).Error; err != nil {
+		log.Printf("Failed to set series ID for archive %s: %v", entry.ID, err)
+	}
+
+	opts.SeriesID = entry.ID
+	log.Printf("Following feed for archive %s: %d items", entry.ID, len(itemURLs))
+	return jobs.StartBatch(itemURLs, batchConcurrency(), func(u string) (*models.ArchiveEntry, bool, error) {
+		entry, err := storage.ArchiveURLWithOptions(database.DB, u, opts)
+		var unchanged *storage.UnchangedError
+		if errors.As(err, &unchanged) {
+			return unchanged.Latest, false, nil
+		}
+		return entry, err == nil, err
+	})
 }
 
 // ListArchives handles the request to list all archived entries
+// (?format=csv for the same list as ListArchivesCSV). ?lang= and ?dir=
+// restrict the list as described in listQuery.
 func ListArchives(c *fiber.Ctx) error {
+	if c.Query("format") == "csv" {
+		return ListArchivesCSV(c)
+	}
+
+	query, err := listQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 	var entries []models.ArchiveEntry
-	result := database.DB.Order("archived_at desc").Find(&entries)
+	result := query.Order("archived_at desc").Find(&entries)
 	if result.Error != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": fmt.Sprintf("Failed to list archives: %s", result.Error.Error()),
@@ -52,6 +323,109 @@ func ListArchives(c *fiber.Ctx) error {
 	return c.JSON(entries)
 }
 
+// ListArchivesCSV handles the request to export the archive list as CSV
+func ListArchivesCSV(c *fiber.Ctx) error {
+	query, err := listQuery(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	var entries []models.ArchiveEntry
+	result := query.Order("archived_at desc").Find(&entries)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to list archives: %s", result.Error.Error()),
+		})
+	}
+	return sendArchivesCSV(c, entries)
+}
+
+// listQuery applies the archive list filters: ?lang= matches entries whose
+// Lang is the given tag or one of its subtags (lang=en matches "en" and
+// "en-US"), ?dir= (ltr or rtl) matches Dir
+func listQuery(c *fiber.Ctx) (*gorm.DB, error) {
+	query := database.DB
+	if lang := strings.TrimSpace(c.Query("lang")); lang != "" {
+		query = query.Where("LOWER(lang) = ? OR LOWER(lang) LIKE ?", strings.ToLower(lang), strings.ToLower(lang)+"-%")
+	}
+	switch dir := strings.ToLower(c.Query("dir")); dir {
+	case "":
+	case "ltr", "rtl":
+		query = query.Where("dir = ?", dir)
+	default:
+		return nil, fmt.Errorf("Invalid dir '%s', expected 'ltr' or 'rtl'", dir)
+	}
+	return query, nil
+}
+
+// archivesCSVHeader is the header row of the CSV export
+var archivesCSVHeader = []string{"url", "title", "host", "archived_at", "status", "size"}
+
+// sendArchivesCSV streams entries as CSV, one row per entry. size is the
+// size in bytes of the stored HTML, empty when the file is missing.
+func sendArchivesCSV(c *fiber.Ctx, entries []models.ArchiveEntry) error {
+	c.Attachment("archives.csv")
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		cw := csv.NewWriter(w)
+		if err := cw.Write(archivesCSVHeader); err != nil {
+			return
+		}
+		for _, entry := range entries {
+			host := ""
+			if u, err := url.Parse(entry.URL); err == nil {
+				host = u.Hostname()
+			}
+			size := ""
+			if info, err := os.Stat(entry.StoragePath); err == nil {
+				size = strconv.FormatInt(info.Size(), 10)
+			}
+			if err := cw.Write([]string{
+				entry.URL,
+				entry.Title,
+				host,
+				entry.ArchivedAt.UTC().Format(time.RFC3339),
+				strconv.Itoa(entry.HTTPStatus),
+				size,
+			}); err != nil {
+				return
+			}
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			log.Printf("Failed to write archive list CSV: %v", err)
+		}
+	}))
+	return nil
+}
+
+// ImportArchives handles the request to upsert entry metadata exported with
+// GET /api/archive (?workers= and ?batchSize= lower the configured defaults)
+func ImportArchives(c *fiber.Ctx) error {
+	var entries []models.ArchiveEntry
+	if err := c.BodyParser(&entries); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON payload, expected an array of archive entries",
+		})
+	}
+
+	opts := storage.ImportOptions{
+		Workers:   c.QueryInt("workers"),
+		BatchSize: c.QueryInt("batchSize"),
+		Progress: func(done, total int) {
+			log.Printf("Import progress: %d/%d entries", done, total)
+		},
+	}
+	if err := opts.Validate(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Invalid import options: %s", err.Error()),
+		})
+	}
+	result := storage.ImportEntries(database.DB, entries, opts)
+	log.Printf("Imported %d of %d entries in %dms (%d failed)", result.Imported, result.Total, result.DurationMs, result.Failed)
+	return c.JSON(result)
+}
+
 // GetArchiveDetails handles the request to get details for a specific archive entry
 func GetArchiveDetails(c *fiber.Ctx) error {
 	id := c.Params("id")
@@ -71,6 +445,61 @@ func GetArchiveDetails(c *fiber.Ctx) error {
 	return c.JSON(entry)
 }
 
+// maxBatchIDs caps the number of IDs accepted by GetArchiveBatch
+const maxBatchIDs = 100
+
+// GetArchiveBatch handles the request to get details for several archive
+// entries at once (?ids=a,b,c). Entries are returned in request order; IDs
+// that don't exist are listed under "missing".
+func GetArchiveBatch(c *fiber.Ctx) error {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range strings.Split(c.Query("ids"), ",") {
+		id = strings.TrimSpace(id)
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ids cannot be empty",
+		})
+	}
+	if len(ids) > maxBatchIDs {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Too many IDs: %d (maximum %d per request)", len(ids), maxBatchIDs),
+		})
+	}
+
+	var found []models.ArchiveEntry
+	result := database.DB.Where("id IN ?", ids).Find(&found)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to fetch archives: %s", result.Error.Error()),
+		})
+	}
+
+	byID := make(map[string]models.ArchiveEntry, len(found))
+	for _, entry := range found {
+		byID[entry.ID] = entry
+	}
+	entries := make([]models.ArchiveEntry, 0, len(ids))
+	missing := make([]string, 0)
+	for _, id := range ids {
+		if entry, ok := byID[id]; ok {
+			entries = append(entries, entry)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"entries": entries,
+		"missing": missing,
+	})
+}
+
 // GetArchiveContent handles the request to retrieve the stored HTML content for an archive
 func GetArchiveContent(c *fiber.Ctx) error {
 	id := c.Params("id")
@@ -101,13 +530,58 @@ func GetArchiveContent(c *fiber.Ctx) error {
 		})
 	}
 
-	// Correctly send the file as text/html
-	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
-	return c.SendFile(entry.StoragePath)
+	contentType := storage.ServedContentType(&entry)
+
+	if sandboxPage(c) {
+		content, err := os.ReadFile(entry.StoragePath)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to read archived content for ID %s: %s", id, err.Error()),
+			})
+		}
+		stripped, err := storage.StripScripts(string(content))
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to strip scripts for ID %s: %s", id, err.Error()),
+			})
+		}
+		c.Set(fiber.HeaderContentType, contentType)
+		return c.SendString(stripped)
+	}
+
+	if err := c.SendFile(entry.StoragePath); err != nil {
+		return err
+	}
+	if entry.AttachmentName != "" {
+		// Served back as the download it was archived from
+		c.Attachment(entry.AttachmentName)
+	}
+	// SendFile and Attachment set a type from the file extension
+	c.Set(fiber.HeaderContentType, contentType)
+	return nil
+}
+
+// sandboxCSP is the Content-Security-Policy applied to archived content when
+// requested with ?sandbox=true
+const sandboxCSP = "default-src 'self' data:; script-src 'self'; style-src 'self' 'unsafe-inline' data:; " +
+	"img-src 'self' data:; font-src 'self' data:; connect-src 'none'; form-action 'none'; " +
+	"frame-ancestors 'self'; sandbox allow-scripts"
+
+// sandboxPage applies the ?sandbox=true and ?stripScripts=true options of the
+// endpoints serving archived pages. With sandbox it sets sandboxCSP, and it
+// reports whether stripScripts was also requested, in which case the caller
+// serves the page through storage.StripScripts.
+func sandboxPage(c *fiber.Ctx) bool {
+	if !c.QueryBool("sandbox") {
+		return false
+	}
+	// Restrict the archived page to its locally stored resources so its
+	// scripts cannot phone home or break out of an embedding iframe
+	c.Set(fiber.HeaderContentSecurityPolicy, sandboxCSP)
+	return c.QueryBool("stripScripts")
 }
 
 // GetArchiveScreenshot handles the request to retrieve a screenshot for an archive
-// This is a placeholder for now, as screenshot functionality is not yet implemented.
 func GetArchiveScreenshot(c *fiber.Ctx) error {
 	id := c.Params("id")
 	if id == "" {
@@ -138,19 +612,535 @@ func GetArchiveScreenshot(c *fiber.Ctx) error {
 		})
 	}
 
-	// Assuming PNG for now, adjust if other formats are used
-	c.Set(fiber.HeaderContentType, "image/png")
+	// Screenshots are captured as JPEG; older entries may be PNG
+	if strings.EqualFold(filepath.Ext(entry.ScreenshotPath), ".png") {
+		c.Set(fiber.HeaderContentType, "image/png")
+	} else {
+		c.Set(fiber.HeaderContentType, "image/jpeg")
+	}
 	return c.SendFile(entry.ScreenshotPath)
 }
 
+// defaultFavicon is served for entries without a stored favicon
+const defaultFavicon = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 16 16"><rect x="2" y="1" width="12" height="14" rx="1" fill="#e5e7eb" stroke="#6b7280"/><path d="M5 5h6M5 8h6M5 11h4" stroke="#6b7280"/></svg>`
+
+// faviconMaxAge is the Cache-Control max-age of favicon responses, in seconds
+const faviconMaxAge = 86400
+
+// GetArchiveFavicon handles the request to get the favicon stored with an
+// archive, falling back to a generic icon when none was stored
+func GetArchiveFavicon(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Archive ID cannot be empty",
+		})
+	}
+
+	var entry models.ArchiveEntry
+	result := database.DB.Where("id = ?", id).First(&entry)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Archive entry with ID %s not found: %s", id, result.Error.Error()),
+		})
+	}
+
+	c.Set(fiber.HeaderCacheControl, fmt.Sprintf("public, max-age=%d", faviconMaxAge))
+	content, contentType, err := storage.Favicon(database.DB, &entry)
+	if err != nil {
+		c.Set(fiber.HeaderContentType, "image/svg+xml")
+		return c.SendString(defaultFavicon)
+	}
+
+	c.Set(fiber.HeaderContentType, contentType)
+	return c.Send(content)
+}
+
+// GetArchiveText handles the request to get the plain-text rendition of an archive
+func GetArchiveText(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Archive ID cannot be empty",
+		})
+	}
+
+	var entry models.ArchiveEntry
+	result := database.DB.Where("id = ?", id).First(&entry)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Archive entry with ID %s not found: %s", id, result.Error.Error()),
+		})
+	}
+
+	if entry.TextPath == "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": fmt.Sprintf("Text not available for archive ID %s. It was archived before text was stored or with ARCHIVE_STORE_TEXT disabled.", id),
+		})
+	}
+
+	if _, err := os.Stat(entry.TextPath); os.IsNotExist(err) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Text file not found at %s for ID %s", entry.TextPath, id),
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+	return c.SendFile(entry.TextPath)
+}
+
+// GetArchiveStorage handles the request to locate an archive's files on disk
+func GetArchiveStorage(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Archive ID cannot be empty",
+		})
+	}
+
+	var entry models.ArchiveEntry
+	result := database.DB.Where("id = ?", id).First(&entry)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Archive entry with ID %s not found: %s", id, result.Error.Error()),
+		})
+	}
+
+	return c.JSON(storage.GetStorageInfo(&entry))
+}
+
+// VerifyArchive handles the request to check the integrity of an archive's stored files
+func VerifyArchive(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Archive ID cannot be empty",
+		})
+	}
+
+	var entry models.ArchiveEntry
+	result := database.DB.Where("id = ?", id).First(&entry)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Archive entry with ID %s not found: %s", id, result.Error.Error()),
+		})
+	}
+
+	return c.JSON(storage.VerifyArchive(&entry))
+}
+
+// UpdateArchivePayload is the expected payload for the UpdateArchive handler
+type UpdateArchivePayload struct {
+	URL string `json:"url"`
+	// Refetch archives the corrected URL again in place of the stored content
+	Refetch bool `json:"refetch"`
+	// OnlyIfChanged keeps the stored content when a refetch finds the page's text unchanged
+	OnlyIfChanged bool `json:"onlyIfChanged"`
+}
+
+// UpdateArchive handles the request to correct an entry's stored URL,
+// optionally refetching the page from the corrected URL
+func UpdateArchive(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Archive ID cannot be empty",
+		})
+	}
+
+	payload := new(UpdateArchivePayload)
+	if err := c.BodyParser(payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON payload",
+		})
+	}
+	if err := storage.ValidateArchiveURL(payload.URL); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Invalid URL: %s", err.Error()),
+		})
+	}
+
+	var entry models.ArchiveEntry
+	result := database.DB.Where("id = ?", id).First(&entry)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Archive entry with ID %s not found: %s", id, result.Error.Error()),
+		})
+	}
+
+	if !payload.Refetch {
+		if err := storage.UpdateEntryURL(database.DB, &entry, payload.URL); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to update archive: %s", err.Error()),
+			})
+		}
+		return c.JSON(entry)
+	}
+
+	if err := storage.RefetchEntry(database.DB, &entry, payload.URL, payload.OnlyIfChanged); err != nil {
+		var unchanged *storage.UnchangedError
+		if errors.As(err, &unchanged) {
+			c.Set("X-Archive-Unchanged", "true")
+			return c.JSON(entry)
+		}
+		var storageErr *storage.InsufficientStorageError
+		if errors.As(err, &storageErr) {
+			return c.Status(fiber.StatusInsufficientStorage).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to refetch archive: %s", err.Error()),
+			})
+		}
+		var locationErr *storage.MissingLocationError
+		if errors.As(err, &locationErr) {
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to refetch archive: %s", err.Error()),
+			})
+		}
+		if errors.Is(err, storage.ErrSoft404) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to refetch archive: %s", err.Error()),
+			})
+		}
+		var typeErr *storage.UnsupportedMainTypeError
+		if errors.As(err, &typeErr) {
+			return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to refetch archive: %s", err.Error()),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to refetch archive: %s", err.Error()),
+		})
+	}
+	return c.JSON(entry)
+}
+
+// ReresolveArchive handles the request to resolve an archive's original
+// request URL again without refetching (?update=true to store a changed result)
+func ReresolveArchive(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Archive ID cannot be empty",
+		})
+	}
+
+	var entry models.ArchiveEntry
+	result := database.DB.Where("id = ?", id).First(&entry)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Archive entry with ID %s not found: %s", id, result.Error.Error()),
+		})
+	}
+
+	resolved, err := storage.ReresolveEntry(database.DB, &entry, c.QueryBool("update"))
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidArchiveURL) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to reresolve archive: %s", err.Error()),
+			})
+		}
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to reresolve archive: %s", err.Error()),
+		})
+	}
+	return c.JSON(resolved)
+}
+
+// GetArchiveMHTML handles the request to get the MHTML capture of an archive
+func GetArchiveMHTML(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Archive ID cannot be empty",
+		})
+	}
+
+	var entry models.ArchiveEntry
+	result := database.DB.Where("id = ?", id).First(&entry)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Archive entry with ID %s not found: %s", id, result.Error.Error()),
+		})
+	}
+
+	if entry.MHTMLPath == "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": fmt.Sprintf("MHTML not available for archive ID %s. It was archived without mhtml or headless Chrome failed to capture it.", id),
+		})
+	}
+
+	if _, err := os.Stat(entry.MHTMLPath); os.IsNotExist(err) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("MHTML file not found at %s for ID %s", entry.MHTMLPath, id),
+		})
+	}
+
+	if err := c.SendFile(entry.MHTMLPath); err != nil {
+		return err
+	}
+	c.Attachment(entry.ID + ".mhtml")
+	c.Set(fiber.HeaderContentType, "multipart/related")
+	return nil
+}
+
+// GetArchiveDebug handles the request to get the debug capture of an archive
+// (ARCHIVE_DEBUG_CAPTURE): the headers sent and received, the redirect chain
+// and the outcome of every asset fetch
+func GetArchiveDebug(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Archive ID cannot be empty",
+		})
+	}
+
+	var entry models.ArchiveEntry
+	result := database.DB.Where("id = ?", id).First(&entry)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Archive entry with ID %s not found: %s", id, result.Error.Error()),
+		})
+	}
+
+	path := storage.DebugPath(entry.ID)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": fmt.Sprintf("Debug capture not available for archive ID %s. It was archived with ARCHIVE_DEBUG_CAPTURE disabled.", id),
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.SendFile(path)
+}
+
+// GetArchiveAssets handles the request to list the recorded asset fetches of an entry
+func GetArchiveAssets(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Archive ID cannot be empty",
+		})
+	}
+
+	var entry models.ArchiveEntry
+	result := database.DB.Where("id = ?", id).First(&entry)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Archive entry with ID %s not found: %s", id, result.Error.Error()),
+		})
+	}
+
+	var assets []models.Asset
+	result = database.DB.Where("entry_id = ?", id).Order("id asc").Find(&assets)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to list assets: %s", result.Error.Error()),
+		})
+	}
+
+	return c.JSON(assets)
+}
+
+// crawlPage is one page of a crawl as returned by GetArchiveTree
+type crawlPage struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	Title      string    `json:"title"`
+	ParentID   string    `json:"parentId"` // Entry the page was discovered from; empty for the crawl's root
+	HTTPStatus int       `json:"httpStatus"`
+	ArchivedAt time.Time `json:"archivedAt"`
+}
+
+// GetArchiveTree handles the request to list the pages archived in the same
+// crawl (series) as an entry, as a flat list with parent references. The
+// series' source entry comes first; pages followed from it reference it as
+// their parent.
+func GetArchiveTree(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Archive ID cannot be empty",
+		})
+	}
+
+	var entry models.ArchiveEntry
+	result := database.DB.Where("id = ?", id).First(&entry)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Archive entry with ID %s not found: %s", id, result.Error.Error()),
+		})
+	}
+
+	// Entries outside any series are a crawl of one page
+	seriesID := entry.SeriesID
+	if seriesID == "" {
+		seriesID = entry.ID
+	}
+
+	var entries []models.ArchiveEntry
+	result = database.DB.Where("id = ? OR series_id = ?", seriesID, seriesID).Order("archived_at asc").Find(&entries)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to list crawl pages: %s", result.Error.Error()),
+		})
+	}
+
+	pages := make([]crawlPage, 0, len(entries))
+	for _, e := range entries {
+		page := crawlPage{ID: e.ID, URL: e.URL, Title: e.Title, HTTPStatus: e.HTTPStatus, ArchivedAt: e.ArchivedAt}
+		if e.ID != seriesID {
+			page.ParentID = seriesID
+		}
+		if page.ParentID == "" {
+			// Keep the root first regardless of when its children finished
+			pages = append([]crawlPage{page}, pages...)
+		} else {
+			pages = append(pages, page)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"rootId": seriesID,
+		"pages":  pages,
+	})
+}
+
+// GetArchiveZIP handles the request to export an archive as a ZIP that
+// works when extracted and opened from disk
+func GetArchiveZIP(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Archive ID cannot be empty",
+		})
+	}
+
+	var entry models.ArchiveEntry
+	result := database.DB.Where("id = ?", id).First(&entry)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Archive entry with ID %s not found: %s", id, result.Error.Error()),
+		})
+	}
+
+	if _, err := os.Stat(entry.StoragePath); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Archived content file not found at %s for ID %s", entry.StoragePath, id),
+		})
+	}
+
+	c.Attachment(entry.ID + ".zip")
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		if err := storage.WriteZIP(w, &entry); err != nil {
+			log.Printf("Failed to write ZIP for archive %s: %v", entry.ID, err)
+			return
+		}
+		w.Flush()
+	}))
+	return nil
+}
+
+// GetArchiveWARC handles the request to export an archive as a WARC file
+// (?gzip=true for a .warc.gz with one gzip member per record)
+func GetArchiveWARC(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Archive ID cannot be empty",
+		})
+	}
+
+	var entry models.ArchiveEntry
+	result := database.DB.Where("id = ?", id).First(&entry)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Archive entry with ID %s not found: %s", id, result.Error.Error()),
+		})
+	}
+
+	if _, err := os.Stat(entry.StoragePath); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Archived content file not found at %s for ID %s", entry.StoragePath, id),
+		})
+	}
+
+	var assets []models.Asset
+	result = database.DB.Where("entry_id = ?", id).Order("id asc").Find(&assets)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to list assets: %s", result.Error.Error()),
+		})
+	}
+
+	// The .warc.gz is the payload itself, so it is not sent with a
+	// Content-Encoding that clients would transparently undo
+	compress := c.QueryBool("gzip")
+	if compress {
+		c.Attachment(entry.ID + ".warc.gz")
+		c.Set(fiber.HeaderContentType, "application/gzip")
+	} else {
+		c.Attachment(entry.ID + ".warc")
+		c.Set(fiber.HeaderContentType, "application/warc")
+	}
+
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		if err := storage.WriteWARC(w, &entry, assets, compress); err != nil {
+			log.Printf("Failed to write WARC for archive %s: %v", entry.ID, err)
+			return
+		}
+		w.Flush()
+	}))
+	return nil
+}
+
 // SetupRoutes configures the API routes for the application
 func SetupRoutes(app *fiber.App) {
 	api := app.Group("/api") // Base path for API routes
+	if rateLimiter := newRateLimiter(); rateLimiter != nil {
+		api.Use(rateLimiter)
+	}
+
+	api.Get("/archive.csv", ListArchivesCSV)
 
 	archiveRoutes := api.Group("/archive")
 	archiveRoutes.Post("/", CreateArchive)
 	archiveRoutes.Get("/", ListArchives)
+	archiveRoutes.Post("/bulk", CreateBulkArchive)
+	archiveRoutes.Post("/import", requireAPIKey(), ImportArchives)
+	archiveRoutes.Post("/refresh", RefreshArchives)
+	archiveRoutes.Get("/batch", GetArchiveBatch)
 	archiveRoutes.Get("/:id", GetArchiveDetails)
+	archiveRoutes.Patch("/:id", UpdateArchive)
 	archiveRoutes.Get("/:id/content", GetArchiveContent)
 	archiveRoutes.Get("/:id/screenshot", GetArchiveScreenshot)
+	archiveRoutes.Post("/:id/screenshot", RefreshArchiveScreenshot)
+	archiveRoutes.Get("/:id/thumbnail", GetArchiveThumbnail)
+	archiveRoutes.Get("/:id/favicon", GetArchiveFavicon)
+	archiveRoutes.Get("/:id/text", GetArchiveText)
+	archiveRoutes.Get("/:id/assets", GetArchiveAssets)
+	archiveRoutes.Get("/:id/debug", GetArchiveDebug)
+	archiveRoutes.Get("/:id/mhtml", GetArchiveMHTML)
+	archiveRoutes.Get("/:id/tree", GetArchiveTree)
+	archiveRoutes.Get("/:id/warc", GetArchiveWARC)
+	archiveRoutes.Get("/:id/zip", GetArchiveZIP)
+	archiveRoutes.Get("/:id/storage", requireAPIKey(), GetArchiveStorage)
+	archiveRoutes.Post("/:id/verify", VerifyArchive)
+	archiveRoutes.Post("/:id/reresolve", ReresolveArchive)
+
+	screenshotRoutes := api.Group("/screenshots")
+	screenshotRoutes.Get("/contactsheet", GetContactSheet)
+
+	jobRoutes := api.Group("/jobs")
+	jobRoutes.Get("/:batchid", GetJobStatus)
+	jobRoutes.Get("/:batchid/events", StreamJobEvents)
+
+	auditRoutes := api.Group("/audit")
+	auditRoutes.Get("/", GetAuditLog)
+	auditRoutes.Get("/verify", VerifyAuditLog)
+
+	adminRoutes := api.Group("/admin", requireAPIKey())
+	adminRoutes.Post("/gc", RunGC)
+
+	api.Get("/activity", GetActivity)
+	api.Get("/metrics.json", GetMetricsJSON)
 }
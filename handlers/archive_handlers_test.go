@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"archive-lite/models"
+	"archive-lite/tests"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func TestCreateArchiveAssignsUUID(t *testing.T) {
+	useTestDB(t)
+	useTestStorage(t)
+	server := newPageServer(t)
+
+	app := tests.CreateTestApp()
+	app.Post("/api/archive", CreateArchive)
+	app.Get("/api/archive/:id", GetArchiveDetails)
+
+	resp, created := createArchive(t, app, "", `{"url": "`+server.URL+`/uuid"}`)
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("create: status %d, want 201", resp.StatusCode)
+	}
+	if _, err := uuid.Parse(created.ID); err != nil {
+		t.Fatalf("created entry ID %q is not a UUID: %v", created.ID, err)
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/archive/"+created.ID, nil))
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var fetched models.ArchiveEntry
+	if resp.StatusCode != fiber.StatusOK || json.Unmarshal(body, &fetched) != nil {
+		t.Fatalf("get: status %d: %s", resp.StatusCode, body)
+	}
+	if fetched.ID != created.ID || fetched.URL != created.URL {
+		t.Errorf("fetched %s (%s), want %s (%s)", fetched.ID, fetched.URL, created.ID, created.URL)
+	}
+}
+
+func TestGetArchiveDetailsNotFound(t *testing.T) {
+	useTestDB(t)
+	app := tests.CreateTestApp()
+	app.Get("/api/archive/:id", GetArchiveDetails)
+
+	for _, id := range []string{"invalid-id", uuid.New().String()} {
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/archive/"+id, nil))
+		if err != nil {
+			t.Fatalf("%s: app.Test: %v", id, err)
+		}
+		if resp.StatusCode != fiber.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", id, resp.StatusCode)
+		}
+	}
+}
+
+func TestImportArchivesRejectsOversizedOptions(t *testing.T) {
+	useTestDB(t)
+	app := tests.CreateTestApp()
+	app.Post("/api/archive/import", ImportArchives)
+
+	cases := []struct {
+		query      string
+		wantStatus int
+	}{
+		{"", fiber.StatusOK},
+		{"?workers=2&batchSize=100", fiber.StatusOK},
+		{"?workers=100000", fiber.StatusBadRequest},
+		{"?batchSize=100000000", fiber.StatusBadRequest},
+		{"?workers=-1", fiber.StatusBadRequest},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("POST", "/api/archive/import"+c.query, strings.NewReader("[]"))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s: app.Test: %v", c.query, err)
+		}
+		if resp.StatusCode != c.wantStatus {
+			t.Errorf("%q: status %d, want %d", c.query, resp.StatusCode, c.wantStatus)
+		}
+	}
+}
+
+func TestCreateArchiveRejectsInvalidDevice(t *testing.T) {
+	app := tests.CreateTestApp()
+	app.Post("/api/archive", CreateArchive)
+
+	for _, body := range []string{
+		`{"url": "https://example.com/", "device": "watch"}`,
+		`{"url": "https://example.com/", "dpr": 4.5}`,
+		`{"url": "https://example.com/", "width": -1}`,
+	} {
+		req := httptest.NewRequest("POST", "/api/archive", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s: app.Test: %v", body, err)
+		}
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", body, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"archive-lite/storage"
+	"path/filepath"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetBundledAsset serves an asset stored in an entry's asset bundle
+// (ARCHIVE_BUNDLE_ASSETS). It is mounted at /data/assets/:name behind the
+// static handler, which serves loose asset files.
+func GetBundledAsset(c *fiber.Ctx) error {
+	name := c.Params("name")
+	content, err := storage.ReadAsset(name)
+	if err != nil {
+		return fiber.ErrNotFound
+	}
+	c.Type(filepath.Ext(name))
+	return c.Send(content)
+}
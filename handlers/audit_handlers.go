@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"archive-lite/database"
+	"archive-lite/storage"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// GetAuditLog handles the request to list the audit log, oldest first. The
+// after query parameter continues from a record ID; limit caps the records
+// returned (default 100, max 1000).
+func GetAuditLog(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", defaultAuditLimit)
+	if limit < 1 || limit > maxAuditLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("limit must be between 1 and %d", maxAuditLimit),
+		})
+	}
+	after := c.QueryInt("after")
+	if after < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "after must not be negative",
+		})
+	}
+
+	records, err := storage.ListAuditRecords(database.DB, uint(after), limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(records)
+}
+
+// VerifyAuditLog handles the request to check the audit log's hash chain
+func VerifyAuditLog(c *fiber.Ctx) error {
+	result, err := storage.VerifyAuditLog(database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return c.JSON(result)
+}
@@ -65,6 +65,7 @@ package handlers
 // // 	entry := models.ArchiveEntry{ URL: url, Title: title, StoragePath: storagePath, ScreenshotPath: screenshotPath, ArchivedAt: archivedAt }
 // // 	result := testDB.Create(&entry)
 // // 	require.NoError(t, result.Error, "Failed to create test archive entry")
+// // 	require.NotEmpty(t, entry.ID, "BeforeCreate should assign a UUID")
 // // 	return entry
 // // }
 
@@ -157,7 +158,7 @@ package handlers
 // // 	entryTime := time.Now().Truncate(time.Second)
 // // 	entry := createTestArchiveEntry(t, "http://example.com/details", "Details", "data/raw/details.html", "ss_details.jpg", entryTime)
 // // 	t.Run("Found", func(t *testing.T) {
-// // 		req := httptest.NewRequest("GET", fmt.Sprintf("/api/archive/%d", entry.ID), nil)
+// // 		req := httptest.NewRequest("GET", fmt.Sprintf("/api/archive/%s", entry.ID), nil)
 // // 		resp, err := app.Test(req, -1)
 // // 		require.NoError(t, err); defer resp.Body.Close()
 // // 		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
@@ -168,14 +169,15 @@ package handlers
 // // 		assert.Equal(t, entry.ID, fetchedEntry.ID)
 // // 	})
 // //     t.Run("Not Found", func(t *testing.T) {
-// //         req := httptest.NewRequest("GET", "/api/archive/99999", nil)
+// //         req := httptest.NewRequest("GET", "/api/archive/00000000-0000-0000-0000-000000000000", nil)
 // //         resp, err := app.Test(req, -1); require.NoError(t, err); defer resp.Body.Close()
 // //         assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
 // //     })
 // //     t.Run("Invalid ID format", func(t *testing.T) {
+// //         // IDs are UUID strings, so a malformed ID is just an unknown one
 // //         req := httptest.NewRequest("GET", "/api/archive/invalid-id", nil)
 // //         resp, err := app.Test(req, -1); require.NoError(t, err); defer resp.Body.Close()
-// //         assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+// //         assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
 // //     })
 // // }
 
@@ -194,7 +196,7 @@ package handlers
 // // 	entryTime := time.Now().Truncate(time.Second)
 // // 	entry := createTestArchiveEntry(t, "http://example.com/content", "Content", dummyStoragePath, "", entryTime)
 // // 	t.Run("Found and content served", func(t *testing.T) {
-// // 		req := httptest.NewRequest("GET", fmt.Sprintf("/api/archive/%d/content", entry.ID), nil)
+// // 		req := httptest.NewRequest("GET", fmt.Sprintf("/api/archive/%s/content", entry.ID), nil)
 // // 		resp, err := app.Test(req, -1)
 // // 		require.NoError(t, err); defer resp.Body.Close()
 // // 		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
@@ -207,12 +209,12 @@ package handlers
 // //         missingFilePath := filepath.Join(storage.RawHTMLDirForTest(), "missing.html")
 // //         entryMissingFileTime := time.Now().Truncate(time.Second)
 // //         entryMissingFile := createTestArchiveEntry(t, "http://example.com/missingfile", "Missing", missingFilePath, "", entryMissingFileTime)
-// //         req := httptest.NewRequest("GET", fmt.Sprintf("/api/archive/%d/content", entryMissingFile.ID), nil)
+// //         req := httptest.NewRequest("GET", fmt.Sprintf("/api/archive/%s/content", entryMissingFile.ID), nil)
 // //         resp, err := app.Test(req, -1); require.NoError(t, err); defer resp.Body.Close()
 // //         assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
 // //     })
 // //     t.Run("Archive entry not found", func(t *testing.T) {
-// //         req := httptest.NewRequest("GET", "/api/archive/99999/content", nil)
+// //         req := httptest.NewRequest("GET", "/api/archive/00000000-0000-0000-0000-000000000000/content", nil)
 // //         resp, err := app.Test(req, -1); require.NoError(t, err); defer resp.Body.Close()
 // //         assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
 // //     })
@@ -264,7 +266,7 @@ package handlers
 // //         // require.NoError(t, statErr, Screenshot file was not created. Ensure Chrome is available for tests.)
 
 // //         // For now, if file not found, check that GET /screenshot returns 404
-// //         getReqNotFound := httptest.NewRequest("GET", fmt.Sprintf("/api/archive/%d/screenshot", createdEntry.ID), nil)
+// //         getReqNotFound := httptest.NewRequest("GET", fmt.Sprintf("/api/archive/%s/screenshot", createdEntry.ID), nil)
 // //         getRespNotFound, errGetNotFound := app.Test(getReqNotFound, -1)
 // //         require.NoError(t, errGetNotFound)
 // //         defer getRespNotFound.Body.Close()
@@ -274,7 +276,7 @@ package handlers
 // // 	require.NoError(t, statErr, "os.Stat on screenshot path returned unexpected error")
 
 
-// // 	getReq := httptest.NewRequest("GET", fmt.Sprintf("/api/archive/%d/screenshot", createdEntry.ID), nil)
+// // 	getReq := httptest.NewRequest("GET", fmt.Sprintf("/api/archive/%s/screenshot", createdEntry.ID), nil)
 // // 	getResp, err := app.Test(getReq, -1)
 // // 	require.NoError(t, err)
 // //     defer getResp.Body.Close()
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"archive-lite/config"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultIdempotencyTTL is how long Idempotency-Key results are remembered
+// unless ARCHIVE_IDEMPOTENCY_TTL_SEC is set
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// maxIdempotencyKeyLength bounds the size of stored keys
+const maxIdempotencyKeyLength = 255
+
+// idempotencyRecord is the remembered outcome of a create request
+type idempotencyRecord struct {
+	URL        string            // Requested URL, to reject a key reused for another request
+	EntryID    string            // Empty while the request is still in progress
+	Status     int               // Response status of the original request
+	Headers    map[string]string // replayedHeaders set on the original response
+	InProgress bool
+	ExpiresAt  time.Time
+}
+
+// replayedHeaders are the response headers of a create request that are
+// repeated when its Idempotency-Key is replayed
+var replayedHeaders = []string{"X-Archive-Unchanged", "X-Feed-Batch-Id"}
+
+// idempotencyStore is an in-memory map of Idempotency-Key to create results
+type idempotencyStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	records map[string]idempotencyRecord
+}
+
+// createIdempotency remembers the results of POST /api/archive per Idempotency-Key
+var createIdempotency = newIdempotencyStore(idempotencyTTL())
+
+// idempotencyTTL reads ARCHIVE_IDEMPOTENCY_TTL_SEC
+func idempotencyTTL() time.Duration {
+	if sec, err := strconv.Atoi(config.Getenv("ARCHIVE_IDEMPOTENCY_TTL_SEC")); err == nil && sec > 0 {
+		return time.Duration(sec) * time.Second
+	}
+	return defaultIdempotencyTTL
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{ttl: ttl, records: make(map[string]idempotencyRecord)}
+}
+
+// begin claims key for a request archiving url. If the key is already known
+// it returns the existing record and false; otherwise the key is marked as in
+// progress and begin returns true.
+func (s *idempotencyStore) begin(key, url string) (idempotencyRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.evictExpired(now)
+
+	if r, ok := s.records[key]; ok {
+		return r, false
+	}
+	s.records[key] = idempotencyRecord{URL: url, InProgress: true, ExpiresAt: now.Add(s.ttl)}
+	return idempotencyRecord{}, true
+}
+
+// evictExpired drops the records expired at now and returns how many were
+// dropped. The caller holds s.mu.
+func (s *idempotencyStore) evictExpired(now time.Time) int {
+	evicted := 0
+	for k, r := range s.records {
+		if now.After(r.ExpiresAt) {
+			delete(s.records, k)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// compact drops expired records and returns how many were dropped and how
+// many remain
+func (s *idempotencyStore) compact() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	evicted := s.evictExpired(time.Now())
+	return evicted, len(s.records)
+}
+
+// complete stores the entry returned for key with the response status and headers
+func (s *idempotencyStore) complete(key, entryID string, status int, headers map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.records[key]; ok {
+		r.EntryID = entryID
+		r.Status = status
+		r.Headers = headers
+		r.InProgress = false
+		r.ExpiresAt = time.Now().Add(s.ttl)
+		s.records[key] = r
+	}
+}
+
+// forget releases key so that a retry is processed again
+func (s *idempotencyStore) forget(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+}
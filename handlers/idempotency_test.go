@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"archive-lite/config"
+	"archive-lite/database"
+	"archive-lite/models"
+	"archive-lite/storage"
+	"archive-lite/tests"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// useTestDB points database.DB at the shared test database for the test
+func useTestDB(t *testing.T) {
+	t.Helper()
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+	origDB := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = origDB })
+}
+
+// useTestStorage stores archives in temporary directories, without the
+// politeness delay between requests
+func useTestStorage(t *testing.T) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.RawHTMLDir, cfg.AssetsDir, cfg.ScreenshotsDir = t.TempDir(), t.TempDir(), t.TempDir()
+	cfg.RequestDelay = 0
+	storage.Configure(cfg)
+	t.Cleanup(func() { storage.Configure(config.Default()) })
+}
+
+// newPageServer serves a small HTML page on every path. The entries archived
+// from it are deleted when the test ends.
+func newPageServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, "<!DOCTYPE html><html><head><title>Page</title></head><body><p>Same text every time</p></body></html>")
+	}))
+	t.Cleanup(func() {
+		server.Close()
+		database.DB.Where("url LIKE ?", server.URL+"/%").Delete(&models.ArchiveEntry{})
+	})
+	return server
+}
+
+// createArchive posts body to /api/archive with the Idempotency-Key key
+// (if any) and returns the response and the decoded entry, if one was returned
+func createArchive(t *testing.T, app *fiber.App, key, body string) (*http.Response, models.ArchiveEntry) {
+	t.Helper()
+	req := httptest.NewRequest("POST", "/api/archive", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	var entry models.ArchiveEntry
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 300 {
+		if err := json.Unmarshal(data, &entry); err != nil {
+			t.Fatalf("decoding entry: %v: %s", err, data)
+		}
+	}
+	return resp, entry
+}
+
+func TestCreateArchiveIdempotencyKey(t *testing.T) {
+	useTestDB(t)
+	useTestStorage(t)
+	defer func(old *idempotencyStore) { createIdempotency = old }(createIdempotency)
+	createIdempotency = newIdempotencyStore(time.Hour)
+
+	server := newPageServer(t)
+	pageURL := server.URL + "/idempotent"
+	body := `{"url": "` + pageURL + `"}`
+
+	app := tests.CreateTestApp()
+	app.Post("/api/archive", CreateArchive)
+
+	first, original := createArchive(t, app, "key-1", body)
+	if first.StatusCode != fiber.StatusCreated || first.Header.Get("Idempotent-Replayed") != "" {
+		t.Fatalf("first request: status %d, Idempotent-Replayed %q", first.StatusCode, first.Header.Get("Idempotent-Replayed"))
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		resp, entry := createArchive(t, app, "key-1", body)
+		if resp.StatusCode != fiber.StatusCreated || resp.Header.Get("Idempotent-Replayed") != "true" {
+			t.Errorf("status %d, Idempotent-Replayed %q, want 201 and true", resp.StatusCode, resp.Header.Get("Idempotent-Replayed"))
+		}
+		if entry.ID != original.ID {
+			t.Errorf("replayed entry %s, want %s", entry.ID, original.ID)
+		}
+	})
+
+	t.Run("replay keeps status and headers", func(t *testing.T) {
+		unchangedBody := `{"url": "` + pageURL + `", "onlyIfChanged": true}`
+		resp, _ := createArchive(t, app, "key-unchanged", unchangedBody)
+		if resp.StatusCode != fiber.StatusOK || resp.Header.Get("X-Archive-Unchanged") != "true" {
+			t.Fatalf("unchanged archive: status %d, X-Archive-Unchanged %q", resp.StatusCode, resp.Header.Get("X-Archive-Unchanged"))
+		}
+
+		resp, entry := createArchive(t, app, "key-unchanged", unchangedBody)
+		if resp.StatusCode != fiber.StatusOK || resp.Header.Get("X-Archive-Unchanged") != "true" || resp.Header.Get("Idempotent-Replayed") != "true" {
+			t.Errorf("replay: status %d, X-Archive-Unchanged %q, Idempotent-Replayed %q, want 200, true and true",
+				resp.StatusCode, resp.Header.Get("X-Archive-Unchanged"), resp.Header.Get("Idempotent-Replayed"))
+		}
+		if entry.ID != original.ID {
+			t.Errorf("replayed entry %s, want %s", entry.ID, original.ID)
+		}
+	})
+
+	t.Run("key reused for a different URL", func(t *testing.T) {
+		resp, _ := createArchive(t, app, "key-1", `{"url": "`+server.URL+`/other"}`)
+		if resp.StatusCode != fiber.StatusUnprocessableEntity {
+			t.Errorf("status %d, want 422", resp.StatusCode)
+		}
+	})
+
+	t.Run("in progress", func(t *testing.T) {
+		if _, claimed := createIdempotency.begin("key-running", pageURL); !claimed {
+			t.Fatal("could not claim key-running")
+		}
+		resp, _ := createArchive(t, app, "key-running", body)
+		if resp.StatusCode != fiber.StatusConflict {
+			t.Errorf("status %d, want 409", resp.StatusCode)
+		}
+	})
+
+	t.Run("failed request releases the key", func(t *testing.T) {
+		resp, _ := createArchive(t, app, "key-failed", `{"url": "http://127.0.0.1:1/unreachable"}`)
+		if resp.StatusCode < 400 {
+			t.Fatalf("status %d, want an error", resp.StatusCode)
+		}
+		if _, claimed := createIdempotency.begin("key-failed", pageURL); !claimed {
+			t.Error("key-failed is still claimed after the request failed")
+		}
+	})
+
+	t.Run("deleted entry is archived again", func(t *testing.T) {
+		if err := database.DB.Where("id = ?", original.ID).Delete(&models.ArchiveEntry{}).Error; err != nil {
+			t.Fatal(err)
+		}
+		resp, entry := createArchive(t, app, "key-1", body)
+		if resp.StatusCode != fiber.StatusCreated || resp.Header.Get("Idempotent-Replayed") != "" {
+			t.Errorf("status %d, Idempotent-Replayed %q, want a new 201", resp.StatusCode, resp.Header.Get("Idempotent-Replayed"))
+		}
+		if entry.ID == "" || entry.ID == original.ID {
+			t.Errorf("entry %q, want a new entry", entry.ID)
+		}
+	})
+}
+
+func TestCreateArchiveIdempotencyKeyExpires(t *testing.T) {
+	useTestDB(t)
+	useTestStorage(t)
+	defer func(old *idempotencyStore) { createIdempotency = old }(createIdempotency)
+	createIdempotency = newIdempotencyStore(50 * time.Millisecond)
+
+	server := newPageServer(t)
+	body := `{"url": "` + server.URL + `/expiring"}`
+	app := tests.CreateTestApp()
+	app.Post("/api/archive", CreateArchive)
+
+	_, first := createArchive(t, app, "key-expiring", body)
+	time.Sleep(100 * time.Millisecond)
+
+	resp, second := createArchive(t, app, "key-expiring", body)
+	if resp.StatusCode != fiber.StatusCreated || resp.Header.Get("Idempotent-Replayed") != "" {
+		t.Errorf("status %d, Idempotent-Replayed %q, want a new 201", resp.StatusCode, resp.Header.Get("Idempotent-Replayed"))
+	}
+	if second.ID == first.ID {
+		t.Errorf("expired key replayed entry %s", first.ID)
+	}
+}
+
+func TestIdempotencyStoreCompact(t *testing.T) {
+	store := newIdempotencyStore(time.Hour)
+	store.begin("live", "https://example.com/a")
+	store.begin("expired", "https://example.com/b")
+	store.records["expired"] = idempotencyRecord{URL: "https://example.com/b", ExpiresAt: time.Now().Add(-time.Second)}
+
+	if evicted, remaining := store.compact(); evicted != 1 || remaining != 1 {
+		t.Errorf("compact = %d, %d; want 1, 1", evicted, remaining)
+	}
+	if _, ok := store.records["live"]; !ok {
+		t.Error("unexpired key was dropped")
+	}
+}
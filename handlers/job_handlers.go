@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"archive-lite/config"
+	"archive-lite/database"
+	"archive-lite/jobs"
+	"archive-lite/models"
+	"archive-lite/storage"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// maxBulkURLs caps the number of URLs accepted in a single bulk request
+const maxBulkURLs = 500
+
+// maxRefreshEntries caps the number of entries refreshed by a single request
+const maxRefreshEntries = 5000
+
+// BulkArchivePayload is the expected payload for the CreateBulkArchive handler
+type BulkArchivePayload struct {
+	URLs []string `json:"urls"`
+}
+
+// batchConcurrency returns how many URLs of a batch are archived in parallel,
+// configured via ARCHIVE_BATCH_CONCURRENCY (default 2)
+func batchConcurrency() int {
+	if n, err := strconv.Atoi(config.Getenv("ARCHIVE_BATCH_CONCURRENCY")); err == nil && n > 0 {
+		return n
+	}
+	return 2
+}
+
+// CreateBulkArchive handles the request to archive several URLs asynchronously
+func CreateBulkArchive(c *fiber.Ctx) error {
+	payload := new(BulkArchivePayload)
+	if err := c.BodyParser(payload); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Cannot parse JSON payload",
+		})
+	}
+
+	var urls []string
+	for _, u := range payload.URLs {
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+	if len(urls) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "URLs cannot be empty",
+		})
+	}
+	if len(urls) > maxBulkURLs {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Too many URLs: %d (maximum %d per request)", len(urls), maxBulkURLs),
+		})
+	}
+
+	batch := jobs.StartBatch(urls, batchConcurrency(), func(u string) (*models.ArchiveEntry, bool, error) {
+		entry, err := storage.ArchiveURL(database.DB, u)
+		return entry, err == nil, err
+	})
+
+	return c.Status(fiber.StatusAccepted).JSON(batch.Status())
+}
+
+// RefreshArchives handles the request to refetch, in place, every entry
+// matching the filters as an asynchronous batch. At least one filter is
+// required so the whole archive isn't refreshed by accident.
+func RefreshArchives(c *fiber.Ctx) error {
+	if c.Query("tag") != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Filtering by tag is not supported: archive entries have no tags",
+		})
+	}
+	host := strings.ToLower(strings.TrimSpace(c.Query("host")))
+	if host == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "At least one filter (host) is required",
+		})
+	}
+
+	// The host is matched exactly on the parsed URL; LIKE only narrows the scan
+	var candidates []models.ArchiveEntry
+	if err := database.DB.Where("url LIKE ?", "%"+host+"%").Order("archived_at asc").Find(&candidates).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to query archive entries: %s", err.Error()),
+		})
+	}
+	var entries []models.ArchiveEntry
+	for _, entry := range candidates {
+		if u, err := url.Parse(entry.URL); err == nil && strings.ToLower(u.Hostname()) == host {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("No archive entries found for host %s", host),
+		})
+	}
+	if len(entries) > maxRefreshEntries {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Too many matching entries: %d (maximum %d per request)", len(entries), maxRefreshEntries),
+		})
+	}
+
+	// Batches track items by URL; snapshots sharing a URL are handed out in order
+	urls := make([]string, len(entries))
+	pending := make(map[string][]*models.ArchiveEntry)
+	for i := range entries {
+		urls[i] = entries[i].URL
+		pending[entries[i].URL] = append(pending[entries[i].URL], &entries[i])
+	}
+	var pendingMu sync.Mutex
+
+	batch := jobs.StartBatch(urls, batchConcurrency(), func(u string) (*models.ArchiveEntry, bool, error) {
+		pendingMu.Lock()
+		entry := pending[u][0]
+		pending[u] = pending[u][1:]
+		pendingMu.Unlock()
+
+		if err := storage.RefetchEntry(database.DB, entry, entry.URL, false); err != nil {
+			return nil, false, err
+		}
+		return entry, true, nil
+	})
+
+	return c.Status(fiber.StatusAccepted).JSON(batch.Status())
+}
+
+// GetJobStatus handles the request to get the progress of a batch
+func GetJobStatus(c *fiber.Ctx) error {
+	batchID := c.Params("batchid")
+	batch, ok := jobs.Get(batchID)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Batch with ID %s not found", batchID),
+		})
+	}
+	return c.JSON(batch.Status())
+}
+
+// StreamJobEvents streams a batch's per-URL progress as Server-Sent Events.
+// Events emitted before the client connected are replayed first, and the
+// stream ends with a "complete" event once the batch has finished.
+func StreamJobEvents(c *fiber.Ctx) error {
+	batchID := c.Params("batchid")
+	batch, ok := jobs.Get(batchID)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Batch with ID %s not found", batchID),
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+
+	past, events, cancel := batch.Subscribe()
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+
+		for _, event := range past {
+			if err := writeSSE(w, "progress", event); err != nil {
+				return
+			}
+		}
+		for event := range events {
+			if err := writeSSE(w, "progress", event); err != nil {
+				return
+			}
+		}
+		writeSSE(w, "complete", batch.Status())
+	}))
+
+	return nil
+}
+
+// writeSSE writes a single Server-Sent Event with a JSON payload and flushes it
+func writeSSE(w *bufio.Writer, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,12 @@
+package handlers
+
+import (
+	"archive-lite/metrics"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GetMetricsJSON handles the request for a snapshot of in-process counters
+func GetMetricsJSON(c *fiber.Ctx) error {
+	return c.JSON(metrics.Current())
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"archive-lite/config"
+	"crypto/subtle"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+)
+
+// rateLimitExemptPrefixes lists API paths that are never rate limited, so that
+// monitoring keeps working while a client is being throttled. Only
+// /api/metrics.json is exempt; there is no separate health endpoint.
+var rateLimitExemptPrefixes = []string{
+	"/api/metrics.json",
+}
+
+// newRateLimiter builds a per-IP limiter from ARCHIVE_RATE_LIMIT (requests per
+// window) and ARCHIVE_RATE_LIMIT_WINDOW_SEC (window length, default 60).
+// It returns nil when rate limiting is not configured.
+func newRateLimiter() fiber.Handler {
+	maxRequests, err := strconv.Atoi(config.Getenv("ARCHIVE_RATE_LIMIT"))
+	if err != nil || maxRequests <= 0 {
+		return nil
+	}
+
+	window := 60 * time.Second
+	if v := config.Getenv("ARCHIVE_RATE_LIMIT_WINDOW_SEC"); v != "" {
+		if sec, err := strconv.Atoi(v); err == nil && sec > 0 {
+			window = time.Duration(sec) * time.Second
+		} else {
+			log.Printf("Invalid ARCHIVE_RATE_LIMIT_WINDOW_SEC '%s', using %s", v, window)
+		}
+	}
+
+	log.Printf("API rate limit enabled: %d requests per %s per IP", maxRequests, window)
+	return limiter.New(limiter.Config{
+		Max:        maxRequests,
+		Expiration: window,
+		Next: func(c *fiber.Ctx) bool {
+			for _, prefix := range rateLimitExemptPrefixes {
+				if strings.HasPrefix(c.Path(), prefix) {
+					return true
+				}
+			}
+			return false
+		},
+		LimitReached: func(c *fiber.Ctx) error {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": "Too many requests, please try again later",
+			})
+		},
+	})
+}
+
+// requireAPIKey allows a request only if it carries the key configured in
+// ARCHIVE_API_KEY, either as an X-API-Key header or as a bearer token. When no
+// key is configured the protected routes are disabled.
+func requireAPIKey() fiber.Handler {
+	apiKey := config.Getenv("ARCHIVE_API_KEY")
+	return func(c *fiber.Ctx) error {
+		if apiKey == "" {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "This endpoint requires ARCHIVE_API_KEY to be configured",
+			})
+		}
+
+		provided := c.Get("X-API-Key")
+		if provided == "" {
+			// Only a bearer token counts; a bare key in Authorization is rejected
+			if token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer "); ok {
+				provided = token
+			}
+		}
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(apiKey)) != 1 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Invalid or missing API key",
+			})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"archive-lite/tests"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequireAPIKey(t *testing.T) {
+	newApp := func() *fiber.App {
+		app := tests.CreateTestApp()
+		app.Get("/protected", requireAPIKey(), func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+		return app
+	}
+
+	t.Run("no key configured", func(t *testing.T) {
+		t.Setenv("ARCHIVE_API_KEY", "")
+		req := httptest.NewRequest("GET", "/protected", nil)
+		req.Header.Set("X-API-Key", "anything")
+		resp, err := newApp().Test(req)
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		if resp.StatusCode != fiber.StatusForbidden {
+			t.Errorf("status %d, want 403", resp.StatusCode)
+		}
+	})
+
+	t.Setenv("ARCHIVE_API_KEY", "s3cret")
+	app := newApp()
+	cases := []struct {
+		name       string
+		header     string
+		value      string
+		wantStatus int
+	}{
+		{"missing key", "", "", fiber.StatusUnauthorized},
+		{"wrong X-API-Key", "X-API-Key", "wrong", fiber.StatusUnauthorized},
+		{"wrong bearer token", "Authorization", "Bearer wrong", fiber.StatusUnauthorized},
+		{"correct X-API-Key", "X-API-Key", "s3cret", fiber.StatusOK},
+		{"correct bearer token", "Authorization", "Bearer s3cret", fiber.StatusOK},
+		{"bare key in Authorization", "Authorization", "s3cret", fiber.StatusUnauthorized},
+		{"other scheme in Authorization", "Authorization", "Basic s3cret", fiber.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/protected", nil)
+		if c.header != "" {
+			req.Header.Set(c.header, c.value)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s: app.Test: %v", c.name, err)
+		}
+		if resp.StatusCode != c.wantStatus {
+			t.Errorf("%s: status %d, want %d", c.name, resp.StatusCode, c.wantStatus)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"archive-lite/tests"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestHandlerPanicReturns500(t *testing.T) {
+	app := tests.CreateTestApp()
+	app.Get("/panic", func(c *fiber.Ctx) error {
+		var entry *struct{ URL string }
+		return c.SendString(entry.URL) // nil dereference
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/panic", nil))
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", resp.StatusCode, fiber.StatusInternalServerError)
+	}
+
+	// The app keeps serving after a panic
+	resp, err = app.Test(httptest.NewRequest("GET", "/panic", nil))
+	if err != nil || resp.StatusCode != fiber.StatusInternalServerError {
+		t.Errorf("second request: status %v, err %v", resp, err)
+	}
+}
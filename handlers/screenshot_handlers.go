@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"archive-lite/database"
+	"archive-lite/models"
+	"archive-lite/storage"
+	"bytes"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"image/png"
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultContactSheetLimit = 20
+	maxContactSheetLimit     = 100
+)
+
+// GetContactSheet handles the request to render recent screenshots as a single
+// grid image. Query parameters: limit, offset, columns and format (png|jpeg).
+func GetContactSheet(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", defaultContactSheetLimit)
+	if limit < 1 || limit > maxContactSheetLimit {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("limit must be between 1 and %d", maxContactSheetLimit),
+		})
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "offset cannot be negative",
+		})
+	}
+	columns := c.QueryInt("columns", 4)
+	format := c.Query("format", "png")
+	if format != "png" && format != "jpeg" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "format must be png or jpeg",
+		})
+	}
+
+	var entries []models.ArchiveEntry
+	result := database.DB.Where("screenshot_path <> ''").
+		Order("archived_at desc").Limit(limit).Offset(offset).Find(&entries)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to list archives: %s", result.Error.Error()),
+		})
+	}
+
+	sheet, count, err := storage.BuildContactSheet(entries, columns)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to build contact sheet: %s", err.Error()),
+		})
+	}
+	if count == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"message": "No screenshots available for the requested range",
+		})
+	}
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, sheet, &jpeg.Options{Quality: 85})
+		c.Set(fiber.HeaderContentType, "image/jpeg")
+	} else {
+		err = png.Encode(&buf, sheet)
+		c.Set(fiber.HeaderContentType, "image/png")
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to encode contact sheet: %s", err.Error()),
+		})
+	}
+
+	return c.Send(buf.Bytes())
+}
+
+// RefreshScreenshotPayload is the optional payload of RefreshArchiveScreenshot
+type RefreshScreenshotPayload struct {
+	// TTLSec sets the entry's ScreenshotTTL (0 stops scheduled refreshes); unchanged when omitted
+	TTLSec *int64 `json:"ttlSec"`
+}
+
+// RefreshArchiveScreenshot handles the request to capture a new screenshot of
+// an archived page without refetching its content, optionally setting how
+// often it is regenerated afterwards
+func RefreshArchiveScreenshot(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Archive ID cannot be empty",
+		})
+	}
+
+	payload := new(RefreshScreenshotPayload)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(payload); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Cannot parse JSON payload",
+			})
+		}
+	}
+	if payload.TTLSec != nil && *payload.TTLSec < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "ttlSec must not be negative",
+		})
+	}
+
+	var entry models.ArchiveEntry
+	result := database.DB.Where("id = ?", id).First(&entry)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Archive entry with ID %s not found: %s", id, result.Error.Error()),
+		})
+	}
+
+	if payload.TTLSec != nil {
+		entry.ScreenshotTTL = *payload.TTLSec
+		if err := database.DB.Model(&entry).Update("screenshot_ttl", entry.ScreenshotTTL).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to update screenshot TTL: %s", err.Error()),
+			})
+		}
+	}
+
+	if err := storage.RefreshScreenshot(database.DB, &entry); err != nil {
+		status := fiber.StatusInternalServerError
+		if errors.Is(err, storage.ErrNoScreenshot) {
+			status = fiber.StatusUnprocessableEntity
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": fmt.Sprintf("Failed to refresh screenshot: %s", err.Error()),
+		})
+	}
+	return c.JSON(entry)
+}
+
+// GetArchiveThumbnail handles the request to retrieve the small JPEG
+// thumbnail of an archive's screenshot, for list and gallery views
+func GetArchiveThumbnail(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Archive ID cannot be empty",
+		})
+	}
+
+	var entry models.ArchiveEntry
+	result := database.DB.Where("id = ?", id).First(&entry)
+	if result.Error != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Archive entry with ID %s not found: %s", id, result.Error.Error()),
+		})
+	}
+
+	if entry.ThumbnailPath == "" {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Thumbnail not available for archive ID %s", id),
+		})
+	}
+	if _, err := os.Stat(entry.ThumbnailPath); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Thumbnail file not found for archive ID %s", id),
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, "image/jpeg")
+	return c.SendFile(entry.ThumbnailPath)
+}
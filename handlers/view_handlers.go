@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"archive-lite/database"
+	"archive-lite/models"
+	"archive-lite/storage"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ViewArchive serves an archive at its stable mount /view/:id/
+// (ARCHIVE_VIEW_MOUNT). The archived page is served at /view/:id/ and
+// /view/:id/index.html with references to its own assets made relative, and
+// /view/:id/<name> serves the entry's asset <id>_<name>, loose or bundled.
+// The page honours ?sandbox=true and ?stripScripts=true like
+// GET /api/archive/:id/content.
+func ViewArchive(c *fiber.Ctx) error {
+	id := c.Params("id")
+	name := c.Params("*")
+	if !strings.HasSuffix(c.Path(), "/") && name == "" {
+		// Relative links only resolve under the prefix with a trailing slash
+		return c.Redirect("/view/"+id+"/", fiber.StatusMovedPermanently)
+	}
+
+	if name != "" && name != "index.html" {
+		content, err := storage.ReadAsset(id + "_" + name)
+		if err != nil {
+			return fiber.ErrNotFound
+		}
+		c.Type(filepath.Ext(name))
+		if filepath.Ext(name) == ".css" {
+			return c.SendString(storage.RelativizeAssetPaths(string(content), id))
+		}
+		return c.Send(content)
+	}
+
+	var entry models.ArchiveEntry
+	if err := database.DB.Where("id = ?", id).First(&entry).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Archive entry with ID %s not found: %s", id, err.Error()),
+		})
+	}
+	stripScripts := sandboxPage(c)
+	if entry.AttachmentName != "" {
+		// Downloads have no assets to resolve; serve them as archived
+		if err := c.SendFile(entry.StoragePath); err != nil {
+			return err
+		}
+		c.Attachment(entry.AttachmentName)
+		return nil
+	}
+
+	content, err := os.ReadFile(entry.StoragePath)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": fmt.Sprintf("Archived content not found for ID %s: %s", id, err.Error()),
+		})
+	}
+	page := string(content)
+	if stripScripts {
+		if page, err = storage.StripScripts(page); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": fmt.Sprintf("Failed to strip scripts for ID %s: %s", id, err.Error()),
+			})
+		}
+	}
+	c.Set(fiber.HeaderContentType, storage.ServedContentType(&entry))
+	return c.SendString(storage.RelativizeAssetPaths(page, entry.ID))
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"archive-lite/database"
+	"archive-lite/models"
+	"archive-lite/tests"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestViewArchiveSandbox(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+	origDB := database.DB
+	database.DB = db
+	defer func() { database.DB = origDB }()
+
+	path := filepath.Join(t.TempDir(), "page.html")
+	page := `<!DOCTYPE html><html><body><p>Archived</p><script>fetch("https://tracker.example/")</script></body></html>`
+	if err := os.WriteFile(path, []byte(page), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entry := models.ArchiveEntry{
+		URL:         "https://example.com/view-sandbox",
+		StoragePath: path,
+		ContentType: "text/html; charset=utf-8",
+		ArchivedAt:  time.Now(),
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+	t.Cleanup(func() { db.Delete(&entry) })
+
+	app := tests.CreateTestApp()
+	app.Get("/view/:id", ViewArchive)
+	app.Get("/view/:id/*", ViewArchive)
+
+	cases := []struct {
+		query      string
+		wantCSP    bool
+		wantScript bool
+	}{
+		{"", false, true},
+		{"?sandbox=true", true, true},
+		{"?sandbox=true&stripScripts=true", true, false},
+	}
+	for _, c := range cases {
+		resp, err := app.Test(httptest.NewRequest("GET", "/view/"+entry.ID+"/"+c.query, nil))
+		if err != nil {
+			t.Fatalf("%s: app.Test: %v", c.query, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("%s: status %d: %s", c.query, resp.StatusCode, body)
+		}
+		if csp := resp.Header.Get("Content-Security-Policy"); (csp == sandboxCSP) != c.wantCSP {
+			t.Errorf("%s: Content-Security-Policy = %q, want sandbox policy: %v", c.query, csp, c.wantCSP)
+		}
+		if hasScript := strings.Contains(string(body), "<script>"); hasScript != c.wantScript {
+			t.Errorf("%s: page has <script>: %v, want %v", c.query, hasScript, c.wantScript)
+		}
+		if !strings.Contains(string(body), "<p>Archived</p>") {
+			t.Errorf("%s: page content missing: %s", c.query, body)
+		}
+	}
+}
@@ -0,0 +1,443 @@
+package jobs
+
+import (
+	"archive-lite/config"
+	"archive-lite/models"
+	"archive-lite/storage"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Status is the progress state of a single URL within a batch
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusArchiving Status = "archiving"
+	StatusDone      Status = "done"
+	StatusFailed    Status = "failed"
+	StatusSkipped   Status = "skipped" // Not started because the batch ran out of time or bytes
+)
+
+// budget is the wall-clock time a batch may spend starting new URLs
+// (ARCHIVE_CRAWL_BUDGET_SEC); zero means unlimited
+var budget = budgetFromEnv()
+
+// budgetFromEnv reads ARCHIVE_CRAWL_BUDGET_SEC
+func budgetFromEnv() time.Duration {
+	v := config.Getenv("ARCHIVE_CRAWL_BUDGET_SEC")
+	if v == "" {
+		return 0
+	}
+	sec, err := strconv.Atoi(v)
+	if err != nil || sec < 0 {
+		log.Printf("Invalid ARCHIVE_CRAWL_BUDGET_SEC '%s', batches are not time-limited", v)
+		return 0
+	}
+	return time.Duration(sec) * time.Second
+}
+
+// byteBudget is the number of bytes a batch may write to disk before it
+// stops starting new URLs (ARCHIVE_CRAWL_BYTE_BUDGET); zero means unlimited
+var byteBudget = byteBudgetFromEnv()
+
+// byteBudgetFromEnv reads ARCHIVE_CRAWL_BYTE_BUDGET
+func byteBudgetFromEnv() int64 {
+	v := config.Getenv("ARCHIVE_CRAWL_BYTE_BUDGET")
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		log.Printf("Invalid ARCHIVE_CRAWL_BYTE_BUDGET '%s', batches are not size-limited", v)
+		return 0
+	}
+	return n
+}
+
+// finishedTTL is how long finished batches stay available to GET
+// /api/jobs/:batchid (ARCHIVE_JOB_TTL_SEC); zero keeps them until restart
+var finishedTTL = finishedTTLFromEnv()
+
+// FinishedTTL returns how long finished batches are kept (ARCHIVE_JOB_TTL_SEC);
+// zero means until restart
+func FinishedTTL() time.Duration {
+	return finishedTTL
+}
+
+// finishedTTLFromEnv reads ARCHIVE_JOB_TTL_SEC, defaulting to 24 hours
+func finishedTTLFromEnv() time.Duration {
+	v := config.Getenv("ARCHIVE_JOB_TTL_SEC")
+	if v == "" {
+		return 24 * time.Hour
+	}
+	sec, err := strconv.Atoi(v)
+	if err != nil || sec < 0 {
+		log.Printf("Invalid ARCHIVE_JOB_TTL_SEC '%s', keeping finished batches for 24 hours", v)
+		return 24 * time.Hour
+	}
+	return time.Duration(sec) * time.Second
+}
+
+// ArchiveFunc archives a single URL and returns its entry. stored reports
+// whether files were written for it, which count toward the batch's byte
+// budget; it is false when an existing snapshot is returned unchanged.
+type ArchiveFunc func(url string) (entry *models.ArchiveEntry, stored bool, err error)
+
+// Item tracks the state of one URL in a batch
+type Item struct {
+	URL     string `json:"url"`
+	Status  Status `json:"status"`
+	EntryID string `json:"entryId,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Event is a progress notification for one URL in a batch
+type Event struct {
+	BatchID   string    `json:"batchId"`
+	Index     int       `json:"index"`
+	URL       string    `json:"url"`
+	Status    Status    `json:"status"`
+	EntryID   string    `json:"entryId,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Batch is an asynchronous group of URLs being archived
+type Batch struct {
+	ID         string
+	CreatedAt  time.Time
+	FinishedAt time.Time
+
+	mu          sync.Mutex
+	items       []Item
+	events      []Event
+	subscribers map[chan struct{}]struct{} // Woken when events are added or the batch finishes
+	done        bool
+	partial     bool  // Some URLs were skipped because a budget ran out
+	written     int64 // Bytes stored by the entries archived so far, excluding unchanged ones
+}
+
+// BatchStatus is a point-in-time view of a batch
+type BatchStatus struct {
+	ID         string     `json:"id"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Done       bool       `json:"done"`
+	Partial    bool       `json:"partial"`
+	Total      int        `json:"total"`
+	Completed  int        `json:"completed"`
+	Failed     int        `json:"failed"`
+	Skipped    int        `json:"skipped"`
+	Bytes      int64      `json:"bytesWritten"`         // Size of the files stored for the batch's entries
+	ByteBudget int64      `json:"byteBudget,omitempty"` // ARCHIVE_CRAWL_BYTE_BUDGET, if set
+	Items      []Item     `json:"items"`
+}
+
+var (
+	batches   = make(map[string]*Batch)
+	batchesMu sync.RWMutex
+
+	evictionOnce sync.Once
+)
+
+// Get returns the batch with the given ID
+func Get(id string) (*Batch, bool) {
+	batchesMu.RLock()
+	defer batchesMu.RUnlock()
+	b, ok := batches[id]
+	return b, ok
+}
+
+// StartBatch registers a new batch and archives its URLs in the background
+// using up to concurrency workers
+func StartBatch(urls []string, concurrency int, archive ArchiveFunc) *Batch {
+	b := &Batch{
+		ID:          uuid.New().String(),
+		CreatedAt:   time.Now(),
+		items:       make([]Item, len(urls)),
+		subscribers: make(map[chan struct{}]struct{}),
+	}
+	for i, u := range urls {
+		b.items[i] = Item{URL: u}
+		b.update(i, StatusQueued, "", "")
+	}
+
+	batchesMu.Lock()
+	batches[b.ID] = b
+	batchesMu.Unlock()
+	evictionOnce.Do(startEviction)
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(urls) {
+		concurrency = len(urls)
+	}
+
+	go b.run(concurrency, archive)
+	return b
+}
+
+// startEviction periodically drops batches finished more than finishedTTL ago
+func startEviction() {
+	if finishedTTL <= 0 {
+		return
+	}
+	interval := min(max(finishedTTL/4, time.Minute), time.Hour)
+	go func() {
+		for range time.Tick(interval) {
+			if evicted, _ := EvictFinished(finishedTTL); evicted > 0 {
+				log.Printf("Evicted %d finished batches older than %s", evicted, finishedTTL)
+			}
+		}
+	}()
+}
+
+// EvictFinished drops batches that finished more than olderThan ago and
+// returns how many were dropped and how many remain
+func EvictFinished(olderThan time.Duration) (int, int) {
+	cutoff := time.Now().Add(-olderThan)
+	batchesMu.Lock()
+	defer batchesMu.Unlock()
+
+	evicted := 0
+	for id, b := range batches {
+		b.mu.Lock()
+		expired := b.done && !b.FinishedAt.After(cutoff)
+		b.mu.Unlock()
+		if expired {
+			delete(batches, id)
+			evicted++
+		}
+	}
+	return evicted, len(batches)
+}
+
+// run distributes the batch's URLs across worker goroutines, at most
+// perHost of them to the same host at a time. Once the time or byte budget
+// is exceeded, URLs that haven't started are skipped; those already being
+// archived are allowed to finish.
+func (b *Batch) run(concurrency int, archive ArchiveFunc) {
+	var deadline time.Time
+	if budget > 0 {
+		deadline = b.CreatedAt.Add(budget)
+	}
+
+	urls := make([]string, len(b.items))
+	for i, item := range b.items {
+		urls[i] = item.URL
+	}
+	scheduler := newHostScheduler(urls, perHost)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i, ok := scheduler.next()
+				if !ok {
+					return
+				}
+				b.process(i, deadline, archive)
+				scheduler.done(i)
+			}
+		}()
+	}
+	wg.Wait()
+
+	b.finish()
+}
+
+// process archives item i, or skips it once deadline has passed or the
+// batch has written more than byteBudget bytes
+func (b *Batch) process(i int, deadline time.Time, archive ArchiveFunc) {
+	if !deadline.IsZero() && time.Now().After(deadline) {
+		b.skip(i, "time budget", budget.String())
+		return
+	}
+	if byteBudget > 0 && b.bytesWritten() > byteBudget {
+		b.skip(i, "byte budget", strconv.FormatInt(byteBudget, 10)+" bytes")
+		return
+	}
+	b.update(i, StatusArchiving, "", "")
+	entry, stored, err := archive(b.items[i].URL)
+	if err != nil {
+		b.update(i, StatusFailed, "", err.Error())
+		return
+	}
+	if stored {
+		size := storage.GetStorageInfo(entry).TotalBytes()
+		b.mu.Lock()
+		b.written += size
+		b.mu.Unlock()
+	}
+	b.update(i, StatusDone, entry.ID, "")
+}
+
+// bytesWritten returns the running total of bytes stored by the batch
+func (b *Batch) bytesWritten() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.written
+}
+
+// update records a status change for item i and notifies subscribers
+func (b *Batch) update(i int, status Status, entryID, errMsg string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	item := &b.items[i]
+	item.Status = status
+	item.EntryID = entryID
+	item.Error = errMsg
+
+	event := Event{
+		BatchID:   b.ID,
+		Index:     i,
+		URL:       item.URL,
+		Status:    status,
+		EntryID:   entryID,
+		Error:     errMsg,
+		Timestamp: time.Now(),
+	}
+	b.events = append(b.events, event)
+	b.wakeSubscribers()
+}
+
+// wakeSubscribers tells subscribers to read the events they haven't sent
+// yet. It never blocks: a subscriber that already has a wake-up pending
+// reads every new event when it handles that one. b.mu must be held.
+func (b *Batch) wakeSubscribers() {
+	for wake := range b.subscribers {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// skip marks item i as not archived because the named budget, of limit,
+// ran out
+func (b *Batch) skip(i int, name, limit string) {
+	b.mu.Lock()
+	if !b.partial {
+		log.Printf("Batch %s exceeded its %s of %s, skipping remaining URLs", b.ID, name, limit)
+	}
+	b.partial = true
+	b.mu.Unlock()
+
+	b.update(i, StatusSkipped, "", "batch "+name+" exceeded")
+}
+
+// finish marks the batch as complete and wakes all subscribers, whose
+// channels are closed once they have sent the remaining events
+func (b *Batch) finish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.done = true
+	b.FinishedAt = time.Now()
+	b.wakeSubscribers()
+	clear(b.subscribers)
+}
+
+// Status returns a snapshot of the batch's progress
+func (b *Batch) Status() BatchStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BatchStatus{
+		ID:        b.ID,
+		CreatedAt: b.CreatedAt,
+		Done:      b.done,
+		Partial:   b.partial,
+		Total:     len(b.items),
+		Items:     append([]Item(nil), b.items...),
+	}
+	status.Bytes, status.ByteBudget = b.written, byteBudget
+	if b.done {
+		finishedAt := b.FinishedAt
+		status.FinishedAt = &finishedAt
+	}
+	for _, item := range b.items {
+		switch item.Status {
+		case StatusDone:
+			status.Completed++
+		case StatusFailed:
+			status.Failed++
+		case StatusSkipped:
+			status.Skipped++
+		}
+	}
+	return status
+}
+
+// Subscribe returns the events emitted so far and a channel that receives
+// subsequent events. Events are read from the batch's log in order, so a
+// slow subscriber receives every event rather than losing those emitted
+// while it lagged. The channel is closed once the batch has finished and
+// all its events were received; the returned cancel func must be called
+// when the subscriber goes away.
+func (b *Batch) Subscribe() ([]Event, <-chan Event, func()) {
+	b.mu.Lock()
+	past := append([]Event(nil), b.events...)
+	ch := make(chan Event)
+	if b.done {
+		b.mu.Unlock()
+		close(ch)
+		return past, ch, func() {}
+	}
+	wake := make(chan struct{}, 1)
+	b.subscribers[wake] = struct{}{}
+	b.mu.Unlock()
+
+	stop := make(chan struct{})
+	go b.forward(len(past), wake, stop, ch)
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			close(stop)
+			b.mu.Lock()
+			delete(b.subscribers, wake)
+			b.mu.Unlock()
+		})
+	}
+	return past, ch, cancel
+}
+
+// forward sends the events from index next on to ch as they are added,
+// waiting on wake for more, until the batch finishes or stop is closed
+func (b *Batch) forward(next int, wake, stop <-chan struct{}, ch chan<- Event) {
+	defer close(ch)
+	for {
+		b.mu.Lock()
+		pending := append([]Event(nil), b.events[next:]...)
+		done := b.done
+		b.mu.Unlock()
+
+		for _, event := range pending {
+			select {
+			case ch <- event:
+			case <-stop:
+				return
+			}
+		}
+		next += len(pending)
+		if done {
+			return
+		}
+
+		select {
+		case <-wake:
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package jobs
+
+import (
+	"archive-lite/models"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestBatch returns a batch of urls that hasn't started
+func newTestBatch(urls []string) *Batch {
+	b := &Batch{CreatedAt: time.Now(), items: make([]Item, len(urls)), subscribers: make(map[chan struct{}]struct{})}
+	for i, u := range urls {
+		b.items[i] = Item{URL: u, Status: StatusQueued}
+	}
+	return b
+}
+
+// hundredByteArchive returns an ArchiveFunc whose entries each have a
+// 100-byte page, reported as stored unless unchanged says otherwise
+func hundredByteArchive(t *testing.T, unchanged func(rawURL string) bool) ArchiveFunc {
+	dir := t.TempDir()
+	return func(rawURL string) (*models.ArchiveEntry, bool, error) {
+		path := filepath.Join(dir, strings.ReplaceAll(rawURL[len("https://"):], "/", "_")+".html")
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", 100)), 0644); err != nil {
+			return nil, false, err
+		}
+		return &models.ArchiveEntry{ID: rawURL, StoragePath: path}, !unchanged(rawURL), nil
+	}
+}
+
+func TestBatchStopsAtByteBudget(t *testing.T) {
+	b := newTestBatch([]string{
+		"https://a.example/1", "https://a.example/2", "https://a.example/3", "https://a.example/4",
+	})
+	archive := hundredByteArchive(t, func(string) bool { return false })
+
+	orig := byteBudget
+	defer func() { byteBudget = orig }()
+	byteBudget = 150
+	b.run(1, archive)
+
+	status := b.Status()
+	if status.Completed != 2 || status.Skipped != 2 {
+		t.Errorf("completed %d, skipped %d; want 2 and 2", status.Completed, status.Skipped)
+	}
+	if !status.Partial {
+		t.Error("batch not marked partial")
+	}
+	if status.Bytes != 200 || status.ByteBudget != 150 {
+		t.Errorf("bytesWritten %d, byteBudget %d; want 200 and 150", status.Bytes, status.ByteBudget)
+	}
+	if item := status.Items[3]; item.Error != "batch byte budget exceeded" {
+		t.Errorf("skipped item error = %q", item.Error)
+	}
+}
+
+// TestBatchByteBudgetIgnoresUnchanged checks that snapshots returned
+// unchanged, which store nothing, don't count toward the byte budget
+func TestBatchStopsAtTimeBudget(t *testing.T) {
+	b := newTestBatch([]string{"https://a.example/1", "https://b.example/2", "https://c.example/3"})
+	slowArchive := func(rawURL string) (*models.ArchiveEntry, bool, error) {
+		time.Sleep(100 * time.Millisecond)
+		return &models.ArchiveEntry{ID: rawURL}, false, nil
+	}
+
+	orig := budget
+	defer func() { budget = orig }()
+	budget = 50 * time.Millisecond
+	b.run(1, slowArchive)
+
+	status := b.Status()
+	if status.Completed != 1 || status.Skipped != 2 {
+		t.Errorf("completed %d, skipped %d; want 1 and 2", status.Completed, status.Skipped)
+	}
+	if !status.Partial || !status.Done {
+		t.Errorf("partial %v, done %v; want both", status.Partial, status.Done)
+	}
+	for _, item := range status.Items[1:] {
+		if item.Status != StatusSkipped || item.Error != "batch time budget exceeded" {
+			t.Errorf("item %s: status %s, error %q; want skipped for the time budget", item.URL, item.Status, item.Error)
+		}
+	}
+}
+
+func TestBatchByteBudgetIgnoresUnchanged(t *testing.T) {
+	b := newTestBatch([]string{
+		"https://a.example/same-1", "https://a.example/same-2", "https://a.example/same-3", "https://a.example/new",
+	})
+	archive := hundredByteArchive(t, func(rawURL string) bool {
+		return strings.Contains(rawURL, "/same-")
+	})
+
+	orig := byteBudget
+	defer func() { byteBudget = orig }()
+	byteBudget = 150
+	b.run(1, archive)
+
+	status := b.Status()
+	if status.Completed != 4 || status.Skipped != 0 || status.Partial {
+		t.Errorf("completed %d, skipped %d, partial %v; want 4, 0 and false", status.Completed, status.Skipped, status.Partial)
+	}
+	if status.Bytes != 100 {
+		t.Errorf("bytesWritten %d, want 100", status.Bytes)
+	}
+}
+
+// TestSubscribeReceivesEveryEvent checks that a subscriber that doesn't read
+// while the batch runs still receives every event, in order, once it does
+func TestSubscribeReceivesEveryEvent(t *testing.T) {
+	urls := make([]string, 100)
+	for i := range urls {
+		urls[i] = "https://a.example/" + strconv.Itoa(i)
+	}
+	b := newTestBatch(urls)
+	past, events, cancel := b.Subscribe()
+	defer cancel()
+	if len(past) != 0 {
+		t.Fatalf("past events before the batch ran: %d", len(past))
+	}
+
+	// Archiving and done for every URL, many more than a channel buffer
+	b.run(4, hundredByteArchive(t, func(string) bool { return false }))
+
+	var received []Event
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				done = true
+				break
+			}
+			received = append(received, event)
+		case <-timeout:
+			t.Fatalf("channel not closed after %d events", len(received))
+		}
+	}
+
+	b.mu.Lock()
+	want := append([]Event(nil), b.events...)
+	b.mu.Unlock()
+	if len(received) != 2*len(urls) || len(received) != len(want) {
+		t.Fatalf("received %d events, want %d", len(received), 2*len(urls))
+	}
+	for i := range want {
+		if received[i] != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, received[i], want[i])
+		}
+	}
+}
+
+func TestEvictFinished(t *testing.T) {
+	defer func(old map[string]*Batch) { batches = old }(batches)
+	now := time.Now()
+	old := newTestBatch(nil)
+	old.done, old.FinishedAt = true, now.Add(-2*time.Hour)
+	recent := newTestBatch(nil)
+	recent.done, recent.FinishedAt = true, now.Add(-time.Minute)
+	running := newTestBatch([]string{"https://a.example/1"})
+	batches = map[string]*Batch{"old": old, "recent": recent, "running": running}
+
+	if evicted, remaining := EvictFinished(time.Hour); evicted != 1 || remaining != 2 {
+		t.Errorf("EvictFinished(1h) = %d, %d; want 1, 2", evicted, remaining)
+	}
+	if _, ok := Get("old"); ok {
+		t.Error("batch finished 2 hours ago was kept")
+	}
+	if evicted, remaining := EvictFinished(0); evicted != 1 || remaining != 1 {
+		t.Errorf("EvictFinished(0) = %d, %d; want 1, 1", evicted, remaining)
+	}
+	if _, ok := Get("running"); !ok {
+		t.Error("running batch was evicted")
+	}
+}
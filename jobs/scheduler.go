@@ -0,0 +1,90 @@
+package jobs
+
+import (
+	"archive-lite/config"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// perHost is the number of URLs of one host a batch archives at the same time
+// (ARCHIVE_BATCH_PER_HOST); zero means no per-host limit
+var perHost = perHostFromEnv()
+
+// perHostFromEnv reads ARCHIVE_BATCH_PER_HOST, defaulting to 1
+func perHostFromEnv() int {
+	v := config.Getenv("ARCHIVE_BATCH_PER_HOST")
+	if v == "" {
+		return 1
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Printf("Invalid ARCHIVE_BATCH_PER_HOST '%s', archiving one URL per host at a time", v)
+		return 1
+	}
+	return n
+}
+
+// hostScheduler hands out the indexes of a batch's items to workers in
+// order, skipping over items whose host already has perHost archives in
+// flight. URLs of different hosts run in parallel while URLs of a busy host
+// wait for it, instead of tying up workers that could serve other hosts.
+type hostScheduler struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	pending  []int    // Indexes not yet handed out, in batch order
+	hosts    []string // Host of each item
+	inFlight map[string]int
+	limit    int
+}
+
+func newHostScheduler(urls []string, limit int) *hostScheduler {
+	s := &hostScheduler{
+		pending:  make([]int, len(urls)),
+		hosts:    make([]string, len(urls)),
+		inFlight: make(map[string]int),
+		limit:    limit,
+	}
+	s.cond = sync.NewCond(&s.mu)
+	for i, u := range urls {
+		s.pending[i] = i
+		if parsed, err := url.Parse(u); err == nil {
+			s.hosts[i] = strings.ToLower(parsed.Hostname())
+		}
+	}
+	return s
+}
+
+// next returns the first pending item whose host has room, waiting for one
+// to finish if every pending item's host is busy. It returns false once all
+// items have been handed out.
+func (s *hostScheduler) next() (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.pending) > 0 {
+		for p, i := range s.pending {
+			host := s.hosts[i]
+			if s.limit > 0 && s.inFlight[host] >= s.limit {
+				continue
+			}
+			s.pending = append(s.pending[:p], s.pending[p+1:]...)
+			s.inFlight[host]++
+			return i, true
+		}
+		s.cond.Wait()
+	}
+	return 0, false
+}
+
+// done releases the host slot taken by item i
+func (s *hostScheduler) done(i int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	host := s.hosts[i]
+	if s.inFlight[host]--; s.inFlight[host] <= 0 {
+		delete(s.inFlight, host)
+	}
+	s.cond.Broadcast()
+}
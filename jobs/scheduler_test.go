@@ -0,0 +1,62 @@
+package jobs
+
+import (
+	"archive-lite/models"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatchLimitsArchivesPerHost(t *testing.T) {
+	urls := []string{
+		"https://a.example/1", "https://a.example/2", "https://a.example/3",
+		"https://b.example/1", "https://b.example/2", "https://b.example/3",
+	}
+	b := &Batch{CreatedAt: time.Now(), items: make([]Item, len(urls))}
+	for i, u := range urls {
+		b.items[i] = Item{URL: u, Status: StatusQueued}
+	}
+
+	var mu sync.Mutex
+	inFlight := make(map[string]int)
+	maxInFlight, maxHosts := 0, 0
+	archive := func(rawURL string) (*models.ArchiveEntry, bool, error) {
+		u, _ := url.Parse(rawURL)
+		mu.Lock()
+		inFlight[u.Host]++
+		if inFlight[u.Host] > maxInFlight {
+			maxInFlight = inFlight[u.Host]
+		}
+		if len(inFlight) > maxHosts {
+			maxHosts = len(inFlight)
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		if inFlight[u.Host]--; inFlight[u.Host] == 0 {
+			delete(inFlight, u.Host)
+		}
+		mu.Unlock()
+		return &models.ArchiveEntry{ID: rawURL}, true, nil
+	}
+
+	orig := perHost
+	defer func() { perHost = orig }()
+	perHost = 1
+	b.run(4, archive)
+
+	if maxInFlight != 1 {
+		t.Errorf("max archives in flight for one host = %d, want 1", maxInFlight)
+	}
+	if maxHosts != 2 {
+		t.Errorf("max hosts archived at once = %d, want 2", maxHosts)
+	}
+	for i, item := range b.items {
+		if item.Status != StatusDone {
+			t.Errorf("item %d status = %s, want %s", i, item.Status, StatusDone)
+		}
+	}
+}
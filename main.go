@@ -1,18 +1,28 @@
 package main
 
 import (
+	"archive-lite/config"
 	"archive-lite/database"
 	"archive-lite/handlers" // Import handlers
 	"archive-lite/storage"
 	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger" // Optional: add logger
+	"github.com/gofiber/fiber/v2/middleware/recover"
 )
 
 func main() {
+	cfg := config.FromEnv()
+	storage.Configure(cfg)
+
 	// Initialize Database
-	_, err := database.Init()
+	_, err := database.Init(cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
@@ -27,11 +37,30 @@ func main() {
 	app := fiber.New()
 
 	// Middleware
-	app.Use(logger.New()) // Add basic request logging
+	app.Use(recover.New(recover.Config{EnableStackTrace: true})) // Turn handler panics into 500s with a logged stack trace
+	app.Use(logger.New())                                        // Add basic request logging
 
 	// 静的ファイル配信: WebUIとアーカイブデータ
 	app.Static("/webui.html", "./webui.html")
-	app.Static("/data", "./data")
+
+	// Archived pages reference their assets under /data/assets, so those are
+	// always served. Raw HTML and screenshots are only reachable by ID through
+	// the API unless ARCHIVE_EXPOSE_DATA_DIR is set. Directory listings are
+	// never served, so stored UUIDs can't be enumerated.
+	dataStatic := fiber.Static{Browse: false}
+	if exposeDataDir, _ := strconv.ParseBool(config.Getenv("ARCHIVE_EXPOSE_DATA_DIR")); exposeDataDir {
+		app.Static("/data", cfg.DataDir, dataStatic)
+	} else {
+		app.Static("/data/assets", cfg.AssetsDir, dataStatic)
+	}
+	// Assets bundled per entry (ARCHIVE_BUNDLE_ASSETS) are not loose files
+	app.Get("/data/assets/:name", handlers.GetBundledAsset)
+
+	// Stable per-entry mount serving each archive with relative asset paths
+	if viewMount, _ := strconv.ParseBool(config.Getenv("ARCHIVE_VIEW_MOUNT")); viewMount {
+		app.Get("/view/:id", handlers.ViewArchive)
+		app.Get("/view/:id/*", handlers.ViewArchive)
+	}
 
 	// Setup Routes
 	handlers.SetupRoutes(app) // Configure API routes
@@ -41,6 +70,36 @@ func main() {
 		return c.SendString("Archive-Lite API is running. Use /api/archive endpoints.")
 	})
 
+	// Regenerate expired screenshots of entries with a ScreenshotTTL
+	storage.StartScreenshotRefresh(database.DB)
+
+	// Periodically persist cookies (no-op unless ARCHIVE_COOKIE_JAR_PATH is set)
+	go func() {
+		for range time.Tick(5 * time.Minute) {
+			if err := storage.SaveCookieJar(); err != nil {
+				log.Printf("Failed to save cookie jar: %v", err)
+			}
+		}
+	}()
+
+	// Shut down gracefully on SIGINT/SIGTERM so state can be saved
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+		<-sigChan
+		log.Println("Shutting down server...")
+		if err := app.Shutdown(); err != nil {
+			log.Printf("Error during server shutdown: %v", err)
+		}
+	}()
+
 	log.Println("Starting server on port 3000...")
-	log.Fatal(app.Listen(":3000"))
+	if err := app.Listen(":3000"); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := storage.SaveCookieJar(); err != nil {
+		log.Printf("Failed to save cookie jar: %v", err)
+	}
+	log.Println("Server stopped.")
 }
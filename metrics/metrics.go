@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// durationSamples is the number of recent operation durations kept for percentiles
+const durationSamples = 1000
+
+var (
+	startedAt = time.Now()
+
+	archivesOK         atomic.Int64
+	archivesFailed     atomic.Int64
+	archivesUnchanged  atomic.Int64
+	refetchesOK        atomic.Int64
+	refetchesFailed    atomic.Int64
+	refetchesUnchanged atomic.Int64
+	assetsStored       atomic.Int64
+	assetsFailed       atomic.Int64
+	assetBytes         atomic.Int64
+	htmlBytes          atomic.Int64
+
+	durationsMu    sync.Mutex
+	durations      = make([]int64, durationSamples) // Ring buffer of durations in milliseconds
+	durationsNext  int
+	durationsCount int
+)
+
+// OperationCounts counts successful and failed operations
+type OperationCounts struct {
+	OK        int64 `json:"ok"`
+	Failed    int64 `json:"failed"`
+	Unchanged int64 `json:"unchanged"` // Only-if-changed operations that stored nothing
+}
+
+// AssetCounts summarizes asset downloads
+type AssetCounts struct {
+	Stored int64 `json:"stored"`
+	Failed int64 `json:"failed"`
+	Bytes  int64 `json:"bytes"`
+}
+
+// DurationStats summarizes the durations of recent archive and refetch operations
+type DurationStats struct {
+	Samples int   `json:"samples"`
+	AvgMs   int64 `json:"avgMs"`
+	P50Ms   int64 `json:"p50Ms"`
+	P90Ms   int64 `json:"p90Ms"`
+	P99Ms   int64 `json:"p99Ms"`
+	MaxMs   int64 `json:"maxMs"`
+}
+
+// Snapshot is a point-in-time copy of the counters
+type Snapshot struct {
+	StartedAt     time.Time       `json:"startedAt"`
+	UptimeSeconds int64           `json:"uptimeSeconds"`
+	Archives      OperationCounts `json:"archives"`
+	Refetches     OperationCounts `json:"refetches"`
+	Assets        AssetCounts     `json:"assets"`
+	HTMLBytes     int64           `json:"htmlBytes"`
+	Durations     DurationStats   `json:"durations"`
+}
+
+// RecordOperation counts an archive or refetch operation and its duration
+func RecordOperation(action string, started time.Time, err error) {
+	ok, failed := &archivesOK, &archivesFailed
+	if action == "refetch" {
+		ok, failed = &refetchesOK, &refetchesFailed
+	}
+	if err != nil {
+		failed.Add(1)
+	} else {
+		ok.Add(1)
+	}
+	recordDuration(started)
+}
+
+// RecordUnchanged counts an only-if-changed archive or refetch operation that
+// found the content unchanged, and its duration
+func RecordUnchanged(action string, started time.Time) {
+	if action == "refetch" {
+		refetchesUnchanged.Add(1)
+	} else {
+		archivesUnchanged.Add(1)
+	}
+	recordDuration(started)
+}
+
+// recordDuration adds the duration of an operation to the percentile samples
+func recordDuration(started time.Time) {
+	durationsMu.Lock()
+	defer durationsMu.Unlock()
+	durations[durationsNext] = time.Since(started).Milliseconds()
+	durationsNext = (durationsNext + 1) % len(durations)
+	if durationsCount < len(durations) {
+		durationsCount++
+	}
+}
+
+// RecordCapture counts the stored HTML and asset downloads of one capture
+func RecordCapture(pageBytes int64, storedAssets, failedAssets int, storedAssetBytes int64) {
+	htmlBytes.Add(pageBytes)
+	assetsStored.Add(int64(storedAssets))
+	assetsFailed.Add(int64(failedAssets))
+	assetBytes.Add(storedAssetBytes)
+}
+
+// Current returns a snapshot of the counters
+func Current() Snapshot {
+	return Snapshot{
+		StartedAt:     startedAt,
+		UptimeSeconds: int64(time.Since(startedAt).Seconds()),
+		Archives:      OperationCounts{OK: archivesOK.Load(), Failed: archivesFailed.Load(), Unchanged: archivesUnchanged.Load()},
+		Refetches:     OperationCounts{OK: refetchesOK.Load(), Failed: refetchesFailed.Load(), Unchanged: refetchesUnchanged.Load()},
+		Assets: AssetCounts{
+			Stored: assetsStored.Load(),
+			Failed: assetsFailed.Load(),
+			Bytes:  assetBytes.Load(),
+		},
+		HTMLBytes: htmlBytes.Load(),
+		Durations: durationStats(),
+	}
+}
+
+// durationStats computes statistics over the recorded durations
+func durationStats() DurationStats {
+	durationsMu.Lock()
+	sorted := append([]int64(nil), durations[:durationsCount]...)
+	durationsMu.Unlock()
+
+	stats := DurationStats{Samples: len(sorted)}
+	if len(sorted) == 0 {
+		return stats
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total int64
+	for _, d := range sorted {
+		total += d
+	}
+	percentile := func(p int) int64 {
+		// Nearest-rank method
+		rank := (p*len(sorted) + 99) / 100
+		return sorted[rank-1]
+	}
+	stats.AvgMs = total / int64(len(sorted))
+	stats.P50Ms = percentile(50)
+	stats.P90Ms = percentile(90)
+	stats.P99Ms = percentile(99)
+	stats.MaxMs = sorted[len(sorted)-1]
+	return stats
+}
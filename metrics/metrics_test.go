@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// resetDurations empties the duration samples for the test and restores them after
+func resetDurations(t *testing.T) {
+	durationsMu.Lock()
+	origDurations, origNext, origCount := durations, durationsNext, durationsCount
+	durations, durationsNext, durationsCount = make([]int64, durationSamples), 0, 0
+	durationsMu.Unlock()
+	t.Cleanup(func() {
+		durationsMu.Lock()
+		durations, durationsNext, durationsCount = origDurations, origNext, origCount
+		durationsMu.Unlock()
+	})
+}
+
+func TestRecordConcurrently(t *testing.T) {
+	resetDurations(t)
+	before := Current()
+
+	const goroutines, perGoroutine = 8, 100
+	failure := errors.New("failed")
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perGoroutine; i++ {
+				RecordOperation("archive", time.Now(), nil)
+				RecordOperation("archive", time.Now(), failure)
+				RecordOperation("refetch", time.Now(), nil)
+				RecordUnchanged("archive", time.Now())
+				RecordUnchanged("refetch", time.Now())
+				RecordCapture(100, 2, 1, 50)
+			}
+		}()
+	}
+	wg.Wait()
+
+	after := Current()
+	n := int64(goroutines * perGoroutine)
+	checks := []struct {
+		name        string
+		got, wanted int64
+	}{
+		{"archives.ok", after.Archives.OK - before.Archives.OK, n},
+		{"archives.failed", after.Archives.Failed - before.Archives.Failed, n},
+		{"archives.unchanged", after.Archives.Unchanged - before.Archives.Unchanged, n},
+		{"refetches.ok", after.Refetches.OK - before.Refetches.OK, n},
+		{"refetches.failed", after.Refetches.Failed - before.Refetches.Failed, 0},
+		{"refetches.unchanged", after.Refetches.Unchanged - before.Refetches.Unchanged, n},
+		{"assets.stored", after.Assets.Stored - before.Assets.Stored, 2 * n},
+		{"assets.failed", after.Assets.Failed - before.Assets.Failed, n},
+		{"assets.bytes", after.Assets.Bytes - before.Assets.Bytes, 50 * n},
+		{"htmlBytes", after.HTMLBytes - before.HTMLBytes, 100 * n},
+	}
+	for _, c := range checks {
+		if c.got != c.wanted {
+			t.Errorf("%s grew by %d, want %d", c.name, c.got, c.wanted)
+		}
+	}
+	// Five timed operations per iteration; the ring buffer keeps the latest
+	if after.Durations.Samples != min(int(5*n), durationSamples) {
+		t.Errorf("duration samples = %d, want %d", after.Durations.Samples, min(int(5*n), durationSamples))
+	}
+}
+
+func TestDurationStats(t *testing.T) {
+	resetDurations(t)
+	if stats := durationStats(); stats != (DurationStats{}) {
+		t.Errorf("no samples: %+v, want zero stats", stats)
+	}
+
+	durationsMu.Lock()
+	for i := 0; i < 100; i++ {
+		// Recorded out of order; stats sort them
+		durations[i] = int64(100 - i)
+	}
+	durationsCount, durationsNext = 100, 100
+	durationsMu.Unlock()
+
+	want := DurationStats{Samples: 100, AvgMs: 50, P50Ms: 50, P90Ms: 90, P99Ms: 99, MaxMs: 100}
+	if stats := durationStats(); stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+}
@@ -1,17 +1,58 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // ArchiveEntry represents an archived URL in the database
 type ArchiveEntry struct {
-	ID             string    `gorm:"primaryKey;type:varchar(36)"` // Random UUID as primary key
-	URL            string    `gorm:"index;not null"`              // The original URL that was archived
-	Title          string    // Optional: Title of the webpage
-	StoragePath    string    `gorm:"not null"` // Path to the stored raw HTML content
-	ScreenshotPath string    // Optional: Path to the stored screenshot
-	ArchivedAt     time.Time `gorm:"not null"` // Timestamp when the archiving process was completed for this entry
-	CreatedAt      time.Time // Creation timestamp
-	UpdatedAt      time.Time // Update timestamp
+	ID             string              `gorm:"primaryKey;type:varchar(36)"` // Random UUID as primary key
+	URL            string              `gorm:"index;not null"`              // The original URL that was archived
+	CanonicalURL   string              `gorm:"index"`                       // Normalized URL used to detect duplicates/snapshots of the same page
+	SeriesID       string              `gorm:"index"`                       // Optional: ID of the source entry this one was archived from (e.g. a followed feed)
+	RequestURL     string              // Optional: URL as requested, before shortener/redirect resolution
+	Fragment       string              // Optional: fragment of the requested URL (without "#"), kept out of URL and CanonicalURL
+	Title          string              // Optional: Title of the webpage
+	Lang           string              `gorm:"index"` // Optional: BCP 47 language tag of the page, from <html lang> or detected from its text
+	Dir            string              // Optional: text direction of the page (ltr or rtl)
+	StoragePath    string              `gorm:"not null"` // Path to the stored raw HTML content
+	ScreenshotPath string              // Optional: Path to the stored screenshot
+	ThumbnailPath  string              // Optional: Path to a small JPEG thumbnail of the screenshot
+	ScreenshotAt   time.Time           // When the stored screenshot was captured (zero if none)
+	ScreenshotTTL  int64               // Optional: seconds after ScreenshotAt when the screenshot expires and is regenerated (0 = never)
+	TextPath       string              // Optional: Path to the stored plain-text rendition
+	MHTMLPath      string              // Optional: Path to the stored MHTML (single-file) capture
+	ContentHash    string              // SHA-256 (hex) of the stored HTML, used for integrity checks
+	TextHash       string              // SHA-256 (hex) of the page's extracted text, compared by onlyIfChanged
+	StructuredData []json.RawMessage   `gorm:"serializer:json"` // JSON-LD blocks found in the page, in document order
+	Device         DeviceProfile       `gorm:"serializer:json"` // Device profile used for the fetch and screenshot
+	HTTPStatus     int                 `gorm:"default:200"`     // Status code of the archived page's response
+	SoftError      bool                // Whether the page was served with 200 but looks like a not-found page (soft 404)
+	ContentType    string              // Media type of the archived page's response (e.g. text/html), without parameters
+	Headers        map[string][]string `gorm:"serializer:json"` // Headers of the archived page's response; Set-Cookie values are redacted unless ARCHIVE_SET_COOKIE=full
+	Negotiation    map[string]string   `gorm:"serializer:json"` // Content-negotiation request headers sent for the page (User-Agent, Accept, Accept-Language), replayed on refetch
+	Referer        string              // Optional: Referer header sent with the page request, replayed on refetch
+	Rendered       bool                // Whether the stored HTML is the DOM rendered by headless Chrome rather than the served HTML
+	FetchStrategy  string              // Fetch strategy that produced the stored HTML: static or browser
+	Challenge      string              // Optional: why the static fetch was taken for a JavaScript challenge, passed in headless Chrome
+	AttachmentName string              // Optional: suggested file name when the URL served a download (Content-Disposition: attachment)
+	FaviconURL     string              // Optional: URL of the page's icon, stored as an asset when it could be fetched
+	Width          int64               // Rendered page's scroll width in CSS pixels (0 if not measured in Chrome)
+	Height         int64               // Rendered page's scroll height in CSS pixels, before screenshot clamping (0 if not measured)
+	ArchivedAt     time.Time           `gorm:"not null"` // Timestamp when the archiving process was completed for this entry
+	CreatedAt      time.Time           // Creation timestamp
+	UpdatedAt      time.Time           // Update timestamp
+}
+
+// BeforeCreate assigns a random UUID primary key to entries created without
+// one. Archived entries already carry the UUID used in their file names.
+func (e *ArchiveEntry) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.NewString()
+	}
+	return nil
 }
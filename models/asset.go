@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// Asset records the fetch of a single page asset for an archive entry
+type Asset struct {
+	ID            uint      `gorm:"primaryKey"`
+	EntryID       string    `gorm:"index;type:varchar(36);not null"` // ID of the ArchiveEntry the asset belongs to
+	URL           string    `gorm:"not null"`                        // The asset URL as referenced by the page
+	FinalURL      string    // The URL after following redirects
+	StatusCode    int       // HTTP status of the final response (0 if no response)
+	ContentType   string    // Content-Type of the response
+	Size          int64     // Number of bytes stored (0 if not stored)
+	FileName      string    // Stored file name under data/assets (empty if not stored)
+	Error         string    // Why the asset was not stored, if it wasn't
+	ErrorBodyFile string    // Stored file name of the error response body under data/assets (empty if not kept)
+	DurationMs    int64     // Time spent fetching the asset
+	FetchedAt     time.Time `gorm:"not null"` // When the fetch started
+	CreatedAt     time.Time // Creation timestamp
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditRecord is one entry of the append-only, hash-chained audit log of
+// archive operations. Each record's Hash covers its fields and the previous
+// record's Hash, so altering or removing a record breaks the chain.
+type AuditRecord struct {
+	ID          uint      `gorm:"primaryKey"`
+	Action      string    `gorm:"not null"` // archive, refetch, update-url or prune
+	EntryID     string    `gorm:"index;type:varchar(36);not null"`
+	URL         string    // URL of the entry after the operation
+	ContentHash string    // ContentHash of the entry after the operation
+	RecordedAt  time.Time `gorm:"not null"` // When the operation was recorded (UTC)
+	PrevHash    string    // Hash of the previous record; empty for the first record
+	Hash        string    `gorm:"uniqueIndex;not null"` // SHA-256 (hex) of this record, see storage.auditHash
+}
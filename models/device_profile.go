@@ -0,0 +1,12 @@
+package models
+
+// DeviceProfile describes the device an archive was captured as. It is stored
+// as JSON on ArchiveEntry.
+type DeviceProfile struct {
+	Name      string  // desktop, mobile, tablet or custom
+	Width     int64   // Viewport width in CSS pixels
+	Height    int64   // Viewport height in CSS pixels
+	DPR       float64 // Device pixel ratio
+	Mobile    bool    // Whether the viewport emulates a mobile device (touch, meta viewport)
+	UserAgent string  // User-Agent sent for the page, its assets and the screenshot
+}
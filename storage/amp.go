@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// preferCanonical makes ArchiveURL archive the canonical page instead of an AMP page
+var preferCanonical = envBool("ARCHIVE_PREFER_CANONICAL", false)
+
+// detectAMP reports whether the document is an AMP page (<html amp> or <html ⚡>)
+// and returns the absolute URL of its <link rel="canonical">, if any
+func detectAMP(htmlContent, baseURL string) (bool, string) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return false, ""
+	}
+
+	isAMP := false
+	canonicalURL := ""
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "html":
+				for _, attr := range n.Attr {
+					if attr.Key == "amp" || attr.Key == "⚡" {
+						isAMP = true
+					}
+				}
+			case "link":
+				if canonicalURL == "" && hasRel(n, "canonical") {
+					canonicalURL = resolveURL(baseURL, getAttr(n, "href"))
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	return isAMP, canonicalURL
+}
+
+// hasRel reports whether a <link> element carries the given rel token
+func hasRel(n *html.Node, rel string) bool {
+	for _, r := range linkRelTypes(n) {
+		if r == rel {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"archive-lite/tests"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDetectAMP(t *testing.T) {
+	cases := []struct {
+		name, page    string
+		wantAMP       bool
+		wantCanonical string
+	}{
+		{"amp attribute", `<html amp><head><link rel="canonical" href="/article"></head></html>`, true, "https://example.com/article"},
+		{"lightning attribute", `<html ⚡><head><link rel="canonical" href="https://example.com/a"></head></html>`, true, "https://example.com/a"},
+		{"no canonical", `<html amp><head></head></html>`, true, ""},
+		{"regular page", `<html><head><link rel="canonical" href="/article"></head></html>`, false, "https://example.com/article"},
+	}
+	for _, c := range cases {
+		isAMP, canonical := detectAMP(c.page, "https://example.com/amp/article")
+		if isAMP != c.wantAMP || canonical != c.wantCanonical {
+			t.Errorf("%s: detectAMP = %v, %q, want %v, %q", c.name, isAMP, canonical, c.wantAMP, c.wantCanonical)
+		}
+	}
+}
+
+func TestArchivePrefersCanonicalOfAMPPage(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+
+	const referer = "https://news.example.org/"
+	var mu sync.Mutex
+	referers := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		referers[r.URL.Path] = r.Header.Get("Referer")
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/amp/article", "/amp/video":
+			canonical := strings.TrimPrefix(r.URL.Path, "/amp")
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<!DOCTYPE html><html amp><head><link rel="canonical" href="%s"></head><body>AMP version</body></html>`, canonical)
+		case "/article":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<!DOCTYPE html><html><head><title>Article</title></head><body>Canonical version</body></html>`)
+		case "/video":
+			w.Header().Set("Content-Type", "video/mp4")
+			fmt.Fprint(w, "not a page")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	origRaw, origAssets, origNoDelay, origScreenshots := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate, captureScreenshots
+	origPrefer := preferCanonical
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate, captureScreenshots = origNoDelay, origScreenshots
+		preferCanonical = origPrefer
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate, captureScreenshots, preferCanonical = true, false, true
+
+	ampURL, videoAMPURL := server.URL+"/amp/article", server.URL+"/amp/video"
+	t.Cleanup(func() { db.Where("request_url IN ?", []string{ampURL, videoAMPURL}).Delete(&models.ArchiveEntry{}) })
+
+	opts := DefaultArchiveOptions()
+	opts.Referer = referer
+	opts.AllowedMainTypes = defaultAllowedMainTypes
+
+	entry, err := ArchiveURLWithOptions(db, ampURL, opts)
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if entry.URL != server.URL+"/article" {
+		t.Errorf("URL = %q, want the canonical page", entry.URL)
+	}
+	if content, err := os.ReadFile(entry.StoragePath); err != nil || !strings.Contains(string(content), "Canonical version") {
+		t.Errorf("stored page = %q (%v), want the canonical page", content, err)
+	}
+	mu.Lock()
+	if got := referers["/article"]; got != referer {
+		t.Errorf("canonical page fetched with Referer %q, want the request's %q", got, referer)
+	}
+	mu.Unlock()
+
+	// A canonical document of a type the request doesn't allow is not swapped in
+	entry, err = ArchiveURLWithOptions(db, videoAMPURL, opts)
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if entry.URL != videoAMPURL {
+		t.Errorf("URL = %q, want the AMP page since its canonical is video/mp4", entry.URL)
+	}
+	if content, err := os.ReadFile(entry.StoragePath); err != nil || !strings.Contains(string(content), "AMP version") {
+		t.Errorf("stored page = %q (%v), want the AMP page", content, err)
+	}
+}
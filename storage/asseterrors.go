@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// storeAssetErrorBodies is the default for ArchiveOptions.StoreAssetErrorBodies
+// (ARCHIVE_STORE_ASSET_ERROR_BODIES)
+var storeAssetErrorBodies = envBool("ARCHIVE_STORE_ASSET_ERROR_BODIES", false)
+
+// maxAssetErrorBodyBytes caps the part of a non-200 asset response that is kept
+const maxAssetErrorBodyBytes = 64 << 10
+
+// assetErrorBodyName returns the file name an asset's error body is stored
+// under. It keeps the entry's asset file prefix, so it is bundled and pruned
+// with the entry's assets, but is never referenced by the rewritten page.
+func assetErrorBodyName(assetURL, entryUUID string) string {
+	return generateAssetFileName(assetURL, entryUUID) + ".error"
+}
+
+// writeAssetErrorBody stores the body of a failed asset fetch for debugging
+// and returns its file name
+func writeAssetErrorBody(result AssetDownloadResult, entryUUID string) (string, error) {
+	name := assetErrorBodyName(result.URL, entryUUID)
+	path := filepath.Join(settings.AssetsDir, name)
+	if err := writeFileAtomic(path, result.Response.ErrorBody, 0644); err != nil {
+		return "", fmt.Errorf("failed to save error body of asset '%s' to '%s': %w", result.URL, path, err)
+	}
+	return name, nil
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDownloadAssetsParallelStoresErrorBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "hotlinking denied", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	origRaw, origAssets, origNoDelay := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate = origNoDelay
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate = true
+
+	entryUUID := "00000000-0000-0000-0000-000000000000"
+	assetURL := server.URL + "/img/photo.jpg"
+
+	downloaded, records := downloadAssetsParallel([]string{assetURL}, entryUUID, 1, "", false)
+	if len(downloaded) != 0 || records[0].StatusCode != http.StatusForbidden || records[0].Error == "" {
+		t.Fatalf("failed asset not recorded: %+v", records)
+	}
+	if records[0].ErrorBodyFile != "" {
+		t.Errorf("error body kept by default: %q", records[0].ErrorBodyFile)
+	}
+
+	_, records = downloadAssetsParallel([]string{assetURL}, entryUUID, 1, "", true)
+	name := records[0].ErrorBodyFile
+	if name != assetErrorBodyName(assetURL, entryUUID) {
+		t.Fatalf("ErrorBodyFile = %q, want %q", name, assetErrorBodyName(assetURL, entryUUID))
+	}
+	body, err := os.ReadFile(filepath.Join(settings.AssetsDir, name))
+	if err != nil || string(body) != "hotlinking denied\n" {
+		t.Errorf("stored error body = %q, %v", body, err)
+	}
+	if _, err := os.Stat(filepath.Join(settings.AssetsDir, generateAssetFileName(assetURL, entryUUID))); err == nil {
+		t.Errorf("error body stored under the asset's own name")
+	}
+}
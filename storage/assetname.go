@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"mime"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// dispositionNames names stored assets with the extension of the file
+	// name in their Content-Disposition header, when there is one, instead of
+	// the one guessed from the URL (ARCHIVE_ASSET_DISPOSITION_NAMES)
+	dispositionNames = envBool("ARCHIVE_ASSET_DISPOSITION_NAMES", true)
+	// contentTypeNames names assets whose URL has no extension, or only the
+	// extension of a server script, after their Content-Type
+	// (ARCHIVE_ASSET_CONTENT_TYPE_NAMES)
+	contentTypeNames = envBool("ARCHIVE_ASSET_CONTENT_TYPE_NAMES", true)
+)
+
+// contentTypeExtensions maps the media types of common assets to the
+// extension they are stored with. mime.ExtensionsByType is not used since
+// its choice among several extensions (.jpe, .jpeg, .jpg) is not stable.
+var contentTypeExtensions = map[string]string{
+	"text/css":                 ".css",
+	"text/javascript":          ".js",
+	"application/javascript":   ".js",
+	"application/x-javascript": ".js",
+	"application/json":         ".json",
+	"image/png":                ".png",
+	"image/jpeg":               ".jpg",
+	"image/gif":                ".gif",
+	"image/webp":               ".webp",
+	"image/avif":               ".avif",
+	"image/svg+xml":            ".svg",
+	"image/bmp":                ".bmp",
+	"image/x-icon":             ".ico",
+	"image/vnd.microsoft.icon": ".ico",
+	"font/woff":                ".woff",
+	"font/woff2":               ".woff2",
+	"font/ttf":                 ".ttf",
+	"font/otf":                 ".otf",
+	"application/font-woff":    ".woff",
+	"application/font-woff2":   ".woff2",
+	"video/mp4":                ".mp4",
+	"video/webm":               ".webm",
+	"audio/mpeg":               ".mp3",
+	"audio/ogg":                ".ogg",
+	"text/vtt":                 ".vtt",
+}
+
+// scriptExtensions are URL extensions of server scripts, which say nothing
+// about the type of the asset they serve (e.g. /image.php?id=123)
+var scriptExtensions = map[string]bool{
+	".php": true, ".asp": true, ".aspx": true, ".ashx": true, ".jsp": true, ".cgi": true, ".pl": true,
+}
+
+// dispositionExtension returns the sanitized extension of the file name in a
+// Content-Disposition header value, or "" if there is none. Names in a
+// charset mime.ParseMediaType doesn't decode (e.g. an ISO-8859-1 filename*)
+// are percent-decoded as bytes: only the extension is used, and it must be
+// short and alphanumeric ASCII.
+func dispositionExtension(disposition string) string {
+	if disposition == "" {
+		return ""
+	}
+	name := ""
+	if _, params, err := mime.ParseMediaType(disposition); err == nil {
+		name = params["filename"]
+	}
+	if name == "" {
+		name = rawDispositionFileName(disposition)
+	}
+	return strings.ToLower(assetExtension(path.Ext(name)))
+}
+
+// rawDispositionFileName extracts the filename* or filename parameter of a
+// Content-Disposition value without decoding its charset
+func rawDispositionFileName(disposition string) string {
+	var plain string
+	for _, param := range strings.Split(disposition, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "filename*":
+			// charset'language'percent-encoded-name
+			if i := strings.LastIndex(value, "'"); i >= 0 {
+				value = value[i+1:]
+			}
+			if decoded, err := url.PathUnescape(value); err == nil {
+				return decoded
+			}
+		case "filename":
+			plain = value
+		}
+	}
+	return plain
+}
+
+// contentTypeExtension returns the extension assets of a Content-Type
+// header value are stored with, or "" for unknown types
+func contentTypeExtension(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return contentTypeExtensions[strings.ToLower(strings.TrimSpace(mediaType))]
+}
+
+// hasTypeExtension reports whether the path of assetURL ends in an
+// extension that tells the asset's type, i.e. one that isn't a server script's
+func hasTypeExtension(assetURL string) bool {
+	u, err := url.Parse(assetURL)
+	if err != nil {
+		return false
+	}
+	ext := strings.ToLower(assetExtension(path.Ext(u.Path)))
+	return ext != "" && !scriptExtensions[ext]
+}
+
+// assetFileNameFor returns the name an asset downloaded from assetURL is
+// stored under: generateAssetFileName, with the extension replaced by the
+// one of the response's Content-Disposition file name when dispositionNames
+// is set, or else by the one of its Content-Type when contentTypeNames is
+// set and the URL's own extension doesn't tell the type. The entry UUID and
+// the hash of the full URL, query included, keep the name unique either way.
+func assetFileNameFor(assetURL, entryUUID string, response assetResponse) string {
+	name := generateAssetFileName(assetURL, entryUUID)
+	var ext string
+	if dispositionNames {
+		ext = dispositionExtension(response.Disposition)
+	}
+	if ext == "" && contentTypeNames && !hasTypeExtension(assetURL) {
+		ext = contentTypeExtension(response.ContentType)
+	}
+	if ext == "" || ext == filepath.Ext(name) {
+		return name
+	}
+	renamed := strings.TrimSuffix(name, filepath.Ext(name)) + ext
+	if len(filepath.Join(settings.AssetsDir, renamed)) > maxPathBytes {
+		return name
+	}
+	return renamed
+}
+
+// assetNameFunc returns the local file name of an asset URL: the name it was
+// stored under according to names (asset URL to file name), falling back to
+// generateAssetFileName for assets not downloaded (yet)
+func assetNameFunc(names map[string]string, entryUUID string) func(string) string {
+	return func(assetURL string) string {
+		if name := names[assetURL]; name != "" {
+			return name
+		}
+		return generateAssetFileName(assetURL, entryUUID)
+	}
+}
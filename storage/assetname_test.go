@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"archive-lite/tests"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDispositionExtension(t *testing.T) {
+	cases := []struct {
+		disposition string
+		want        string
+	}{
+		{"", ""},
+		{"inline", ""},
+		{`attachment; filename="Inter.woff2"`, ".woff2"},
+		{`attachment; filename="REPORT.PDF"`, ".pdf"},
+		{`attachment; filename*=UTF-8''%E6%97%A5%E6%9C%AC.png`, ".png"},
+		// Not decoded by mime.ParseMediaType
+		{`attachment; filename*=ISO-8859-1''%E9t%E9.svg`, ".svg"},
+		{`attachment; filename="archive"`, ""},
+		{`attachment; filename="evil.p/hp"`, ""},
+	}
+	for _, tt := range cases {
+		if got := dispositionExtension(tt.disposition); got != tt.want {
+			t.Errorf("dispositionExtension(%q) = %q, want %q", tt.disposition, got, tt.want)
+		}
+	}
+}
+
+func TestArchiveNamesAssetsFromContentDisposition(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><head><link rel="stylesheet" href="/style.css"></head>`+
+				`<body><img src="/download?id=2"></body></html>`)
+		case "/style.css":
+			w.Header().Set("Content-Type", "text/css")
+			fmt.Fprint(w, `@font-face { font-family: Inter; src: url("/font?id=1") format("woff2"); }`)
+		case "/font":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Content-Disposition", `attachment; filename="Inter.woff2"`)
+			fmt.Fprint(w, "wOF2")
+		case "/download":
+			w.Header().Set("Content-Type", "image/svg+xml")
+			w.Header().Set("Content-Disposition", `inline; filename*=ISO-8859-1''%E9t%E9.svg`)
+			fmt.Fprint(w, `<svg xmlns="http://www.w3.org/2000/svg"/>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	origRaw, origAssets, origNoDelay, origScreenshots := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate, captureScreenshots
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate, captureScreenshots = origNoDelay, origScreenshots
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate, captureScreenshots = true, false
+
+	pageURL := server.URL + "/page"
+	t.Cleanup(func() { db.Where("request_url = ?", pageURL).Delete(&models.ArchiveEntry{}) })
+
+	entry, err := ArchiveURLWithOptions(db, pageURL, DefaultArchiveOptions())
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+
+	var assets []models.Asset
+	db.Where("entry_id = ?", entry.ID).Find(&assets)
+	names := storedAssetNames(assets)
+
+	font := names[server.URL+"/font?id=1"]
+	if !strings.HasPrefix(font, entry.ID+"_") || !strings.HasSuffix(font, ".woff2") {
+		t.Fatalf("font stored as %q, want %s_<hash>.woff2", font, entry.ID)
+	}
+	image := names[server.URL+"/download?id=2"]
+	if !strings.HasPrefix(image, entry.ID+"_") || !strings.HasSuffix(image, ".svg") {
+		t.Fatalf("image stored as %q, want %s_<hash>.svg", image, entry.ID)
+	}
+
+	page, err := os.ReadFile(entry.StoragePath)
+	if err != nil {
+		t.Fatalf("read page: %v", err)
+	}
+	if !strings.Contains(string(page), localAssetPrefix+image) {
+		t.Errorf("page does not reference %s:\n%s", image, page)
+	}
+	css, err := os.ReadFile(filepath.Join(settings.AssetsDir, names[server.URL+"/style.css"]))
+	if err != nil {
+		t.Fatalf("read stylesheet: %v", err)
+	}
+	if !strings.Contains(string(css), localAssetPrefix+font) {
+		t.Errorf("stylesheet does not reference %s:\n%s", font, css)
+	}
+}
+
+func TestArchiveNamesQueryOnlyAssetsFromContentType(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><body><img src="/image.php?id=123&amp;size=large">`+
+				`<img src="/image.php?id=123&amp;size=small"></body></html>`)
+		case "/image.php":
+			if r.URL.Query().Get("size") == "large" {
+				w.Header().Set("Content-Type", "image/png")
+				fmt.Fprint(w, "large")
+			} else {
+				w.Header().Set("Content-Type", "image/jpeg; charset=binary")
+				fmt.Fprint(w, "small")
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	origRaw, origAssets, origNoDelay, origScreenshots := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate, captureScreenshots
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate, captureScreenshots = origNoDelay, origScreenshots
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate, captureScreenshots = true, false
+
+	pageURL := server.URL + "/page"
+	t.Cleanup(func() { db.Where("request_url = ?", pageURL).Delete(&models.ArchiveEntry{}) })
+
+	entry, err := ArchiveURLWithOptions(db, pageURL, DefaultArchiveOptions())
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+
+	var assets []models.Asset
+	db.Where("entry_id = ?", entry.ID).Find(&assets)
+	names := storedAssetNames(assets)
+	page, err := os.ReadFile(entry.StoragePath)
+	if err != nil {
+		t.Fatalf("read page: %v", err)
+	}
+
+	large := names[server.URL+"/image.php?id=123&size=large"]
+	small := names[server.URL+"/image.php?id=123&size=small"]
+	if large == "" || small == "" || large == small {
+		t.Fatalf("stored names %q and %q, want two distinct names", large, small)
+	}
+	for name, want := range map[string]struct{ ext, body string }{large: {".png", "large"}, small: {".jpg", "small"}} {
+		if !strings.HasPrefix(name, entry.ID+"_") || filepath.Ext(name) != want.ext {
+			t.Errorf("stored as %q, want %s_<hash>%s", name, entry.ID, want.ext)
+		}
+		if content, err := os.ReadFile(filepath.Join(settings.AssetsDir, name)); err != nil || string(content) != want.body {
+			t.Errorf("%s holds %q (%v), want %q", name, content, err, want.body)
+		}
+		if !strings.Contains(string(page), localAssetPrefix+name) {
+			t.Errorf("page does not reference %s", name)
+		}
+	}
+}
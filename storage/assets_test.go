@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"crypto/md5"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// collidingAssetURLs returns two URLs under base whose MD5 hex digests share
+// their first 8 characters, the hash length asset file names used to have
+func collidingAssetURLs(t *testing.T, base string) (string, string) {
+	t.Helper()
+	seen := make(map[string]string)
+	for i := 0; i < 1<<22; i++ {
+		u := fmt.Sprintf("%s/asset-%d.css", base, i)
+		prefix := fmt.Sprintf("%x", md5.Sum([]byte(u)))[:8]
+		if other, ok := seen[prefix]; ok {
+			return other, u
+		}
+		seen[prefix] = u
+	}
+	t.Fatal("no colliding URLs found")
+	return "", ""
+}
+
+func TestGenerateAssetFileNameDistinguishesShortHashCollisions(t *testing.T) {
+	first, second := collidingAssetURLs(t, "https://example.com")
+
+	entryUUID := "00000000-0000-0000-0000-000000000000"
+	if generateAssetFileName(first, entryUUID) == generateAssetFileName(second, entryUUID) {
+		t.Fatalf("URLs %q and %q map to the same asset file name", first, second)
+	}
+}
+
+func TestDownloadAssetsParallelKeepsCollidingAssets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		fmt.Fprintf(w, "/* %s */ body { color: red; }", r.URL.Path)
+	}))
+	defer server.Close()
+
+	origRaw, origAssets, origNoDelay := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate = origNoDelay
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate = true
+
+	first, second := collidingAssetURLs(t, server.URL)
+	entryUUID := "00000000-0000-0000-0000-000000000000"
+	downloaded, _ := downloadAssetsParallel([]string{first, second}, entryUUID, 2, "", false)
+
+	if len(downloaded) != 2 {
+		t.Fatalf("expected 2 downloaded assets, got %d", len(downloaded))
+	}
+	for _, u := range []string{first, second} {
+		content, err := os.ReadFile(filepath.Join(settings.AssetsDir, downloaded[u]))
+		if err != nil {
+			t.Fatalf("reading asset for %q: %v", u, err)
+		}
+		parsed, _ := http.NewRequest("GET", u, nil)
+		want := fmt.Sprintf("/* %s */ body { color: red; }", parsed.URL.Path)
+		if string(content) != want {
+			t.Errorf("asset for %q was overwritten: got %q, want %q", u, content, want)
+		}
+	}
+}
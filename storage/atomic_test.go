@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestWriteFileAtomicConcurrentWritersSameHash(t *testing.T) {
+	dir := t.TempDir()
+	// Content-addressed names are shared by every archive holding the same asset
+	path := filepath.Join(dir, generateAssetFileName("https://example.com/static/app.js", "shared"))
+
+	const (
+		writers = 8
+		rounds  = 20
+		size    = 256 << 10
+	)
+	contents := make([][]byte, writers)
+	for i := range contents {
+		contents[i] = bytes.Repeat([]byte{byte('a' + i)}, size)
+	}
+
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	var partial error
+	var partialOnce sync.Once
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				data, err := os.ReadFile(path)
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+				if err != nil {
+					partialOnce.Do(func() { partial = err })
+					return
+				}
+				if len(data) != size || !bytes.Equal(data, bytes.Repeat(data[:1], size)) {
+					partialOnce.Do(func() {
+						partial = errors.New("reader observed a partially written or mixed file")
+					})
+					return
+				}
+			}
+		}()
+	}
+
+	var writersWG sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		writersWG.Add(1)
+		go func(w int) {
+			defer writersWG.Done()
+			for i := 0; i < rounds; i++ {
+				if err := writeFileAtomic(path, contents[w], 0644); err != nil {
+					t.Errorf("writeFileAtomic: %v", err)
+					return
+				}
+			}
+		}(w)
+	}
+	writersWG.Wait()
+	close(stop)
+	readers.Wait()
+
+	if partial != nil {
+		t.Fatal(partial)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != filepath.Base(path) {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("expected only %s to remain, found %v", filepath.Base(path), names)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("file mode = %v, want 0644", info.Mode().Perm())
+	}
+}
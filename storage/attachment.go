@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"archive-lite/config"
+	"archive-lite/metrics"
+	"archive-lite/models"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Policies for main responses sent with Content-Disposition: attachment
+// (ARCHIVE_ATTACHMENT_POLICY)
+const (
+	attachmentStore  = "store"  // Store the body verbatim and serve it back as a download
+	attachmentPage   = "page"   // Archive the body like any other page
+	attachmentReject = "reject" // Fail the archive
+)
+
+// attachmentPolicy controls how downloads (e.g. PDFs served as attachments)
+// are archived
+var attachmentPolicy = envAttachmentPolicy("ARCHIVE_ATTACHMENT_POLICY")
+
+// ErrAttachmentRejected is returned when the archived URL answers with a
+// download and ARCHIVE_ATTACHMENT_POLICY is reject
+var ErrAttachmentRejected = errors.New("URL serves a download (Content-Disposition: attachment)")
+
+// envAttachmentPolicy reads an attachment policy, defaulting to store
+func envAttachmentPolicy(key string) string {
+	switch policy := strings.ToLower(strings.TrimSpace(config.Getenv(key))); policy {
+	case "":
+		return attachmentStore
+	case attachmentStore, attachmentPage, attachmentReject:
+		return policy
+	default:
+		log.Printf("Invalid %s '%s', expected store, page or reject; using store", key, policy)
+		return attachmentStore
+	}
+}
+
+// attachmentName reports whether header marks the response as a download
+// and returns its suggested file name, falling back to the last segment of
+// pageURL. The name is sanitized for use on disk and in headers.
+func attachmentName(header http.Header, pageURL string) (string, bool) {
+	disposition, params, err := mime.ParseMediaType(header.Get("Content-Disposition"))
+	if err != nil || disposition != "attachment" {
+		return "", false
+	}
+
+	// mime.ParseMediaType decodes RFC 2231 filename* into filename
+	name := sanitizeFileName(params["filename"], 0)
+	if name == "" {
+		if u, err := url.Parse(pageURL); err == nil {
+			name = sanitizeFileName(path.Base(u.Path), 0)
+		}
+	}
+	if name == "" || name == "_" {
+		name = "download"
+	}
+	return name, true
+}
+
+// captureAttachment stores the body of a download verbatim under entryUUID,
+// keeping the extension of its suggested file name. Assets, text and
+// screenshots don't apply to downloads and are skipped.
+func captureAttachment(urlToArchive, finalURL, entryUUID string, opts ArchiveOptions, fetched fetchResult, fileName string) (*models.ArchiveEntry, []models.Asset, error) {
+	ext := assetExtension(filepath.Ext(fileName))
+	if ext == "" {
+		ext = ".bin"
+	}
+	storagePath := filepath.Join(settings.RawHTMLDir, entryUUID+ext)
+	body := []byte(fetched.HTML)
+	textHash := hashContent(body)
+	if opts.previousTextHash != "" && textHash == opts.previousTextHash {
+		return nil, nil, errContentUnchanged
+	}
+	if err := writeFileAtomic(storagePath, body, 0644); err != nil {
+		return nil, nil, fmt.Errorf("failed to write download to '%s': %w", storagePath, err)
+	}
+	fmt.Printf("Stored download '%s' for '%s' (%d bytes)\n", fileName, finalURL, len(body))
+
+	canonicalURL := finalURL
+	if opts.Canonicalize {
+		canonicalURL = CanonicalizeURL(finalURL)
+	}
+	entry := &models.ArchiveEntry{
+		ID:             entryUUID,
+		URL:            finalURL,
+		RequestURL:     urlToArchive,
+		CanonicalURL:   canonicalURL,
+		SeriesID:       opts.SeriesID,
+		Title:          fileName,
+		StoragePath:    storagePath,
+		AttachmentName: fileName,
+		ContentHash:    hashContent(body),
+		TextHash:       textHash,
+		HTTPStatus:     fetched.Page.StatusCode,
+		ContentType:    fetched.Page.ContentType,
+		Headers:        recordedHeaders(fetched.Page.Header),
+		Negotiation:    negotiationContext(opts),
+		FetchStrategy:  fetched.Strategy,
+		Device:         opts.Device,
+		ArchivedAt:     time.Now(),
+	}
+	metrics.RecordCapture(int64(len(body)), 0, 0, 0)
+	writeDebugCapture(entry, fetched, fetched.Page, nil)
+	return entry, nil, nil
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAttachmentName(t *testing.T) {
+	const pageURL = "https://example.com/files/report.pdf?v=2"
+	tests := []struct {
+		name        string
+		disposition string
+		pageURL     string
+		want        string
+		wantOK      bool
+	}{
+		{"no header", "", pageURL, "", false},
+		{"inline", `inline; filename="report.pdf"`, pageURL, "", false},
+		{"malformed", `attachment; filename="unterminated`, pageURL, "", false},
+		{"quoted filename", `attachment; filename="Q3 Report.pdf"`, pageURL, "Q3 Report.pdf", true},
+		{"case-insensitive disposition", `Attachment; filename=data.csv`, pageURL, "data.csv", true},
+		{"filename* in UTF-8", `attachment; filename*=UTF-8''%E6%97%A5%E6%9C%AC%20report.pdf`, pageURL, "日本 report.pdf", true},
+		{"filename* preferred over filename", `attachment; filename="fallback.pdf"; filename*=UTF-8''%C3%A9t%C3%A9.pdf`, pageURL, "été.pdf", true},
+		{"filename* in an unsupported charset uses the URL", `attachment; filename*=ISO-8859-1''%E9t%E9.pdf`, pageURL, "report.pdf", true},
+		{"unix path separators", `attachment; filename="../../etc/passwd"`, pageURL, "_.._etc_passwd", true},
+		{"windows path separators", `attachment; filename="C:\\Windows\\win.ini"`, pageURL, "C__Windows_win.ini", true},
+		{"encoded path separators", `attachment; filename*=UTF-8''..%2F..%2Fsecret.txt`, pageURL, "_.._secret.txt", true},
+		{"no filename uses the URL", `attachment`, pageURL, "report.pdf", true},
+		{"empty filename uses the URL", `attachment; filename=""`, pageURL, "report.pdf", true},
+		{"filename of dots uses the URL", `attachment; filename=".."`, pageURL, "report.pdf", true},
+		{"no name in header or URL", `attachment; filename=""`, "https://example.com/", "download", true},
+		{"unparsable URL", `attachment`, "://bad", "download", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.disposition != "" {
+				header.Set("Content-Disposition", tt.disposition)
+			}
+			got, ok := attachmentName(header, tt.pageURL)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("attachmentName(%q) = %q, %v; want %q, %v", tt.disposition, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// auditLog appends archive operations to the hash-chained audit log
+// (ARCHIVE_AUDIT_LOG)
+var auditLog = envBool("ARCHIVE_AUDIT_LOG", false)
+
+// auditMu serializes appends so each record links to its predecessor
+var auditMu sync.Mutex
+
+// auditHash returns the chain hash of record, covering every field but ID
+// and Hash. Fields are length-prefixed so they can't bleed into each other.
+func auditHash(record *models.AuditRecord) string {
+	hasher := sha256.New()
+	for _, field := range []string{
+		record.PrevHash,
+		record.Action,
+		record.EntryID,
+		record.URL,
+		record.ContentHash,
+		record.RecordedAt.UTC().Format(time.RFC3339Nano),
+	} {
+		hasher.Write([]byte(strconv.Itoa(len(field))))
+		hasher.Write([]byte{':'})
+		hasher.Write([]byte(field))
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// appendAudit records action on entry in the audit log when ARCHIVE_AUDIT_LOG
+// is enabled. Failures are logged rather than failing the operation.
+func appendAudit(db *gorm.DB, action string, entry *models.ArchiveEntry) {
+	if !auditLog {
+		return
+	}
+	if err := AppendAuditRecord(db, action, entry); err != nil {
+		fmt.Printf("Warning: failed to append '%s' of entry '%s' to the audit log: %v\n", action, entry.ID, err)
+	}
+}
+
+// AppendAuditRecord appends a record of action on entry to the audit log,
+// chained to the last record
+func AppendAuditRecord(db *gorm.DB, action string, entry *models.ArchiveEntry) error {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var last models.AuditRecord
+		err := tx.Order("id desc").Limit(1).Find(&last).Error
+		if err != nil {
+			return err
+		}
+
+		record := models.AuditRecord{
+			Action:      action,
+			EntryID:     entry.ID,
+			URL:         entry.URL,
+			ContentHash: entry.ContentHash,
+			// Microseconds survive every database round trip unchanged
+			RecordedAt: time.Now().UTC().Truncate(time.Microsecond),
+			PrevHash:   last.Hash,
+		}
+		record.Hash = auditHash(&record)
+		return tx.Create(&record).Error
+	})
+}
+
+// AuditVerification is the result of VerifyAuditLog
+type AuditVerification struct {
+	OK       bool   `json:"ok"`
+	Checked  int    `json:"checked"`            // Records checked, up to and including the first break
+	BrokenAt uint   `json:"brokenAt,omitempty"` // ID of the first record that doesn't verify
+	Reason   string `json:"reason,omitempty"`
+}
+
+// auditVerifyBatchSize is the number of records read at a time by VerifyAuditLog
+const auditVerifyBatchSize = 1000
+
+// errAuditBroken stops VerifyAuditLog's batch scan at the first break
+var errAuditBroken = errors.New("audit chain broken")
+
+// VerifyAuditLog walks the audit log in order and reports the first record
+// whose hash doesn't match its content or whose PrevHash doesn't match the
+// previous record, which indicates a modified, removed or inserted record
+func VerifyAuditLog(db *gorm.DB) (AuditVerification, error) {
+	var result AuditVerification
+	var batch []models.AuditRecord
+	prevHash := ""
+
+	err := db.Order("id asc").FindInBatches(&batch, auditVerifyBatchSize, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			record := &batch[i]
+			result.Checked++
+			switch {
+			case record.PrevHash != prevHash:
+				result.BrokenAt = record.ID
+				result.Reason = "previous hash does not match the preceding record"
+			case auditHash(record) != record.Hash:
+				result.BrokenAt = record.ID
+				result.Reason = "hash does not match the record's content"
+			}
+			if result.BrokenAt != 0 {
+				return errAuditBroken
+			}
+			prevHash = record.Hash
+		}
+		return nil
+	}).Error
+	if err != nil && !errors.Is(err, errAuditBroken) {
+		return result, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	result.OK = result.BrokenAt == 0
+	return result, nil
+}
+
+// ListAuditRecords returns up to limit audit records after the record with
+// ID afterID, oldest first
+func ListAuditRecords(db *gorm.DB, afterID uint, limit int) ([]models.AuditRecord, error) {
+	records := []models.AuditRecord{}
+	err := db.Where("id > ?", afterID).Order("id asc").Limit(limit).Find(&records).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit records: %w", err)
+	}
+	return records, nil
+}
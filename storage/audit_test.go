@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"archive-lite/tests"
+	"testing"
+)
+
+func TestVerifyAuditLogDetectsBreaks(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+	reset := func() { db.Where("1 = 1").Delete(&models.AuditRecord{}) }
+	reset()
+	t.Cleanup(reset)
+
+	for _, hash := range []string{"aa", "bb", "cc"} {
+		entry := &models.ArchiveEntry{ID: "entry-" + hash, URL: "https://example.com/" + hash, ContentHash: hash}
+		if err := AppendAuditRecord(db, "archive", entry); err != nil {
+			t.Fatalf("AppendAuditRecord: %v", err)
+		}
+	}
+	var records []models.AuditRecord
+	db.Order("id asc").Find(&records)
+
+	result, err := VerifyAuditLog(db)
+	if err != nil || !result.OK || result.Checked != 3 {
+		t.Fatalf("intact log: %+v, %v", result, err)
+	}
+
+	// Rewriting a record's content breaks its own hash
+	db.Model(&records[1]).Update("content_hash", "forged")
+	result, _ = VerifyAuditLog(db)
+	if result.OK || result.BrokenAt != records[1].ID {
+		t.Errorf("tampered record: %+v, want break at %d", result, records[1].ID)
+	}
+	db.Model(&records[1]).Update("content_hash", "bb")
+
+	// Removing a record breaks the link of its successor
+	db.Delete(&records[1])
+	result, _ = VerifyAuditLog(db)
+	if result.OK || result.BrokenAt != records[2].ID {
+		t.Errorf("removed record: %+v, want break at %d", result, records[2].ID)
+	}
+}
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// bundleAssets stores each entry's assets in a single data/assets/<uuid>.tar
+// instead of loose files (ARCHIVE_BUNDLE_ASSETS), for archives with many
+// small assets. Bundled assets are served from the tar on demand.
+var bundleAssets = envBool("ARCHIVE_BUNDLE_ASSETS", false)
+
+// entryIDLength is the length of the UUID prefix of asset file names
+const entryIDLength = 36
+
+// bundlePath returns the path of an entry's asset bundle
+func bundlePath(entryUUID string) string {
+	return filepath.Join(settings.AssetsDir, entryUUID+".tar")
+}
+
+// bundleEntryID returns the entry ID an asset file name starts with, or "" if
+// the name is not an entry's asset file
+func bundleEntryID(name string) string {
+	if len(name) <= entryIDLength || name[entryIDLength] != '_' || strings.ContainsAny(name, `/\`) {
+		return ""
+	}
+	return name[:entryIDLength]
+}
+
+// writeAssetBundle moves the loose asset files of an entry into its bundle,
+// replacing any previous bundle. Without loose files the bundle is removed.
+func writeAssetBundle(entryUUID string) error {
+	names, err := filepath.Glob(filepath.Join(settings.AssetsDir, entryUUID+"_*"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		if err := os.Remove(bundlePath(entryUUID)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(settings.AssetsDir, "."+entryUUID+".tar.tmp*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if err := writeTar(tmp, names); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, bundlePath(entryUUID)); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	for _, name := range names {
+		os.Remove(name)
+	}
+	return nil
+}
+
+// writeTar writes the files at paths to w as a tar archive of their base names
+func writeTar(w io.Writer, paths []string) error {
+	tw := tar.NewWriter(w)
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return err
+		}
+		header := &tar.Header{
+			Name:    filepath.Base(path),
+			Mode:    0644,
+			Size:    info.Size(),
+			ModTime: info.ModTime().Truncate(time.Second),
+			Format:  tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			f.Close()
+			return err
+		}
+		_, err = io.Copy(tw, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to add '%s' to bundle: %w", path, err)
+		}
+	}
+	return tw.Close()
+}
+
+// findBundled positions a reader of name's bundle at name's content and
+// returns it with the member's header. The caller closes the file.
+func findBundled(name string) (*os.File, *tar.Reader, *tar.Header, error) {
+	entryUUID := bundleEntryID(name)
+	if entryUUID == "" {
+		return nil, nil, nil, fs.ErrNotExist
+	}
+	f, err := os.Open(bundlePath(entryUUID))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			f.Close()
+			return nil, nil, nil, fs.ErrNotExist
+		}
+		if err != nil {
+			f.Close()
+			return nil, nil, nil, fmt.Errorf("failed to read bundle of '%s': %w", entryUUID, err)
+		}
+		if header.Name == name {
+			return f, tr, header, nil
+		}
+	}
+}
+
+// ReadAsset returns the content of a stored asset file, loose or bundled
+func ReadAsset(name string) ([]byte, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return nil, fs.ErrNotExist
+	}
+	content, err := os.ReadFile(filepath.Join(settings.AssetsDir, name))
+	if !errors.Is(err, fs.ErrNotExist) {
+		return content, err
+	}
+
+	f, tr, _, err := findBundled(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(tr)
+}
+
+// statAsset returns the size of a stored asset file, loose or bundled, and
+// the path of the file holding it
+func statAsset(name string) (int64, string, error) {
+	path := filepath.Join(settings.AssetsDir, name)
+	info, err := os.Stat(path)
+	if err == nil {
+		return info.Size(), path, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return 0, "", err
+	}
+
+	f, _, header, err := findBundled(name)
+	if err != nil {
+		return 0, "", err
+	}
+	f.Close()
+	return header.Size, f.Name(), nil
+}
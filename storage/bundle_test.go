@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"archive-lite/tests"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteAssetBundle(t *testing.T) {
+	origRaw, origAssets := settings.RawHTMLDir, settings.AssetsDir
+	defer SetStorageBaseDirsForTest(origRaw, origAssets)
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+
+	const entryUUID = "0b6f2d4e-8c1a-4f3b-9d7e-5a2c1e0f9b8d"
+	files := map[string]string{
+		entryUUID + "_style.css": "body { color: red; }",
+		entryUUID + "_logo.png":  "\x89PNG\r\n\x1a\nfake",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(settings.AssetsDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Another entry's assets stay loose
+	other := "1c7e3e5f-9d2b-4a4c-8e8f-6b3d2f1a0c9e_style.css"
+	if err := os.WriteFile(filepath.Join(settings.AssetsDir, other), []byte("p {}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeAssetBundle(entryUUID); err != nil {
+		t.Fatalf("writeAssetBundle: %v", err)
+	}
+	if _, err := os.Stat(bundlePath(entryUUID)); err != nil {
+		t.Fatalf("bundle not written: %v", err)
+	}
+	for name, content := range files {
+		if _, err := os.Stat(filepath.Join(settings.AssetsDir, name)); !os.IsNotExist(err) {
+			t.Errorf("loose file %s was not removed (stat: %v)", name, err)
+		}
+		got, err := ReadAsset(name)
+		if err != nil || string(got) != content {
+			t.Errorf("ReadAsset(%s) = %q, %v; want %q", name, got, err, content)
+		}
+		size, path, err := statAsset(name)
+		if err != nil || size != int64(len(content)) || path != bundlePath(entryUUID) {
+			t.Errorf("statAsset(%s) = %d, %s, %v; want %d, %s", name, size, path, err, len(content), bundlePath(entryUUID))
+		}
+	}
+	if _, err := os.Stat(filepath.Join(settings.AssetsDir, other)); err != nil {
+		t.Errorf("another entry's asset was moved: %v", err)
+	}
+
+	for _, name := range []string{
+		entryUUID + "_missing.js",
+		"",
+		"../" + entryUUID + "_style.css",
+		`sub\` + entryUUID + "_style.css",
+		"not-an-asset",
+	} {
+		if _, err := ReadAsset(name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("ReadAsset(%q) = %v, want fs.ErrNotExist", name, err)
+		}
+	}
+
+	// A loose file takes precedence over the bundled copy
+	loose := entryUUID + "_style.css"
+	if err := os.WriteFile(filepath.Join(settings.AssetsDir, loose), []byte("body {}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ReadAsset(loose); string(got) != "body {}" {
+		t.Errorf("ReadAsset preferred the bundle over the loose file: %q", got)
+	}
+
+	// Rewriting the bundle replaces it with the current loose files
+	if err := writeAssetBundle(entryUUID); err != nil {
+		t.Fatalf("rewriting bundle: %v", err)
+	}
+	if _, err := ReadAsset(entryUUID + "_logo.png"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("asset of the previous bundle still readable: %v", err)
+	}
+
+	// Without loose files the bundle is removed
+	os.Remove(filepath.Join(settings.AssetsDir, loose))
+	if err := writeAssetBundle(entryUUID); err != nil {
+		t.Fatalf("writeAssetBundle without assets: %v", err)
+	}
+	if _, err := os.Stat(bundlePath(entryUUID)); !os.IsNotExist(err) {
+		t.Errorf("empty bundle was not removed (stat: %v)", err)
+	}
+}
+
+func TestArchiveBundlesAssets(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/site.css":
+			w.Header().Set("Content-Type", "text/css")
+			fmt.Fprint(w, `body { background: url("/bg.png"); }`)
+		case "/bg.png", "/logo.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("\x89PNG\r\n\x1a\nfake"))
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><head><link rel="stylesheet" href="/site.css"></head><body><img src="/logo.png"></body></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	origRaw, origAssets, origNoDelay, origScreenshots, origBundle := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate, captureScreenshots, bundleAssets
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate, captureScreenshots, bundleAssets = origNoDelay, origScreenshots, origBundle
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate, captureScreenshots, bundleAssets = true, false, true
+
+	pageURL := server.URL + "/page"
+	t.Cleanup(func() { db.Where("request_url = ?", pageURL).Delete(&models.ArchiveEntry{}) })
+
+	entry, err := ArchiveURLWithOptions(db, pageURL, DefaultArchiveOptions())
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+
+	loose, _ := filepath.Glob(filepath.Join(settings.AssetsDir, entry.ID+"_*"))
+	if len(loose) != 0 {
+		t.Errorf("loose asset files left next to the bundle: %v", loose)
+	}
+	html, err := os.ReadFile(entry.StoragePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check := verifyAssetFiles(string(html)); !check.OK || check.Checked != 3 {
+		t.Errorf("verifyAssetFiles = %+v, want 3 bundled assets checked", check)
+	}
+	if info := GetStorageInfo(entry); info.Assets.Count != 3 {
+		t.Errorf("GetStorageInfo lists %d assets, want 3", info.Assets.Count)
+	}
+	name := generateAssetFileName(server.URL+"/bg.png", entry.ID)
+	if content, err := ReadAsset(name); err != nil || string(content) != "\x89PNG\r\n\x1a\nfake" {
+		t.Errorf("ReadAsset(%s) = %q, %v", name, content, err)
+	}
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"archive-lite/config"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// trackingParamPrefixes are query parameters that never affect page identity
+// and are dropped during canonicalization
+var trackingParamPrefixes = []string{"utm_", "fbclid", "gclid", "msclkid", "mc_cid", "mc_eid"}
+
+// paramRules are the per-host query parameter rules applied during
+// canonicalization (ARCHIVE_PARAM_RULES)
+var paramRules = parseParamRules(config.Getenv("ARCHIVE_PARAM_RULES"))
+
+// paramRule controls which query parameters of a host survive canonicalization.
+// Names ending in "*" match by prefix.
+type paramRule struct {
+	Keep []string // When set, only these parameters are kept
+	Drop []string // Parameters dropped in addition to tracking parameters
+}
+
+// parseParamRules parses ";"-separated rules of the form host:keep=a,b or
+// host:drop=a,b. A rule applies to its host and the host's subdomains; "*"
+// applies to every host without a more specific rule.
+func parseParamRules(spec string) map[string]paramRule {
+	rules := make(map[string]paramRule)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, rest, ok := strings.Cut(entry, ":")
+		action, list, ok2 := strings.Cut(rest, "=")
+		host = strings.ToLower(strings.TrimSpace(host))
+		action = strings.ToLower(strings.TrimSpace(action))
+		if !ok || !ok2 || host == "" || (action != "keep" && action != "drop") {
+			log.Printf("Invalid ARCHIVE_PARAM_RULES entry '%s', expected host:keep=a,b or host:drop=a,b", entry)
+			continue
+		}
+
+		var names []string
+		for _, name := range strings.Split(list, ",") {
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				names = append(names, name)
+			}
+		}
+		rule := rules[host]
+		if action == "keep" {
+			rule.Keep = append(rule.Keep, names...)
+		} else {
+			rule.Drop = append(rule.Drop, names...)
+		}
+		rules[host] = rule
+	}
+	return rules
+}
+
+// paramRuleFor returns the rule of the most specific host matching host
+func paramRuleFor(host string) (paramRule, bool) {
+	for h := host; h != ""; {
+		if rule, ok := paramRules[h]; ok {
+			return rule, true
+		}
+		_, parent, found := strings.Cut(h, ".")
+		if !found {
+			break
+		}
+		h = parent
+	}
+	rule, ok := paramRules["*"]
+	return rule, ok
+}
+
+// matchesParam reports whether key matches one of names
+func matchesParam(key string, names []string) bool {
+	key = strings.ToLower(key)
+	for _, name := range names {
+		if prefix, ok := strings.CutSuffix(name, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == name {
+			return true
+		}
+	}
+	return false
+}
+
+// keepParam reports whether a query parameter survives canonicalization
+func keepParam(key string, rule paramRule, hasRule bool) bool {
+	if hasRule && len(rule.Keep) > 0 {
+		// An explicit whitelist overrides the tracking list
+		return matchesParam(key, rule.Keep) && !matchesParam(key, rule.Drop)
+	}
+	if isTrackingParam(key) {
+		return false
+	}
+	return !hasRule || !matchesParam(key, rule.Drop)
+}
+
+// CanonicalizeURL normalizes rawURL for duplicate detection: the scheme and
+// host are lower-cased, default ports and the fragment are removed, tracking
+// parameters and those excluded by ARCHIVE_PARAM_RULES are dropped, repeated
+// identical parameters are collapsed and the remaining parameters are sorted.
+// Unparseable URLs are returned unchanged.
+func CanonicalizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	if (u.Scheme == "http" && u.Port() == "80") || (u.Scheme == "https" && u.Port() == "443") {
+		u.Host = u.Hostname()
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	rule, hasRule := paramRuleFor(u.Hostname())
+	query := u.Query()
+	for key, values := range query {
+		if !keepParam(key, rule, hasRule) {
+			query.Del(key)
+			continue
+		}
+		seen := make(map[string]bool, len(values))
+		deduped := values[:0]
+		for _, v := range values {
+			if !seen[v] {
+				seen[v] = true
+				deduped = append(deduped, v)
+			}
+		}
+		query[key] = deduped
+	}
+	// url.Values.Encode sorts by key
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// splitFragment splits rawURL at its first "#" into the URL to fetch and the
+// fragment, which is never sent to the server and only locates part of the page
+func splitFragment(rawURL string) (string, string) {
+	u, fragment, _ := strings.Cut(rawURL, "#")
+	return u, fragment
+}
+
+// isTrackingParam reports whether a query parameter is a known tracking parameter
+func isTrackingParam(key string) bool {
+	key = strings.ToLower(key)
+	for _, prefix := range trackingParamPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
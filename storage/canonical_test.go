@@ -0,0 +1,118 @@
+package storage
+
+import "testing"
+
+func TestCanonicalizeURL(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			"scheme and host are lower-cased",
+			"HTTPS://Example.COM/Path",
+			"https://example.com/Path",
+		},
+		{
+			"default ports are removed",
+			"http://example.com:80/a",
+			"http://example.com/a",
+		},
+		{
+			"other ports are kept",
+			"https://example.com:8443/a",
+			"https://example.com:8443/a",
+		},
+		{
+			"fragment is removed and empty path becomes /",
+			"https://example.com#top",
+			"https://example.com/",
+		},
+		{
+			"tracking params are dropped and the rest sorted",
+			"https://example.com/a?b=2&utm_source=x&fbclid=y&a=1",
+			"https://example.com/a?a=1&b=2",
+		},
+		{
+			"unparseable URLs are returned unchanged",
+			"://bad url",
+			"://bad url",
+		},
+		{
+			"relative URLs are returned unchanged",
+			"/just/a/path?utm_source=x",
+			"/just/a/path?utm_source=x",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanonicalizeURL(tt.in); got != tt.want {
+				t.Errorf("CanonicalizeURL(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeURLParamRules(t *testing.T) {
+	defer func(old map[string]paramRule) { paramRules = old }(paramRules)
+	paramRules = parseParamRules("shop.example:keep=id,page; news.example:drop=ref,session*; *:drop=sort; bad-entry")
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			"whitelist keeps identity params only",
+			"https://shop.example/item?utm_source=x&id=5&ref=home&page=2",
+			"https://shop.example/item?id=5&page=2",
+		},
+		{
+			"whitelist applies to subdomains",
+			"https://www.shop.example/item?id=5&color=red&fbclid=abc",
+			"https://www.shop.example/item?id=5",
+		},
+		{
+			"whitelist with only tracking params",
+			"https://shop.example/?utm_source=x&utm_medium=y",
+			"https://shop.example/",
+		},
+		{
+			"blacklist drops listed and tracking params",
+			"https://news.example/story?id=7&ref=tw&sessionid=abc&session_token=z&utm_campaign=c",
+			"https://news.example/story?id=7",
+		},
+		{
+			"default rule for other hosts",
+			"https://other.example/list?sort=asc&q=go&gclid=1",
+			"https://other.example/list?q=go",
+		},
+		{
+			"repeated identical params collapse",
+			"https://shop.example/item?id=5&id=5&page=1",
+			"https://shop.example/item?id=5&page=1",
+		},
+		{
+			"repeated distinct values are kept in order",
+			"https://other.example/search?tag=b&tag=a&tag=b",
+			"https://other.example/search?tag=b&tag=a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanonicalizeURL(tt.in); got != tt.want {
+				t.Errorf("CanonicalizeURL(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeURLWithoutParamRules(t *testing.T) {
+	defer func(old map[string]paramRule) { paramRules = old }(paramRules)
+	paramRules = parseParamRules("")
+
+	got := CanonicalizeURL("https://Example.com:443/a?b=2&utm_source=x&a=1#top")
+	if want := "https://example.com/a?a=1&b=2"; got != want {
+		t.Errorf("CanonicalizeURL = %q, want %q", got, want)
+	}
+}
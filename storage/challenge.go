@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"golang.org/x/net/html"
+)
+
+var (
+	// passJSChallenges fetches a page again in headless Chrome when the static
+	// fetch ran into an interstitial that sets cookies with JavaScript and
+	// reloads (ARCHIVE_PASS_JS_CHALLENGES)
+	passJSChallenges = envBool("ARCHIVE_PASS_JS_CHALLENGES", false)
+	// challengeWait bounds how long Chrome waits for a challenge to give way
+	// to the real page (ARCHIVE_CHALLENGE_WAIT_SEC)
+	challengeWait = time.Duration(envInt64("ARCHIVE_CHALLENGE_WAIT_SEC", 15)) * time.Second
+)
+
+// maxChallengeBytes is the HTML size above which a page is never taken for
+// an interstitial: challenge pages are small, articles mentioning one aren't
+const maxChallengeBytes = 32 << 10
+
+// challengeReason describes why a page looks like an interstitial that only
+// JavaScript gets past (a known challenge marker, a meta refresh, or a script
+// that sets a cookie and reloads), or returns "" if it doesn't
+func challengeReason(htmlContent string) string {
+	if len(htmlContent) > maxChallengeBytes {
+		return ""
+	}
+	lower := strings.ToLower(htmlContent)
+	for _, marker := range blockedMarkers {
+		if strings.Contains(lower, marker) {
+			return fmt.Sprintf("contains %q", marker)
+		}
+	}
+	if hasMetaRefresh(htmlContent) {
+		return "meta refresh"
+	}
+	if strings.Contains(lower, "document.cookie") &&
+		(strings.Contains(lower, "location.reload") || strings.Contains(lower, "location.replace") || strings.Contains(lower, "location.href")) {
+		return "script sets a cookie and reloads"
+	}
+	return ""
+}
+
+// hasMetaRefresh reports whether a page has a <meta http-equiv="refresh">
+func hasMetaRefresh(htmlContent string) bool {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return false
+	}
+	var found bool
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "meta" && strings.EqualFold(strings.TrimSpace(getAttr(n, "http-equiv")), "refresh") {
+			found = true
+		}
+		for c := n.FirstChild; c != nil && !found; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return found
+}
+
+// passChallenge fetches url again in headless Chrome when passJSChallenges
+// is set and the static fetch returned an interstitial (see
+// challengeReason), recording the reason in the result's Challenge. It
+// returns false, keeping the static page, when there is nothing to pass or
+// Chrome fails.
+func passChallenge(url string, opts ArchiveOptions, result fetchResult) (fetchResult, bool) {
+	if !passJSChallenges || result.Strategy != strategyStatic || !servedHTML(result) {
+		return result, false
+	}
+	reason := challengeReason(result.HTML)
+	if reason == "" {
+		return result, false
+	}
+	fmt.Printf("Static fetch of '%s' hit a challenge (%s), passing it in headless Chrome\n", url, reason)
+	passed, err := fetchWithStrategy(strategyBrowser, url, opts)
+	if err == nil && challengeReason(passed.HTML) != "" {
+		err = fmt.Errorf("page still shows the challenge after %s", challengeWait)
+	}
+	if err != nil {
+		fmt.Printf("Warning: failed to pass challenge of '%s': %v, archiving served HTML\n", url, err)
+		return result, false
+	}
+	passed.Challenge = reason
+	return passed, true
+}
+
+// waitForChallenge polls the loaded page until it no longer looks like an
+// interstitial, giving its scripts time to set cookies and reload. After
+// challengeWait the page is captured as it is; pages that merely look like
+// an interstitial, e.g. a small page with a meta refresh, still get captured.
+func waitForChallenge() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		deadline := time.Now().Add(challengeWait)
+		for {
+			var dom string
+			// Evaluation fails while the challenge reloads the page
+			err := chromedp.Evaluate(`document.documentElement ? document.documentElement.outerHTML : ""`, &dom).Do(ctx)
+			if err == nil && dom != "" && challengeReason(dom) == "" {
+				return nil
+			}
+			if time.Now().After(deadline) {
+				fmt.Printf("Warning: page still looks like a challenge after %s, capturing it as is\n", challengeWait)
+				return nil
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(500 * time.Millisecond):
+			}
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestChallengeReason(t *testing.T) {
+	article := "<p>" + strings.Repeat("Server-rendered article text. ", 5) + "</p>"
+	cases := []struct {
+		name, html string
+		challenge  bool
+	}{
+		{"cloudflare", `<html><head><title>Just a moment...</title></head><body></body></html>`, true},
+		{"meta refresh", `<html><head><meta http-equiv="Refresh" content="0"></head><body>Checking your browser</body></html>`, true},
+		{"cookie script", `<html><body><script>document.cookie="bm=1; path=/"; location.reload();</script></body></html>`, true},
+		{"article", `<html><body>` + article + `</body></html>`, false},
+		{"large page", `<html><head><meta http-equiv="refresh" content="300"></head><body>` + strings.Repeat(article, 400) + `</body></html>`, false},
+	}
+	for _, tc := range cases {
+		if got := challengeReason(tc.html) != ""; got != tc.challenge {
+			t.Errorf("%s: challenge = %v, want %v", tc.name, got, tc.challenge)
+		}
+	}
+}
+
+func TestPassChallengeKeepsOrdinaryPages(t *testing.T) {
+	orig := passJSChallenges
+	defer func() { passJSChallenges = orig }()
+	passJSChallenges = true
+
+	page := pageResponse{StatusCode: http.StatusOK, ContentType: "text/html"}
+	cases := []fetchResult{
+		{HTML: `<html><body><p>Article</p></body></html>`, Page: page, Strategy: strategyStatic},
+		// Already rendered
+		{HTML: `<html><head><title>Just a moment...</title></head></html>`, Page: page, Strategy: strategyBrowser},
+		// Not a page
+		{HTML: `{"refresh": "document.cookie; location.reload"}`, Page: pageResponse{StatusCode: http.StatusOK, ContentType: "application/json"}, Strategy: strategyStatic},
+	}
+	for i, result := range cases {
+		if _, ok := passChallenge("https://example.com/", DefaultArchiveOptions(), result); ok {
+			t.Errorf("case %d: escalated to Chrome", i)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"archive-lite/activity"
+	"archive-lite/config"
+	"archive-lite/metrics"
+	"archive-lite/models"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UnchangedError is returned by only-if-changed archives and refetches when
+// the fetched content matches the latest snapshot, so nothing was stored
+type UnchangedError struct {
+	URL    string
+	Latest *models.ArchiveEntry // The snapshot the content matched
+}
+
+func (e *UnchangedError) Error() string {
+	return fmt.Sprintf("content of '%s' unchanged since snapshot %s", e.URL, e.Latest.ID)
+}
+
+// errContentUnchanged is returned by captureURL when the fetched content's
+// change hash equals ArchiveOptions.previousTextHash
+var errContentUnchanged = errors.New("content unchanged")
+
+// diffIgnore are regular expressions whose matches are removed from a page's
+// text before change detection, for volatile bits such as timestamps, ad slots
+// or visitor counters (ARCHIVE_DIFF_IGNORE)
+var diffIgnore = envPatterns("ARCHIVE_DIFF_IGNORE")
+
+// envPatterns reads a comma-separated list of regular expressions; a comma
+// inside a pattern (e.g. in {1,2}) is written as \,. Invalid patterns are
+// logged and skipped.
+func envPatterns(key string) []*regexp.Regexp {
+	var patterns []*regexp.Regexp
+	for _, expr := range splitEscaped(config.Getenv(key), ',') {
+		if expr = strings.TrimSpace(expr); expr == "" {
+			continue
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			log.Printf("Invalid %s pattern '%s': %v", key, expr, err)
+			continue
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns
+}
+
+// splitEscaped splits s at each sep not preceded by a backslash and turns
+// escaped separators back into plain ones. Other escapes are kept as is.
+func splitEscaped(s string, sep byte) []string {
+	var parts []string
+	var part strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == sep:
+			part.WriteByte(sep)
+			i++
+		case s[i] == sep:
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(s[i])
+		}
+	}
+	return append(parts, part.String())
+}
+
+// changeText returns the text compared by change detection: the page's
+// extracted text with diffIgnore matches removed
+func changeText(htmlContent string) string {
+	text, err := ExtractText(htmlContent)
+	if err != nil {
+		text = htmlContent
+	}
+	for _, re := range diffIgnore {
+		text = re.ReplaceAllString(text, "")
+	}
+	return text
+}
+
+// changeHash returns the hash compared by only-if-changed operations: the
+// SHA-256 of changeText. Unlike ContentHash it doesn't depend on the entry ID
+// embedded in rewritten asset paths or on markup-only changes.
+func changeHash(htmlContent string) string {
+	return hashContent([]byte(changeText(htmlContent)))
+}
+
+// latestSnapshot returns the most recent entry archived from urlToArchive,
+// matched by request URL or canonical URL, or nil if there is none. The
+// canonical URL is derived as the new capture's would be: canonicalized, or
+// verbatim when canonicalize is false, so an opt-out request only matches
+// captures of the exact same URL.
+func latestSnapshot(db *gorm.DB, urlToArchive string, canonicalize bool) (*models.ArchiveEntry, error) {
+	urlToArchive, _ = splitFragment(urlToArchive)
+	canonicalURL := urlToArchive
+	if canonicalize {
+		canonicalURL = CanonicalizeURL(urlToArchive)
+	}
+	var entries []models.ArchiveEntry
+	err := db.Where("request_url = ? OR canonical_url = ?", urlToArchive, canonicalURL).
+		Order("archived_at desc").Limit(1).Find(&entries).Error
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+// recordUnchanged reports an only-if-changed operation that stored nothing
+// to the activity log and metrics
+func recordUnchanged(action, url, entryID string, started time.Time) {
+	activity.RecordUnchanged(action, url, entryID, started)
+	metrics.RecordUnchanged(action, started)
+}
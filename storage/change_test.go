@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"archive-lite/tests"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestArchiveOnlyIfChanged(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+
+	headline := "First headline"
+	visits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		visits++
+		w.Header().Set("Content-Type", "text/html")
+		// The nonce changes the markup on every request but not the text
+		fmt.Fprintf(w, `<html><body><h1>%s</h1><script nonce="%d"></script></body></html>`, headline, visits)
+	}))
+	defer server.Close()
+
+	origRaw, origAssets, origNoDelay, origScreenshots := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate, captureScreenshots
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate, captureScreenshots = origNoDelay, origScreenshots
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate, captureScreenshots = true, false
+
+	pageURL := server.URL + "/article"
+	t.Cleanup(func() { db.Where("request_url = ?", pageURL).Delete(&models.ArchiveEntry{}) })
+
+	opts := DefaultArchiveOptions()
+	opts.OnlyIfChanged = true
+	first, err := ArchiveURLWithOptions(db, pageURL, opts)
+	if err != nil {
+		t.Fatalf("first archive: %v", err)
+	}
+	if first.TextHash == "" {
+		t.Fatalf("TextHash not recorded")
+	}
+
+	_, err = ArchiveURLWithOptions(db, pageURL, opts)
+	var unchanged *UnchangedError
+	if !errors.As(err, &unchanged) {
+		t.Fatalf("second archive: got %v, want UnchangedError", err)
+	}
+	if unchanged.Latest.ID != first.ID {
+		t.Errorf("Latest = %s, want %s", unchanged.Latest.ID, first.ID)
+	}
+
+	headline = "Second headline"
+	second, err := ArchiveURLWithOptions(db, pageURL, opts)
+	if err != nil {
+		t.Fatalf("changed archive: %v", err)
+	}
+	if second.ID == first.ID || second.TextHash == first.TextHash {
+		t.Errorf("changed page not archived as a new snapshot: %+v", second)
+	}
+}
+
+func TestChangeHashIgnoresPatterns(t *testing.T) {
+	orig := diffIgnore
+	defer func() { diffIgnore = orig }()
+	t.Setenv("ARCHIVE_DIFF_IGNORE", `Updated \d{1\,2}:\d{2}, Visitors: \d+`)
+	diffIgnore = envPatterns("ARCHIVE_DIFF_IGNORE")
+	if len(diffIgnore) != 2 {
+		t.Fatalf("parsed %d patterns, want 2", len(diffIgnore))
+	}
+
+	page := `<html><body><p>Updated %s</p><article>%s</article><p>Visitors: %d</p></body></html>`
+	base := changeHash(fmt.Sprintf(page, "9:41", "Body text", 1200))
+	if got := changeHash(fmt.Sprintf(page, "10:05", "Body text", 1311)); got != base {
+		t.Errorf("page differing only in ignored regions hashed as changed")
+	}
+	if got := changeHash(fmt.Sprintf(page, "9:41", "Edited body text", 1200)); got == base {
+		t.Errorf("page with changed article hashed as unchanged")
+	}
+}
+
+func TestArchiveOnlyIfChangedWithIgnoredRegion(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+
+	visits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		visits++
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><h1>Headline</h1><div class="ad">Ad slot %d</div></body></html>`, visits)
+	}))
+	defer server.Close()
+
+	origRaw, origAssets, origNoDelay, origScreenshots, origIgnore := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate, captureScreenshots, diffIgnore
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate, captureScreenshots, diffIgnore = origNoDelay, origScreenshots, origIgnore
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate, captureScreenshots = true, false
+	t.Setenv("ARCHIVE_DIFF_IGNORE", `Ad slot \d+`)
+	diffIgnore = envPatterns("ARCHIVE_DIFF_IGNORE")
+
+	pageURL := server.URL + "/ads"
+	t.Cleanup(func() { db.Where("request_url = ?", pageURL).Delete(&models.ArchiveEntry{}) })
+
+	opts := DefaultArchiveOptions()
+	opts.OnlyIfChanged = true
+	if _, err := ArchiveURLWithOptions(db, pageURL, opts); err != nil {
+		t.Fatalf("first archive: %v", err)
+	}
+	_, err = ArchiveURLWithOptions(db, pageURL, opts)
+	var unchanged *UnchangedError
+	if !errors.As(err, &unchanged) {
+		t.Fatalf("page differing only in an ignored region: got %v, want UnchangedError", err)
+	}
+}
+
+func TestLatestSnapshotHonoursCanonicalize(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+
+	canonical := models.ArchiveEntry{
+		URL:          "https://snapshots.example/page?utm_source=feed",
+		RequestURL:   "https://snapshots.example/page?utm_source=feed",
+		CanonicalURL: "https://snapshots.example/page",
+		StoragePath:  "unused.html",
+	}
+	verbatim := models.ArchiveEntry{
+		URL:          "https://snapshots.example/variant?b=1&a=2",
+		RequestURL:   "https://snapshots.example/variant?b=1&a=2",
+		CanonicalURL: "https://snapshots.example/variant?b=1&a=2",
+		StoragePath:  "unused.html",
+	}
+	for _, entry := range []*models.ArchiveEntry{&canonical, &verbatim} {
+		if err := db.Create(entry).Error; err != nil {
+			t.Fatalf("creating entry: %v", err)
+		}
+		id := entry.ID
+		t.Cleanup(func() { db.Where("id = ?", id).Delete(&models.ArchiveEntry{}) })
+	}
+
+	tests := []struct {
+		url          string
+		canonicalize bool
+		want         string
+	}{
+		{"https://snapshots.example/page?utm_source=mail", true, canonical.ID},
+		{"https://snapshots.example/page", true, canonical.ID},
+		// Opting out matches the exact URL only, not other captures' canonical URL
+		{"https://snapshots.example/page?utm_source=mail", false, ""},
+		{"https://snapshots.example/page", false, canonical.ID},
+		{"https://snapshots.example/variant?b=1&a=2", false, verbatim.ID},
+		{"https://snapshots.example/variant?a=2&b=1", false, ""},
+	}
+	for _, tt := range tests {
+		latest, err := latestSnapshot(db, tt.url, tt.canonicalize)
+		if err != nil {
+			t.Fatalf("latestSnapshot(%s, %v): %v", tt.url, tt.canonicalize, err)
+		}
+		got := ""
+		if latest != nil {
+			got = latest.ID
+		}
+		if got != tt.want {
+			t.Errorf("latestSnapshot(%s, %v) = %q, want %q", tt.url, tt.canonicalize, got, tt.want)
+		}
+	}
+}
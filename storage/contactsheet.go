@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // Register JPEG decoder for screenshots
+	_ "image/png"  // Register PNG decoder for screenshots
+	"os"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	contactSheetTileWidth  = 320
+	contactSheetTileHeight = 240
+	contactSheetCaption    = 36 // Height reserved below each tile for title and date
+	contactSheetPadding    = 8
+)
+
+// BuildContactSheet tiles the screenshots of entries into a grid with the
+// given number of columns, captioning each tile with the entry's title (or
+// URL) and archive date. Entries whose screenshot is missing or cannot be
+// decoded are skipped. Only the top of tall full-page screenshots is shown.
+func BuildContactSheet(entries []models.ArchiveEntry, columns int) (image.Image, int, error) {
+	type tile struct {
+		img   image.Image
+		entry models.ArchiveEntry
+	}
+
+	var tiles []tile
+	for _, entry := range entries {
+		if entry.ScreenshotPath == "" {
+			continue
+		}
+		img, err := decodeImageFile(entry.ScreenshotPath)
+		if err != nil {
+			fmt.Printf("Warning: skipping screenshot '%s' in contact sheet: %v\n", entry.ScreenshotPath, err)
+			continue
+		}
+		tiles = append(tiles, tile{img: img, entry: entry})
+	}
+	if len(tiles) == 0 {
+		return nil, 0, nil
+	}
+
+	if columns < 1 {
+		columns = 1
+	}
+	if columns > len(tiles) {
+		columns = len(tiles)
+	}
+	rows := (len(tiles) + columns - 1) / columns
+
+	cellWidth := contactSheetTileWidth + contactSheetPadding
+	cellHeight := contactSheetTileHeight + contactSheetCaption + contactSheetPadding
+	sheet := image.NewRGBA(image.Rect(0, 0, columns*cellWidth+contactSheetPadding, rows*cellHeight+contactSheetPadding))
+	draw.Draw(sheet, sheet.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	for i, t := range tiles {
+		x := contactSheetPadding + (i%columns)*cellWidth
+		y := contactSheetPadding + (i/columns)*cellHeight
+
+		// Crop the top of the screenshot to the tile's aspect ratio, then scale it down
+		src := t.img.Bounds()
+		cropHeight := src.Dx() * contactSheetTileHeight / contactSheetTileWidth
+		if cropHeight > src.Dy() {
+			cropHeight = src.Dy()
+		}
+		srcRect := image.Rect(src.Min.X, src.Min.Y, src.Max.X, src.Min.Y+cropHeight)
+		dstRect := image.Rect(x, y, x+contactSheetTileWidth, y+contactSheetTileHeight)
+		draw.ApproxBiLinear.Scale(sheet, dstRect, t.img, srcRect, draw.Src, nil)
+
+		title := t.entry.Title
+		if title == "" {
+			title = t.entry.URL
+		}
+		drawCaption(sheet, x, y+contactSheetTileHeight+14, title)
+		drawCaption(sheet, x, y+contactSheetTileHeight+28, t.entry.ArchivedAt.Format("2006-01-02 15:04"))
+	}
+
+	return sheet, len(tiles), nil
+}
+
+// decodeImageFile decodes a PNG or JPEG image from disk
+func decodeImageFile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// drawCaption draws a single line of text at (x, baselineY), truncated to the tile width
+func drawCaption(dst draw.Image, x, baselineY int, text string) {
+	face := basicfont.Face7x13
+	maxChars := contactSheetTileWidth / face.Advance
+	if runes := []rune(text); len(runes) > maxChars {
+		text = string(runes[:maxChars-3]) + "..."
+	}
+
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(color.Black),
+		Face: face,
+		Dot:  fixed.P(x, baselineY),
+	}
+	d.DrawString(text)
+}
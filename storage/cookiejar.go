@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"archive-lite/config"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cookieJarPath is where cookies are persisted across restarts (ARCHIVE_COOKIE_JAR_PATH);
+// empty keeps the jar in memory only
+var cookieJarPath = config.Getenv("ARCHIVE_COOKIE_JAR_PATH")
+
+// storedCookie is the on-disk form of a cookie together with the URL it was set for
+type storedCookie struct {
+	URL      string        `json:"url"`
+	Name     string        `json:"name"`
+	Value    string        `json:"value"`
+	Path     string        `json:"path,omitempty"`
+	Domain   string        `json:"domain,omitempty"`
+	Expires  time.Time     `json:"expires,omitempty"`
+	Secure   bool          `json:"secure,omitempty"`
+	HttpOnly bool          `json:"httpOnly,omitempty"`
+	SameSite http.SameSite `json:"sameSite,omitempty"`
+}
+
+// persistentJar wraps a cookiejar.Jar and remembers every cookie it is given
+// so the jar can be saved to and restored from a file. The standard jar
+// doesn't expose its contents, so restoring replays the recorded SetCookies
+// calls and lets the inner jar apply its usual domain/path/expiry rules.
+type persistentJar struct {
+	*cookiejar.Jar
+	path string
+
+	mu      sync.Mutex
+	cookies map[string]storedCookie // keyed by domain|path|name
+}
+
+// newPersistentJar creates a jar backed by path, loading any cookies saved there
+func newPersistentJar(path string) (*persistentJar, error) {
+	inner, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	jar := &persistentJar{
+		Jar:     inner,
+		path:    path,
+		cookies: make(map[string]storedCookie),
+	}
+	if err := jar.load(); err != nil {
+		return nil, err
+	}
+	return jar, nil
+}
+
+// SetCookies records the cookies before handing them to the inner jar
+func (j *persistentJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.Jar.SetCookies(u, cookies)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, c := range cookies {
+		domain := c.Domain
+		if domain == "" {
+			domain = u.Hostname()
+		}
+		key := domain + "|" + c.Path + "|" + c.Name
+
+		if c.MaxAge < 0 || (!c.Expires.IsZero() && c.Expires.Before(time.Now())) {
+			delete(j.cookies, key)
+			continue
+		}
+		expires := c.Expires
+		if c.MaxAge > 0 {
+			expires = time.Now().Add(time.Duration(c.MaxAge) * time.Second)
+		}
+		j.cookies[key] = storedCookie{
+			URL:      u.Scheme + "://" + u.Host + u.Path,
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			Expires:  expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+			SameSite: c.SameSite,
+		}
+	}
+}
+
+// load restores cookies from the jar file; a missing file is not an error
+func (j *persistentJar) load() error {
+	data, err := os.ReadFile(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read cookie jar '%s': %w", j.path, err)
+	}
+
+	var stored []storedCookie
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("failed to parse cookie jar '%s': %w", j.path, err)
+	}
+
+	now := time.Now()
+	for _, sc := range stored {
+		if !sc.Expires.IsZero() && sc.Expires.Before(now) {
+			continue
+		}
+		u, err := url.Parse(sc.URL)
+		if err != nil {
+			continue
+		}
+		j.SetCookies(u, []*http.Cookie{{
+			Name:     sc.Name,
+			Value:    sc.Value,
+			Path:     sc.Path,
+			Domain:   sc.Domain,
+			Expires:  sc.Expires,
+			Secure:   sc.Secure,
+			HttpOnly: sc.HttpOnly,
+			SameSite: sc.SameSite,
+		}})
+	}
+	return nil
+}
+
+// save writes the unexpired cookies to the jar file atomically
+func (j *persistentJar) save() error {
+	j.mu.Lock()
+	now := time.Now()
+	stored := make([]storedCookie, 0, len(j.cookies))
+	for key, sc := range j.cookies {
+		if !sc.Expires.IsZero() && sc.Expires.Before(now) {
+			delete(j.cookies, key)
+			continue
+		}
+		stored = append(stored, sc)
+	}
+	j.mu.Unlock()
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cookie jar: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return fmt.Errorf("failed to create cookie jar directory: %w", err)
+	}
+	// Cookies may hold session tokens; keep the file private
+	return writeFileAtomic(j.path, data, 0600)
+}
+
+// count returns the number of unexpired cookies the jar remembers
+func (j *persistentJar) count() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	n := 0
+	for _, sc := range j.cookies {
+		if sc.Expires.IsZero() || sc.Expires.After(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// clearableJar is the shared HTTP client's cookie jar. It delegates to a jar
+// that ClearCookieJar can replace while requests are in flight.
+type clearableJar struct {
+	mu  sync.RWMutex
+	jar http.CookieJar
+}
+
+// current returns the jar requests are currently using
+func (c *clearableJar) current() http.CookieJar {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.jar
+}
+
+func (c *clearableJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	c.current().SetCookies(u, cookies)
+}
+
+func (c *clearableJar) Cookies(u *url.URL) []*http.Cookie {
+	return c.current().Cookies(u)
+}
+
+// sharedPersistentJar returns the shared HTTP client's jar when it is persisted
+// to ARCHIVE_COOKIE_JAR_PATH
+func sharedPersistentJar() (*persistentJar, bool) {
+	shared, ok := httpClient.Jar.(*clearableJar)
+	if !ok {
+		return nil, false
+	}
+	jar, ok := shared.current().(*persistentJar)
+	return jar, ok
+}
+
+// SaveCookieJar persists the shared HTTP client's cookies when
+// ARCHIVE_COOKIE_JAR_PATH is configured; otherwise it does nothing
+func SaveCookieJar() error {
+	jar, ok := sharedPersistentJar()
+	if !ok {
+		return nil
+	}
+	return jar.save()
+}
+
+// CookieJarSize returns the number of cookies in the shared HTTP client's
+// jar. Only persisted jars can be counted; ok is false otherwise.
+func CookieJarSize() (n int, ok bool) {
+	jar, ok := sharedPersistentJar()
+	if !ok {
+		return 0, false
+	}
+	return jar.count(), true
+}
+
+// ClearCookieJar replaces the shared HTTP client's cookie jar with an empty
+// one. A persisted jar keeps its path and its file is emptied too.
+func ClearCookieJar() error {
+	shared, ok := httpClient.Jar.(*clearableJar)
+	if !ok {
+		return nil
+	}
+	inner, err := cookiejar.New(nil)
+	if err != nil {
+		return err
+	}
+
+	shared.mu.Lock()
+	old, persisted := shared.jar.(*persistentJar)
+	if !persisted {
+		shared.jar = inner
+		shared.mu.Unlock()
+		return nil
+	}
+	fresh := &persistentJar{Jar: inner, path: old.path, cookies: make(map[string]storedCookie)}
+	shared.jar = fresh
+	shared.mu.Unlock()
+	return fresh.save()
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxStylesheetDepth bounds how many levels of stylesheet references (e.g.
+// @import chains) are followed
+const maxStylesheetDepth = 2
+
+// cssURLPattern matches url(...) tokens in CSS text, quoted or unquoted. This
+// covers url() anywhere in a declaration value, including custom properties
+// such as --bg: url(hero.png), and the url() form of @import.
+var cssURLPattern = regexp.MustCompile(`url\(\s*(?:"([^"]*)"|'([^']*)'|([^'")\s]*))\s*\)`)
+
+// cssImportPattern matches the string form of @import ("@import 'a.css';")
+var cssImportPattern = regexp.MustCompile(`@import\s+(?:"([^"]*)"|'([^']*)')`)
+
+// cssReference returns the URL captured by a cssURLPattern or cssImportPattern match
+func cssReference(groups []string) string {
+	for _, g := range groups[1:] {
+		if g != "" {
+			return strings.TrimSpace(g)
+		}
+	}
+	return ""
+}
+
+// cssURLs returns the distinct absolute http(s) URLs referenced by a
+// stylesheet located at baseURL. data: URLs and fragment-only references
+// are ignored.
+func cssURLs(css, baseURL string) []string {
+	seen := make(map[string]bool)
+	var urls []string
+	for _, pattern := range []*regexp.Regexp{cssURLPattern, cssImportPattern} {
+		for _, groups := range pattern.FindAllStringSubmatch(css, -1) {
+			ref := cssReference(groups)
+			if ref == "" || strings.HasPrefix(ref, "#") {
+				continue
+			}
+			if resolved := resolveURL(baseURL, ref); resolved != "" && !seen[resolved] {
+				seen[resolved] = true
+				urls = append(urls, resolved)
+			}
+		}
+	}
+	return urls
+}
+
+// rewriteCSSURLs points every http(s) url() and @import of a stylesheet
+// located at baseURL at its local asset path, or at its absolute URL for
+// keepExternalHosts. names maps downloaded asset
+// URLs to their stored file names (see assetNameFunc); it may be nil.
+func rewriteCSSURLs(css, baseURL, entryUUID string, names map[string]string) string {
+	assetName := assetNameFunc(names, entryUUID)
+	localPath := func(ref string) (string, bool) {
+		if ref == "" || strings.HasPrefix(ref, "#") {
+			return "", false
+		}
+		resolved := resolveURL(baseURL, ref)
+		if resolved == "" {
+			return "", false
+		}
+		if keepsExternal(resolved) {
+			return resolved, true
+		}
+		return localAssetPrefix + assetName(resolved), true
+	}
+
+	css = cssURLPattern.ReplaceAllStringFunc(css, func(match string) string {
+		if path, ok := localPath(cssReference(cssURLPattern.FindStringSubmatch(match))); ok {
+			return fmt.Sprintf(`url("%s")`, path)
+		}
+		return match
+	})
+	return cssImportPattern.ReplaceAllStringFunc(css, func(match string) string {
+		if path, ok := localPath(cssReference(cssImportPattern.FindStringSubmatch(match))); ok {
+			return fmt.Sprintf(`@import "%s"`, path)
+		}
+		return match
+	})
+}
+
+// isStylesheetRecord reports whether a downloaded asset is CSS
+func isStylesheetRecord(record models.Asset) bool {
+	if strings.Contains(strings.ToLower(record.ContentType), "text/css") {
+		return true
+	}
+	return strings.EqualFold(filepath.Ext(record.FileName), ".css")
+}
+
+// rewriteStylesheets rewrites the url() references of the stylesheets among
+// records to local asset paths, then downloads the referenced assets that
+// haven't been downloaded yet. Stylesheets found among those are processed in
+// turn, up to maxStylesheetDepth levels. It returns the records of the
+// additional downloads; keepErrorBodies is passed to downloadAssetsParallel.
+func rewriteStylesheets(records []models.Asset, entryUUID string, maxWorkers int, userAgent string, keepErrorBodies bool) []models.Asset {
+	downloaded := make(map[string]bool)
+	for _, r := range records {
+		downloaded[r.URL] = true
+	}
+
+	names := storedAssetNames(records)
+
+	// A stylesheet read at one depth is rewritten once the assets it
+	// references are downloaded, since their stored names may depend on
+	// the responses (see assetFileNameFor)
+	type stylesheet struct{ path, css, baseURL string }
+	rewrite := func(sheets []stylesheet) {
+		for _, sheet := range sheets {
+			if err := writeFileAtomic(sheet.path, []byte(rewriteCSSURLs(sheet.css, sheet.baseURL, entryUUID, names)), 0644); err != nil {
+				fmt.Printf("Warning: failed to rewrite stylesheet '%s': %v\n", sheet.path, err)
+			}
+		}
+	}
+
+	var extra []models.Asset
+	current := records
+	for depth := 0; depth < maxStylesheetDepth && len(current) > 0; depth++ {
+		var sheets []stylesheet
+		var nested []string
+		for _, record := range current {
+			if record.FileName == "" || !isStylesheetRecord(record) {
+				continue
+			}
+			// url() references are relative to the stylesheet's own (final) URL
+			baseURL := record.FinalURL
+			if baseURL == "" {
+				baseURL = record.URL
+			}
+
+			path := filepath.Join(settings.AssetsDir, record.FileName)
+			content, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Printf("Warning: failed to read stylesheet '%s': %v\n", path, err)
+				continue
+			}
+			css := string(content)
+			for _, u := range cssURLs(css, baseURL) {
+				if !downloaded[u] && !keepsExternal(u) {
+					downloaded[u] = true
+					nested = append(nested, u)
+				}
+			}
+			sheets = append(sheets, stylesheet{path, css, baseURL})
+		}
+		if len(nested) == 0 {
+			rewrite(sheets)
+			break
+		}
+
+		prioritizeAssets(nested, nil)
+		fmt.Printf("Found %d assets referenced from stylesheets\n", len(nested))
+		workers := maxWorkers
+		if len(nested) < workers {
+			workers = len(nested)
+		}
+		_, current = downloadAssetsParallel(nested, entryUUID, workers, userAgent, keepErrorBodies)
+		for url, name := range storedAssetNames(current) {
+			names[url] = name
+		}
+		rewrite(sheets)
+		extra = append(extra, current...)
+	}
+	return extra
+}
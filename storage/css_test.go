@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+const customPropertyCSS = `:root {
+  --hero-bg: url("img/hero.png");
+  --icon:url(/icons/arrow.svg);
+  --inline: url(data:image/png;base64,iVBORw0KGgo=);
+}
+@import 'print.css';
+.hero { background-image: var(--hero-bg); }
+.logo { background: url( 'https://cdn.example.net/logo.png' ) no-repeat; }
+`
+
+func TestCSSURLsIncludesCustomProperties(t *testing.T) {
+	got := cssURLs(customPropertyCSS, "https://example.com/static/css/site.css")
+	want := []string{
+		"https://example.com/static/css/img/hero.png",
+		"https://example.com/icons/arrow.svg",
+		"https://cdn.example.net/logo.png",
+		"https://example.com/static/css/print.css",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("cssURLs returned %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("cssURLs[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRewriteCSSURLsRewritesCustomProperties(t *testing.T) {
+	const entryUUID = "00000000-0000-0000-0000-000000000000"
+	base := "https://example.com/static/css/site.css"
+	got := rewriteCSSURLs(customPropertyCSS, base, entryUUID, nil)
+
+	hero := localAssetPrefix + generateAssetFileName("https://example.com/static/css/img/hero.png", entryUUID)
+	if !strings.Contains(got, `--hero-bg: url("`+hero+`");`) {
+		t.Errorf("custom property url() not rewritten to %s:\n%s", hero, got)
+	}
+	icon := localAssetPrefix + generateAssetFileName("https://example.com/icons/arrow.svg", entryUUID)
+	if !strings.Contains(got, `--icon:url("`+icon+`");`) {
+		t.Errorf("unquoted custom property url() not rewritten to %s:\n%s", icon, got)
+	}
+	printCSS := localAssetPrefix + generateAssetFileName("https://example.com/static/css/print.css", entryUUID)
+	if !strings.Contains(got, `@import "`+printCSS+`";`) {
+		t.Errorf("@import not rewritten to %s:\n%s", printCSS, got)
+	}
+	if !strings.Contains(got, "url(data:image/png;base64,iVBORw0KGgo=)") {
+		t.Errorf("data: URL should be left untouched:\n%s", got)
+	}
+	if !strings.Contains(got, "background-image: var(--hero-bg);") {
+		t.Errorf("var() reference should be left untouched:\n%s", got)
+	}
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+// debugCapture writes a sidecar file per archive describing how it was
+// fetched, for diagnosing archives that came out wrong (ARCHIVE_DEBUG_CAPTURE)
+var debugCapture = envBool("ARCHIVE_DEBUG_CAPTURE", false)
+
+// redactedRequestHeaders are request headers whose values are not written to
+// debug files, since they may carry login sessions or credentials
+var redactedRequestHeaders = []string{"Cookie", "Authorization"}
+
+// RedirectHop is one redirect response followed while fetching a page
+type RedirectHop struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"statusCode"`
+	Location   string `json:"location"`
+}
+
+// DebugCapture is the content of an archive's debug file
+type DebugCapture struct {
+	RequestURL      string              `json:"requestUrl"`  // URL as requested, before shortener resolution
+	ArchivedURL     string              `json:"archivedUrl"` // URL stored on the entry
+	FinalURL        string              `json:"finalUrl"`    // URL of the final page response, after redirects
+	FetchStrategy   string              `json:"fetchStrategy"`
+	StatusCode      int                 `json:"statusCode"`
+	RequestHeaders  map[string][]string `json:"requestHeaders,omitempty"` // Sent with the final page request; empty for browser fetches
+	ResponseHeaders map[string][]string `json:"responseHeaders,omitempty"`
+	Redirects       []RedirectHop       `json:"redirects,omitempty"` // Followed by the page request, in order
+	Assets          []models.Asset      `json:"assets"`
+	CapturedAt      time.Time           `json:"capturedAt"`
+}
+
+// DebugPath returns the path of an entry's debug file
+func DebugPath(entryID string) string {
+	return filepath.Join(settings.RawHTMLDir, entryID+".debug.json")
+}
+
+// redirectChain returns the redirects followed to get resp, oldest first
+func redirectChain(resp *http.Response) []RedirectHop {
+	var hops []RedirectHop
+	for req := resp.Request; req != nil && req.Response != nil; req = req.Response.Request {
+		prev := req.Response
+		hops = append([]RedirectHop{{
+			URL:        prev.Request.URL.String(),
+			StatusCode: prev.StatusCode,
+			Location:   prev.Header.Get("Location"),
+		}}, hops...)
+	}
+	return hops
+}
+
+// debugRequestHeaders returns a copy of the headers sent with a page request
+// for a debug file, without credentials
+func debugRequestHeaders(header http.Header) map[string][]string {
+	if len(header) == 0 {
+		return nil
+	}
+	recorded := make(map[string][]string, len(header))
+	for key, values := range header {
+		recorded[key] = append([]string(nil), values...)
+	}
+	for _, key := range redactedRequestHeaders {
+		if _, ok := recorded[key]; ok {
+			recorded[key] = []string{redactedCookieValue}
+		}
+	}
+	return recorded
+}
+
+// writeDebugCapture writes the debug file of entry when ARCHIVE_DEBUG_CAPTURE
+// is enabled. Failures are logged and don't fail the archive.
+func writeDebugCapture(entry *models.ArchiveEntry, fetched fetchResult, page pageResponse, assetRecords []models.Asset) {
+	if !debugCapture {
+		return
+	}
+	capture := DebugCapture{
+		RequestURL:      entry.RequestURL,
+		ArchivedURL:     entry.URL,
+		FinalURL:        entry.URL,
+		FetchStrategy:   fetched.Strategy,
+		StatusCode:      page.StatusCode,
+		RequestHeaders:  debugRequestHeaders(page.RequestHeader),
+		ResponseHeaders: recordedHeaders(page.Header),
+		Redirects:       page.Redirects,
+		Assets:          assetRecords,
+		CapturedAt:      time.Now(),
+	}
+	if page.URL != "" {
+		capture.FinalURL = page.URL
+	}
+	if capture.Assets == nil {
+		capture.Assets = []models.Asset{}
+	}
+	data, err := json.MarshalIndent(capture, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(DebugPath(entry.ID), data, 0644)
+	}
+	if err != nil {
+		fmt.Printf("Warning: failed to write debug capture for '%s': %v\n", entry.URL, err)
+	}
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"archive-lite/tests"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestDebugCaptureRecordsFetch(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		case "/new":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body><img src="/missing.png"></body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	origRaw, origAssets, origNoDelay, origScreenshots, origDebug := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate, captureScreenshots, debugCapture
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate, captureScreenshots, debugCapture = origNoDelay, origScreenshots, origDebug
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate, captureScreenshots, debugCapture = true, false, true
+
+	pageURL := server.URL + "/old"
+	t.Cleanup(func() { db.Where("request_url = ?", pageURL).Delete(&models.ArchiveEntry{}) })
+
+	entry, err := ArchiveURLWithOptions(db, pageURL, DefaultArchiveOptions())
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	data, err := os.ReadFile(DebugPath(entry.ID))
+	if err != nil {
+		t.Fatalf("debug file not written: %v", err)
+	}
+	var capture DebugCapture
+	if err := json.Unmarshal(data, &capture); err != nil {
+		t.Fatalf("debug file: %v", err)
+	}
+
+	if capture.FinalURL != server.URL+"/new" {
+		t.Errorf("FinalURL = %q, want %q", capture.FinalURL, server.URL+"/new")
+	}
+	if len(capture.Redirects) != 1 || capture.Redirects[0].StatusCode != http.StatusMovedPermanently || capture.Redirects[0].Location != "/new" {
+		t.Errorf("Redirects = %+v", capture.Redirects)
+	}
+	if capture.RequestHeaders["User-Agent"] == nil || capture.ResponseHeaders["Content-Type"] == nil {
+		t.Errorf("headers not recorded: %+v, %+v", capture.RequestHeaders, capture.ResponseHeaders)
+	}
+	var missing *models.Asset
+	for i := range capture.Assets {
+		if capture.Assets[i].URL == server.URL+"/missing.png" {
+			missing = &capture.Assets[i]
+		}
+	}
+	if missing == nil || missing.StatusCode != http.StatusNotFound || missing.Error == "" {
+		t.Errorf("failed asset not recorded: %+v", capture.Assets)
+	}
+}
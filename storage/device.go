@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"archive-lite/config"
+	"archive-lite/models"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// defaultUserAgent is the desktop Chrome User-Agent sent unless a device profile overrides it
+const defaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+
+// deviceProfiles are the named profiles accepted by ResolveDeviceProfile
+var deviceProfiles = map[string]models.DeviceProfile{
+	"desktop": {
+		Name:      "desktop",
+		Width:     1280,
+		Height:    800,
+		DPR:       desktopDPR,
+		UserAgent: defaultUserAgent,
+	},
+	"mobile": {
+		Name:      "mobile",
+		Width:     390,
+		Height:    844,
+		DPR:       3,
+		Mobile:    true,
+		UserAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+	},
+	"tablet": {
+		Name:      "tablet",
+		Width:     820,
+		Height:    1180,
+		DPR:       2,
+		Mobile:    true,
+		UserAgent: "Mozilla/5.0 (iPad; CPU OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+	},
+}
+
+var (
+	// desktopDPR is the device scale factor of the desktop profile, and so of
+	// CaptureSPA screenshots (ARCHIVE_SCREENSHOT_DPR)
+	desktopDPR = envDPR("ARCHIVE_SCREENSHOT_DPR")
+
+	// userAgents is the rotation list for the desktop profile (ARCHIVE_USER_AGENTS,
+	// separated by "|" since User-Agents contain commas); empty keeps defaultUserAgent
+	userAgents = envUserAgents("ARCHIVE_USER_AGENTS")
+
+	userAgentMu   sync.Mutex
+	userAgentNext = make(map[string]int) // Next rotation index per host
+)
+
+const (
+	maxDeviceDimension = 8192
+	maxDeviceDPR       = 4
+)
+
+// ResetUserAgentRotation forgets the per-host rotation state of
+// ARCHIVE_USER_AGENTS, so every host starts over with the first User-Agent. It
+// returns the number of hosts forgotten.
+func ResetUserAgentRotation() int {
+	userAgentMu.Lock()
+	defer userAgentMu.Unlock()
+	n := len(userAgentNext)
+	userAgentNext = make(map[string]int)
+	return n
+}
+
+// DefaultDeviceProfile returns the desktop profile used when none is requested
+func DefaultDeviceProfile() models.DeviceProfile {
+	return deviceProfiles["desktop"]
+}
+
+// ResolveDeviceProfile returns the named profile (desktop if name is empty)
+// with any non-zero overrides applied. A profile with overrides is named
+// "custom".
+func ResolveDeviceProfile(name string, width, height int64, userAgent string, dpr float64) (models.DeviceProfile, error) {
+	if name == "" {
+		name = "desktop"
+	}
+	profile, ok := deviceProfiles[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(deviceProfiles))
+		for n := range deviceProfiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return models.DeviceProfile{}, fmt.Errorf("unknown device profile '%s' (expected one of %s)", name, strings.Join(names, ", "))
+	}
+
+	if width < 0 || width > maxDeviceDimension || height < 0 || height > maxDeviceDimension {
+		return models.DeviceProfile{}, fmt.Errorf("width and height must be between 0 (keep the profile's) and %d", maxDeviceDimension)
+	}
+	if dpr < 0 || dpr > maxDeviceDPR {
+		return models.DeviceProfile{}, fmt.Errorf("dpr must be between 0 (keep the profile's) and %d", maxDeviceDPR)
+	}
+
+	if width > 0 || height > 0 || userAgent != "" || dpr > 0 {
+		profile.Name = "custom"
+		if width > 0 {
+			profile.Width = width
+		}
+		if height > 0 {
+			profile.Height = height
+		}
+		if userAgent != "" {
+			profile.UserAgent = userAgent
+		}
+		if dpr > 0 {
+			profile.DPR = dpr
+		}
+	}
+	return profile, nil
+}
+
+// envDPR reads a device scale factor, falling back to 1 when it is unset or
+// outside (0, maxDeviceDPR]
+func envDPR(key string) float64 {
+	dpr := envFloat64(key, 1)
+	if dpr <= 0 || dpr > maxDeviceDPR {
+		log.Printf("Invalid %s %v: must be greater than 0 and at most %d, using 1", key, dpr, maxDeviceDPR)
+		return 1
+	}
+	return dpr
+}
+
+// envUserAgents reads a "|"-separated list of User-Agents
+func envUserAgents(key string) []string {
+	var agents []string
+	for _, ua := range strings.Split(config.Getenv(key), "|") {
+		if ua = strings.TrimSpace(ua); ua != "" {
+			agents = append(agents, ua)
+		}
+	}
+	return agents
+}
+
+// nextUserAgent returns the next User-Agent of the ARCHIVE_USER_AGENTS rotation
+// for the host of targetURL, round-robin per host, or "" if no list is set
+func nextUserAgent(targetURL string) string {
+	if len(userAgents) == 0 {
+		return ""
+	}
+	host := targetURL
+	if u, err := url.Parse(targetURL); err == nil {
+		host = strings.ToLower(u.Host)
+	}
+
+	userAgentMu.Lock()
+	defer userAgentMu.Unlock()
+	i := userAgentNext[host]
+	userAgentNext[host] = (i + 1) % len(userAgents)
+	return userAgents[i]
+}
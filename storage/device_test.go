@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"testing"
+)
+
+func TestResolveDeviceProfile(t *testing.T) {
+	mobile := deviceProfiles["mobile"]
+	tests := []struct {
+		name      string
+		profile   string
+		width     int64
+		height    int64
+		userAgent string
+		dpr       float64
+		want      models.DeviceProfile
+		wantErr   bool
+	}{
+		{name: "empty name is desktop", want: DefaultDeviceProfile()},
+		{name: "named profile", profile: "Mobile", want: mobile},
+		{name: "unknown profile", profile: "watch", wantErr: true},
+		{
+			name: "overrides", profile: "mobile", width: 500, userAgent: "TestAgent/1.0", dpr: 2,
+			want: models.DeviceProfile{Name: "custom", Width: 500, Height: mobile.Height, DPR: 2, Mobile: true, UserAgent: "TestAgent/1.0"},
+		},
+		{name: "largest values", width: maxDeviceDimension, height: maxDeviceDimension, dpr: maxDeviceDPR,
+			want: models.DeviceProfile{Name: "custom", Width: maxDeviceDimension, Height: maxDeviceDimension, DPR: maxDeviceDPR, UserAgent: DefaultDeviceProfile().UserAgent}},
+		{name: "dpr above 4", dpr: 4.5, wantErr: true},
+		{name: "negative dpr", dpr: -1, wantErr: true},
+		{name: "negative width", width: -1, wantErr: true},
+		{name: "height too large", height: maxDeviceDimension + 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveDeviceProfile(tt.profile, tt.width, tt.height, tt.userAgent, tt.dpr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("profile = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package storage
+
+import "fmt"
+
+// minFreeBytes is the free space that must remain on the data volume before an
+// archive is started; 0 disables the check
+var minFreeBytes = envInt64("ARCHIVE_MIN_FREE_BYTES", 0)
+
+// InsufficientStorageError is returned by ArchiveURL when the data volume has
+// less free space than ARCHIVE_MIN_FREE_BYTES
+type InsufficientStorageError struct {
+	Path      string
+	FreeBytes uint64
+	MinBytes  int64
+}
+
+func (e *InsufficientStorageError) Error() string {
+	return fmt.Sprintf("insufficient storage on '%s': %d bytes free, %d required", e.Path, e.FreeBytes, e.MinBytes)
+}
+
+// checkFreeSpace fails fast when the volume holding path is nearly full, so
+// that an archive is never left half-written
+func checkFreeSpace(path string) error {
+	if minFreeBytes <= 0 {
+		return nil
+	}
+
+	free, err := freeDiskBytes(path)
+	if err != nil {
+		// Not supported or not determinable; don't block archiving on it
+		fmt.Printf("Warning: could not determine free disk space for '%s': %v\n", path, err)
+		return nil
+	}
+	if free < uint64(minFreeBytes) {
+		return &InsufficientStorageError{Path: path, FreeBytes: free, MinBytes: minFreeBytes}
+	}
+	return nil
+}
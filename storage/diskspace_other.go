@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package storage
+
+import (
+	"errors"
+	"runtime"
+)
+
+// freeDiskBytes is only implemented on Linux and macOS, whose Statfs_t
+// fields have the types used there; elsewhere free space is unknown and the
+// free space check is skipped
+func freeDiskBytes(path string) (uint64, error) {
+	return 0, errors.New("free disk space check is not supported on " + runtime.GOOS)
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"archive-lite/config"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// dismissSelectors are CSS selectors of overlays such as cookie-consent
+// banners to click or hide before a page is captured (ARCHIVE_DISMISS_SELECTORS)
+var dismissSelectors = envSelectors("ARCHIVE_DISMISS_SELECTORS")
+
+// dismissClickPrefix marks a selector whose elements are clicked (e.g. an
+// "Accept" button) rather than hidden
+const dismissClickPrefix = "click:"
+
+// dismissSettleDelay gives pages time to react after dismissal buttons are clicked
+const dismissSettleDelay = 500 * time.Millisecond
+
+// envSelectors reads a ";"-separated list of CSS selectors. Commas can't be
+// used as the separator since they are part of selector syntax.
+func envSelectors(key string) []string {
+	var selectors []string
+	for _, sel := range strings.Split(config.Getenv(key), ";") {
+		if sel = strings.TrimSpace(sel); sel != "" {
+			selectors = append(selectors, sel)
+		}
+	}
+	return selectors
+}
+
+// dismissScript clicks the elements matching the "click" selectors and hides
+// those matching the others, returning how many elements were clicked.
+// Invalid selectors are skipped. When anything was hidden, scrolling locked by
+// the overlay is restored.
+const dismissScript = `((click, hide) => {
+	const each = (sel, fn) => {
+		try { document.querySelectorAll(sel).forEach(fn); } catch (e) {}
+	};
+	let clicked = 0, hidden = 0;
+	click.forEach(sel => each(sel, el => { el.click(); clicked++; }));
+	hide.forEach(sel => each(sel, el => {
+		el.style.setProperty("display", "none", "important");
+		hidden++;
+	}));
+	if (hidden > 0) {
+		for (const el of [document.documentElement, document.body]) {
+			if (el) el.style.setProperty("overflow", "auto", "important");
+		}
+	}
+	return clicked;
+})(%s, %s)`
+
+// dismissOverlays clicks or hides the elements matching selectors. It is
+// best-effort: selectors that match nothing or fail are ignored, and it is
+// bounded by the capture's overall timeout.
+func dismissOverlays(selectors []string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var click, hide []string
+		for _, sel := range selectors {
+			if rest, ok := strings.CutPrefix(sel, dismissClickPrefix); ok {
+				click = append(click, strings.TrimSpace(rest))
+			} else {
+				hide = append(hide, sel)
+			}
+		}
+		clickJSON, err := json.Marshal(click)
+		if err != nil {
+			return err
+		}
+		hideJSON, err := json.Marshal(hide)
+		if err != nil {
+			return err
+		}
+
+		var clicked int
+		if err := chromedp.Evaluate(fmt.Sprintf(dismissScript, clickJSON, hideJSON), &clicked).Do(ctx); err != nil {
+			fmt.Printf("Warning: failed to dismiss overlays: %v\n", err)
+			return nil
+		}
+		if clicked > 0 {
+			return chromedp.Sleep(dismissSettleDelay).Do(ctx)
+		}
+		return nil
+	})
+}
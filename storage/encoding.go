@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"archive-lite/config"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// assetAcceptEncoding is the Accept-Encoding header of asset requests
+// (ARCHIVE_ASSET_ACCEPT_ENCODING). Servers may compress assets regardless,
+// and every encoding decodeContent handles is undone before storing, so
+// "gzip, br, zstd" saves bandwidth without changing what is stored.
+var assetAcceptEncoding = envAcceptEncoding("ARCHIVE_ASSET_ACCEPT_ENCODING")
+
+// envAcceptEncoding reads an Accept-Encoding setting, defaulting to identity
+func envAcceptEncoding(key string) string {
+	if v := strings.TrimSpace(config.Getenv(key)); v != "" {
+		return v
+	}
+	return "identity"
+}
+
+// decodeContent returns a reader of body with the Content-Encoding
+// encoding undone: gzip, br (Brotli), zstd and deflate are supported, and
+// encodings applied in sequence ("gzip, br") are undone in reverse order.
+// The returned function releases the decoders.
+func decodeContent(body io.Reader, encoding string) (io.Reader, func(), error) {
+	var closers []func()
+	release := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+
+	codings := strings.Split(encoding, ",")
+	reader := body
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		switch coding {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			gzReader, err := gzip.NewReader(reader)
+			if err != nil {
+				release()
+				return nil, nil, fmt.Errorf("failed to create gzip reader: %w", err)
+			}
+			closers = append(closers, func() { gzReader.Close() })
+			reader = gzReader
+		case "br":
+			reader = brotli.NewReader(reader)
+		case "zstd":
+			zstdReader, err := zstd.NewReader(reader)
+			if err != nil {
+				release()
+				return nil, nil, fmt.Errorf("failed to create zstd reader: %w", err)
+			}
+			closers = append(closers, zstdReader.Close)
+			reader = zstdReader
+		case "deflate":
+			// HTTP deflate is zlib-wrapped
+			zlibReader, err := zlib.NewReader(reader)
+			if err != nil {
+				release()
+				return nil, nil, fmt.Errorf("failed to create deflate reader: %w", err)
+			}
+			closers = append(closers, func() { zlibReader.Close() })
+			reader = zlibReader
+		default:
+			release()
+			return nil, nil, fmt.Errorf("unsupported Content-Encoding '%s'", coding)
+		}
+	}
+	return reader, release, nil
+}
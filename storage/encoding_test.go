@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"archive-lite/tests"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const encodedCSS = "body { font-family: Inter, sans-serif; background: #fafafa; }\n"
+
+// encode compresses content with a single Content-Encoding coding
+func encode(t *testing.T, coding string, content []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatalf("zstd.NewWriter: %v", err)
+		}
+		w = zw
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	default:
+		t.Fatalf("unknown coding %s", coding)
+	}
+	if _, err := w.Write(content); err != nil {
+		t.Fatalf("encode %s: %v", coding, err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("encode %s: %v", coding, err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeContent(t *testing.T) {
+	cases := map[string][]byte{
+		"":              []byte(encodedCSS),
+		"identity":      []byte(encodedCSS),
+		"gzip":          encode(t, "gzip", []byte(encodedCSS)),
+		"br":            encode(t, "br", []byte(encodedCSS)),
+		"zstd":          encode(t, "zstd", []byte(encodedCSS)),
+		"deflate":       encode(t, "deflate", []byte(encodedCSS)),
+		"gzip, BR":      encode(t, "br", encode(t, "gzip", []byte(encodedCSS))),
+		"br, zstd":      encode(t, "zstd", encode(t, "br", []byte(encodedCSS))),
+		"identity, br ": encode(t, "br", []byte(encodedCSS)),
+	}
+	for encoding, body := range cases {
+		reader, release, err := decodeContent(bytes.NewReader(body), encoding)
+		if err != nil {
+			t.Errorf("decodeContent(%q): %v", encoding, err)
+			continue
+		}
+		got, err := io.ReadAll(reader)
+		release()
+		if err != nil || string(got) != encodedCSS {
+			t.Errorf("decodeContent(%q) = %q, %v, want %q", encoding, got, err, encodedCSS)
+		}
+	}
+
+	if _, _, err := decodeContent(bytes.NewReader(nil), "compress"); err == nil {
+		t.Error("decodeContent(\"compress\") succeeded, want an unsupported encoding error")
+	}
+}
+
+func TestArchiveStoresCompressedAssetsDecoded(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+
+	codings := map[string]string{"/br.css": "br", "/zstd.css": "zstd"}
+	bodies := make(map[string][]byte)
+	for path, coding := range codings {
+		bodies[path] = encode(t, coding, []byte(encodedCSS))
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><head><link rel="stylesheet" href="/br.css"><link rel="stylesheet" href="/zstd.css"></head><body></body></html>`)
+		case "/br.css", "/zstd.css":
+			// CDNs may compress regardless of the Accept-Encoding sent
+			w.Header().Set("Content-Type", "text/css")
+			w.Header().Set("Content-Encoding", codings[r.URL.Path])
+			w.Write(bodies[r.URL.Path])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	origRaw, origAssets, origNoDelay, origScreenshots := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate, captureScreenshots
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate, captureScreenshots = origNoDelay, origScreenshots
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate, captureScreenshots = true, false
+
+	pageURL := server.URL + "/page"
+	t.Cleanup(func() { db.Where("request_url = ?", pageURL).Delete(&models.ArchiveEntry{}) })
+
+	entry, err := ArchiveURLWithOptions(db, pageURL, DefaultArchiveOptions())
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+
+	var assets []models.Asset
+	db.Where("entry_id = ?", entry.ID).Find(&assets)
+	names := storedAssetNames(assets)
+	for _, path := range []string{"/br.css", "/zstd.css"} {
+		name := names[server.URL+path]
+		if name == "" {
+			t.Errorf("%s was not stored", path)
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(settings.AssetsDir, name))
+		if err != nil || string(content) != encodedCSS {
+			t.Errorf("%s stored as %q (%v), want the decoded stylesheet %q", path, content, err, encodedCSS)
+		}
+	}
+}
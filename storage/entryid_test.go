@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"archive-lite/tests"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestArchiveEntryIDMatchesStoragePath(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/style.css" {
+			w.Header().Set("Content-Type", "text/css")
+			fmt.Fprint(w, "body { color: black; }")
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><link rel="stylesheet" href="/style.css"></head><body><p>Hello</p></body></html>`)
+	}))
+	defer server.Close()
+
+	origRaw, origAssets, origNoDelay, origScreenshots := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate, captureScreenshots
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate, captureScreenshots = origNoDelay, origScreenshots
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate, captureScreenshots = true, false
+
+	pageURL := server.URL + "/page"
+	t.Cleanup(func() { db.Where("request_url = ?", pageURL).Delete(&models.ArchiveEntry{}) })
+
+	entry, err := ArchiveURLWithOptions(db, pageURL, DefaultArchiveOptions())
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if _, err := uuid.Parse(entry.ID); err != nil {
+		t.Fatalf("ID %q is not a UUID: %v", entry.ID, err)
+	}
+	name := filepath.Base(entry.StoragePath)
+	if prefix := strings.TrimSuffix(name, filepath.Ext(name)); prefix != entry.ID {
+		t.Errorf("StoragePath file %q does not start with ID %q", name, entry.ID)
+	}
+
+	var stored models.ArchiveEntry
+	if err := db.Where("id = ?", entry.ID).First(&stored).Error; err != nil {
+		t.Fatalf("stored entry not found by ID: %v", err)
+	}
+	var assets []models.Asset
+	db.Where("entry_id = ?", entry.ID).Find(&assets)
+	if len(assets) == 0 {
+		t.Errorf("no asset records keyed by entry ID %s", entry.ID)
+	}
+	for _, asset := range assets {
+		if asset.FileName != "" && !strings.HasPrefix(asset.FileName, entry.ID+"_") {
+			t.Errorf("asset file %q not prefixed with entry ID", asset.FileName)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"archive-lite/config"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// envBool reads a boolean setting (see config.Getenv), returning def when unset or invalid
+func envBool(key string, def bool) bool {
+	v := config.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("Invalid boolean for %s: '%s', using default %v", key, v, def)
+		return def
+	}
+	return b
+}
+
+// envInt64 reads an integer setting, returning def when unset or invalid
+func envInt64(key string, def int64) int64 {
+	v := config.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		log.Printf("Invalid integer for %s: '%s', using default %d", key, v, def)
+		return def
+	}
+	return n
+}
+
+// envFloat64 reads a floating-point setting, returning def when unset or invalid
+func envFloat64(key string, def float64) float64 {
+	v := config.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("Invalid number for %s: '%s', using default %v", key, v, def)
+		return def
+	}
+	return f
+}
+
+// envList reads a comma-separated setting into a trimmed slice
+func envList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(config.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
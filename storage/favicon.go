@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"gorm.io/gorm"
+)
+
+// storeFavicon downloads /favicon.ico for pages that declare no icon
+// (ARCHIVE_STORE_FAVICON), so every entry can show one in list views
+var storeFavicon = envBool("ARCHIVE_STORE_FAVICON", true)
+
+// ErrNoFavicon is returned by Favicon when no icon was stored for an entry
+var ErrNoFavicon = errors.New("no favicon stored")
+
+// faviconURL returns the resolved URL of the icon declared by a page,
+// preferring rel=icon over apple-touch-icon, or "" if it declares none
+func faviconURL(htmlContent, baseURL string) string {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return ""
+	}
+
+	var icon, touchIcon string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "link" {
+			href := resolveURL(baseURL, getAttr(n, "href"))
+			if href != "" && icon == "" && hasRel(n, "icon") {
+				// Includes "shortcut icon"
+				icon = href
+			} else if href != "" && touchIcon == "" && hasRel(n, "apple-touch-icon") {
+				touchIcon = href
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	if icon != "" {
+		return icon
+	}
+	return touchIcon
+}
+
+// defaultFaviconURL returns the conventional /favicon.ico URL of a page's origin
+func defaultFaviconURL(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/favicon.ico"}).String()
+}
+
+// Favicon returns the content and content type of an entry's stored favicon,
+// or ErrNoFavicon if none was stored. The content type comes from the asset
+// record when there is one and is sniffed from the file otherwise.
+func Favicon(db *gorm.DB, entry *models.ArchiveEntry) ([]byte, string, error) {
+	if entry.FaviconURL == "" {
+		return nil, "", ErrNoFavicon
+	}
+
+	var asset models.Asset
+	db.Where("entry_id = ? AND url = ? AND file_name <> ''", entry.ID, entry.FaviconURL).Limit(1).Find(&asset)
+	name := asset.FileName
+	if name == "" {
+		name = generateAssetFileName(entry.FaviconURL, entry.ID)
+	}
+	content, err := ReadAsset(name)
+	if err != nil || len(content) == 0 {
+		return nil, "", ErrNoFavicon
+	}
+
+	contentType, _, _ := strings.Cut(asset.ContentType, ";")
+	if contentType = strings.TrimSpace(contentType); !strings.HasPrefix(contentType, "image/") {
+		contentType = http.DetectContentType(content)
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		// Not an image, e.g. a soft-404 HTML page served as /favicon.ico
+		return nil, "", ErrNoFavicon
+	}
+	return content, contentType, nil
+}
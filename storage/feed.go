@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// maxFeedItems caps how many feed items are archived when following a feed
+var maxFeedItems = int(envInt64("ARCHIVE_FEED_MAX_ITEMS", 10))
+
+// feedDocument covers RSS 2.0 (channel/item), RSS 1.0 (item at the root) and Atom (entry)
+type feedDocument struct {
+	Channel struct {
+		Items []feedItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []feedItem  `xml:"item"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type feedItem struct {
+	Link string `xml:"link"`
+}
+
+type atomEntry struct {
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+}
+
+// findFeedURL returns the absolute URL of the first RSS/Atom feed advertised
+// via <link rel="alternate" type="application/rss+xml|atom+xml">
+func findFeedURL(htmlContent, baseURL string) string {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return ""
+	}
+
+	feedURL := ""
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if feedURL != "" {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "link" && hasRel(n, "alternate") {
+			switch strings.ToLower(getAttr(n, "type")) {
+			case "application/rss+xml", "application/atom+xml":
+				feedURL = resolveURL(baseURL, getAttr(n, "href"))
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return feedURL
+}
+
+// parseFeedItemURLs extracts item links from an RSS or Atom document, resolved
+// against feedURL, de-duplicated and capped at limit as limitLinks does. When
+// pathPrefix is set, only links on pageURL's origin under that path are kept.
+func parseFeedItemURLs(data []byte, feedURL, pageURL, pathPrefix string, limit int) ([]string, error) {
+	var doc feedDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse feed '%s': %w", feedURL, err)
+	}
+
+	var links []string
+	for _, item := range append(doc.Channel.Items, doc.Items...) {
+		links = append(links, strings.TrimSpace(item.Link))
+	}
+	for _, entry := range doc.Entries {
+		for _, link := range entry.Links {
+			if link.Rel == "" || link.Rel == "alternate" {
+				links = append(links, strings.TrimSpace(link.Href))
+				break
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	var urls []string
+	for _, link := range links {
+		resolved := resolveURL(feedURL, link)
+		if resolved == "" || seen[resolved] {
+			continue
+		}
+		if pathPrefix != "" && !InScope(pageURL, resolved, pathPrefix) {
+			continue
+		}
+		seen[resolved] = true
+		urls = append(urls, resolved)
+	}
+	return limitLinks(pageURL, urls, pathPrefix, limit), nil
+}
+
+// FeedItemURLs looks for an RSS/Atom feed linked from an archived page and
+// returns up to limit item URLs from it (bounded by ARCHIVE_FEED_MAX_ITEMS
+// and ARCHIVE_MAX_LINKS_PER_PAGE), restricted to pathPrefix on the page's
+// origin when pathPrefix is set. It returns no URLs and no error when the
+// page does not advertise a feed.
+func FeedItemURLs(htmlPath, pageURL, pathPrefix string, limit int) ([]string, error) {
+	limit = linkLimit(limit, maxFeedItems)
+
+	content, err := os.ReadFile(htmlPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archived HTML '%s': %w", htmlPath, err)
+	}
+
+	feedURL := findFeedURL(string(content), pageURL)
+	if feedURL == "" {
+		return nil, nil
+	}
+
+	data, err := FetchAsset(feedURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch feed '%s': %w", feedURL, err)
+	}
+	return parseFeedItemURLs(data, feedURL, pageURL, pathPrefix, limit)
+}
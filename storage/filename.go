@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxFileNameBytes is the file name length limit of common filesystems
+	// (ext4, APFS, NTFS)
+	maxFileNameBytes = 255
+	// maxPathBytes keeps stored paths below Windows' classic MAX_PATH
+	maxPathBytes = 259
+	// maxAssetExtLength caps the extension kept from an asset URL, dot included
+	maxAssetExtLength = 16
+)
+
+// windowsReservedNames are device names that cannot be used as file names on
+// Windows, with or without an extension
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// assetExtension returns ext if it is a plausible file extension: a dot
+// followed by at most maxAssetExtLength-1 ASCII letters and digits.
+// Anything else, e.g. a dotted path segment full of punctuation, yields "".
+func assetExtension(ext string) string {
+	if len(ext) < 2 || len(ext) > maxAssetExtLength || ext[0] != '.' {
+		return ""
+	}
+	for _, r := range ext[1:] {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return ""
+		}
+	}
+	return ext
+}
+
+// sanitizeFileName turns name, e.g. a page title, into a file name that is
+// valid on common filesystems: path separators, characters reserved on
+// Windows and control characters become "_", leading and trailing dots and
+// spaces are trimmed, reserved device names are prefixed with "_", and the
+// result is truncated to maxBytes (at most maxFileNameBytes) on a UTF-8
+// boundary. It returns "" when nothing usable remains.
+func sanitizeFileName(name string, maxBytes int) string {
+	if maxBytes <= 0 || maxBytes > maxFileNameBytes {
+		maxBytes = maxFileNameBytes
+	}
+
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r == utf8.RuneError, unicode.IsControl(r), strings.ContainsRune(`/\:*?"<>|`, r):
+			b.WriteByte('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	clean := strings.Trim(b.String(), ". ")
+
+	base, _, _ := strings.Cut(clean, ".")
+	if windowsReservedNames[strings.ToUpper(strings.TrimSpace(base))] {
+		clean = "_" + clean
+	}
+
+	if len(clean) > maxBytes {
+		cut := maxBytes
+		for cut > 0 && !utf8.RuneStart(clean[cut]) {
+			cut--
+		}
+		clean = strings.TrimRight(clean[:cut], ". ")
+	}
+	return clean
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestGenerateAssetFileNamePathologicalURL(t *testing.T) {
+	const entryUUID = "00000000-0000-0000-0000-000000000000"
+	long := "https://example.com/" + strings.Repeat("a", 1000) + "." + strings.Repeat("b", 980) + "?q=" + strings.Repeat("c", 20)
+	if len(long) < 2000 {
+		t.Fatalf("test URL is only %d bytes", len(long))
+	}
+
+	for _, assetURL := range []string{
+		long,
+		"https://example.com/x.c%3Fs%5C:*|<>",
+		"https://example.com/" + strings.Repeat("%E2%98%83", 600) + ".png",
+	} {
+		name := generateAssetFileName(assetURL, entryUUID)
+		if len(name) > maxFileNameBytes {
+			t.Errorf("name for %.40q... is %d bytes", assetURL, len(name))
+		}
+		if len(filepath.Join(settings.AssetsDir, name)) > maxPathBytes {
+			t.Errorf("path for %.40q... exceeds %d bytes", assetURL, maxPathBytes)
+		}
+		if strings.ContainsAny(name, `/\:*?"<>|`) {
+			t.Errorf("name %q contains illegal characters", name)
+		}
+	}
+
+	if got := generateAssetFileName("https://example.com/a/style.css?v=2", entryUUID); !strings.HasSuffix(got, ".css") {
+		t.Errorf("ordinary extension dropped: %q", got)
+	}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	title := "Réponse: a/b\\c — 日本語のタイトル <draft>?*|\"quoted\"\x00 " + strings.Repeat("/雪", 200)
+	got := sanitizeFileName(title, 0)
+	if len(got) > maxFileNameBytes {
+		t.Errorf("len = %d, want <= %d", len(got), maxFileNameBytes)
+	}
+	if !utf8.ValidString(got) {
+		t.Errorf("result is not valid UTF-8: %q", got)
+	}
+	if strings.ContainsAny(got, "/\\:*?\"<>|\x00") {
+		t.Errorf("result contains illegal characters: %q", got)
+	}
+	if !strings.HasPrefix(got, "Réponse_ a_b_c — 日本語のタイトル") {
+		t.Errorf("unexpected result %q", got)
+	}
+
+	tests := map[string]string{
+		"  .hidden.  ": "hidden",
+		"CON":          "_CON",
+		"nul.txt":      "_nul.txt",
+		"///":          "___",
+		"..":           "",
+	}
+	for in, want := range tests {
+		if got := sanitizeFileName(in, 0); got != want {
+			t.Errorf("sanitizeFileName(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := sanitizeFileName("ééé", 5); got != "éé" {
+		t.Errorf("truncation split a rune: %q", got)
+	}
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"archive-lite/tests"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestArchiveRecordsFragmentAndDedupesWithout(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/article" {
+			http.NotFound(w, r)
+			return
+		}
+		requested = append(requested, r.RequestURI)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><h2 id="comments">Comments</h2></body></html>`))
+	}))
+	defer server.Close()
+
+	origRaw, origAssets, origNoDelay, origScreenshots := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate, captureScreenshots
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate, captureScreenshots = origNoDelay, origScreenshots
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate, captureScreenshots = true, false
+
+	pageURL := server.URL + "/article?x=1"
+	t.Cleanup(func() { db.Where("request_url = ?", pageURL).Delete(&models.ArchiveEntry{}) })
+
+	for _, canonicalize := range []bool{true, false} {
+		opts := DefaultArchiveOptions()
+		opts.Canonicalize = canonicalize
+
+		withFragment, err := ArchiveURLWithOptions(db, pageURL+"#comments", opts)
+		if err != nil {
+			t.Fatalf("archive with fragment: %v", err)
+		}
+		if withFragment.Fragment != "comments" {
+			t.Errorf("Fragment = %q, want %q", withFragment.Fragment, "comments")
+		}
+		if withFragment.URL != pageURL || withFragment.RequestURL != pageURL {
+			t.Errorf("fragment kept in URL %q or RequestURL %q", withFragment.URL, withFragment.RequestURL)
+		}
+
+		without, err := ArchiveURLWithOptions(db, pageURL, opts)
+		if err != nil {
+			t.Fatalf("archive without fragment: %v", err)
+		}
+		if without.Fragment != "" || without.CanonicalURL != withFragment.CanonicalURL {
+			t.Errorf("canonicalize=%v: CanonicalURL %q and %q differ, Fragment %q", canonicalize, withFragment.CanonicalURL, without.CanonicalURL, without.Fragment)
+		}
+
+		// Only-if-changed treats both as snapshots of the same page
+		opts.OnlyIfChanged = true
+		_, err = ArchiveURLWithOptions(db, pageURL+"#top", opts)
+		var unchanged *UnchangedError
+		if !errors.As(err, &unchanged) || unchanged.Latest.ID != without.ID {
+			t.Errorf("canonicalize=%v: archive with another fragment: got %v, want UnchangedError for %s", canonicalize, err, without.ID)
+		}
+	}
+
+	for _, uri := range requested {
+		if uri != "/article?x=1" {
+			t.Errorf("server was asked for %q", uri)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"archive-lite/config"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// redactedCookieValue replaces cookie values when Set-Cookie headers are redacted
+const redactedCookieValue = "REDACTED"
+
+// storeFullSetCookie keeps Set-Cookie values of archived responses verbatim
+// (ARCHIVE_SET_COOKIE=full); by default they are redacted so session tokens
+// are not stored
+var storeFullSetCookie = envSetCookieMode("ARCHIVE_SET_COOKIE")
+
+// envSetCookieMode reads the Set-Cookie capture mode, "redact" or "full"
+func envSetCookieMode(key string) bool {
+	switch v := strings.ToLower(config.Getenv(key)); v {
+	case "", "redact":
+		return false
+	case "full":
+		return true
+	default:
+		log.Printf("Invalid %s '%s', expected 'redact' or 'full'; redacting", key, v)
+		return false
+	}
+}
+
+// recordedHeaders returns a copy of a page's response headers for storage,
+// with Set-Cookie values redacted unless ARCHIVE_SET_COOKIE=full
+func recordedHeaders(header http.Header) map[string][]string {
+	if len(header) == 0 {
+		return nil
+	}
+	recorded := make(map[string][]string, len(header))
+	for key, values := range header {
+		values = append([]string(nil), values...)
+		if key == "Set-Cookie" && !storeFullSetCookie {
+			for i, v := range values {
+				values[i] = redactSetCookie(v)
+			}
+		}
+		recorded[key] = values
+	}
+	return recorded
+}
+
+// redactSetCookie replaces the value of a Set-Cookie header, keeping the
+// cookie name and its attributes (Path, Domain, Expires, Secure, ...)
+func redactSetCookie(setCookie string) string {
+	pair, attrs, hasAttrs := strings.Cut(setCookie, ";")
+	name, _, _ := strings.Cut(pair, "=")
+	redacted := strings.TrimSpace(name) + "=" + redactedCookieValue
+	if hasAttrs {
+		redacted += ";" + attrs
+	}
+	return redacted
+}
@@ -0,0 +1,305 @@
+package storage
+
+import (
+	"archive-lite/config"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// httpCacheDir enables the on-disk cache for asset fetches (ARCHIVE_HTTP_CACHE_DIR);
+	// empty disables caching
+	httpCacheDir = config.Getenv("ARCHIVE_HTTP_CACHE_DIR")
+	// httpCacheMaxBytes caps the total size of the cache directory (ARCHIVE_HTTP_CACHE_MAX_BYTES)
+	httpCacheMaxBytes = envInt64("ARCHIVE_HTTP_CACHE_MAX_BYTES", 256<<20)
+)
+
+// cachingTransport is an http.RoundTripper that keeps successful GET responses
+// on disk, keyed by URL. Fresh responses (per Cache-Control max-age or Expires)
+// are served without contacting the origin; stale ones carrying an ETag or
+// Last-Modified are revalidated with a conditional request.
+//
+// The cache is shared by every capture, whatever its device profile or login
+// session, so responses that may differ between requests to the same URL
+// (see isSharedCacheable) are never stored.
+//
+// Request cache directives are ignored: setProperHeaders always sends
+// "Cache-Control: no-cache" to look like a browser navigation, which would
+// otherwise bypass the cache entirely.
+type cachingTransport struct {
+	next     http.RoundTripper
+	dir      string
+	maxBytes int64
+
+	mu sync.Mutex // serializes writes and eviction
+}
+
+// newCachingTransport wraps next with a cache stored in dir
+func newCachingTransport(next http.RoundTripper, dir string, maxBytes int64) (*cachingTransport, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create HTTP cache directory '%s': %w", dir, err)
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &cachingTransport{next: next, dir: dir, maxBytes: maxBytes}, nil
+}
+
+// RoundTrip serves req from the cache when possible and stores cacheable responses
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.next.RoundTrip(req)
+	}
+
+	path := t.pathFor(req.URL.String())
+	cached, storedAt, err := t.load(path, req)
+	if err != nil {
+		cached = nil
+	}
+	if cached != nil && !isSharedCacheable(cached.Header) {
+		// Stored before such responses were excluded
+		cached.Body.Close()
+		cached = nil
+	}
+
+	if cached != nil {
+		if isFresh(cached.Header, storedAt) {
+			return cached, nil
+		}
+
+		// Stale: revalidate if we have a validator, otherwise refetch
+		if etag, lastModified := cached.Header.Get("ETag"), cached.Header.Get("Last-Modified"); etag != "" || lastModified != "" {
+			condReq := req.Clone(req.Context())
+			if etag != "" {
+				condReq.Header.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				condReq.Header.Set("If-Modified-Since", lastModified)
+			}
+			resp, err := t.next.RoundTrip(condReq)
+			if err != nil {
+				cached.Body.Close()
+				return nil, err
+			}
+			if resp.StatusCode == http.StatusNotModified {
+				resp.Body.Close()
+				for _, key := range []string{"Cache-Control", "Expires", "ETag", "Last-Modified", "Date"} {
+					if v := resp.Header.Get(key); v != "" {
+						cached.Header.Set(key, v)
+					}
+				}
+				cached.Header.Del("Age")
+				return t.store(path, cached)
+			}
+			cached.Body.Close()
+			return t.storeIfCacheable(path, resp)
+		}
+		cached.Body.Close()
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	return t.storeIfCacheable(path, resp)
+}
+
+// pathFor returns the cache file for a URL
+func (t *cachingTransport) pathFor(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return filepath.Join(t.dir, hex.EncodeToString(sum[:])+".resp")
+}
+
+// load reads a cached response and the time it was stored; (nil, zero, nil) if absent
+func (t *cachingTransport) load(path string, req *http.Request) (*http.Response, time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to parse cached response '%s': %w", path, err)
+	}
+	return resp, info.ModTime(), nil
+}
+
+// storeIfCacheable caches resp when it is a 200 that a shared cache may store
+func (t *cachingTransport) storeIfCacheable(path string, resp *http.Response) (*http.Response, error) {
+	if resp.StatusCode != http.StatusOK || !isSharedCacheable(resp.Header) {
+		return resp, nil
+	}
+	return t.store(path, resp)
+}
+
+// isSharedCacheable reports whether a response may be reused for any request
+// to its URL. Entries are keyed on the URL alone, so responses marked no-store
+// or private, responses setting cookies (which would be replayed into the
+// shared jar) and responses varying on request headers other than
+// Accept-Encoding are excluded.
+func isSharedCacheable(header http.Header) bool {
+	if hasCacheDirective(header, "no-store") || hasCacheDirective(header, "private") {
+		return false
+	}
+	if len(header.Values("Set-Cookie")) > 0 {
+		return false
+	}
+	for _, vary := range header.Values("Vary") {
+		for _, name := range strings.Split(vary, ",") {
+			// Accept-Encoding is the same for every asset fetch
+			if name = strings.TrimSpace(name); name != "" && !strings.EqualFold(name, "Accept-Encoding") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// store buffers resp, writes it to path and returns a response reading from the buffer
+func (t *cachingTransport) store(path string, resp *http.Response) (*http.Response, error) {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.TransferEncoding = nil
+
+	if t.maxBytes > 0 && int64(len(body)) > t.maxBytes {
+		return resp, nil
+	}
+
+	var buf bytes.Buffer
+	if err := resp.Write(&buf); err != nil {
+		fmt.Printf("Warning: failed to serialize response for cache: %v\n", err)
+	} else {
+		t.mu.Lock()
+		if err := writeFileAtomic(path, buf.Bytes(), 0644); err != nil {
+			fmt.Printf("Warning: failed to write HTTP cache entry: %v\n", err)
+		} else {
+			t.evict()
+		}
+		t.mu.Unlock()
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// evict removes the oldest stored entries until the cache fits in maxBytes.
+// Must be called with t.mu held.
+func (t *cachingTransport) evict() {
+	if t.maxBytes <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return
+	}
+
+	type cacheFile struct {
+		path     string
+		size     int64
+		storedAt time.Time
+	}
+	var files []cacheFile
+	var total int64
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".resp") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cacheFile{
+			path:     filepath.Join(t.dir, e.Name()),
+			size:     info.Size(),
+			storedAt: info.ModTime(),
+		})
+		total += info.Size()
+	}
+	if total <= t.maxBytes {
+		return
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].storedAt.Before(files[j].storedAt) })
+	for _, f := range files {
+		if total <= t.maxBytes {
+			break
+		}
+		if err := os.Remove(f.path); err == nil {
+			total -= f.size
+		}
+	}
+}
+
+// isFresh reports whether a response stored at storedAt may be reused without revalidation
+func isFresh(header http.Header, storedAt time.Time) bool {
+	if hasCacheDirective(header, "no-cache") {
+		return false
+	}
+	age := time.Since(storedAt)
+	if v, err := strconv.Atoi(header.Get("Age")); err == nil && v > 0 {
+		age += time.Duration(v) * time.Second
+	}
+
+	if maxAge, ok := cacheDirectiveSeconds(header, "max-age"); ok {
+		return age < time.Duration(maxAge)*time.Second
+	}
+	if expires := header.Get("Expires"); expires != "" {
+		expiresAt, err := http.ParseTime(expires)
+		if err != nil {
+			return false
+		}
+		date := storedAt
+		if d, err := http.ParseTime(header.Get("Date")); err == nil {
+			date = d
+		}
+		return age < expiresAt.Sub(date)
+	}
+	return false
+}
+
+// hasCacheDirective reports whether the Cache-Control header contains directive
+func hasCacheDirective(header http.Header, directive string) bool {
+	for _, part := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if strings.EqualFold(name, directive) {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheDirectiveSeconds returns the value of a delta-seconds Cache-Control directive
+func cacheDirectiveSeconds(header http.Header, directive string) (int64, bool) {
+	for _, part := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || !strings.EqualFold(name, directive) {
+			continue
+		}
+		seconds, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+		if err != nil || seconds < 0 {
+			return 0, false
+		}
+		return seconds, true
+	}
+	return 0, false
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// TestCachingTransportSharedCacheability checks that only responses any
+// capture may reuse are served from the cache on a second fetch
+func TestCachingTransportSharedCacheability(t *testing.T) {
+	cases := []struct {
+		name   string
+		header http.Header
+		cached bool
+	}{
+		{"public", http.Header{"Cache-Control": {"max-age=3600"}}, true},
+		{"private", http.Header{"Cache-Control": {"private, max-age=3600"}}, false},
+		{"set-cookie", http.Header{"Cache-Control": {"max-age=3600"}, "Set-Cookie": {"session=abc"}}, false},
+		{"vary user-agent", http.Header{"Cache-Control": {"max-age=3600"}, "Vary": {"User-Agent"}}, false},
+		{"vary accept-encoding and cookie", http.Header{"Cache-Control": {"max-age=3600"}, "Vary": {"Accept-Encoding, Cookie"}}, false},
+		{"vary accept-encoding", http.Header{"Cache-Control": {"max-age=3600"}, "Vary": {"Accept-Encoding"}}, true},
+	}
+
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("case")
+		mu.Lock()
+		hits[name]++
+		mu.Unlock()
+		for _, c := range cases {
+			if c.name == name {
+				for key, values := range c.header {
+					w.Header()[key] = values
+				}
+			}
+		}
+		io.WriteString(w, "body of "+name)
+	}))
+	defer server.Close()
+
+	transport, err := newCachingTransport(http.DefaultTransport, t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("newCachingTransport: %v", err)
+	}
+	client := &http.Client{Transport: transport}
+
+	for _, c := range cases {
+		assetURL := server.URL + "/asset.css?case=" + url.QueryEscape(c.name)
+		for i := 0; i < 2; i++ {
+			resp, err := client.Get(assetURL)
+			if err != nil {
+				t.Fatalf("%s: get: %v", c.name, err)
+			}
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil || string(body) != "body of "+c.name {
+				t.Fatalf("%s: body = %q (%v)", c.name, body, err)
+			}
+		}
+
+		want := 2
+		if c.cached {
+			want = 1
+		}
+		mu.Lock()
+		if got := hits[c.name]; got != want {
+			t.Errorf("%s: origin fetched %d times, want %d", c.name, got, want)
+		}
+		mu.Unlock()
+	}
+}
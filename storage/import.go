@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// importWorkers is the default and maximum number of batches upserted
+	// concurrently by ImportEntries (ARCHIVE_IMPORT_WORKERS)
+	importWorkers = envInt64("ARCHIVE_IMPORT_WORKERS", 4)
+	// importBatchSize is the default and maximum number of entries upserted
+	// per transaction by ImportEntries (ARCHIVE_IMPORT_BATCH_SIZE)
+	importBatchSize = envInt64("ARCHIVE_IMPORT_BATCH_SIZE", 500)
+)
+
+// maxImportErrors caps the error messages kept in an ImportResult
+const maxImportErrors = 20
+
+// ImportOptions controls the parallelism of ImportEntries. Zero values use
+// ARCHIVE_IMPORT_WORKERS and ARCHIVE_IMPORT_BATCH_SIZE, which are also the
+// largest values allowed.
+type ImportOptions struct {
+	Workers   int
+	BatchSize int
+	// Progress, when set, is called after each batch with the number of
+	// entries processed so far. Calls may come from several goroutines.
+	Progress func(done, total int)
+}
+
+// Validate rejects negative values and values above ARCHIVE_IMPORT_WORKERS
+// and ARCHIVE_IMPORT_BATCH_SIZE
+func (o ImportOptions) Validate() error {
+	if o.Workers < 0 || int64(o.Workers) > max(importWorkers, 1) {
+		return fmt.Errorf("workers must be between 1 and %d", max(importWorkers, 1))
+	}
+	if o.BatchSize < 0 || int64(o.BatchSize) > max(importBatchSize, 1) {
+		return fmt.Errorf("batchSize must be between 1 and %d", max(importBatchSize, 1))
+	}
+	return nil
+}
+
+// ImportResult summarizes an ImportEntries run
+type ImportResult struct {
+	Total      int      `json:"total"`
+	Imported   int      `json:"imported"`
+	Failed     int      `json:"failed"`
+	Errors     []string `json:"errors"` // First failures, at most maxImportErrors
+	DurationMs int64    `json:"durationMs"`
+}
+
+// ImportEntries upserts entry metadata, e.g. exported from another instance
+// with GET /api/archive, by ID. Entries are split into batches that are each
+// written in one transaction, with batches upserted concurrently by a bounded
+// worker pool. Only database rows are written; stored files are not copied,
+// and entries whose file paths lie outside the data directories are
+// rejected (see checkImportedPaths). Each upserted entry is recorded in the
+// audit log as an "import".
+func ImportEntries(db *gorm.DB, entries []models.ArchiveEntry, opts ImportOptions) ImportResult {
+	started := time.Now()
+	workers, batchSize := int(max(importWorkers, 1)), int(max(importBatchSize, 1))
+	if opts.Workers > 0 {
+		workers = min(opts.Workers, workers)
+	}
+	if opts.BatchSize > 0 {
+		batchSize = min(opts.BatchSize, batchSize)
+	}
+
+	result := ImportResult{Total: len(entries), Errors: []string{}}
+	var mu sync.Mutex
+	fail := func(count int, msg string) {
+		mu.Lock()
+		defer mu.Unlock()
+		result.Failed += count
+		if len(result.Errors) < maxImportErrors {
+			result.Errors = append(result.Errors, msg)
+		}
+	}
+
+	// Rows without an ID or URL can't be upserted
+	valid := make([]models.ArchiveEntry, 0, len(entries))
+	for i, entry := range entries {
+		if entry.ID == "" || entry.URL == "" {
+			fail(1, fmt.Sprintf("entry %d: missing ID or URL", i))
+			continue
+		}
+		if err := checkImportedPaths(&entry); err != nil {
+			fail(1, fmt.Sprintf("entry %d (%s): %v", i, entry.ID, err))
+			continue
+		}
+		if entry.ArchivedAt.IsZero() {
+			entry.ArchivedAt = time.Now()
+		}
+		valid = append(valid, entry)
+	}
+
+	batches := make(chan []models.ArchiveEntry)
+	var done, imported atomic.Int64
+	done.Store(int64(len(entries) - len(valid)))
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				err := db.Transaction(func(tx *gorm.DB) error {
+					return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&batch).Error
+				})
+				if err != nil {
+					fail(len(batch), fmt.Sprintf("batch starting at %s: %v", batch[0].ID, err))
+				} else {
+					imported.Add(int64(len(batch)))
+					for i := range batch {
+						appendAudit(db, "import", &batch[i])
+					}
+				}
+				processed := done.Add(int64(len(batch)))
+				if opts.Progress != nil {
+					opts.Progress(int(processed), len(entries))
+				}
+			}
+		}()
+	}
+
+	for start := 0; start < len(valid); start += batchSize {
+		end := min(start+batchSize, len(valid))
+		batches <- valid[start:end]
+	}
+	close(batches)
+	wg.Wait()
+
+	result.Imported = int(imported.Load())
+	result.DurationMs = time.Since(started).Milliseconds()
+	return result
+}
+
+// checkImportedPaths rejects an imported entry whose stored file paths don't
+// lie under the data directory they belong in. The paths are served to
+// anyone who can read archives, so an import must not be able to point them
+// at other files on the host (e.g. "/etc/passwd" or "data/raw/../../.env").
+func checkImportedPaths(entry *models.ArchiveEntry) error {
+	paths := []struct {
+		field, path, dir string
+	}{
+		{"storagePath", entry.StoragePath, settings.RawHTMLDir},
+		{"textPath", entry.TextPath, settings.RawHTMLDir},
+		{"mhtmlPath", entry.MHTMLPath, settings.RawHTMLDir},
+		{"screenshotPath", entry.ScreenshotPath, settings.ScreenshotsDir},
+		{"thumbnailPath", entry.ThumbnailPath, settings.ScreenshotsDir},
+	}
+	for _, p := range paths {
+		if p.path != "" && !pathWithin(p.path, p.dir) {
+			return fmt.Errorf("%s '%s' is outside '%s'", p.field, p.path, p.dir)
+		}
+	}
+	return nil
+}
+
+// pathWithin reports whether path, once cleaned and made absolute, names a
+// file below dir
+func pathWithin(path, dir string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
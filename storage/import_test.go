@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"archive-lite/database"
+	"archive-lite/models"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openImportTestDB returns an empty database of its own for an import test
+func openImportTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(database.SQLiteDSN(filepath.Join(t.TempDir(), "import.db"))),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.ArchiveEntry{}, &models.Asset{}, &models.AuditRecord{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func TestImportEntriesParallel(t *testing.T) {
+	db := openImportTestDB(t)
+
+	const total = 5000
+	entries := make([]models.ArchiveEntry, total)
+	for i := range entries {
+		entries[i] = models.ArchiveEntry{
+			ID:          fmt.Sprintf("00000000-0000-0000-0000-%012d", i),
+			URL:         fmt.Sprintf("https://example.com/page/%d", i),
+			Title:       "original",
+			StoragePath: fmt.Sprintf("data/raw/%d.html", i),
+			ArchivedAt:  time.Now(),
+		}
+	}
+	// Rows without an ID are reported, not imported
+	entries = append(entries, models.ArchiveEntry{URL: "https://example.com/no-id"})
+
+	var progressCalls atomic.Int64
+	opts := ImportOptions{Workers: 8, BatchSize: 250, Progress: func(done, total int) {
+		progressCalls.Add(1)
+	}}
+	result := ImportEntries(db, entries, opts)
+	if result.Imported != total || result.Failed != 1 {
+		t.Fatalf("result = %+v, want %d imported and 1 failed", result, total)
+	}
+	if got := progressCalls.Load(); got != total/250 {
+		t.Errorf("progress called %d times, want %d", got, total/250)
+	}
+
+	var count int64
+	db.Model(&models.ArchiveEntry{}).Count(&count)
+	if count != total {
+		t.Fatalf("rows = %d, want %d", count, total)
+	}
+
+	// Importing again updates the existing rows instead of duplicating them
+	for i := range entries[:total] {
+		entries[i].Title = "updated"
+	}
+	result = ImportEntries(db, entries[:total], opts)
+	if result.Imported != total || result.Failed != 0 {
+		t.Fatalf("re-import result = %+v", result)
+	}
+	db.Model(&models.ArchiveEntry{}).Count(&count)
+	var updated int64
+	db.Model(&models.ArchiveEntry{}).Where("title = ?", "updated").Count(&updated)
+	if count != total || updated != total {
+		t.Errorf("after re-import: %d rows, %d updated; want %d", count, updated, total)
+	}
+}
+
+func TestImportEntriesRejectsPathsOutsideDataDirs(t *testing.T) {
+	db := openImportTestDB(t)
+
+	entry := func(id string, modify func(*models.ArchiveEntry)) models.ArchiveEntry {
+		e := models.ArchiveEntry{
+			ID:          "00000000-0000-0000-0000-" + id,
+			URL:         "https://example.com/" + id,
+			StoragePath: filepath.Join(settings.RawHTMLDir, id+".html"),
+			ArchivedAt:  time.Now(),
+		}
+		if modify != nil {
+			modify(&e)
+		}
+		return e
+	}
+	entries := []models.ArchiveEntry{
+		entry("000000000001", nil),
+		entry("000000000002", func(e *models.ArchiveEntry) {
+			e.ScreenshotPath = filepath.Join(settings.ScreenshotsDir, "000000000002.jpg")
+			e.ThumbnailPath = filepath.Join(settings.ScreenshotsDir, "000000000002.thumb.jpg")
+		}),
+		entry("000000000003", func(e *models.ArchiveEntry) { e.StoragePath = "/etc/passwd" }),
+		entry("000000000004", func(e *models.ArchiveEntry) {
+			e.StoragePath = filepath.Join(settings.RawHTMLDir, "..", "..", "go.mod")
+		}),
+		entry("000000000005", func(e *models.ArchiveEntry) { e.StoragePath = settings.RawHTMLDir }),
+		entry("000000000006", func(e *models.ArchiveEntry) { e.TextPath = "../secrets.txt" }),
+		entry("000000000007", func(e *models.ArchiveEntry) {
+			// Screenshots belong under the screenshots directory only
+			e.ScreenshotPath = filepath.Join(settings.RawHTMLDir, "000000000007.jpg")
+		}),
+		entry("000000000008", func(e *models.ArchiveEntry) { e.MHTMLPath = "/root/.ssh/id_rsa" }),
+		entry("000000000009", func(e *models.ArchiveEntry) { e.ThumbnailPath = "/etc/shadow" }),
+	}
+
+	result := ImportEntries(db, entries, ImportOptions{})
+	if result.Imported != 2 || result.Failed != 7 {
+		t.Fatalf("result = %+v, want 2 imported and 7 rejected", result)
+	}
+	var ids []string
+	db.Model(&models.ArchiveEntry{}).Order("id").Pluck("id", &ids)
+	if len(ids) != 2 || ids[0] != entries[0].ID || ids[1] != entries[1].ID {
+		t.Errorf("stored entries %v, want only the two with paths under the data directories", ids)
+	}
+}
+
+func TestImportOptionsValidate(t *testing.T) {
+	defer func(workers, batchSize int64) { importWorkers, importBatchSize = workers, batchSize }(importWorkers, importBatchSize)
+	importWorkers, importBatchSize = 4, 500
+
+	tests := []struct {
+		opts    ImportOptions
+		wantErr bool
+	}{
+		{ImportOptions{}, false},
+		{ImportOptions{Workers: 4, BatchSize: 500}, false},
+		{ImportOptions{Workers: 1, BatchSize: 1}, false},
+		{ImportOptions{Workers: 5}, true},
+		{ImportOptions{BatchSize: 501}, true},
+		{ImportOptions{Workers: -1}, true},
+		{ImportOptions{BatchSize: -1}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) = %v, want error: %v", tt.opts, err, tt.wantErr)
+		}
+	}
+}
+
+func TestImportEntriesAppendsAuditRecords(t *testing.T) {
+	db := openImportTestDB(t)
+	defer func(old bool) { auditLog = old }(auditLog)
+	auditLog = true
+
+	entries := []models.ArchiveEntry{
+		{ID: "00000000-0000-0000-0000-000000000001", URL: "https://example.com/a", ContentHash: "aa"},
+		{ID: "00000000-0000-0000-0000-000000000002", URL: "https://example.com/b", ContentHash: "bb"},
+		{ID: "00000000-0000-0000-0000-000000000003", URL: "https://example.com/c", ContentHash: "cc"},
+		// Rejected rows are not recorded
+		{URL: "https://example.com/no-id"},
+	}
+	result := ImportEntries(db, entries, ImportOptions{Workers: 2, BatchSize: 1})
+	if result.Imported != 3 {
+		t.Fatalf("result = %+v, want 3 imported", result)
+	}
+
+	var records []models.AuditRecord
+	db.Order("id asc").Find(&records)
+	if len(records) != 3 {
+		t.Fatalf("%d audit records, want 3", len(records))
+	}
+	recorded := make(map[string]bool)
+	for _, record := range records {
+		if record.Action != "import" {
+			t.Errorf("record %d action = %q, want import", record.ID, record.Action)
+		}
+		recorded[record.EntryID] = true
+	}
+	for _, entry := range entries[:3] {
+		if !recorded[entry.ID] {
+			t.Errorf("no audit record for %s", entry.ID)
+		}
+	}
+
+	verification, err := VerifyAuditLog(db)
+	if err != nil || !verification.OK || verification.Checked != 3 {
+		t.Errorf("VerifyAuditLog = %+v, %v; want an intact chain of 3", verification, err)
+	}
+}
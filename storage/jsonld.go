@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// extractJSONLD returns the <script type="application/ld+json"> blocks of the
+// document, compacted, in document order. Blocks that aren't valid JSON are
+// skipped with a warning.
+func extractJSONLD(htmlContent string) []json.RawMessage {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return nil
+	}
+
+	var blocks []json.RawMessage
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "script" && isJSONLDType(getAttr(n, "type")) {
+			var text strings.Builder
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.TextNode {
+					text.WriteString(c.Data)
+				}
+			}
+
+			var compacted bytes.Buffer
+			if err := json.Compact(&compacted, []byte(strings.TrimSpace(text.String()))); err != nil {
+				fmt.Printf("Warning: skipping invalid JSON-LD block: %v\n", err)
+			} else {
+				blocks = append(blocks, json.RawMessage(compacted.Bytes()))
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	return blocks
+}
+
+// isJSONLDType reports whether a script type attribute denotes JSON-LD
+func isJSONLDType(scriptType string) bool {
+	mediaType, _, err := mime.ParseMediaType(scriptType)
+	return err == nil && mediaType == "application/ld+json"
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"net/url"
+	"strings"
+)
+
+// keepExternalHosts lists hosts whose assets are neither downloaded nor
+// localized, e.g. a CDN trusted to stay up (ARCHIVE_KEEP_EXTERNAL_HOSTS).
+// Entries match the host name or host:port exactly; an entry starting with
+// "." matches subdomains of the rest (".example.net" matches cdn.example.net).
+var keepExternalHosts = envList("ARCHIVE_KEEP_EXTERNAL_HOSTS")
+
+// keepsExternal reports whether assetURL is left pointing at its original
+// absolute URL instead of being archived
+func keepsExternal(assetURL string) bool {
+	if len(keepExternalHosts) == 0 {
+		return false
+	}
+	u, err := url.Parse(assetURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	hostname := strings.ToLower(u.Hostname())
+	for _, h := range keepExternalHosts {
+		h = strings.ToLower(h)
+		if h == hostname || h == strings.ToLower(u.Host) {
+			return true
+		}
+		if strings.HasPrefix(h, ".") && strings.HasSuffix(hostname, h) {
+			return true
+		}
+	}
+	return false
+}
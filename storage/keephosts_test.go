@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeepsExternal(t *testing.T) {
+	orig := keepExternalHosts
+	defer func() { keepExternalHosts = orig }()
+	keepExternalHosts = []string{"cdn.jsdelivr.net", "static.example.com:8443", ".fonts.example.net"}
+
+	cases := map[string]bool{
+		"https://cdn.jsdelivr.net/npm/lib.js":        true,
+		"https://CDN.jsdelivr.net/npm/lib.js":        true,
+		"https://static.example.com:8443/app.css":    true,
+		"https://static.example.com/app.css":         false,
+		"https://a.fonts.example.net/font.woff2":     true,
+		"https://fonts.example.net/font.woff2":       false,
+		"https://notcdn.jsdelivr.net.evil.test/x.js": false,
+		"https://example.com/img.png":                false,
+	}
+	for u, want := range cases {
+		if got := keepsExternal(u); got != want {
+			t.Errorf("keepsExternal(%q) = %v, want %v", u, got, want)
+		}
+	}
+}
+
+func TestModifyHTMLPathsKeepsExternalHosts(t *testing.T) {
+	const entryUUID = "00000000-0000-0000-0000-000000000000"
+	const baseURL = "https://example.com/post"
+	page := `<html><head>
+<link rel="stylesheet" href="//cdn.jsdelivr.net/npm/lib.css" integrity="sha384-abc">
+<script src="https://cdn.jsdelivr.net/npm/lib.js"></script>
+<link rel="stylesheet" href="/site.css">
+</head><body>
+<img src="/hero.jpg" srcset="/hero-2x.jpg 2x, https://cdn.jsdelivr.net/hero-3x.jpg 3x">
+</body></html>`
+
+	orig := keepExternalHosts
+	defer func() { keepExternalHosts = orig }()
+	keepExternalHosts = []string{"cdn.jsdelivr.net"}
+
+	assets, err := extractAssetsFromHTML(page, baseURL)
+	if err != nil {
+		t.Fatalf("extractAssetsFromHTML: %v", err)
+	}
+	for _, u := range assets {
+		if keepsExternal(u) {
+			t.Errorf("extractAssetsFromHTML = %v, includes kept asset %s", assets, u)
+		}
+	}
+	if len(assets) != 3 {
+		t.Errorf("extractAssetsFromHTML = %v, want the 3 assets of example.com", assets)
+	}
+
+	got, err := modifyHTMLPaths(page, entryUUID, baseURL, nil)
+	if err != nil {
+		t.Fatalf("modifyHTMLPaths: %v", err)
+	}
+	for _, want := range []string{
+		`href="https://cdn.jsdelivr.net/npm/lib.css" integrity="sha384-abc"`,
+		`src="https://cdn.jsdelivr.net/npm/lib.js"`,
+		`https://cdn.jsdelivr.net/hero-3x.jpg 3x`,
+		`href="/data/assets/` + generateAssetFileName("https://example.com/site.css", entryUUID) + `"`,
+		`src="/data/assets/` + generateAssetFileName("https://example.com/hero.jpg", entryUUID) + `"`,
+		`/data/assets/` + generateAssetFileName("https://example.com/hero-2x.jpg", entryUUID) + ` 2x`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("modifyHTMLPaths output missing %s:\n%s", want, got)
+		}
+	}
+}
+
+func TestRewriteCSSURLsKeepsExternalHosts(t *testing.T) {
+	const entryUUID = "00000000-0000-0000-0000-000000000000"
+	orig := keepExternalHosts
+	defer func() { keepExternalHosts = orig }()
+	keepExternalHosts = []string{"fonts.gstatic.com"}
+
+	css := `@font-face { src: url(https://fonts.gstatic.com/s/font.woff2); }
+body { background: url(/bg.png); }`
+	got := rewriteCSSURLs(css, "https://example.com/site.css", entryUUID, nil)
+	if !strings.Contains(got, `url("https://fonts.gstatic.com/s/font.woff2")`) {
+		t.Errorf("rewriteCSSURLs localized a kept host:\n%s", got)
+	}
+	if want := `url("/data/assets/` + generateAssetFileName("https://example.com/bg.png", entryUUID) + `")`; !strings.Contains(got, want) {
+		t.Errorf("rewriteCSSURLs output missing %s:\n%s", want, got)
+	}
+}
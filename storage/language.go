@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"archive-lite/config"
+	"log"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/text/language"
+)
+
+// languageMode selects how an archive's Lang and Dir are determined
+type languageMode int
+
+const (
+	languageDetect    languageMode = iota // <html lang>/dir, falling back to detection from the text
+	languageAttribute                     // <html lang>/dir only
+	languageOff                           // Lang and Dir are not recorded
+)
+
+// langMode is the configured language mode (ARCHIVE_LANGUAGE)
+var langMode = envLanguageMode("ARCHIVE_LANGUAGE")
+
+// envLanguageMode reads a language mode: detect (the default), attribute or off
+func envLanguageMode(key string) languageMode {
+	switch v := strings.ToLower(config.Getenv(key)); v {
+	case "", "detect":
+		return languageDetect
+	case "attribute":
+		return languageAttribute
+	case "off":
+		return languageOff
+	default:
+		log.Printf("Invalid %s '%s', expected 'detect', 'attribute' or 'off'; detecting", key, v)
+		return languageDetect
+	}
+}
+
+// rtlScripts are the ISO 15924 codes of scripts written right to left
+var rtlScripts = map[string]bool{
+	"Adlm": true, "Arab": true, "Hebr": true, "Mand": true, "Nkoo": true,
+	"Rohg": true, "Samr": true, "Syrc": true, "Thaa": true,
+}
+
+// scriptLanguages maps scripts used by a single major language to it. Scripts
+// shared by many languages (Latin, Cyrillic, Arabic, Devanagari...) are left
+// out: detection only reports their direction.
+var scriptLanguages = map[string]string{
+	"Hebrew":   "he",
+	"Hangul":   "ko",
+	"Thai":     "th",
+	"Greek":    "el",
+	"Armenian": "hy",
+	"Georgian": "ka",
+}
+
+// detectedScripts are the scripts counted by detectLanguage
+var detectedScripts = map[string]*unicode.RangeTable{
+	"Latin":      unicode.Latin,
+	"Cyrillic":   unicode.Cyrillic,
+	"Greek":      unicode.Greek,
+	"Armenian":   unicode.Armenian,
+	"Georgian":   unicode.Georgian,
+	"Hebrew":     unicode.Hebrew,
+	"Arabic":     unicode.Arabic,
+	"Syriac":     unicode.Syriac,
+	"Thaana":     unicode.Thaana,
+	"Devanagari": unicode.Devanagari,
+	"Thai":       unicode.Thai,
+	"Hangul":     unicode.Hangul,
+	"Han":        unicode.Han,
+	"Hiragana":   unicode.Hiragana,
+	"Katakana":   unicode.Katakana,
+}
+
+// documentLanguage returns the BCP 47 language tag and text direction (ltr
+// or rtl) of a page according to langMode. The <html> element's lang (or
+// xml:lang) and dir attributes win; missing values are inferred from the
+// tag's script or, in detect mode, from the page's text. Either value is ""
+// when it can't be determined.
+func documentLanguage(htmlContent string) (lang, dir string) {
+	if langMode == languageOff {
+		return "", ""
+	}
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", ""
+	}
+	if root := findElement(doc, "html"); root != nil {
+		for _, attr := range root.Attr {
+			switch strings.ToLower(attr.Key) {
+			case "lang", "xml:lang":
+				if lang == "" {
+					lang = normalizeLanguage(attr.Val)
+				}
+			case "dir":
+				if v := strings.ToLower(strings.TrimSpace(attr.Val)); v == "ltr" || v == "rtl" {
+					dir = v
+				}
+			}
+		}
+	}
+	if lang != "" && dir == "" {
+		dir = languageDirection(lang)
+	}
+	if langMode == languageDetect && (lang == "" || dir == "") {
+		if text, err := ExtractText(htmlContent); err == nil {
+			detectedLang, detectedDir := detectLanguage(text)
+			if lang == "" {
+				lang = detectedLang
+			}
+			if dir == "" {
+				dir = detectedDir
+			}
+		}
+	}
+	return lang, dir
+}
+
+// findElement returns the first element named name in document order
+func findElement(n *html.Node, name string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == name {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// normalizeLanguage returns the canonical form of a BCP 47 tag (e.g. "en_us"
+// becomes "en-US"), or "" if it isn't a valid tag
+func normalizeLanguage(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	tag, err := language.Parse(value)
+	if err != nil || tag == language.Und {
+		return ""
+	}
+	return tag.String()
+}
+
+// languageDirection returns the writing direction of a language tag's
+// (likely) script
+func languageDirection(lang string) string {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return ""
+	}
+	script, confidence := tag.Script()
+	if confidence == language.No {
+		return ""
+	}
+	if rtlScripts[script.String()] {
+		return "rtl"
+	}
+	return "ltr"
+}
+
+// detectLanguage guesses the language and direction of text from the script
+// most of its letters are written in. The language is only reported for
+// scripts used by a single major language (and for Japanese and Chinese,
+// told apart by the presence of kana); the direction is reported whenever a
+// script dominates.
+func detectLanguage(text string) (lang, dir string) {
+	counts := make(map[string]int)
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for name, table := range detectedScripts {
+			if unicode.Is(table, r) {
+				counts[name]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return "", ""
+	}
+
+	// Japanese mixes kana with Han characters
+	kana := counts["Hiragana"] + counts["Katakana"]
+	if kana > 0 && (kana+counts["Han"])*2 > letters {
+		return "ja", "ltr"
+	}
+
+	dominant, best := "", 0
+	for name, n := range counts {
+		if n > best || (n == best && name < dominant) {
+			dominant, best = name, n
+		}
+	}
+	if best*2 <= letters {
+		return "", ""
+	}
+	switch dominant {
+	case "Hebrew", "Arabic", "Syriac", "Thaana":
+		dir = "rtl"
+	default:
+		dir = "ltr"
+	}
+	if dominant == "Han" {
+		return "zh", dir
+	}
+	return scriptLanguages[dominant], dir
+}
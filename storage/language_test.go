@@ -0,0 +1,32 @@
+package storage
+
+import "testing"
+
+func TestDocumentLanguage(t *testing.T) {
+	orig := langMode
+	defer func() { langMode = orig }()
+	langMode = languageDetect
+
+	cases := []struct {
+		name, html, lang, dir string
+	}{
+		{"attribute", `<html lang="en_us"><body><p>Hello</p></body></html>`, "en-US", "ltr"},
+		{"rtl from script", `<html lang="ar"><body><p>مرحبا</p></body></html>`, "ar", "rtl"},
+		{"explicit dir", `<html lang="en" dir="RTL"><body></body></html>`, "en", "rtl"},
+		{"detected hebrew", `<html><body><p>שלום עולם, זהו דף בדיקה</p></body></html>`, "he", "rtl"},
+		{"detected japanese", `<html><body><p>これは日本語のページです</p></body></html>`, "ja", "ltr"},
+		{"latin only gives direction", `<html><body><p>Bonjour tout le monde</p></body></html>`, "", "ltr"},
+		{"invalid tag", `<html lang="not a tag"><body><p>123</p></body></html>`, "", ""},
+	}
+	for _, tc := range cases {
+		lang, dir := documentLanguage(tc.html)
+		if lang != tc.lang || dir != tc.dir {
+			t.Errorf("%s: got (%q, %q), want (%q, %q)", tc.name, lang, dir, tc.lang, tc.dir)
+		}
+	}
+
+	langMode = languageAttribute
+	if lang, dir := documentLanguage(`<html><body><p>שלום עולם</p></body></html>`); lang != "" || dir != "" {
+		t.Errorf("attribute mode detected (%q, %q) without a lang attribute", lang, dir)
+	}
+}
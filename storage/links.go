@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// maxLinksPerPage caps how many links discovered on one page are enqueued
+// for archiving (ARCHIVE_MAX_LINKS_PER_PAGE); zero means no cap besides the
+// feed's own ARCHIVE_FEED_MAX_ITEMS
+var maxLinksPerPage = int(envInt64("ARCHIVE_MAX_LINKS_PER_PAGE", 0))
+
+// linkLimit returns how many links of one page may be followed when the
+// request asks for requested (0 for as many as allowed) and at most max are
+// allowed by the link source itself
+func linkLimit(requested, max int) int {
+	limit := max
+	if requested > 0 && requested < limit {
+		limit = requested
+	}
+	if maxLinksPerPage > 0 && maxLinksPerPage < limit {
+		limit = maxLinksPerPage
+	}
+	return limit
+}
+
+// limitLinks returns at most limit of the links discovered on pageURL,
+// preferring links that stay close to the page: first those in scope
+// (pageURL's origin, under pathPrefix when set) whose path lies in the
+// page's own directory, then other in-scope links, then the rest. Links keep
+// their order within each group.
+func limitLinks(pageURL string, links []string, pathPrefix string, limit int) []string {
+	dir := "/"
+	if u, err := url.Parse(pageURL); err == nil && u.EscapedPath() != "" {
+		dir = path.Dir(u.EscapedPath())
+		if !strings.HasSuffix(dir, "/") {
+			dir += "/"
+		}
+	}
+	rank := func(link string) int {
+		if !InScope(pageURL, link, pathPrefix) {
+			return 2
+		}
+		if u, err := url.Parse(link); err == nil && strings.HasPrefix(u.EscapedPath(), dir) {
+			return 0
+		}
+		return 1
+	}
+
+	ranked := append([]string(nil), links...)
+	sort.SliceStable(ranked, func(i, j int) bool { return rank(ranked[i]) < rank(ranked[j]) })
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
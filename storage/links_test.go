@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFeedLinksCappedPerPage(t *testing.T) {
+	orig := maxLinksPerPage
+	defer func() { maxLinksPerPage = orig }()
+	maxLinksPerPage = 20
+
+	// 300 items, interleaving other origins, other sections and the page's own
+	const pageURL = "https://example.com/blog/"
+	var feed strings.Builder
+	feed.WriteString(`<rss version="2.0"><channel>`)
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&feed, "<item><link>https://other.example/post/%d</link></item>", i)
+		fmt.Fprintf(&feed, "<item><link>https://example.com/shop/item/%d</link></item>", i)
+		fmt.Fprintf(&feed, "<item><link>https://example.com/blog/post-%d</link></item>", i)
+	}
+	feed.WriteString(`</channel></rss>`)
+
+	links, err := parseFeedItemURLs([]byte(feed.String()), pageURL+"feed.xml", pageURL, "", linkLimit(0, 1000))
+	if err != nil {
+		t.Fatalf("parseFeedItemURLs: %v", err)
+	}
+	if len(links) != maxLinksPerPage {
+		t.Fatalf("followed %d links, want %d", len(links), maxLinksPerPage)
+	}
+	for i, link := range links {
+		if want := fmt.Sprintf("https://example.com/blog/post-%d", i); link != want {
+			t.Errorf("link %d = %s, want %s", i, link, want)
+		}
+	}
+
+	// A smaller request wins over the per-page cap
+	if limit := linkLimit(5, 1000); limit != 5 {
+		t.Errorf("linkLimit(5) = %d, want 5", limit)
+	}
+	// Once the page's own section is exhausted, same-origin links come next
+	mixed := limitLinks(pageURL, []string{
+		"https://other.example/a", "https://example.com/shop/1", "https://example.com/blog/1",
+	}, "", 2)
+	if strings.Join(mixed, " ") != "https://example.com/blog/1 https://example.com/shop/1" {
+		t.Errorf("limitLinks = %v", mixed)
+	}
+}
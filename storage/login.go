@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	cdpstorage "github.com/chromedp/cdproto/storage"
+	"github.com/chromedp/chromedp"
+)
+
+// loginSettleDelay is how long to wait after submitting a login form when no
+// WaitFor selector is given, so redirects and Set-Cookie responses complete
+const loginSettleDelay = 3 * time.Second
+
+// ErrLoginFailed is returned by ArchiveURLWithOptions when the pre-auth login
+// step of ArchiveOptions.Login does not complete
+var ErrLoginFailed = errors.New("login failed")
+
+// LoginField is a form field filled in during a pre-auth login
+type LoginField struct {
+	Selector string `json:"selector"` // CSS selector of the input
+	Value    string `json:"value"`    // Text typed into the input; never logged or stored
+}
+
+// LoginConfig describes a form login performed in headless Chrome before a
+// page is captured. The session cookies it produces are used for the capture
+// only; they are not added to the shared cookie jar.
+type LoginConfig struct {
+	URL     string       `json:"url"`     // Page containing the login form
+	Fields  []LoginField `json:"fields"`  // Inputs to fill, in order
+	Submit  string       `json:"submit"`  // Optional: selector of the button to click; default submits the last field's form
+	WaitFor string       `json:"waitFor"` // Optional: selector that appears once logged in
+}
+
+// Validate checks that the login config is complete enough to run
+func (cfg *LoginConfig) Validate() error {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("login url must be an absolute http(s) URL")
+	}
+	if len(cfg.Fields) == 0 {
+		return fmt.Errorf("login requires at least one field")
+	}
+	for i, field := range cfg.Fields {
+		if strings.TrimSpace(field.Selector) == "" {
+			return fmt.Errorf("login field %d has no selector", i+1)
+		}
+	}
+	return nil
+}
+
+// loginSession holds the cookies of a completed login, in the forms needed
+// by the static fetch and by Chrome
+type loginSession struct {
+	jar     http.CookieJar
+	cookies []*network.CookieParam
+}
+
+// addCookies adds the session cookies matching req's URL to req
+func (s *loginSession) addCookies(req *http.Request) {
+	if s == nil {
+		return
+	}
+	for _, cookie := range s.jar.Cookies(req.URL) {
+		req.AddCookie(cookie)
+	}
+}
+
+// setCookies returns an action that installs the session cookies in Chrome
+func (s *loginSession) setCookies() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if s == nil || len(s.cookies) == 0 {
+			return nil
+		}
+		return network.SetCookies(s.cookies).Do(ctx)
+	})
+}
+
+// performLogin runs the login described by cfg in headless Chrome as device
+// and returns the resulting cookies. Errors name the failing step but never
+// include field values.
+func performLogin(cfg *LoginConfig, device models.DeviceProfile) (*loginSession, error) {
+	ctx, cancel := newChromeContext(screenshotTimeout)
+	defer cancel()
+
+	step := func(what string, actions ...chromedp.Action) error {
+		if err := runChrome(ctx, actions...); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("%w: timed out while %s", ErrLoginFailed, what)
+			}
+			return fmt.Errorf("%w: %s: %v", ErrLoginFailed, what, err)
+		}
+		return nil
+	}
+
+	if err := step("loading "+cfg.URL, emulateDevice(device), chromedp.Navigate(cfg.URL)); err != nil {
+		return nil, err
+	}
+	for _, field := range cfg.Fields {
+		if err := step(fmt.Sprintf("filling field '%s'", field.Selector),
+			chromedp.WaitVisible(field.Selector, chromedp.ByQuery),
+			chromedp.SendKeys(field.Selector, field.Value, chromedp.ByQuery),
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Submit != "" {
+		if err := step(fmt.Sprintf("clicking '%s'", cfg.Submit), chromedp.Click(cfg.Submit, chromedp.ByQuery)); err != nil {
+			return nil, err
+		}
+	} else {
+		last := cfg.Fields[len(cfg.Fields)-1].Selector
+		if err := step("submitting the form", chromedp.Submit(last, chromedp.ByQuery)); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.WaitFor != "" {
+		if err := step(fmt.Sprintf("waiting for '%s' after submitting", cfg.WaitFor), chromedp.WaitVisible(cfg.WaitFor, chromedp.ByQuery)); err != nil {
+			return nil, err
+		}
+	} else if err := step("waiting after submitting", chromedp.Sleep(loginSettleDelay)); err != nil {
+		return nil, err
+	}
+
+	var cookies []*network.Cookie
+	if err := step("reading cookies", chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		cookies, err = cdpstorage.GetCookies().Do(ctx)
+		return err
+	})); err != nil {
+		return nil, err
+	}
+	if len(cookies) == 0 {
+		return nil, fmt.Errorf("%w: no cookies were set", ErrLoginFailed)
+	}
+	return newLoginSession(cookies), nil
+}
+
+// newLoginSession builds a loginSession from cookies read from Chrome
+func newLoginSession(cookies []*network.Cookie) *loginSession {
+	jar, _ := cookiejar.New(nil)
+	session := &loginSession{jar: jar}
+	for _, c := range cookies {
+		host := strings.TrimPrefix(c.Domain, ".")
+		cookie := &http.Cookie{Name: c.Name, Value: c.Value, Path: c.Path, Secure: c.Secure, HttpOnly: c.HTTPOnly}
+		if strings.HasPrefix(c.Domain, ".") {
+			// Domain cookies also apply to subdomains; host-only cookies don't
+			cookie.Domain = host
+		}
+		jar.SetCookies(&url.URL{Scheme: "https", Host: host, Path: "/"}, []*http.Cookie{cookie})
+
+		session.cookies = append(session.cookies, &network.CookieParam{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HTTPOnly: c.HTTPOnly,
+			SameSite: c.SameSite,
+		})
+	}
+	return session
+}
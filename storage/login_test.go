@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+func TestLoginConfigValidate(t *testing.T) {
+	field := []LoginField{{Selector: "#user", Value: "alice"}}
+	tests := []struct {
+		name    string
+		cfg     LoginConfig
+		wantErr string
+	}{
+		{"valid", LoginConfig{URL: "https://example.com/login", Fields: field}, ""},
+		{"relative url", LoginConfig{URL: "/login", Fields: field}, "absolute http(s) URL"},
+		{"non-http scheme", LoginConfig{URL: "ftp://example.com/login", Fields: field}, "absolute http(s) URL"},
+		{"no fields", LoginConfig{URL: "https://example.com/login"}, "at least one field"},
+		{"blank selector", LoginConfig{URL: "https://example.com/login", Fields: []LoginField{field[0], {Selector: "  "}}}, "field 2 has no selector"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoginSessionCookies(t *testing.T) {
+	session := newLoginSession([]*network.Cookie{
+		{Name: "domain", Value: "1", Domain: ".example.com", Path: "/"},
+		{Name: "host", Value: "2", Domain: "example.com", Path: "/"},
+		{Name: "secure", Value: "3", Domain: "example.com", Path: "/", Secure: true},
+		{Name: "scoped", Value: "4", Domain: "example.com", Path: "/account"},
+	})
+	if len(session.cookies) != 4 {
+		t.Errorf("%d Chrome cookies, want 4", len(session.cookies))
+	}
+
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{"https://example.com/", []string{"domain", "host", "secure"}},
+		{"https://example.com/account/settings", []string{"domain", "host", "secure", "scoped"}},
+		{"http://example.com/", []string{"domain", "host"}},
+		{"https://www.example.com/", []string{"domain"}},
+		{"https://example.org/", nil},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.url, nil)
+		session.addCookies(req)
+		var got []string
+		for _, c := range req.Cookies() {
+			got = append(got, c.Name)
+		}
+		if !sameNames(got, tt.want) {
+			t.Errorf("%s: cookies %v, want %v", tt.url, got, tt.want)
+		}
+	}
+
+	// Without a login, requests are left alone
+	var none *loginSession
+	req := httptest.NewRequest("GET", "https://example.com/", nil)
+	none.addCookies(req)
+	if len(req.Cookies()) != 0 {
+		t.Errorf("nil session added cookies %v", req.Cookies())
+	}
+}
+
+// sameNames reports whether a and b hold the same names in any order
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]int)
+	for _, s := range a {
+		seen[s]++
+	}
+	for _, s := range b {
+		if seen[s] == 0 {
+			return false
+		}
+		seen[s]--
+	}
+	return true
+}
+
+func TestPerformLoginErrors(t *testing.T) {
+	defer func(old func(context.Context, ...chromedp.Action) error) { runChrome = old }(runChrome)
+	cfg := &LoginConfig{
+		URL:    "https://example.com/login",
+		Fields: []LoginField{{Selector: "#user", Value: "alice"}, {Selector: "#password", Value: "hunter2"}},
+	}
+	device, _ := ResolveDeviceProfile("", 0, 0, "", 0)
+
+	t.Run("failing step is named without field values", func(t *testing.T) {
+		runs := 0
+		runChrome = func(context.Context, ...chromedp.Action) error {
+			runs++
+			if runs == 3 {
+				return errors.New("node not found")
+			}
+			return nil
+		}
+		_, err := performLogin(cfg, device)
+		if !errors.Is(err, ErrLoginFailed) {
+			t.Fatalf("performLogin = %v, want ErrLoginFailed", err)
+		}
+		if !strings.Contains(err.Error(), "#password") {
+			t.Errorf("error %q does not name the failing field", err)
+		}
+		if strings.Contains(err.Error(), "hunter2") || strings.Contains(err.Error(), "alice") {
+			t.Errorf("error %q leaks a field value", err)
+		}
+	})
+
+	t.Run("no cookies set", func(t *testing.T) {
+		runChrome = func(context.Context, ...chromedp.Action) error { return nil }
+		_, err := performLogin(cfg, device)
+		if !errors.Is(err, ErrLoginFailed) || !strings.Contains(err.Error(), "no cookies") {
+			t.Errorf("performLogin = %v, want ErrLoginFailed for missing cookies", err)
+		}
+	})
+}
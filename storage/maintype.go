@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// defaultAllowedMainTypes are the main document types archived when
+// ARCHIVE_ALLOWED_MAIN_TYPES is unset: pages, feeds, documents and text.
+// JSON is included since content-negotiated endpoints that keep answering
+// with JSON are archived as JSON (see retryHTMLAccept).
+var defaultAllowedMainTypes = []string{
+	"text/*",
+	"application/xhtml+xml",
+	"application/xml",
+	"application/rss+xml",
+	"application/atom+xml",
+	"application/pdf",
+	"application/json",
+}
+
+// allowedMainTypes is the default for ArchiveOptions.AllowedMainTypes
+// (ARCHIVE_ALLOWED_MAIN_TYPES)
+var allowedMainTypes = envAllowedMainTypes("ARCHIVE_ALLOWED_MAIN_TYPES")
+
+// UnsupportedMainTypeError is returned when the archived URL answers with a
+// Content-Type outside ArchiveOptions.AllowedMainTypes. The body is not read.
+type UnsupportedMainTypeError struct {
+	URL         string
+	ContentType string
+}
+
+func (e *UnsupportedMainTypeError) Error() string {
+	return fmt.Sprintf("'%s' is served as %s, which is not an allowed main document type", e.URL, e.ContentType)
+}
+
+// envAllowedMainTypes reads a comma-separated list of media type patterns,
+// defaulting to defaultAllowedMainTypes
+func envAllowedMainTypes(key string) []string {
+	var patterns []string
+	for _, p := range envList(key) {
+		p = strings.ToLower(p)
+		if p == "*" {
+			p = "*/*"
+		}
+		if !strings.Contains(p, "/") {
+			log.Printf("Invalid %s entry '%s', expected type/subtype, type/* or */*", key, p)
+			continue
+		}
+		patterns = append(patterns, p)
+	}
+	if len(patterns) == 0 {
+		return defaultAllowedMainTypes
+	}
+	return patterns
+}
+
+// mainTypeAllowed reports whether the media type mediaType (without
+// parameters) matches one of patterns: type/subtype, type/* or */*.
+// Responses without a Content-Type are allowed, as they are taken for HTML.
+func mainTypeAllowed(mediaType string, patterns []string) bool {
+	if mediaType == "" {
+		return true
+	}
+	mediaType = strings.ToLower(mediaType)
+	major, _, _ := strings.Cut(mediaType, "/")
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "*" || p == "*/*" || p == mediaType || p == major+"/*" {
+			return true
+		}
+	}
+	return false
+}
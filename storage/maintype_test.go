@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"archive-lite/tests"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMainTypeAllowed(t *testing.T) {
+	cases := []struct {
+		mediaType string
+		patterns  []string
+		want      bool
+	}{
+		{"text/html", defaultAllowedMainTypes, true},
+		{"text/plain", defaultAllowedMainTypes, true},
+		{"application/pdf", defaultAllowedMainTypes, true},
+		{"application/rss+xml", defaultAllowedMainTypes, true},
+		{"", defaultAllowedMainTypes, true},
+		{"video/mp4", defaultAllowedMainTypes, false},
+		{"application/octet-stream", defaultAllowedMainTypes, false},
+		{"Video/MP4", []string{"video/*"}, true},
+		{"image/png", []string{"video/*"}, false},
+		{"application/zip", []string{"*/*"}, true},
+		{"application/zip", []string{" * "}, true},
+	}
+	for _, c := range cases {
+		if got := mainTypeAllowed(c.mediaType, c.patterns); got != c.want {
+			t.Errorf("mainTypeAllowed(%q, %v) = %v, want %v", c.mediaType, c.patterns, got, c.want)
+		}
+	}
+}
+
+func TestArchiveChecksMainType(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/report.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			fmt.Fprint(w, "%PDF-1.4 report")
+		case "/clip.mp4":
+			w.Header().Set("Content-Type", "video/mp4")
+			fmt.Fprint(w, "not really a video")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	origRaw, origAssets, origNoDelay, origScreenshots := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate, captureScreenshots
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate, captureScreenshots = origNoDelay, origScreenshots
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate, captureScreenshots = true, false
+
+	allowedURL, rejectedURL := server.URL+"/report.pdf", server.URL+"/clip.mp4"
+	t.Cleanup(func() { db.Where("request_url IN ?", []string{allowedURL, rejectedURL}).Delete(&models.ArchiveEntry{}) })
+
+	opts := DefaultArchiveOptions()
+	opts.AllowedMainTypes = defaultAllowedMainTypes
+	if _, err := ArchiveURLWithOptions(db, allowedURL, opts); err != nil {
+		t.Errorf("archive of application/pdf: %v", err)
+	}
+
+	_, err = ArchiveURLWithOptions(db, rejectedURL, opts)
+	var typeErr *UnsupportedMainTypeError
+	if !errors.As(err, &typeErr) || typeErr.ContentType != "video/mp4" {
+		t.Fatalf("archive of video/mp4 = %v, want an UnsupportedMainTypeError", err)
+	}
+	var count int64
+	db.Model(&models.ArchiveEntry{}).Where("request_url = ?", rejectedURL).Count(&count)
+	if count != 0 {
+		t.Errorf("rejected URL has %d entries, want none", count)
+	}
+
+	// A request may widen the allowed types
+	opts.AllowedMainTypes = []string{"video/*"}
+	if _, err := ArchiveURLWithOptions(db, rejectedURL, opts); err != nil {
+		t.Errorf("archive of video/mp4 allowing video/*: %v", err)
+	}
+}
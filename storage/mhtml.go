@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// storeMHTML is the default for ArchiveOptions.MHTML (ARCHIVE_MHTML)
+var storeMHTML = envBool("ARCHIVE_MHTML", false)
+
+// CaptureMHTML renders targetURL in headless Chrome and writes it to
+// outputPath as MHTML, a single multipart/related file holding the page and
+// the resources Chrome loaded for it. The file is written atomically; on any
+// error, including ErrScreenshotTimeout, no file is left behind.
+func CaptureMHTML(targetURL, outputPath string) error {
+	return captureMHTML(targetURL, outputPath, DefaultDeviceProfile(), dismissSelectors, nil)
+}
+
+// captureMHTML is CaptureMHTML rendering the page as device, with the cookies
+// of session (if any) and the overlays matching dismiss clicked or hidden
+// first. It is bounded by ARCHIVE_SCREENSHOT_TIMEOUT_SEC like screenshots.
+func captureMHTML(targetURL, outputPath string, device models.DeviceProfile, dismiss []string, session *loginSession) error {
+	ctx, cancel := newChromeContext(screenshotTimeout)
+	defer cancel()
+
+	var snapshot string
+	tasks := chromedp.Tasks{
+		emulateDevice(device),
+		session.setCookies(),
+		chromedp.Navigate(targetURL),
+		chromedp.WaitReady("body", chromedp.ByQuery),
+	}
+	if screenshotWaitFonts {
+		tasks = append(tasks, waitForFonts())
+	}
+	if len(dismiss) > 0 {
+		tasks = append(tasks, dismissOverlays(dismiss))
+	}
+	tasks = append(tasks, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		snapshot, err = page.CaptureSnapshot().WithFormat(page.CaptureSnapshotFormatMhtml).Do(ctx)
+		return err
+	}))
+	if err := chromedp.Run(ctx, tasks); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("%w after %s for '%s'", ErrScreenshotTimeout, screenshotTimeout, targetURL)
+		}
+		return fmt.Errorf("failed to capture '%s': %w", targetURL, err)
+	}
+	if recordProvenance {
+		snapshot = withMHTMLProvenance(snapshot, provenance(time.Now(), targetURL))
+	}
+	return writeFileAtomic(outputPath, []byte(snapshot), 0644)
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// mimeOverrides maps recorded or sniffed media types to the Content-Type
+// stored content is served with (ARCHIVE_MIME_OVERRIDES)
+var mimeOverrides = envMIMEOverrides("ARCHIVE_MIME_OVERRIDES")
+
+// envMIMEOverrides reads a comma-separated list of from=to media type pairs
+func envMIMEOverrides(key string) map[string]string {
+	overrides := make(map[string]string)
+	for _, pair := range envList(key) {
+		from, to, ok := strings.Cut(pair, "=")
+		from, to = strings.ToLower(strings.TrimSpace(from)), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			log.Printf("Invalid %s entry '%s', expected from=to", key, pair)
+			continue
+		}
+		overrides[from] = to
+	}
+	return overrides
+}
+
+// ServedContentType returns the Content-Type an entry's stored content is
+// served with: the type recorded when it was archived, else one sniffed from
+// the stored file, else HTML, mapped through ARCHIVE_MIME_OVERRIDES
+func ServedContentType(entry *models.ArchiveEntry) string {
+	mediaType := strings.ToLower(entry.ContentType)
+	if mediaType == "" {
+		mediaType = sniffMediaType(entry.StoragePath)
+	}
+	if mediaType == "" {
+		mediaType = "text/html"
+	}
+
+	if override, ok := mimeOverrides[mediaType]; ok {
+		return override
+	}
+	if mediaType == "text/html" {
+		return "text/html; charset=utf-8"
+	}
+	return mediaType
+}
+
+// sniffMediaType detects the media type of a file from its first bytes; it
+// returns "" when the file can't be read or its type is not recognised
+func sniffMediaType(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return ""
+	}
+	detected := http.DetectContentType(head[:n])
+	mediaType, _, err := mime.ParseMediaType(detected)
+	if err != nil || mediaType == "application/octet-stream" {
+		return ""
+	}
+	return mediaType
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"archive-lite/config"
+	"log"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	htmlOutputRaw    = "raw"
+	htmlOutputMinify = "minify"
+)
+
+// htmlOutputMode selects how stored HTML is serialized (ARCHIVE_HTML_OUTPUT)
+var htmlOutputMode = parseHTMLOutputMode(config.Getenv("ARCHIVE_HTML_OUTPUT"))
+
+func parseHTMLOutputMode(v string) string {
+	switch strings.ToLower(v) {
+	case "", htmlOutputRaw:
+		return htmlOutputRaw
+	case htmlOutputMinify:
+		return htmlOutputMinify
+	default:
+		log.Printf("Invalid ARCHIVE_HTML_OUTPUT '%s', using '%s'", v, htmlOutputRaw)
+		return htmlOutputRaw
+	}
+}
+
+// whitespaceInsensitiveParents are elements whose whitespace-only children
+// never affect rendering and can be dropped entirely
+var whitespaceInsensitiveParents = map[string]bool{
+	"html": true, "head": true, "table": true, "thead": true, "tbody": true,
+	"tfoot": true, "tr": true, "ul": true, "ol": true, "dl": true,
+	"select": true, "optgroup": true, "colgroup": true, "picture": true,
+}
+
+// whitespacePreservingElements keep their contents byte-for-byte
+var whitespacePreservingElements = map[string]bool{
+	"pre": true, "textarea": true, "script": true, "style": true,
+	"listing": true, "plaintext": true, "xmp": true,
+}
+
+// minifyNode conservatively shrinks a parsed document in place: comments are
+// removed (except IE conditional comments), whitespace-only text is dropped
+// where it cannot render and runs of whitespace elsewhere collapse to a
+// single space. Contents of <pre>, <textarea>, <script> and <style> are never
+// touched.
+func minifyNode(n *html.Node) {
+	if n.Type == html.ElementNode && whitespacePreservingElements[n.Data] {
+		return
+	}
+
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch c.Type {
+		case html.CommentNode:
+			if !strings.HasPrefix(strings.TrimSpace(c.Data), "[if") {
+				n.RemoveChild(c)
+			}
+		case html.TextNode:
+			if strings.TrimSpace(c.Data) == "" && (n.Type == html.DocumentNode || whitespaceInsensitiveParents[n.Data]) {
+				n.RemoveChild(c)
+			} else {
+				c.Data = collapseWhitespace(c.Data)
+			}
+		case html.ElementNode:
+			minifyNode(c)
+		}
+		c = next
+	}
+}
+
+// collapseWhitespace replaces every run of HTML whitespace with a single space
+func collapseWhitespace(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	inSpace := false
+	for _, r := range s {
+		switch r {
+		case ' ', '\t', '\n', '\r', '\f':
+			if !inSpace {
+				b.WriteByte(' ')
+				inSpace = true
+			}
+		default:
+			b.WriteRune(r)
+			inSpace = false
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestMinifyNode(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			"pre content kept byte-for-byte",
+			"<pre>  line 1\n\n    line 2  </pre>",
+			"<html><head></head><body><pre>  line 1\n\n    line 2  </pre></body></html>",
+		},
+		{
+			"textarea content kept byte-for-byte",
+			"<textarea>  a\n\n  b </textarea>",
+			"<html><head></head><body><textarea>  a\n\n  b </textarea></body></html>",
+		},
+		{
+			"script content kept byte-for-byte",
+			"<script>\n  var  s = 'a   b';\n  // comment\n</script>",
+			"<html><head><script>\n  var  s = 'a   b';\n  // comment\n</script></head><body></body></html>",
+		},
+		{
+			"comments removed, IE conditional comments kept",
+			"<p>a<!-- note -->b</p><!--[if lt IE 9]><script src=\"html5shiv.js\"></script><![endif]-->",
+			"<html><head></head><body><p>ab</p><!--[if lt IE 9]><script src=\"html5shiv.js\"></script><![endif]--></body></html>",
+		},
+		{
+			"whitespace between inline elements collapses to one space",
+			"<p><b>a</b> \n\t <i>b</i></p>",
+			"<html><head></head><body><p><b>a</b> <i>b</i></p></body></html>",
+		},
+		{
+			"whitespace-only text dropped under table",
+			"<table>\n  <tbody>\n    <tr>\n      <td>x</td>\n    </tr>\n  </tbody>\n</table>",
+			"<html><head></head><body><table><tbody><tr><td>x</td></tr></tbody></table></body></html>",
+		},
+		{
+			"whitespace-only text dropped under ul",
+			"<ul>\n  <li>one</li>\n  <li>two</li>\n</ul>",
+			"<html><head></head><body><ul><li>one</li><li>two</li></ul></body></html>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := html.Parse(strings.NewReader(tt.in))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			minifyNode(doc)
+			var out strings.Builder
+			if err := html.Render(&out, doc); err != nil {
+				t.Fatalf("render: %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("minified\n%q\nwant\n%q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHTMLOutputMode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", htmlOutputRaw},
+		{"raw", htmlOutputRaw},
+		{"minify", htmlOutputMinify},
+		{"MINIFY", htmlOutputMinify},
+		{"compress", htmlOutputRaw},
+	}
+	for _, tt := range tests {
+		if got := parseHTMLOutputMode(tt.in); got != tt.want {
+			t.Errorf("parseHTMLOutputMode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"archive-lite/config"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// negotiationHeaderNames are the request headers servers commonly vary on
+// (Vary: User-Agent, Accept, Accept-Language); their values are recorded on
+// each entry as its negotiation context
+var negotiationHeaderNames = []string{"User-Agent", "Accept", "Accept-Language"}
+
+// replayNegotiation sends an entry's recorded negotiation headers again when
+// it is refetched, so its snapshots stay comparable
+// (ARCHIVE_REFETCH_NEGOTIATION=original); with "current" a refetch sends the
+// headers a new archive would
+var replayNegotiation = envNegotiationMode("ARCHIVE_REFETCH_NEGOTIATION")
+
+// retryHTMLAccept fetches a page again with htmlOnlyAccept when it was
+// served as JSON despite the browser-like Accept header (ARCHIVE_RETRY_HTML_ACCEPT)
+var retryHTMLAccept = envBool("ARCHIVE_RETRY_HTML_ACCEPT", false)
+
+// htmlOnlyAccept is the Accept header of the retry made for retryHTMLAccept
+const htmlOnlyAccept = "text/html"
+
+// envNegotiationMode reads the refetch negotiation mode, "original" or "current"
+func envNegotiationMode(key string) bool {
+	switch v := strings.ToLower(config.Getenv(key)); v {
+	case "", "original":
+		return true
+	case "current":
+		return false
+	default:
+		log.Printf("Invalid %s '%s', expected 'original' or 'current'; replaying original headers", key, v)
+		return true
+	}
+}
+
+// setNegotiationHeaders sets the content-negotiation headers of a page
+// request: the User-Agent of opts.Device, then any replayed values from
+// opts.negotiation, then opts.Accept. It runs after setProperHeaders.
+func setNegotiationHeaders(req *http.Request, opts ArchiveOptions) {
+	if opts.Device.UserAgent != "" {
+		req.Header.Set("User-Agent", opts.Device.UserAgent)
+	}
+	for _, name := range negotiationHeaderNames {
+		if v := opts.negotiation[name]; v != "" {
+			req.Header.Set(name, v)
+		}
+	}
+	if opts.Accept != "" {
+		req.Header.Set("Accept", opts.Accept)
+	}
+}
+
+// negotiationContext returns the negotiation headers a page request made
+// with opts sends, for storing on the entry
+func negotiationContext(opts ArchiveOptions) map[string]string {
+	req := &http.Request{Header: make(http.Header)}
+	setProperHeaders(req)
+	setNegotiationHeaders(req, opts)
+	context := make(map[string]string, len(negotiationHeaderNames))
+	for _, name := range negotiationHeaderNames {
+		if v := req.Header.Get(name); v != "" {
+			context[name] = v
+		}
+	}
+	return context
+}
+
+// isJSONMediaType reports whether mediaType is JSON (application/json or a
+// +json type such as application/ld+json)
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"archive-lite/tests"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRefetchReplaysNegotiationHeaders(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/page" {
+			http.NotFound(w, r)
+			return
+		}
+		seen = append(seen, r.Header.Get("Accept-Language"))
+		w.Header().Set("Vary", "Accept-Language")
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><p>%s</p></body></html>`, r.Header.Get("Accept-Language"))
+	}))
+	defer server.Close()
+
+	origRaw, origAssets, origNoDelay, origScreenshots, origReplay := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate, captureScreenshots, replayNegotiation
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate, captureScreenshots, replayNegotiation = origNoDelay, origScreenshots, origReplay
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate, captureScreenshots = true, false
+
+	pageURL := server.URL + "/page"
+	t.Cleanup(func() { db.Where("request_url = ?", pageURL).Delete(&models.ArchiveEntry{}) })
+
+	entry, err := ArchiveURLWithOptions(db, pageURL, DefaultArchiveOptions())
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if entry.Negotiation["Accept-Language"] != seen[0] || entry.Negotiation["User-Agent"] == "" {
+		t.Fatalf("Negotiation = %v, sent Accept-Language %q", entry.Negotiation, seen[0])
+	}
+	if got := entry.Headers["Vary"]; len(got) != 1 || got[0] != "Accept-Language" {
+		t.Errorf("Vary header not stored: %v", entry.Headers)
+	}
+
+	// An entry archived with other negotiation headers is refetched with them
+	entry.Negotiation["Accept-Language"] = "fr"
+	replayNegotiation = true
+	if err := RefetchEntry(db, entry, pageURL, false); err != nil {
+		t.Fatalf("refetch: %v", err)
+	}
+	if last := seen[len(seen)-1]; last != "fr" {
+		t.Errorf("refetch sent Accept-Language %q, want the recorded \"fr\"", last)
+	}
+	if entry.Negotiation["Accept-Language"] != "fr" {
+		t.Errorf("Negotiation after refetch = %v", entry.Negotiation)
+	}
+
+	replayNegotiation = false
+	if err := RefetchEntry(db, entry, pageURL, false); err != nil {
+		t.Fatalf("refetch: %v", err)
+	}
+	if last := seen[len(seen)-1]; last != seen[0] {
+		t.Errorf("refetch with current headers sent Accept-Language %q, want %q", last, seen[0])
+	}
+}
+
+func TestArchiveRetriesJSONWithHTMLAccept(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/item" {
+			http.NotFound(w, r)
+			return
+		}
+		// Anything but an explicit request for HTML gets the API representation
+		if r.Header.Get("Accept") != "text/html" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"title":"Item"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><h1>Item</h1></body></html>`)
+	}))
+	defer server.Close()
+
+	origRaw, origAssets, origNoDelay, origScreenshots, origRetry := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate, captureScreenshots, retryHTMLAccept
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate, captureScreenshots, retryHTMLAccept = origNoDelay, origScreenshots, origRetry
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate, captureScreenshots = true, false
+
+	pageURL := server.URL + "/item"
+	t.Cleanup(func() { db.Where("request_url = ?", pageURL).Delete(&models.ArchiveEntry{}) })
+
+	retryHTMLAccept = false
+	entry, err := ArchiveURLWithOptions(db, pageURL, DefaultArchiveOptions())
+	if err != nil {
+		t.Fatalf("archive without retry: %v", err)
+	}
+	if entry.ContentType != "application/json" {
+		t.Errorf("without retry: ContentType = %q, want application/json", entry.ContentType)
+	}
+
+	retryHTMLAccept = true
+	entry, err = ArchiveURLWithOptions(db, pageURL, DefaultArchiveOptions())
+	if err != nil {
+		t.Fatalf("archive with retry: %v", err)
+	}
+	if entry.ContentType != "text/html" || entry.Negotiation["Accept"] != htmlOnlyAccept {
+		t.Errorf("with retry: ContentType %q, recorded Accept %q", entry.ContentType, entry.Negotiation["Accept"])
+	}
+
+	retryHTMLAccept = false
+	opts := DefaultArchiveOptions()
+	opts.Accept = "text/html"
+	entry, err = ArchiveURLWithOptions(db, pageURL, opts)
+	if err != nil {
+		t.Fatalf("archive with Accept override: %v", err)
+	}
+	if entry.ContentType != "text/html" || entry.Negotiation["Accept"] != "text/html" {
+		t.Errorf("with override: ContentType %q, recorded Accept %q", entry.ContentType, entry.Negotiation["Accept"])
+	}
+}
@@ -0,0 +1,31 @@
+package storage
+
+import "strings"
+
+var (
+	// normalizeLineEndings converts CRLF and CR line endings in fetched HTML to LF
+	// (ARCHIVE_NORMALIZE_LINE_ENDINGS)
+	normalizeLineEndings = envBool("ARCHIVE_NORMALIZE_LINE_ENDINGS", false)
+	// trimTrailingNewline removes a single trailing newline from fetched HTML
+	// (ARCHIVE_TRIM_TRAILING_NEWLINE)
+	trimTrailingNewline = envBool("ARCHIVE_TRIM_TRAILING_NEWLINE", false)
+)
+
+// normalizeNewlines applies the configured line-ending normalization to a
+// fetched document, so the same page served with different line endings or
+// with and without a final newline produces the same stored content. By
+// default the document is returned as is.
+func normalizeNewlines(content string) string {
+	if normalizeLineEndings {
+		content = strings.ReplaceAll(content, "\r\n", "\n")
+		content = strings.ReplaceAll(content, "\r", "\n")
+	}
+	if trimTrailingNewline {
+		if trimmed, ok := strings.CutSuffix(content, "\r\n"); ok {
+			content = trimmed
+		} else {
+			content = strings.TrimSuffix(content, "\n")
+		}
+	}
+	return content
+}
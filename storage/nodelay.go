@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+var (
+	// noDelayHosts lists hosts fetched without the politeness delay (ARCHIVE_NODELAY_HOSTS).
+	// Entries match the host name or host:port exactly.
+	noDelayHosts = envList("ARCHIVE_NODELAY_HOSTS")
+	// noDelayPrivate skips the delay for localhost and loopback/private IP
+	// addresses (ARCHIVE_NODELAY_PRIVATE)
+	noDelayPrivate = envBool("ARCHIVE_NODELAY_PRIVATE", false)
+)
+
+// skipsDelay reports whether requests to targetURL bypass waitBetweenRequests
+func skipsDelay(targetURL string) bool {
+	if len(noDelayHosts) == 0 && !noDelayPrivate {
+		return false
+	}
+	u, err := url.Parse(targetURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	hostname := strings.ToLower(u.Hostname())
+	for _, h := range noDelayHosts {
+		h = strings.ToLower(h)
+		if h == hostname || h == strings.ToLower(u.Host) {
+			return true
+		}
+	}
+
+	if noDelayPrivate {
+		if hostname == "localhost" || strings.HasSuffix(hostname, ".localhost") {
+			return true
+		}
+		if ip := net.ParseIP(hostname); ip != nil {
+			return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast()
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// promoteNoscript replaces <noscript> elements with their content in stored
+// pages (ARCHIVE_PROMOTE_NOSCRIPT), so fallback images and links show when the
+// archived copy is viewed offline with scripts enabled
+var promoteNoscript = envBool("ARCHIVE_PROMOTE_NOSCRIPT", false)
+
+// noscriptNodes parses the content of a <noscript> element as HTML. With
+// scripting enabled the parser keeps that content as a single raw text node,
+// so fallback <img> and <link> elements are otherwise invisible to DOM walks.
+// It returns nil for other nodes and for empty or unparseable content.
+func noscriptNodes(n *html.Node) []*html.Node {
+	if n.Type != html.ElementNode || n.DataAtom != atom.Noscript {
+		return nil
+	}
+
+	var text strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			text.WriteString(c.Data)
+		}
+	}
+	if strings.TrimSpace(text.String()) == "" {
+		return nil
+	}
+
+	context := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(text.String()), context)
+	if err != nil {
+		return nil
+	}
+	return nodes
+}
+
+// setNoscriptNodes replaces the content of a <noscript> element with nodes,
+// serialized back to the raw text form the parser expects
+func setNoscriptNodes(n *html.Node, nodes []*html.Node) error {
+	var buf strings.Builder
+	for _, node := range nodes {
+		if err := html.Render(&buf, node); err != nil {
+			return err
+		}
+	}
+	for n.FirstChild != nil {
+		n.RemoveChild(n.FirstChild)
+	}
+	n.AppendChild(&html.Node{Type: html.TextNode, Data: buf.String()})
+	return nil
+}
+
+// promoteNoscripts replaces every <noscript> element in doc with its parsed content
+func promoteNoscripts(doc *html.Node) {
+	var noscripts []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Noscript {
+			noscripts = append(noscripts, n)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	for _, n := range noscripts {
+		for _, node := range noscriptNodes(n) {
+			n.Parent.InsertBefore(node, n)
+		}
+		n.Parent.RemoveChild(n)
+	}
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+const noscriptPage = `<!DOCTYPE html>
+<html><head>
+<noscript><link rel="stylesheet" href="/css/nojs.css"></noscript>
+</head><body>
+<img class="lazy" data-src="/img/photo.jpg">
+<noscript><img src="/img/photo-fallback.jpg" alt="Photo"></noscript>
+</body></html>`
+
+func TestExtractAssetsFromHTMLIncludesNoscriptFallbacks(t *testing.T) {
+	assets, err := extractAssetsFromHTML(noscriptPage, "https://example.com/post")
+	if err != nil {
+		t.Fatalf("extractAssetsFromHTML: %v", err)
+	}
+	for _, want := range []string{"https://example.com/img/photo-fallback.jpg", "https://example.com/css/nojs.css"} {
+		found := false
+		for _, a := range assets {
+			if a == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("extractAssetsFromHTML = %v, missing noscript asset %s", assets, want)
+		}
+	}
+}
+
+func TestModifyHTMLPathsRewritesNoscriptFallbacks(t *testing.T) {
+	const entryUUID = "00000000-0000-0000-0000-000000000000"
+	img := localAssetPrefix + generateAssetFileName("https://example.com/img/photo-fallback.jpg", entryUUID)
+
+	defer func(old bool) { promoteNoscript = old }(promoteNoscript)
+
+	promoteNoscript = false
+	got, err := modifyHTMLPaths(noscriptPage, entryUUID, "https://example.com/post", nil)
+	if err != nil {
+		t.Fatalf("modifyHTMLPaths: %v", err)
+	}
+	if !strings.Contains(got, `<noscript><img src="`+img+`" alt="Photo"/></noscript>`) {
+		t.Errorf("noscript fallback not rewritten to %s:\n%s", img, got)
+	}
+	if names := localAssetNames(got); len(names) != 2 {
+		t.Errorf("localAssetNames = %v, want the noscript image and stylesheet", names)
+	}
+
+	promoteNoscript = true
+	got, err = modifyHTMLPaths(noscriptPage, entryUUID, "https://example.com/post", nil)
+	if err != nil {
+		t.Fatalf("modifyHTMLPaths: %v", err)
+	}
+	if strings.Contains(got, "<noscript>") {
+		t.Errorf("noscript element should be promoted:\n%s", got)
+	}
+	if !strings.Contains(got, `<img src="`+img+`" alt="Photo"/>`) {
+		t.Errorf("promoted fallback image missing:\n%s", got)
+	}
+}
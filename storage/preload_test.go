@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+// firstLink parses a document and returns its first <link> element
+func firstLink(t *testing.T, doc string) *html.Node {
+	t.Helper()
+	root, err := html.Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("parse %s: %v", doc, err)
+	}
+	var link *html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if link == nil && n.Type == html.ElementNode && n.Data == "link" {
+			link = n
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+	if link == nil {
+		t.Fatalf("no <link> in %s", doc)
+	}
+	return link
+}
+
+func TestIsArchivableLink(t *testing.T) {
+	cases := []struct {
+		link string
+		want bool
+	}{
+		{`<link rel="stylesheet" href="/a.css">`, true},
+		{`<link rel="alternate stylesheet" href="/dark.css">`, true},
+		{`<link rel="preload" as="font" href="/f.woff2" crossorigin>`, true},
+		{`<link rel="preload" as="style" href="/a.css">`, true},
+		{`<link rel="preload" as="script" href="/a.js">`, true},
+		{`<link rel="preload" as="image" href="/hero.jpg">`, true},
+		{`<link rel="preload" href="/unknown">`, true},
+		{`<link rel="preload" as="fetch" href="/api/data.json" crossorigin>`, false},
+		{`<link rel="preload" as="document" href="/next">`, false},
+		{`<link rel="prefetch" as="script" href="/next.js">`, true},
+		{`<link rel="modulepreload" href="/app.mjs">`, true},
+		{`<link rel="preconnect" href="https://fonts.gstatic.com">`, false},
+		{`<link rel="dns-prefetch" href="//cdn.example.net">`, false},
+		{`<link rel="canonical" href="https://example.com/">`, false},
+		{`<link rel="icon" href="/favicon.png">`, true},
+	}
+	for _, c := range cases {
+		if got := isArchivableLink(firstLink(t, c.link)); got != c.want {
+			t.Errorf("isArchivableLink(%s) = %v, want %v", c.link, got, c.want)
+		}
+	}
+}
+
+func TestExtractAssetsFromHTMLPreloads(t *testing.T) {
+	page := `<!DOCTYPE html><html><head>
+<link rel="preconnect" href="https://fonts.gstatic.com">
+<link rel="dns-prefetch" href="//cdn.example.net">
+<link rel="preload" as="font" href="/fonts/inter.woff2" type="font/woff2" crossorigin>
+<link rel="preload" as="fetch" href="/api/data.json" crossorigin>
+<link rel="modulepreload" href="/js/app.mjs">
+<script type="module" src="/js/main.mjs"></script>
+</head><body></body></html>`
+
+	assets, err := extractAssetsFromHTML(page, "https://example.com/post")
+	if err != nil {
+		t.Fatalf("extractAssetsFromHTML: %v", err)
+	}
+	got := append([]string(nil), assets...)
+	sort.Strings(got)
+	want := []string{
+		"https://example.com/fonts/inter.woff2",
+		"https://example.com/js/app.mjs",
+		"https://example.com/js/main.mjs",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("extractAssetsFromHTML = %v, want %v", assets, want)
+	}
+
+	const entryUUID = "00000000-0000-0000-0000-000000000000"
+	rewritten, err := modifyHTMLPaths(page, entryUUID, "https://example.com/post", nil)
+	if err != nil {
+		t.Fatalf("modifyHTMLPaths: %v", err)
+	}
+	for _, want := range []string{
+		`href="/data/assets/` + generateAssetFileName("https://example.com/fonts/inter.woff2", entryUUID) + `"`,
+		`href="/data/assets/` + generateAssetFileName("https://example.com/js/app.mjs", entryUUID) + `"`,
+		// Hints without a resource and runtime fetches keep their URLs
+		`href="https://fonts.gstatic.com"`,
+		`href="//cdn.example.net"`,
+		`href="/api/data.json"`,
+	} {
+		if !strings.Contains(rewritten, want) {
+			t.Errorf("modifyHTMLPaths output missing %s:\n%s", want, rewritten)
+		}
+	}
+}
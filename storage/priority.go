@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"log"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Asset kinds accepted in ARCHIVE_ASSET_PRIORITY
+const (
+	assetKindStyle    = "style"
+	assetKindFont     = "font"
+	assetKindScript   = "script"
+	assetKindImage    = "image"
+	assetKindMedia    = "media"    // Audio, video and subtitle tracks
+	assetKindDocument = "document" // Frames
+	assetKindOther    = "other"
+)
+
+// defaultAssetPriority fetches what a page needs to render before what it
+// merely displays
+var defaultAssetPriority = []string{
+	assetKindStyle, assetKindFont, assetKindScript, assetKindImage, assetKindMedia, assetKindDocument, assetKindOther,
+}
+
+// assetPriority ranks asset kinds in download order (ARCHIVE_ASSET_PRIORITY)
+var assetPriority = envAssetPriority("ARCHIVE_ASSET_PRIORITY")
+
+// envAssetPriority reads a comma-separated list of asset kinds. Kinds left
+// out keep their default order after the listed ones.
+func envAssetPriority(key string) map[string]int {
+	known := make(map[string]bool)
+	for _, kind := range defaultAssetPriority {
+		known[kind] = true
+	}
+	var kinds []string
+	for _, kind := range envList(key) {
+		kind = strings.ToLower(kind)
+		if !known[kind] {
+			log.Printf("Invalid %s entry '%s', expected one of %s", key, kind, strings.Join(defaultAssetPriority, ", "))
+			continue
+		}
+		kinds = append(kinds, kind)
+	}
+
+	rank := make(map[string]int)
+	for _, kind := range append(kinds, defaultAssetPriority...) {
+		if _, ok := rank[kind]; !ok {
+			rank[kind] = len(rank)
+		}
+	}
+	return rank
+}
+
+// elementAssetKind returns the kind of asset an element references through
+// its src/href attribute
+func elementAssetKind(n *html.Node) string {
+	switch n.Data {
+	case "link":
+		if hasRel(n, "stylesheet") {
+			return assetKindStyle
+		}
+		for _, rel := range linkRelTypes(n) {
+			switch rel {
+			case "preload", "prefetch":
+				switch strings.ToLower(getAttr(n, "as")) {
+				case "style":
+					return assetKindStyle
+				case "font":
+					return assetKindFont
+				case "script":
+					return assetKindScript
+				case "image":
+					return assetKindImage
+				case "audio", "video", "track":
+					return assetKindMedia
+				}
+			case "modulepreload":
+				return assetKindScript
+			case "icon", "apple-touch-icon":
+				return assetKindImage
+			}
+		}
+	case "script":
+		return assetKindScript
+	case "img":
+		return assetKindImage
+	case "source":
+		if n.Parent != nil && n.Parent.Data == "picture" {
+			return assetKindImage
+		}
+		return assetKindMedia
+	case "track":
+		return assetKindMedia
+	case "iframe":
+		return assetKindDocument
+	}
+	// e.g. <link rel="manifest">: guess from the URL
+	if attrName := assetAttrName(n); attrName != "" {
+		return urlAssetKind(getAttr(n, attrName))
+	}
+	return assetKindOther
+}
+
+// urlAssetKind guesses an asset's kind from its URL's file extension, for
+// assets referenced from stylesheets
+func urlAssetKind(assetURL string) string {
+	ext := ""
+	if u, err := url.Parse(assetURL); err == nil {
+		ext = strings.ToLower(path.Ext(u.Path))
+	}
+	switch ext {
+	case ".css":
+		return assetKindStyle
+	case ".woff", ".woff2", ".ttf", ".otf", ".eot":
+		return assetKindFont
+	case ".js", ".mjs":
+		return assetKindScript
+	case ".png", ".jpg", ".jpeg", ".gif", ".webp", ".avif", ".svg", ".ico", ".bmp":
+		return assetKindImage
+	case ".mp4", ".webm", ".ogg", ".mp3", ".wav", ".vtt":
+		return assetKindMedia
+	}
+	return assetKindOther
+}
+
+// prioritizeAssets stably sorts asset URLs by assetPriority, so that a
+// download cut short has fetched the render-critical resources first. kinds
+// holds the kind of each URL found in the page; other URLs are classified by
+// urlAssetKind.
+func prioritizeAssets(assets []string, kinds map[string]string) {
+	rankOf := func(assetURL string) int {
+		kind, ok := kinds[assetURL]
+		if !ok {
+			kind = urlAssetKind(assetURL)
+		}
+		return assetPriority[kind]
+	}
+	sort.SliceStable(assets, func(i, j int) bool {
+		return rankOf(assets[i]) < rankOf(assets[j])
+	})
+}
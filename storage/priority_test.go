@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractAssetsPrioritizesRenderCritical(t *testing.T) {
+	page := `<html><head>
+<link rel="icon" href="/favicon.png">
+<script src="/app.js"></script>
+<link rel="preload" as="font" href="/font.woff2" crossorigin>
+<link rel="stylesheet" href="/site.css">
+</head><body>
+<img src="/hero.jpg">
+<video><source src="/clip.mp4"></video>
+<iframe src="/embed.html"></iframe>
+</body></html>`
+
+	orig := assetPriority
+	defer func() { assetPriority = orig }()
+
+	assetPriority = envAssetPriority("ARCHIVE_ASSET_PRIORITY_UNSET")
+	assets, err := extractAssetsFromHTML(page, "https://example.com/")
+	if err != nil {
+		t.Fatalf("extractAssetsFromHTML: %v", err)
+	}
+	want := []string{
+		"https://example.com/site.css",
+		"https://example.com/font.woff2",
+		"https://example.com/app.js",
+		"https://example.com/favicon.png",
+		"https://example.com/hero.jpg",
+		"https://example.com/clip.mp4",
+		"https://example.com/embed.html",
+	}
+	if !reflect.DeepEqual(assets, want) {
+		t.Errorf("default order:\n got %v\nwant %v", assets, want)
+	}
+
+	t.Setenv("ARCHIVE_ASSET_PRIORITY", "image,bogus,style")
+	assetPriority = envAssetPriority("ARCHIVE_ASSET_PRIORITY")
+	assets, _ = extractAssetsFromHTML(page, "https://example.com/")
+	want = []string{
+		"https://example.com/favicon.png",
+		"https://example.com/hero.jpg",
+		"https://example.com/site.css",
+		"https://example.com/font.woff2",
+		"https://example.com/app.js",
+		"https://example.com/clip.mp4",
+		"https://example.com/embed.html",
+	}
+	if !reflect.DeepEqual(assets, want) {
+		t.Errorf("configured order:\n got %v\nwant %v", assets, want)
+	}
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Version identifies the archive-lite build in provenance records. Release
+// builds set it with -ldflags "-X archive-lite/storage.Version=v1.2.0".
+var Version = "dev"
+
+// recordProvenance marks stored pages and MHTML captures with the tool,
+// version, time and URL they were archived with (ARCHIVE_PROVENANCE)
+var recordProvenance = envBool("ARCHIVE_PROVENANCE", false)
+
+// provenanceMetaName is the name of the <meta> added to stored pages
+const provenanceMetaName = "archived-by"
+
+// provenance describes an archive of originalURL made at archivedAt, e.g.
+// "archive-lite v1.2.0 (2024-05-01T12:00:00Z, https://example.com/)"
+func provenance(archivedAt time.Time, originalURL string) string {
+	return fmt.Sprintf("archive-lite %s (%s, %s)", Version, archivedAt.UTC().Format(time.RFC3339), originalURL)
+}
+
+// injectProvenance appends a <meta name="archived-by"> holding content to
+// the <head> of doc. Existing elements, including an archived-by meta of a
+// page that was itself an archive, are left as they are.
+func injectProvenance(doc *html.Node, content string) {
+	head := findElement(doc, "head")
+	if head == nil {
+		// html.Parse always creates a <head>; documents built otherwise are skipped
+		return
+	}
+	head.AppendChild(&html.Node{
+		Type:     html.ElementNode,
+		Data:     "meta",
+		DataAtom: atom.Meta,
+		Attr: []html.Attribute{
+			{Key: "name", Val: provenanceMetaName},
+			{Key: "content", Val: content},
+		},
+	})
+}
+
+// withMHTMLProvenance adds an X-Archived-By header holding content to the
+// top-level headers of an MHTML snapshot
+func withMHTMLProvenance(snapshot, content string) string {
+	end := strings.Index(snapshot, "\r\n\r\n")
+	if end < 0 {
+		return snapshot
+	}
+	return snapshot[:end] + "\r\nX-Archived-By: " + content + snapshot[end:]
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"archive-lite/tests"
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchiveRecordsProvenance(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><meta charset="utf-8"><title>Post</title></head><body><p>Hello</p></body></html>`)
+	}))
+	defer server.Close()
+
+	origRaw, origAssets, origNoDelay, origScreenshots := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate, captureScreenshots
+	origProvenance, origVersion := recordProvenance, Version
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate, captureScreenshots = origNoDelay, origScreenshots
+		recordProvenance, Version = origProvenance, origVersion
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate, captureScreenshots = true, false
+	recordProvenance, Version = true, "v1.2.3"
+
+	pageURL := server.URL + "/post"
+	t.Cleanup(func() { db.Where("request_url = ?", pageURL).Delete(&models.ArchiveEntry{}) })
+
+	entry, err := ArchiveURLWithOptions(db, pageURL, DefaultArchiveOptions())
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	page, err := os.ReadFile(entry.StoragePath)
+	if err != nil {
+		t.Fatalf("read page: %v", err)
+	}
+
+	want := fmt.Sprintf(`<meta name="archived-by" content="archive-lite v1.2.3 (%s, %s)"/></head>`,
+		entry.ArchivedAt.UTC().Format(time.RFC3339), pageURL)
+	if !strings.Contains(string(page), want) {
+		t.Errorf("stored page missing %s:\n%s", want, page)
+	}
+	if !strings.Contains(string(page), `<meta charset="utf-8"/><title>Post</title>`) {
+		t.Errorf("stored page changed the existing <head> content:\n%s", page)
+	}
+
+	var warc bytes.Buffer
+	if err := WriteWARC(&warc, entry, nil, false); err != nil {
+		t.Fatalf("WriteWARC: %v", err)
+	}
+	for _, field := range []string{
+		"software: archive-lite v1.2.3\r\n",
+		"archived-by: " + provenance(entry.ArchivedAt, pageURL) + "\r\n",
+	} {
+		if !strings.Contains(warc.String(), field) {
+			t.Errorf("WARC warcinfo missing %q", field)
+		}
+	}
+}
+
+func TestInjectProvenance(t *testing.T) {
+	doc := `<html><head><title>Post</title></head><body></body></html>`
+	parsed, err := parseAssetPage(doc, "https://example.com/")
+	if err != nil {
+		t.Fatalf("parseAssetPage: %v", err)
+	}
+	injectProvenance(parsed.doc, "archive-lite dev (2024-05-01T12:00:00Z, https://example.com/)")
+	got, err := parsed.rewrite("00000000-0000-0000-0000-000000000000", nil)
+	if err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	want := `<head><title>Post</title><meta name="archived-by" content="archive-lite dev (2024-05-01T12:00:00Z, https://example.com/)"/></head>`
+	if !strings.Contains(got, want) {
+		t.Errorf("rewritten page = %s, want it to contain %s", got, want)
+	}
+}
+
+func TestWithMHTMLProvenance(t *testing.T) {
+	snapshot := "From: <Saved by Blink>\r\nSubject: Post\r\nMIME-Version: 1.0\r\n\r\n------MultipartBoundary--\r\n"
+	got := withMHTMLProvenance(snapshot, "archive-lite dev (2024-05-01T12:00:00Z, https://example.com/)")
+	want := "From: <Saved by Blink>\r\nSubject: Post\r\nMIME-Version: 1.0\r\nX-Archived-By: archive-lite dev (2024-05-01T12:00:00Z, https://example.com/)\r\n\r\n------MultipartBoundary--\r\n"
+	if got != want {
+		t.Errorf("withMHTMLProvenance = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWaitBetweenRequestsPerHost(t *testing.T) {
+	origDelay, origNoDelay, origHosts := settings.RequestDelay, noDelayPrivate, noDelayHosts
+	defer func() { settings.RequestDelay, noDelayPrivate, noDelayHosts = origDelay, origNoDelay, origHosts }()
+	settings.RequestDelay, noDelayPrivate, noDelayHosts = 200*time.Millisecond, false, nil
+	t.Cleanup(func() {
+		hostSlotsMu.Lock()
+		delete(hostSlots, "a.example")
+		delete(hostSlots, "b.example")
+		hostSlotsMu.Unlock()
+	})
+
+	// First requests to two different hosts must not wait for each other
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, u := range []string{"https://a.example/1", "https://b.example/1"} {
+		wg.Add(1)
+		go func(u string) {
+			defer wg.Done()
+			waitBetweenRequests(u)
+		}(u)
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed >= settings.RequestDelay {
+		t.Errorf("requests to different hosts took %v, want no delay", elapsed)
+	}
+
+	// A second request to the same host waits out the delay
+	start = time.Now()
+	waitBetweenRequests("https://a.example/2")
+	if elapsed := time.Since(start); elapsed < settings.RequestDelay/2 {
+		t.Errorf("second request to the same host took %v, want about %v", elapsed, settings.RequestDelay)
+	}
+}
+
+func TestEvictIdleHosts(t *testing.T) {
+	origDelay := settings.RequestDelay
+	defer func(old map[string]*hostSlot) {
+		hostSlots = old
+		settings.RequestDelay = origDelay
+	}(hostSlots)
+	settings.RequestDelay = time.Minute
+
+	busy := &hostSlot{last: time.Now().Add(-time.Hour)}
+	busy.mu.Lock() // A request to the host is waiting
+	defer busy.mu.Unlock()
+	hostSlots = map[string]*hostSlot{
+		"idle.example":   {last: time.Now().Add(-time.Hour)},
+		"recent.example": {last: time.Now()},
+		"busy.example":   busy,
+	}
+
+	if evicted, remaining := EvictIdleHosts(); evicted != 1 || remaining != 2 {
+		t.Errorf("EvictIdleHosts = %d, %d; want 1, 2", evicted, remaining)
+	}
+	if _, ok := hostSlots["idle.example"]; ok {
+		t.Error("idle host was kept")
+	}
+}
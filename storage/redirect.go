@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"archive-lite/config"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Policies for asset redirects to another origin (ARCHIVE_ASSET_REDIRECTS)
+const (
+	assetRedirectsAny        = "any"         // Follow redirects to any origin, e.g. a CDN
+	assetRedirectsSameOrigin = "same-origin" // Fail assets that redirect to another origin
+)
+
+var (
+	// assetRedirects controls whether assets may redirect to another origin
+	assetRedirects = envAssetRedirects("ARCHIVE_ASSET_REDIRECTS")
+	// assetMaxRedirects caps the redirects followed for a single asset
+	// (ARCHIVE_ASSET_MAX_REDIRECTS)
+	assetMaxRedirects = envInt64("ARCHIVE_ASSET_MAX_REDIRECTS", 10)
+)
+
+// envAssetRedirects reads an asset redirect policy, defaulting to any
+func envAssetRedirects(key string) string {
+	switch policy := strings.ToLower(strings.TrimSpace(config.Getenv(key))); policy {
+	case "":
+		return assetRedirectsAny
+	case assetRedirectsAny, assetRedirectsSameOrigin:
+		return policy
+	default:
+		log.Printf("Invalid %s '%s', expected any or same-origin; using any", key, policy)
+		return assetRedirectsAny
+	}
+}
+
+// checkAssetRedirect is the redirect policy of assetClient. Redirects are
+// followed up to assetMaxRedirects and only to http(s) URLs; with the
+// same-origin policy they must also stay on the asset's origin. Assets are
+// still stored under the URL the page references, so rewritten references
+// keep pointing at them; the final URL is recorded on the Asset.
+func checkAssetRedirect(req *http.Request, via []*http.Request) error {
+	if int64(len(via)) >= assetMaxRedirects {
+		return fmt.Errorf("stopped after %d redirects", len(via))
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return fmt.Errorf("redirect to unsupported scheme '%s'", req.URL.Scheme)
+	}
+	if assetRedirects == assetRedirectsSameOrigin {
+		first := via[0].URL
+		if req.URL.Scheme != first.Scheme || !strings.EqualFold(req.URL.Host, first.Host) {
+			return fmt.Errorf("redirect to another origin (%s) not allowed by ARCHIVE_ASSET_REDIRECTS", req.URL.Redacted())
+		}
+	}
+	return nil
+}
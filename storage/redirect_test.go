@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDownloadAssetFollowsCrossOriginRedirect(t *testing.T) {
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG\r\n\x1a\nimage"))
+	}))
+	defer cdn.Close()
+	// Same server under another host name, so the redirect changes origin
+	cdnURL := strings.Replace(cdn.URL, "127.0.0.1", "localhost", 1) + "/img/logo.png"
+
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, cdnURL, http.StatusFound)
+	}))
+	defer site.Close()
+
+	origRaw, origAssets, origNoDelay, origPolicy := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate, assetRedirects
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate = origNoDelay
+		assetRedirects = origPolicy
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate = true
+
+	entryUUID := "00000000-0000-0000-0000-000000000000"
+	page := fmt.Sprintf(`<html><body><img src="%s/logo.png"></body></html>`, site.URL)
+	assetURL := site.URL + "/logo.png"
+
+	downloaded, records := downloadAssetsParallel([]string{assetURL}, entryUUID, 1, "", false)
+	fileName, ok := downloaded[assetURL]
+	if !ok {
+		t.Fatalf("redirected asset not downloaded: %+v", records)
+	}
+	if records[0].FinalURL != cdnURL {
+		t.Errorf("FinalURL = %q, want %q", records[0].FinalURL, cdnURL)
+	}
+	if _, err := os.Stat(filepath.Join(settings.AssetsDir, fileName)); err != nil {
+		t.Errorf("asset file not stored: %v", err)
+	}
+
+	rewritten, err := modifyHTMLPaths(page, entryUUID, site.URL+"/", nil)
+	if err != nil {
+		t.Fatalf("modifyHTMLPaths: %v", err)
+	}
+	if !strings.Contains(rewritten, "/data/assets/"+fileName) {
+		t.Errorf("img not rewritten to the stored asset %s: %s", fileName, rewritten)
+	}
+
+	assetRedirects = assetRedirectsSameOrigin
+	downloaded, records = downloadAssetsParallel([]string{assetURL}, entryUUID, 1, "", false)
+	if len(downloaded) != 0 || !strings.Contains(records[0].Error, "another origin") {
+		t.Errorf("same-origin policy: downloaded %v, error %q", downloaded, records[0].Error)
+	}
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"archive-lite/tests"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestArchiveWithReferer(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+
+	const referer = "https://www.google.com/"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/gated" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Referer") != referer {
+			http.Error(w, "Subscribers only", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><p>Full article</p></body></html>`)
+	}))
+	defer server.Close()
+
+	origRaw, origAssets, origNoDelay, origScreenshots := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate, captureScreenshots
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate, captureScreenshots = origNoDelay, origScreenshots
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate, captureScreenshots = true, false
+
+	pageURL := server.URL + "/gated"
+	t.Cleanup(func() { db.Where("request_url = ?", pageURL).Delete(&models.ArchiveEntry{}) })
+
+	opts := DefaultArchiveOptions()
+	opts.ArchiveNon200 = false
+	if _, err := ArchiveURLWithOptions(db, pageURL, opts); err == nil {
+		t.Fatalf("archive without referer succeeded, want 403 failure")
+	}
+
+	opts.Referer = referer
+	entry, err := ArchiveURLWithOptions(db, pageURL, opts)
+	if err != nil {
+		t.Fatalf("archive with referer: %v", err)
+	}
+	if entry.Referer != referer {
+		t.Errorf("Referer = %q, want %q", entry.Referer, referer)
+	}
+
+	// The refetch must send the recorded referer again to get past the gate
+	if err := RefetchEntry(db, entry, entry.URL, false); err != nil {
+		t.Fatalf("refetch: %v", err)
+	}
+	if entry.Referer != referer || entry.HTTPStatus != http.StatusOK {
+		t.Errorf("refetched entry: Referer %q, HTTPStatus %d", entry.Referer, entry.HTTPStatus)
+	}
+}
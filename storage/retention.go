@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gorm.io/gorm"
+)
+
+// maxSnapshotsPerURL is the number of snapshots kept per canonical URL
+// (ARCHIVE_MAX_SNAPSHOTS_PER_URL); older ones are pruned when a new snapshot
+// is archived. 0 keeps every snapshot.
+var maxSnapshotsPerURL = envInt64("ARCHIVE_MAX_SNAPSHOTS_PER_URL", 0)
+
+// pruneSnapshots removes the oldest snapshots of entry's canonical URL beyond
+// maxSnapshotsPerURL. Failures are logged rather than failing the archive.
+func pruneSnapshots(db *gorm.DB, entry *models.ArchiveEntry) {
+	if maxSnapshotsPerURL <= 0 || entry.CanonicalURL == "" {
+		return
+	}
+
+	var stale []models.ArchiveEntry
+	err := db.Where("canonical_url = ?", entry.CanonicalURL).
+		Order("archived_at desc").Order("created_at desc").
+		Offset(int(maxSnapshotsPerURL)).Find(&stale).Error
+	if err != nil {
+		fmt.Printf("Warning: failed to list snapshots of '%s' for pruning: %v\n", entry.CanonicalURL, err)
+		return
+	}
+
+	for i := range stale {
+		if stale[i].ID == entry.ID {
+			continue
+		}
+		if err := removeEntry(db, &stale[i]); err != nil {
+			fmt.Printf("Warning: failed to prune snapshot '%s' of '%s': %v\n", stale[i].ID, entry.CanonicalURL, err)
+			continue
+		}
+		appendAudit(db, "prune", &stale[i])
+		fmt.Printf("Pruned snapshot %s of %s\n", stale[i].ID, entry.CanonicalURL)
+	}
+}
+
+// removeEntry deletes an entry and its asset records, then its stored HTML,
+// text, screenshot, MHTML and asset files
+func removeEntry(db *gorm.DB, entry *models.ArchiveEntry) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("entry_id = ?", entry.ID).Delete(&models.Asset{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(entry).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, path := range []string{entry.StoragePath, entry.TextPath, entry.ScreenshotPath, entry.ThumbnailPath, entry.MHTMLPath} {
+		if path != "" {
+			os.Remove(path)
+		}
+	}
+	os.Remove(bundlePath(entry.ID))
+	os.Remove(DebugPath(entry.ID))
+	// Asset file names start with the entry ID (see generateAssetFileName)
+	if files, err := filepath.Glob(filepath.Join(settings.AssetsDir, entry.ID+"_*")); err == nil {
+		for _, file := range files {
+			os.Remove(file)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"archive-lite/tests"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPruneSnapshotsKeepsMostRecent(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+	dir := t.TempDir()
+	origRaw, origAssets := settings.RawHTMLDir, settings.AssetsDir
+	SetStorageBaseDirsForTest(dir, dir)
+	origMax := maxSnapshotsPerURL
+	maxSnapshotsPerURL = 2
+	t.Cleanup(func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		maxSnapshotsPerURL = origMax
+	})
+
+	canonicalURL := "https://example.com/prune-" + uuid.New().String()
+	base := time.Now().Add(-time.Hour)
+	var entries []*models.ArchiveEntry
+	for i := 0; i < 3; i++ {
+		id := uuid.New().String()
+		entry := &models.ArchiveEntry{
+			ID:           id,
+			URL:          canonicalURL,
+			CanonicalURL: canonicalURL,
+			StoragePath:  filepath.Join(dir, id+".html"),
+			ArchivedAt:   base.Add(time.Duration(i) * time.Minute),
+		}
+		if err := os.WriteFile(entry.StoragePath, []byte("<html></html>"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		asset := filepath.Join(dir, fmt.Sprintf("%s_0123456789abcdef.css", id))
+		if err := os.WriteFile(asset, []byte("body{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Create(entry).Error; err != nil {
+			t.Fatalf("create entry: %v", err)
+		}
+		entries = append(entries, entry)
+		pruneSnapshots(db, entry)
+	}
+
+	var remaining []models.ArchiveEntry
+	db.Where("canonical_url = ?", canonicalURL).Find(&remaining)
+	if len(remaining) != 2 {
+		t.Fatalf("remaining snapshots = %d, want 2", len(remaining))
+	}
+	for _, e := range remaining {
+		if e.ID == entries[0].ID {
+			t.Errorf("oldest snapshot %s was kept", e.ID)
+		}
+	}
+	if _, err := os.Stat(entries[0].StoragePath); !os.IsNotExist(err) {
+		t.Errorf("oldest snapshot's HTML still exists: %v", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, entries[0].ID+"_*")); len(files) != 0 {
+		t.Errorf("oldest snapshot's assets still exist: %v", files)
+	}
+	if _, err := os.Stat(entries[2].StoragePath); err != nil {
+		t.Errorf("newest snapshot's HTML was removed: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// benchmarkPage returns a page with n paragraphs, each with an image, a
+// responsive image and a link, plus the usual stylesheets and scripts
+func benchmarkPage(n int) string {
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html><html><head>
+<link rel="stylesheet" href="/site.css">
+<link rel="preload" as="font" href="/font.woff2" crossorigin>
+<script src="/app.js"></script>
+</head><body>
+<noscript><img src="/pixel.gif"></noscript>
+`)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, `<p>Paragraph %d with <a href="/post/%d">a link</a>.</p>
+<img src="/img/%d.jpg" alt="">
+<picture><source srcset="/img/%d-1x.webp 1x, /img/%d-2x.webp 2x"><img src="/img/%d.png"></picture>
+`, i, i, i, i, i, i)
+	}
+	b.WriteString(`</body></html>`)
+	return b.String()
+}
+
+// BenchmarkExtractAndRewrite compares extracting and rewriting a page's
+// assets from two separate parses with doing both on a single parsed tree,
+// as captureURL does
+func BenchmarkExtractAndRewrite(b *testing.B) {
+	page := benchmarkPage(200)
+	const entryUUID = "00000000-0000-0000-0000-000000000000"
+	const baseURL = "https://example.com/article"
+
+	b.Run("TwoParses", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := extractAssetsFromHTML(page, baseURL); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := modifyHTMLPaths(page, entryUUID, baseURL, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("SingleParse", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			parsed, err := parseAssetPage(page, baseURL)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := parsed.rewrite(entryUUID, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// singlePassPage exercises every kind of asset reference rewritten: URL
+// attributes with and without integrity, srcsets, nested <noscript>
+// fallbacks, templates, and references that are left alone
+const singlePassPage = `<!DOCTYPE html>
+<html><head>
+<meta charset="utf-8">
+<link rel="stylesheet" href="/css/site.css" integrity="sha384-abc" crossorigin="anonymous">
+<link rel="preload" as="image" href="/img/hero.jpg" imagesrcset="/img/hero-1x.jpg 1x, /img/hero-2x.jpg 2x" integrity="sha384-def">
+<link rel="preconnect" href="https://cdn.example.net">
+<link rel="canonical" href="https://example.com/article">
+<script src="https://cdn.example.net/lib.js?v=3" integrity="sha384-ghi"></script>
+<script>var inline = "<img src='/not-an-asset.png'>";</script>
+</head><body>
+<noscript><img src="/pixel.gif?id=1"><noscript><img src="/nested.gif"></noscript></noscript>
+<p>Text <a href="/other">link</a></p>
+<img src="data:image/gif;base64,R0lGODlhAQABAAAAACw=" alt="inline">
+<img src="" srcset="">
+<picture><source srcset="/img/a.webp 1x, /img/a@2x.webp 2x" type="image/webp"><img src="/img/a.png" srcset="/img/a.png 1x, /img/a@2x.png 2x"></picture>
+<video poster="/img/poster.jpg"><source src="/media/clip.mp4"><track src="/media/subs.vtt" kind="subtitles"></video>
+<template id="card"><div><img src="/img/avatar.png"></div></template>
+<iframe src="/embed.html"></iframe>
+</body></html>`
+
+// TestSinglePassRewriteOutput checks that extracting and rewriting on one
+// parsed tree produces exactly the HTML the separate extraction and rewrite
+// parses produced, which is recorded here
+func TestSinglePassRewriteOutput(t *testing.T) {
+	const entryUUID = "00000000-0000-0000-0000-000000000000"
+	const baseURL = "https://example.com/article"
+	names := map[string]string{"https://example.com/img/a.png": "a-named.png"}
+
+	origSRI, origOutput, origPromote := sriMode, htmlOutputMode, promoteNoscript
+	defer func() { sriMode, htmlOutputMode, promoteNoscript = origSRI, origOutput, origPromote }()
+
+	wantAssets := []string{
+		"https://example.com/css/site.css",
+		"https://cdn.example.net/lib.js?v=3",
+		"https://example.com/img/hero.jpg",
+		"https://example.com/img/hero-1x.jpg",
+		"https://example.com/img/hero-2x.jpg",
+		"https://example.com/pixel.gif?id=1",
+		"https://example.com/nested.gif",
+		"https://example.com/img/a.webp",
+		"https://example.com/img/a@2x.webp",
+		"https://example.com/img/a.png",
+		"https://example.com/img/a.png",
+		"https://example.com/img/a@2x.png",
+		"https://example.com/img/avatar.png",
+		"https://example.com/media/clip.mp4",
+		"https://example.com/media/subs.vtt",
+		"https://example.com/embed.html",
+	}
+	cases := []struct {
+		sri, output string
+		promote     bool
+		want        string
+	}{
+		{sriStrip, htmlOutputRaw, false, `<!DOCTYPE html><html><head>
+<meta charset="utf-8"/>
+<link rel="stylesheet" href="/data/assets/00000000-0000-0000-0000-000000000000_88d9adbea90f8cc9.css" crossorigin="anonymous"/>
+<link rel="preload" as="image" href="/data/assets/00000000-0000-0000-0000-000000000000_8a3feb47b6cb639d.jpg" imagesrcset="/data/assets/00000000-0000-0000-0000-000000000000_b3505af490bac16d.jpg 1x, /data/assets/00000000-0000-0000-0000-000000000000_1de4efd0a1084b52.jpg 2x"/>
+<link rel="preconnect" href="https://cdn.example.net"/>
+<link rel="canonical" href="https://example.com/article"/>
+<script src="/data/assets/00000000-0000-0000-0000-000000000000_7307d28be6554179.js"></script>
+<script>var inline = "<img src='/not-an-asset.png'>";</script>
+</head><body>
+<noscript><img src="/data/assets/00000000-0000-0000-0000-000000000000_35dfa7df85e00f30.gif"/><noscript><img src="/data/assets/00000000-0000-0000-0000-000000000000_7c9c390e66e97ca2.gif"/></noscript></noscript>
+<p>Text <a href="/other">link</a></p>
+<img src="data:image/gif;base64,R0lGODlhAQABAAAAACw=" alt="inline"/>
+<img src="" srcset=""/>
+<picture><source srcset="/data/assets/00000000-0000-0000-0000-000000000000_fc302f9dcad24b4e.webp 1x, /data/assets/00000000-0000-0000-0000-000000000000_b5f167f6a301f25b.webp 2x" type="image/webp"/><img src="/data/assets/a-named.png" srcset="/data/assets/a-named.png 1x, /data/assets/00000000-0000-0000-0000-000000000000_215a25ab06ffda40.png 2x"/></picture>
+<video poster="/img/poster.jpg"><source src="/data/assets/00000000-0000-0000-0000-000000000000_5a31d8874aea5c7d.mp4"/><track src="/data/assets/00000000-0000-0000-0000-000000000000_e5c34362782455be.vtt" kind="subtitles"/></video>
+<template id="card"><div><img src="/data/assets/00000000-0000-0000-0000-000000000000_02b63328c3ccd886.png"/></div></template>
+<iframe src="/data/assets/00000000-0000-0000-0000-000000000000_89f88437070dbcc2.html"></iframe>
+</body></html>`},
+		{sriKeep, htmlOutputMinify, true, `<!DOCTYPE html><html><head><meta charset="utf-8"/><link rel="stylesheet" href="/data/assets/00000000-0000-0000-0000-000000000000_88d9adbea90f8cc9.css" integrity="sha384-abc" crossorigin="anonymous"/><link rel="preload" as="image" href="/data/assets/00000000-0000-0000-0000-000000000000_8a3feb47b6cb639d.jpg" imagesrcset="/data/assets/00000000-0000-0000-0000-000000000000_b3505af490bac16d.jpg 1x, /data/assets/00000000-0000-0000-0000-000000000000_1de4efd0a1084b52.jpg 2x" integrity="sha384-def"/><link rel="preconnect" href="https://cdn.example.net"/><link rel="canonical" href="https://example.com/article"/><script src="/data/assets/00000000-0000-0000-0000-000000000000_7307d28be6554179.js" integrity="sha384-ghi"></script><script>var inline = "<img src='/not-an-asset.png'>";</script></head><body> <img src="/data/assets/00000000-0000-0000-0000-000000000000_35dfa7df85e00f30.gif"/><noscript><img src="/data/assets/00000000-0000-0000-0000-000000000000_7c9c390e66e97ca2.gif"/></noscript> <p>Text <a href="/other">link</a></p> <img src="data:image/gif;base64,R0lGODlhAQABAAAAACw=" alt="inline"/> <img src="" srcset=""/> <picture><source srcset="/data/assets/00000000-0000-0000-0000-000000000000_fc302f9dcad24b4e.webp 1x, /data/assets/00000000-0000-0000-0000-000000000000_b5f167f6a301f25b.webp 2x" type="image/webp"/><img src="/data/assets/a-named.png" srcset="/data/assets/a-named.png 1x, /data/assets/00000000-0000-0000-0000-000000000000_215a25ab06ffda40.png 2x"/></picture> <video poster="/img/poster.jpg"><source src="/data/assets/00000000-0000-0000-0000-000000000000_5a31d8874aea5c7d.mp4"/><track src="/data/assets/00000000-0000-0000-0000-000000000000_e5c34362782455be.vtt" kind="subtitles"/></video> <template id="card"><div><img src="/data/assets/00000000-0000-0000-0000-000000000000_02b63328c3ccd886.png"/></div></template> <iframe src="/data/assets/00000000-0000-0000-0000-000000000000_89f88437070dbcc2.html"></iframe> </body></html>`},
+	}
+	for _, c := range cases {
+		sriMode, htmlOutputMode, promoteNoscript = c.sri, c.output, c.promote
+
+		parsed, err := parseAssetPage(singlePassPage, baseURL)
+		if err != nil {
+			t.Fatalf("parseAssetPage: %v", err)
+		}
+		if !reflect.DeepEqual(parsed.assets, wantAssets) {
+			t.Errorf("assets = %v, want %v", parsed.assets, wantAssets)
+		}
+		got, err := parsed.rewrite(entryUUID, names)
+		if err != nil {
+			t.Fatalf("rewrite: %v", err)
+		}
+		if got != c.want {
+			t.Errorf("sri=%s output=%s: rewritten HTML differs\ngot:  %s\nwant: %s", c.sri, c.output, got, c.want)
+		}
+
+		// The string wrappers parse on their own and must agree
+		if wrapped, err := modifyHTMLPaths(singlePassPage, entryUUID, baseURL, names); err != nil || wrapped != got {
+			t.Errorf("modifyHTMLPaths = %q, %v, want the single-pass output", wrapped, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// scriptURLAttributes are the attributes whose value is a URL a browser may
+// navigate to or load, and so must not hold a javascript: URL. xlink:href is
+// the SVG form of href; data is only a URL on <object>.
+var scriptURLAttributes = map[string]bool{
+	"href": true, "src": true, "action": true, "formaction": true, "xlink:href": true,
+}
+
+// StripScripts removes <script> elements, inline event handlers (on*),
+// javascript: URLs, iframe srcdoc documents and meta refreshes to javascript:
+// URLs from an HTML document so it can be viewed without running any of the
+// archived page's code
+func StripScripts(htmlContent string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	var stripFunc func(*html.Node)
+	stripFunc = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; {
+			next := c.NextSibling
+			if c.Type == html.ElementNode && (c.Data == "script" || isScriptRefresh(c)) {
+				n.RemoveChild(c)
+			} else {
+				stripFunc(c)
+			}
+			c = next
+		}
+
+		if n.Type == html.ElementNode {
+			attrs := n.Attr[:0]
+			for _, attr := range n.Attr {
+				key := strings.ToLower(attr.Key)
+				if attr.Namespace != "" {
+					key = attr.Namespace + ":" + key
+				}
+				if strings.HasPrefix(key, "on") || (key == "srcdoc" && n.Data == "iframe") {
+					continue
+				}
+				if (scriptURLAttributes[key] || (key == "data" && n.Data == "object")) && isJavaScriptURL(attr.Val) {
+					continue
+				}
+				attrs = append(attrs, attr)
+			}
+			n.Attr = attrs
+		}
+	}
+
+	stripFunc(doc)
+
+	var buf strings.Builder
+	if err := html.Render(&buf, doc); err != nil {
+		return "", fmt.Errorf("failed to render stripped HTML: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// isJavaScriptURL reports whether a browser would treat value as a
+// javascript: URL. Browsers ignore ASCII whitespace and control characters in
+// the scheme, so "java\tscript:" and " \x01javascript:" count too.
+func isJavaScriptURL(value string) bool {
+	return strings.HasPrefix(stripURLControls(value), "javascript:")
+}
+
+// isScriptRefresh reports whether n is a <meta http-equiv="refresh"> that
+// redirects to a javascript: URL
+func isScriptRefresh(n *html.Node) bool {
+	if n.Data != "meta" || !strings.EqualFold(getAttr(n, "http-equiv"), "refresh") {
+		return false
+	}
+	// content is "<delay>;url=<target>", with optional spaces and quotes
+	_, target, found := strings.Cut(stripURLControls(getAttr(n, "content")), "url=")
+	return found && isJavaScriptURL(strings.Trim(target, `'"`))
+}
+
+// stripURLControls lower-cases value and removes every ASCII whitespace and
+// control character from it
+func stripURLControls(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, value)
+}
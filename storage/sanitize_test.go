@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStripScripts(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		removed []string // substrings that must be gone
+		kept    []string // substrings that must remain
+	}{
+		{
+			"script elements and event handlers",
+			`<p onclick="alert(1)" class="x">text</p><script>alert(2)</script>`,
+			[]string{"onclick", "alert"},
+			[]string{`<p class="x">text</p>`},
+		},
+		{
+			"javascript URLs in href, src and action",
+			`<a href="javascript:alert(1)">a</a><img src="JavaScript:alert(2)"><form action="javascript:alert(3)"></form>`,
+			[]string{"javascript:", "JavaScript:"},
+			[]string{"<a>a</a>", "<img/>", "<form></form>"},
+		},
+		{
+			"formaction",
+			`<form><button formaction="javascript:alert(1)">go</button></form>`,
+			[]string{"formaction"},
+			[]string{"<button>go</button>"},
+		},
+		{
+			"xlink:href",
+			`<svg><a xlink:href="javascript:alert(1)"><text>t</text></a></svg>`,
+			[]string{"javascript:"},
+			[]string{"<text>t</text>"},
+		},
+		{
+			"data on object",
+			`<object data="javascript:alert(1)"></object><div data="javascript:kept"></div>`,
+			[]string{`<object data=`},
+			[]string{"<object></object>", `<div data="javascript:kept">`},
+		},
+		{
+			"iframe srcdoc",
+			`<iframe srcdoc="&lt;script&gt;alert(1)&lt;/script&gt;" title="frame"></iframe>`,
+			[]string{"srcdoc", "alert"},
+			[]string{`<iframe title="frame">`},
+		},
+		{
+			"meta refresh to a javascript URL",
+			`<head><meta http-equiv="Refresh" content="0; URL='javascript:alert(1)'"><meta http-equiv="refresh" content="5;url=https://example.com/"></head>`,
+			[]string{"javascript:"},
+			[]string{`content="5;url=https://example.com/"`},
+		},
+		{
+			"obfuscated schemes",
+			"<a href=\"java\tscript:alert(1)\">a</a><a href=\" \x01javascript:alert(2)\">b</a><a href=\"jav&#x0A;ascript:alert(3)\">c</a><a href=\"https://example.com/javascript:ok\">d</a>",
+			[]string{"alert"},
+			[]string{"<a>a</a>", "<a>b</a>", "<a>c</a>", `href="https://example.com/javascript:ok"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := StripScripts(tt.in)
+			if err != nil {
+				t.Fatalf("StripScripts: %v", err)
+			}
+			for _, s := range tt.removed {
+				if strings.Contains(out, s) {
+					t.Errorf("output still contains %q: %s", s, out)
+				}
+			}
+			for _, s := range tt.kept {
+				if !strings.Contains(out, s) {
+					t.Errorf("output lost %q: %s", s, out)
+				}
+			}
+		})
+	}
+}
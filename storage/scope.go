@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ValidatePathPrefix checks that a link-following scope is an absolute URL path
+func ValidatePathPrefix(pathPrefix string) error {
+	if pathPrefix == "" {
+		return nil
+	}
+	if !strings.HasPrefix(pathPrefix, "/") || strings.ContainsAny(pathPrefix, "?#") {
+		return fmt.Errorf("path prefix '%s' must be a URL path starting with '/'", pathPrefix)
+	}
+	return nil
+}
+
+// InScope reports whether target is on the same origin (scheme, host and port)
+// as base and its path lies under pathPrefix. A prefix without a trailing
+// slash matches whole path segments only, so "/docs" matches "/docs" and
+// "/docs/intro" but not "/docsearch". An empty pathPrefix only requires the
+// same origin.
+func InScope(base, target, pathPrefix string) bool {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return false
+	}
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return false
+	}
+	if !strings.EqualFold(baseURL.Scheme, targetURL.Scheme) || !strings.EqualFold(baseURL.Host, targetURL.Host) {
+		return false
+	}
+	if pathPrefix == "" {
+		return true
+	}
+
+	path := targetURL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if strings.HasSuffix(pathPrefix, "/") {
+		return strings.HasPrefix(path, pathPrefix)
+	}
+	return path == pathPrefix || strings.HasPrefix(path, pathPrefix+"/")
+}
@@ -0,0 +1,75 @@
+package storage
+
+import "testing"
+
+func TestInScope(t *testing.T) {
+	const base = "https://example.com/docs/index.html"
+	tests := []struct {
+		name       string
+		target     string
+		pathPrefix string
+		want       bool
+	}{
+		{"under prefix", "https://example.com/docs/guide/intro", "/docs/", true},
+		{"prefix itself", "https://example.com/docs/", "/docs/", true},
+		{"outside prefix", "https://example.com/blog/post", "/docs/", false},
+		{"site root", "https://example.com/", "/docs/", false},
+		{"segment prefix matches path", "https://example.com/docs", "/docs", true},
+		{"segment prefix matches child", "https://example.com/docs/api", "/docs", true},
+		{"segment prefix rejects sibling", "https://example.com/docsearch", "/docs", false},
+		{"other host", "https://cdn.example.com/docs/guide", "/docs/", false},
+		{"other scheme", "http://example.com/docs/guide", "/docs/", false},
+		{"other port", "https://example.com:8443/docs/guide", "/docs/", false},
+		{"host case-insensitive", "https://EXAMPLE.com/docs/guide", "/docs/", true},
+		{"query ignored", "https://example.com/docs/search?q=/blog/", "/docs/", true},
+		{"no prefix same origin", "https://example.com/blog/post", "", true},
+		{"no prefix other origin", "https://other.example/blog/post", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InScope(base, tt.target, tt.pathPrefix); got != tt.want {
+				t.Errorf("InScope(%q, %q, %q) = %v, want %v", base, tt.target, tt.pathPrefix, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFeedItemURLsFiltersByPathPrefix(t *testing.T) {
+	feed := []byte(`<rss><channel>
+<item><link>https://example.com/docs/a</link></item>
+<item><link>https://example.com/blog/b</link></item>
+<item><link>/docs/c</link></item>
+<item><link>https://elsewhere.example/docs/d</link></item>
+<item><link>https://example.com/docs/e</link></item>
+</channel></rss>`)
+
+	got, err := parseFeedItemURLs(feed, "https://example.com/feed.xml", "https://example.com/docs/", "/docs/", 2)
+	if err != nil {
+		t.Fatalf("parseFeedItemURLs: %v", err)
+	}
+	want := []string{"https://example.com/docs/a", "https://example.com/docs/c"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("parseFeedItemURLs = %v, want %v (limit applies after scope filtering)", got, want)
+	}
+
+	all, err := parseFeedItemURLs(feed, "https://example.com/feed.xml", "https://example.com/docs/", "", 10)
+	if err != nil {
+		t.Fatalf("parseFeedItemURLs: %v", err)
+	}
+	if len(all) != 5 {
+		t.Errorf("without a path prefix every item should be kept, got %v", all)
+	}
+}
+
+func TestValidatePathPrefix(t *testing.T) {
+	for _, ok := range []string{"", "/", "/docs", "/docs/"} {
+		if err := ValidatePathPrefix(ok); err != nil {
+			t.Errorf("ValidatePathPrefix(%q) = %v, want nil", ok, err)
+		}
+	}
+	for _, bad := range []string{"docs/", "https://example.com/docs/", "/docs/?x=1"} {
+		if err := ValidatePathPrefix(bad); err == nil {
+			t.Errorf("ValidatePathPrefix(%q) = nil, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"archive-lite/config"
+	"archive-lite/models"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+var (
+	// captureScreenshots enables screenshot capture during ArchiveURL
+	captureScreenshots = envBool("ARCHIVE_SCREENSHOTS", false)
+	// screenshotTimeout bounds a whole capture, from Chrome startup to the encoded image
+	screenshotTimeout = time.Duration(envInt64("ARCHIVE_SCREENSHOT_TIMEOUT_SEC", 30)) * time.Second
+	// screenshotMaxHeight clamps full-page captures of very tall pages (CSS pixels)
+	screenshotMaxHeight = envInt64("ARCHIVE_SCREENSHOT_MAX_HEIGHT", 16384)
+	// screenshotQuality is the JPEG quality used for screenshots
+	screenshotQuality = 90
+	// screenshotWaitFonts waits for document.fonts.ready before capturing
+	// (ARCHIVE_SCREENSHOT_WAIT_FONTS), so web fonts render instead of fallbacks
+	screenshotWaitFonts = envBool("ARCHIVE_SCREENSHOT_WAIT_FONTS", false)
+	// renderDOM is the default for ArchiveOptions.RenderDOM (ARCHIVE_RENDER_DOM)
+	renderDOM = envBool("ARCHIVE_RENDER_DOM", false)
+	// chromeWSURL is the DevTools endpoint of a remote browser (ARCHIVE_CHROME_WS_URL),
+	// e.g. a browserless/chrome container; empty starts Chrome locally
+	chromeWSURL = config.Getenv("ARCHIVE_CHROME_WS_URL")
+
+	// runChrome, layoutContentSize and captureClip are the Chrome calls of a
+	// capture or login; tests replace them since no browser is available there
+	runChrome         = chromedp.Run
+	layoutContentSize = func(ctx context.Context) (*dom.Rect, error) {
+		_, _, contentSize, _, _, cssContentSize, err := page.GetLayoutMetrics().Do(ctx)
+		if cssContentSize != nil {
+			contentSize = cssContentSize
+		}
+		return contentSize, err
+	}
+	captureClip = func(ctx context.Context, clip *page.Viewport) ([]byte, error) {
+		return page.CaptureScreenshot().
+			WithCaptureBeyondViewport(true).
+			WithFromSurface(true).
+			WithFormat(page.CaptureScreenshotFormatJpeg).
+			WithQuality(int64(screenshotQuality)).
+			WithClip(clip).
+			Do(ctx)
+	}
+)
+
+// ErrScreenshotTimeout is returned by CaptureSPA when the capture does not
+// finish within ARCHIVE_SCREENSHOT_TIMEOUT_SEC
+var ErrScreenshotTimeout = errors.New("screenshot capture timed out")
+
+func ScreenshotsDirForTest() string { return settings.ScreenshotsDir }
+
+// chromeAllocatorOptions returns the exec allocator options for local Chrome,
+// honouring CHROME_BIN_PATH and CHROMEDP_EXTRA_FLAGS
+func chromeAllocatorOptions() []chromedp.ExecAllocatorOption {
+	opts := append([]chromedp.ExecAllocatorOption{}, chromedp.DefaultExecAllocatorOptions[:]...)
+	opts = append(opts, chromedp.WindowSize(1280, 800))
+	if chromePath := config.Getenv("CHROME_BIN_PATH"); chromePath != "" {
+		opts = append(opts, chromedp.ExecPath(chromePath))
+	}
+	for _, flag := range envList("CHROMEDP_EXTRA_FLAGS") {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(flag, "-"), "=")
+		if hasValue {
+			opts = append(opts, chromedp.Flag(name, value))
+		} else {
+			opts = append(opts, chromedp.Flag(name, true))
+		}
+	}
+	return opts
+}
+
+// newChromeContext starts a Chrome tab bounded by timeout. The tab is opened
+// in the remote browser at ARCHIVE_CHROME_WS_URL when set, otherwise in a
+// local Chrome started for the capture. The returned cancel func releases the
+// tab and, for local Chrome, the browser.
+func newChromeContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	timeoutCtx, cancelTimeout := context.WithTimeout(context.Background(), timeout)
+	var allocCtx context.Context
+	var cancelAlloc context.CancelFunc
+	if chromeWSURL != "" {
+		var opts []chromedp.RemoteAllocatorOption
+		if strings.Contains(chromeWSURL, "?") {
+			// Services like browserless take a token in the query, which the
+			// /json/version lookup would drop; connect to the URL as given
+			opts = append(opts, chromedp.NoModifyURL)
+		}
+		allocCtx, cancelAlloc = chromedp.NewRemoteAllocator(timeoutCtx, chromeWSURL, opts...)
+	} else {
+		allocCtx, cancelAlloc = chromedp.NewExecAllocator(timeoutCtx, chromeAllocatorOptions()...)
+	}
+	taskCtx, cancelTask := chromedp.NewContext(allocCtx)
+	return taskCtx, func() {
+		cancelTask()
+		cancelAlloc()
+		cancelTimeout()
+	}
+}
+
+// pageCapture is what capturePage took from a page load
+type pageCapture struct {
+	DOM        string // Rendered DOM, when requested
+	Screenshot []byte // Full-page JPEG, when requested
+	Width      int64  // Document scroll width in CSS pixels
+	Height     int64  // Document scroll height in CSS pixels, before screenshot clamping
+}
+
+// measurePage reads the document's scroll width and height in CSS pixels
+func measurePage(width, height *int64) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var size []int64
+		err := chromedp.Evaluate(`[document.documentElement.scrollWidth, document.documentElement.scrollHeight]`, &size).Do(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to measure page: %w", err)
+		}
+		if len(size) == 2 {
+			*width, *height = size[0], size[1]
+		}
+		return nil
+	})
+}
+
+// captureFullPage captures the page as a JPEG, clamping the height to
+// screenshotMaxHeight so infinite-scroll pages don't produce enormous images
+func captureFullPage(res *[]byte) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		contentSize, err := layoutContentSize(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get layout metrics: %w", err)
+		}
+
+		width := math.Ceil(contentSize.Width)
+		height := math.Ceil(contentSize.Height)
+		if screenshotMaxHeight > 0 && height > float64(screenshotMaxHeight) {
+			height = float64(screenshotMaxHeight)
+		}
+
+		*res, err = captureClip(ctx, &page.Viewport{X: 0, Y: 0, Width: width, Height: height, Scale: 1})
+		return err
+	})
+}
+
+// CaptureSPA renders targetURL in headless Chrome and writes a full-page JPEG
+// screenshot to screenshotPath. The image is written atomically: on any error,
+// including ErrScreenshotTimeout, no file is left behind.
+func CaptureSPA(targetURL, screenshotPath string) error {
+	_, err := captureScreenshot(targetURL, screenshotPath, DefaultDeviceProfile(), dismissSelectors, nil)
+	return err
+}
+
+// emulateDevice applies the viewport, pixel ratio and User-Agent of device
+func emulateDevice(device models.DeviceProfile) chromedp.Tasks {
+	var tasks chromedp.Tasks
+	if device.Width > 0 && device.Height > 0 {
+		opts := []chromedp.EmulateViewportOption{chromedp.EmulateScale(device.DPR)}
+		if device.Mobile {
+			opts = append(opts, chromedp.EmulateMobile, chromedp.EmulateTouch)
+		}
+		tasks = append(tasks, chromedp.EmulateViewport(device.Width, device.Height, opts...))
+	}
+	if device.UserAgent != "" {
+		tasks = append(tasks, emulation.SetUserAgentOverride(device.UserAgent))
+	}
+	return tasks
+}
+
+// waitForFonts blocks until the page's web fonts have loaded. It is bounded by
+// the capture's overall timeout.
+func waitForFonts() chromedp.Action {
+	var loaded bool
+	return chromedp.Evaluate(`document.fonts.ready.then(() => true)`, &loaded,
+		func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+			return p.WithAwaitPromise(true)
+		})
+}
+
+// captureScreenshot is CaptureSPA rendering the page as device, with the
+// cookies of session (if any) and the overlays matching dismiss clicked or
+// hidden first. The returned capture also holds the page's dimensions.
+func captureScreenshot(targetURL, screenshotPath string, device models.DeviceProfile, dismiss []string, session *loginSession) (pageCapture, error) {
+	captured, err := capturePage(targetURL, device, dismiss, session, false, true)
+	if err != nil {
+		return captured, err
+	}
+	return captured, writeFileAtomic(screenshotPath, captured.Screenshot, 0644)
+}
+
+// capturePage loads targetURL once in headless Chrome as device with the
+// cookies of session (if any), waits for a JavaScript challenge to pass
+// (with passJSChallenges), clicks or hides the overlays matching
+// dismiss, and returns the rendered DOM (when
+// withDOM) and a full-page JPEG screenshot (when withScreenshot), so both
+// reflect the same page state. The page's dimensions are always measured.
+func capturePage(targetURL string, device models.DeviceProfile, dismiss []string, session *loginSession, withDOM, withScreenshot bool) (pageCapture, error) {
+	ctx, cancel := newChromeContext(screenshotTimeout)
+	defer cancel()
+
+	var captured pageCapture
+	tasks := chromedp.Tasks{
+		emulateDevice(device),
+		session.setCookies(),
+		chromedp.Navigate(targetURL),
+		chromedp.WaitReady("body", chromedp.ByQuery),
+	}
+	if passJSChallenges {
+		tasks = append(tasks, waitForChallenge())
+	}
+	if screenshotWaitFonts {
+		tasks = append(tasks, waitForFonts())
+	}
+	if len(dismiss) > 0 {
+		tasks = append(tasks, dismissOverlays(dismiss))
+	}
+	tasks = append(tasks, measurePage(&captured.Width, &captured.Height))
+	if withDOM {
+		tasks = append(tasks, chromedp.OuterHTML("html", &captured.DOM, chromedp.ByQuery))
+	}
+	if withScreenshot {
+		tasks = append(tasks, captureFullPage(&captured.Screenshot))
+	}
+	err := runChrome(ctx, tasks)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return pageCapture{}, fmt.Errorf("%w after %s for '%s'", ErrScreenshotTimeout, screenshotTimeout, targetURL)
+		}
+		return pageCapture{}, fmt.Errorf("failed to capture '%s': %w", targetURL, err)
+	}
+
+	if withDOM {
+		// outerHTML doesn't include the doctype; keep pages in standards mode
+		captured.DOM = "<!DOCTYPE html>\n" + captured.DOM
+	}
+	if withScreenshot && normalizesScreenshots() {
+		if normalized, err := normalizeScreenshot(captured.Screenshot); err != nil {
+			fmt.Printf("Warning: keeping screenshot of '%s' as captured: %v\n", targetURL, err)
+		} else {
+			captured.Screenshot = normalized
+		}
+	}
+	return captured, nil
+}
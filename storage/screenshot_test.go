@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+func TestCaptureSPATimeoutLeavesNoFile(t *testing.T) {
+	defer func(old func(context.Context, ...chromedp.Action) error) { runChrome = old }(runChrome)
+	defer func(old time.Duration) { screenshotTimeout = old }(screenshotTimeout)
+	screenshotTimeout = 50 * time.Millisecond
+	// A page that never finishes loading
+	runChrome = func(ctx context.Context, actions ...chromedp.Action) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	dir := t.TempDir()
+	err := CaptureSPA("https://example.com/slow", filepath.Join(dir, "shot.jpg"))
+	if !errors.Is(err, ErrScreenshotTimeout) {
+		t.Fatalf("CaptureSPA error = %v, want ErrScreenshotTimeout", err)
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("files left behind after timeout: %v", files)
+	}
+}
+
+func TestCaptureFullPageClampsHeight(t *testing.T) {
+	defer func(old func(context.Context) (*dom.Rect, error)) { layoutContentSize = old }(layoutContentSize)
+	defer func(old func(context.Context, *page.Viewport) ([]byte, error)) { captureClip = old }(captureClip)
+	defer func(old int64) { screenshotMaxHeight = old }(screenshotMaxHeight)
+
+	tests := []struct {
+		name       string
+		maxHeight  int64
+		pageHeight float64
+		wantHeight float64
+	}{
+		{"short page is kept", 1000, 800.2, 801},
+		{"tall page is clamped", 1000, 50000, 1000},
+		{"zero disables the clamp", 0, 50000, 50000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			screenshotMaxHeight = tt.maxHeight
+			layoutContentSize = func(context.Context) (*dom.Rect, error) {
+				return &dom.Rect{Width: 1280, Height: tt.pageHeight}, nil
+			}
+			var clip *page.Viewport
+			captureClip = func(_ context.Context, c *page.Viewport) ([]byte, error) {
+				clip = c
+				return []byte("jpeg"), nil
+			}
+
+			var buf []byte
+			if err := captureFullPage(&buf).Do(context.Background()); err != nil {
+				t.Fatalf("captureFullPage: %v", err)
+			}
+			if string(buf) != "jpeg" {
+				t.Errorf("screenshot = %q, want the captured bytes", buf)
+			}
+			if clip == nil || clip.Width != 1280 || clip.Height != tt.wantHeight {
+				t.Errorf("clip = %+v, want 1280x%v", clip, tt.wantHeight)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+
+	"golang.org/x/image/draw"
+)
+
+var (
+	// screenshotNormalize re-encodes screenshots after capture, dropping any
+	// metadata segments (ARCHIVE_SCREENSHOT_NORMALIZE)
+	screenshotNormalize = envBool("ARCHIVE_SCREENSHOT_NORMALIZE", false)
+	// screenshotMaxDim downscales normalized screenshots so neither side
+	// exceeds this many pixels (ARCHIVE_SCREENSHOT_MAX_DIM); 0 keeps the size.
+	// Setting it enables normalization.
+	screenshotMaxDim = envInt64("ARCHIVE_SCREENSHOT_MAX_DIM", 0)
+)
+
+// normalizesScreenshots reports whether captured screenshots are re-encoded
+func normalizesScreenshots() bool {
+	return screenshotNormalize || screenshotMaxDim > 0
+}
+
+// fitWithin returns the size of a w x h image scaled down, keeping its aspect
+// ratio, so neither side exceeds maxDim. Sizes that fit are returned as is.
+func fitWithin(w, h, maxDim int) (int, int) {
+	if maxDim <= 0 || (w <= maxDim && h <= maxDim) {
+		return w, h
+	}
+	if w >= h {
+		return maxDim, max(h*maxDim/w, 1)
+	}
+	return max(w*maxDim/h, 1), maxDim
+}
+
+// normalizeScreenshot decodes a screenshot and encodes it again as a plain
+// JPEG, which carries no EXIF, XMP, ICC or comment segments, downscaling it
+// to screenshotMaxDim. The result is checked to decode to the expected size.
+func normalizeScreenshot(buf []byte) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(buf))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode screenshot: %w", err)
+	}
+
+	bounds := img.Bounds()
+	w, h := fitWithin(bounds.Dx(), bounds.Dy(), int(screenshotMaxDim))
+	if w != bounds.Dx() || h != bounds.Dy() {
+		scaled := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.CatmullRom.Scale(scaled, scaled.Bounds(), img, bounds, draw.Src, nil)
+		img = scaled
+	}
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, img, &jpeg.Options{Quality: screenshotQuality}); err != nil {
+		return nil, fmt.Errorf("failed to encode screenshot: %w", err)
+	}
+	config, err := jpeg.DecodeConfig(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		return nil, fmt.Errorf("re-encoded screenshot does not decode: %w", err)
+	}
+	if config.Width != w || config.Height != h {
+		return nil, fmt.Errorf("re-encoded screenshot is %dx%d, expected %dx%d", config.Width, config.Height, w, h)
+	}
+	return out.Bytes(), nil
+}
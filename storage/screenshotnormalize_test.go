@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// jpegWithEXIF encodes a w x h JPEG and inserts an APP1 (EXIF) segment after
+// the SOI marker
+func jpegWithEXIF(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		img.Set(x, h/2, color.RGBA{R: 255, A: 255})
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	payload := []byte("Exif\x00\x00secret-camera-serial")
+	segment := append([]byte{0xFF, 0xE1, 0, byte(len(payload) + 2)}, payload...)
+	data := buf.Bytes()
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+}
+
+func TestNormalizeScreenshot(t *testing.T) {
+	defer func(old int64) { screenshotMaxDim = old }(screenshotMaxDim)
+
+	tests := []struct {
+		maxDim       int64
+		wantW, wantH int
+	}{
+		{0, 3000, 120},
+		{1000, 1000, 40},
+	}
+	for _, tt := range tests {
+		screenshotMaxDim = tt.maxDim
+		src := jpegWithEXIF(t, 3000, 120)
+		if !bytes.Contains(src, []byte("secret-camera-serial")) {
+			t.Fatal("test image has no EXIF segment")
+		}
+
+		got, err := normalizeScreenshot(src)
+		if err != nil {
+			t.Fatalf("normalizeScreenshot (max %d): %v", tt.maxDim, err)
+		}
+		if bytes.Contains(got, []byte("Exif")) {
+			t.Errorf("max %d: EXIF segment kept", tt.maxDim)
+		}
+		config, err := jpeg.DecodeConfig(bytes.NewReader(got))
+		if err != nil {
+			t.Fatalf("max %d: result does not decode: %v", tt.maxDim, err)
+		}
+		if config.Width != tt.wantW || config.Height != tt.wantH {
+			t.Errorf("max %d: size %dx%d, want %dx%d", tt.maxDim, config.Width, config.Height, tt.wantW, tt.wantH)
+		}
+	}
+
+	if _, err := normalizeScreenshot([]byte("not an image")); err == nil {
+		t.Error("undecodable screenshot normalized without error")
+	}
+}
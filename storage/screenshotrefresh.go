@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"archive-lite/activity"
+	"archive-lite/models"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrNoScreenshot is returned when refreshing the screenshot of an entry that
+// can't have one, such as a stored download
+var ErrNoScreenshot = errors.New("archive has no page to screenshot")
+
+// screenshotRefreshCheck is how often entries are checked for screenshots due
+// for regeneration (ARCHIVE_SCREENSHOT_REFRESH_CHECK_SEC); 0 disables the
+// scheduled refresh
+var screenshotRefreshCheck = time.Duration(envInt64("ARCHIVE_SCREENSHOT_REFRESH_CHECK_SEC", 60)) * time.Second
+
+// RefreshScreenshot captures a new screenshot of entry's live page with the
+// entry's device profile, replacing the stored one without refetching the
+// HTML or assets. ScreenshotPath, ScreenshotAt, the thumbnail and the page
+// dimensions are updated and saved.
+func RefreshScreenshot(db *gorm.DB, entry *models.ArchiveEntry) error {
+	if entry.AttachmentName != "" {
+		return ErrNoScreenshot
+	}
+	if err := EnsureStorageDirs(); err != nil {
+		return fmt.Errorf("failed to ensure storage directories: %w", err)
+	}
+	started := time.Now()
+	pageURL := entry.URL
+	if entry.Fragment != "" {
+		pageURL += "#" + entry.Fragment
+	}
+
+	waitBetweenRequests(entry.URL)
+	path := filepath.Join(settings.ScreenshotsDir, fmt.Sprintf("%s.jpg", entry.ID))
+	captured, err := captureScreenshot(pageURL, path, entry.Device, nil, nil)
+	if err != nil {
+		activity.Record("screenshot", entry.URL, entry.ID, started, err)
+		return err
+	}
+	if entry.ScreenshotPath != "" && entry.ScreenshotPath != path {
+		// e.g. a PNG from before screenshots were JPEG
+		os.Remove(entry.ScreenshotPath)
+	}
+
+	thumbnailPath := writeThumbnail(path)
+	if thumbnailPath == "" && entry.ThumbnailPath != "" {
+		os.Remove(entry.ThumbnailPath)
+	}
+
+	entry.ScreenshotPath = path
+	entry.ScreenshotAt = time.Now()
+	entry.ThumbnailPath = thumbnailPath
+	entry.Width, entry.Height = captured.Width, captured.Height
+	err = db.Model(entry).Updates(map[string]interface{}{
+		"screenshot_path": entry.ScreenshotPath,
+		"screenshot_at":   entry.ScreenshotAt,
+		"thumbnail_path":  entry.ThumbnailPath,
+		"width":           entry.Width,
+		"height":          entry.Height,
+	}).Error
+	if err != nil {
+		err = fmt.Errorf("failed to update screenshot of archive %s: %w", entry.ID, err)
+	}
+	activity.Record("screenshot", entry.URL, entry.ID, started, err)
+	return err
+}
+
+// StartScreenshotRefresh periodically regenerates the screenshots of entries
+// whose ScreenshotTTL has elapsed since ScreenshotAt
+func StartScreenshotRefresh(db *gorm.DB) {
+	if screenshotRefreshCheck <= 0 {
+		return
+	}
+	go func() {
+		for range time.Tick(screenshotRefreshCheck) {
+			if refreshed := refreshDueScreenshots(db, time.Now()); refreshed > 0 {
+				log.Printf("Refreshed %d scheduled screenshots", refreshed)
+			}
+		}
+	}()
+}
+
+// refreshDueScreenshots refreshes, one at a time, the screenshots due at now
+// and returns how many were refreshed. Failures are logged and retried at
+// the next check.
+func refreshDueScreenshots(db *gorm.DB, now time.Time) int {
+	entries, err := dueScreenshots(db, now)
+	if err != nil {
+		log.Printf("Failed to list scheduled screenshots: %v", err)
+		return 0
+	}
+	refreshed := 0
+	for i := range entries {
+		entry := &entries[i]
+		if err := RefreshScreenshot(db, entry); err != nil {
+			log.Printf("Failed to refresh screenshot of archive %s: %v", entry.ID, err)
+			continue
+		}
+		refreshed++
+	}
+	return refreshed
+}
+
+// dueScreenshots returns the entries whose screenshot has expired at now.
+// Entries that never had a screenshot are due immediately.
+func dueScreenshots(db *gorm.DB, now time.Time) ([]models.ArchiveEntry, error) {
+	var entries []models.ArchiveEntry
+	if err := db.Where("screenshot_ttl > 0 AND attachment_name = ''").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	due := entries[:0]
+	for _, entry := range entries {
+		if !now.Before(entry.ScreenshotAt.Add(time.Duration(entry.ScreenshotTTL) * time.Second)) {
+			due = append(due, entry)
+		}
+	}
+	return due, nil
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"archive-lite/tests"
+	"testing"
+	"time"
+)
+
+func TestDueScreenshots(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+
+	now := time.Now()
+	entries := []models.ArchiveEntry{
+		{URL: "https://example.com/expired", ScreenshotAt: now.Add(-2 * time.Hour), ScreenshotTTL: 3600},
+		{URL: "https://example.com/fresh", ScreenshotAt: now.Add(-10 * time.Minute), ScreenshotTTL: 3600},
+		{URL: "https://example.com/never-captured", ScreenshotTTL: 60},
+		{URL: "https://example.com/no-ttl", ScreenshotAt: now.Add(-48 * time.Hour)},
+		{URL: "https://example.com/file.pdf", ScreenshotTTL: 60, AttachmentName: "file.pdf"},
+	}
+	for i := range entries {
+		entries[i].StoragePath = "unused.html"
+		entries[i].ArchivedAt = now
+		if err := db.Create(&entries[i]).Error; err != nil {
+			t.Fatalf("creating entry: %v", err)
+		}
+		id := entries[i].ID
+		t.Cleanup(func() { db.Where("id = ?", id).Delete(&models.ArchiveEntry{}) })
+	}
+
+	due, err := dueScreenshots(db, now)
+	if err != nil {
+		t.Fatalf("dueScreenshots: %v", err)
+	}
+	got := make(map[string]bool)
+	for _, entry := range due {
+		got[entry.URL] = true
+	}
+	if len(got) != 2 || !got["https://example.com/expired"] || !got["https://example.com/never-captured"] {
+		t.Errorf("due = %v, want expired and never-captured", got)
+	}
+}
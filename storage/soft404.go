@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ErrSoft404 is returned when the archived URL answers 200 with a page that
+// looks like a "not found" page and ARCHIVE_REJECT_SOFT_404 is set
+var ErrSoft404 = errors.New("page looks like a soft 404 (200 response with a not-found page)")
+
+// defaultSoft404Patterns match the <title> or <h1> of common not-found pages
+var defaultSoft404Patterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(page|file|article) (was )?not found\b`),
+	regexp.MustCompile(`(?i)^\s*(error )?404\b|\b404\s*$|\b404 (error|not found)\b`),
+	regexp.MustCompile(`(?i)\b(page|content) (you (were looking|are looking|requested)[^.]*)?(does not|doesn't|no longer) exists?\b`),
+	regexp.MustCompile(`ページが見つかりません|お探しのページ.*(見つかりません|存在しません)`),
+}
+
+var (
+	// detectSoft404 flags 200 responses that look like not-found pages (ARCHIVE_DETECT_SOFT_404)
+	detectSoft404 = envBool("ARCHIVE_DETECT_SOFT_404", true)
+	// rejectSoft404 refuses to store pages flagged as soft 404s (ARCHIVE_REJECT_SOFT_404)
+	rejectSoft404 = envBool("ARCHIVE_REJECT_SOFT_404", false)
+	// soft404Patterns are matched against a page's <title> and <h1> headings
+	// (ARCHIVE_SOFT_404_PATTERNS, replacing the built-in patterns)
+	soft404Patterns = envSoft404Patterns("ARCHIVE_SOFT_404_PATTERNS")
+	// soft404MinText is the extracted text length, in bytes, below which a
+	// page counts as a soft 404 (ARCHIVE_SOFT_404_MIN_TEXT); 0 disables the check
+	soft404MinText = envInt64("ARCHIVE_SOFT_404_MIN_TEXT", 0)
+)
+
+// envSoft404Patterns reads the soft-404 patterns, defaulting to the built-in ones
+func envSoft404Patterns(key string) []*regexp.Regexp {
+	if patterns := envPatterns(key); len(patterns) > 0 {
+		return patterns
+	}
+	return defaultSoft404Patterns
+}
+
+// soft404Reason describes why a page served with 200 looks like a not-found
+// page, or returns "" if it doesn't
+func soft404Reason(htmlContent string) string {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return ""
+	}
+	for _, heading := range soft404Headings(doc) {
+		for _, re := range soft404Patterns {
+			if re.MatchString(heading) {
+				return "heading '" + heading + "' matches " + re.String()
+			}
+		}
+	}
+	if soft404MinText > 0 {
+		if text, err := ExtractText(htmlContent); err == nil && int64(len(text)) < soft404MinText {
+			return "text shorter than ARCHIVE_SOFT_404_MIN_TEXT"
+		}
+	}
+	return ""
+}
+
+// soft404Headings returns the whitespace-collapsed text of the document's
+// <title> and <h1> elements
+func soft404Headings(doc *html.Node) []string {
+	var headings []string
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "title" || n.Data == "h1") {
+			if text := strings.TrimSpace(collapseWhitespace(nodeText(n))); text != "" {
+				headings = append(headings, text)
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return headings
+}
+
+// nodeText returns the concatenated text nodes under n
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(nodeText(c))
+	}
+	return b.String()
+}
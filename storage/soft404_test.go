@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"archive-lite/tests"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSoft404Reason(t *testing.T) {
+	for _, tc := range []struct {
+		page string
+		soft bool
+	}{
+		{`<html><head><title>Page Not Found | Example</title></head><body><p>Sorry.</p></body></html>`, true},
+		{`<html><body><h1>404</h1><p>Try the home page.</p></body></html>`, true},
+		{`<html><head><title>お探しのページは見つかりませんでした</title></head></html>`, true},
+		{`<html><body><h1>The page you requested does not exist</h1></body></html>`, true},
+		{`<html><head><title>How we fixed our 404 handling</title></head><body><h1>Engineering blog</h1></body></html>`, false},
+		{`<html><head><title>Example Domain</title></head><body><p>Page not found errors are common.</p></body></html>`, false},
+	} {
+		if got := soft404Reason(tc.page) != ""; got != tc.soft {
+			t.Errorf("soft404Reason(%s) flagged = %v, want %v", tc.page, got, tc.soft)
+		}
+	}
+}
+
+func TestArchiveSoft404(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/gone" {
+			w.Write([]byte(`<html><head><title>Page not found</title></head><body><h1>Oops!</h1></body></html>`))
+			return
+		}
+		w.Write([]byte(`<html><head><title>Article</title></head><body><h1>Article</h1></body></html>`))
+	}))
+	defer server.Close()
+
+	origRaw, origAssets, origNoDelay, origScreenshots, origReject := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate, captureScreenshots, rejectSoft404
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate, captureScreenshots, rejectSoft404 = origNoDelay, origScreenshots, origReject
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate, captureScreenshots, rejectSoft404 = true, false, false
+	t.Cleanup(func() { db.Where("url LIKE ?", server.URL+"%").Delete(&models.ArchiveEntry{}) })
+
+	gone, err := ArchiveURL(db, server.URL+"/gone")
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if !gone.SoftError || gone.HTTPStatus != http.StatusOK {
+		t.Errorf("not-found page served with 200: SoftError %v, HTTPStatus %d", gone.SoftError, gone.HTTPStatus)
+	}
+	article, err := ArchiveURL(db, server.URL+"/article")
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if article.SoftError {
+		t.Errorf("ordinary page flagged as soft 404")
+	}
+
+	rejectSoft404 = true
+	if _, err := ArchiveURL(db, server.URL+"/gone"); !errors.Is(err, ErrSoft404) {
+		t.Errorf("rejected soft 404: got %v, want ErrSoft404", err)
+	}
+	var count int64
+	db.Model(&models.ArchiveEntry{}).Where("url = ?", server.URL+"/gone").Count(&count)
+	if count != 1 {
+		t.Errorf("%d entries for the soft 404, want only the one archived before rejecting", count)
+	}
+}
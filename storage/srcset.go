@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// srcsetCandidate is a single "url [descriptor]" entry of a srcset attribute
+type srcsetCandidate struct {
+	URL        string
+	Descriptor string
+}
+
+// srcsetAttrName returns the responsive-image attribute of n, or "" if it has none.
+// <img> and <source> (inside <picture>) use srcset; preload links use imagesrcset.
+func srcsetAttrName(n *html.Node) string {
+	switch n.Data {
+	case "img", "source":
+		return "srcset"
+	case "link":
+		if isArchivableLink(n) {
+			return "imagesrcset"
+		}
+	}
+	return ""
+}
+
+// parseSrcset splits a srcset value into its candidates following the HTML
+// parsing rules: URLs end at whitespace (a trailing comma ends the candidate),
+// and descriptors run until the next comma outside parentheses.
+func parseSrcset(srcset string) []srcsetCandidate {
+	var candidates []srcsetCandidate
+	s := srcset
+	for {
+		s = strings.TrimLeft(s, " \t\n\r\f,")
+		if s == "" {
+			return candidates
+		}
+
+		end := strings.IndexAny(s, " \t\n\r\f")
+		if end == -1 {
+			end = len(s)
+		}
+		rawURL := s[:end]
+		s = s[end:]
+
+		if strings.HasSuffix(rawURL, ",") {
+			candidates = append(candidates, srcsetCandidate{URL: strings.TrimRight(rawURL, ",")})
+			continue
+		}
+
+		depth := 0
+		descEnd := len(s)
+		for i, r := range s {
+			if r == '(' {
+				depth++
+			} else if r == ')' && depth > 0 {
+				depth--
+			} else if r == ',' && depth == 0 {
+				descEnd = i
+				break
+			}
+		}
+		candidates = append(candidates, srcsetCandidate{
+			URL:        rawURL,
+			Descriptor: strings.TrimSpace(s[:descEnd]),
+		})
+		s = s[descEnd:]
+	}
+}
+
+// srcsetURLs returns the resolved absolute URL of every candidate in srcset
+func srcsetURLs(srcset, baseURL string) []string {
+	var urls []string
+	for _, c := range parseSrcset(srcset) {
+		if resolved := resolveURL(baseURL, c.URL); resolved != "" {
+			urls = append(urls, resolved)
+		}
+	}
+	return urls
+}
+
+// rewriteSrcset replaces every resolvable candidate URL in srcset with the
+// result of rewrite, keeping the width/density descriptors intact
+func rewriteSrcset(srcset, baseURL string, rewrite func(resolvedURL string) string) string {
+	candidates := parseSrcset(srcset)
+	parts := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		u := c.URL
+		if resolved := resolveURL(baseURL, c.URL); resolved != "" {
+			u = rewrite(resolved)
+		}
+		if c.Descriptor != "" {
+			u += " " + c.Descriptor
+		}
+		parts = append(parts, u)
+	}
+	return strings.Join(parts, ", ")
+}
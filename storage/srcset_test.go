@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPreloadImagesrcset(t *testing.T) {
+	const entryUUID = "00000000-0000-0000-0000-000000000000"
+	const baseURL = "https://example.com/post"
+	page := `<!DOCTYPE html><html><head>
+<link rel="preload" as="image" href="/img/hero.jpg" imagesrcset="/img/hero-480.jpg 480w, /img/hero-960.jpg 960w, https://cdn.example.net/hero-1920.jpg 1920w" imagesizes="100vw">
+</head><body></body></html>`
+
+	assets, err := extractAssetsFromHTML(page, baseURL)
+	if err != nil {
+		t.Fatalf("extractAssetsFromHTML: %v", err)
+	}
+	want := []string{
+		"https://example.com/img/hero.jpg",
+		"https://example.com/img/hero-480.jpg",
+		"https://example.com/img/hero-960.jpg",
+		"https://cdn.example.net/hero-1920.jpg",
+	}
+	if !reflect.DeepEqual(assets, want) {
+		t.Errorf("extractAssetsFromHTML = %v, want %v", assets, want)
+	}
+
+	got, err := modifyHTMLPaths(page, entryUUID, baseURL, nil)
+	if err != nil {
+		t.Fatalf("modifyHTMLPaths: %v", err)
+	}
+	local := func(u string) string { return "/data/assets/" + generateAssetFileName(u, entryUUID) }
+	wantSrcset := `imagesrcset="` + local("https://example.com/img/hero-480.jpg") + ` 480w, ` +
+		local("https://example.com/img/hero-960.jpg") + ` 960w, ` +
+		local("https://cdn.example.net/hero-1920.jpg") + ` 1920w"`
+	for _, want := range []string{
+		`href="` + local("https://example.com/img/hero.jpg") + `"`,
+		wantSrcset,
+		`imagesizes="100vw"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("modifyHTMLPaths output missing %s:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"archive-lite/config"
+	"archive-lite/models"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Subresource Integrity modes (ARCHIVE_SRI)
+const (
+	sriKeep   = "keep"   // Leave integrity attributes untouched
+	sriStrip  = "strip"  // Remove integrity attributes from rewritten tags
+	sriVerify = "verify" // Check downloaded assets against their hashes, then strip
+)
+
+// sriMode controls how integrity attributes of rewritten <script> and <link>
+// tags are handled. Local copies are served from another origin and
+// stylesheets are rewritten, so kept hashes make browsers block the assets.
+var sriMode = envSRIMode("ARCHIVE_SRI")
+
+// envSRIMode reads a Subresource Integrity mode, defaulting to strip
+func envSRIMode(name string) string {
+	switch mode := strings.ToLower(strings.TrimSpace(config.Getenv(name))); mode {
+	case "":
+		return sriStrip
+	case sriKeep, sriStrip, sriVerify:
+		return mode
+	default:
+		log.Printf("Invalid %s '%s', expected keep, strip or verify; using strip", name, mode)
+		return sriStrip
+	}
+}
+
+// sriHashes maps the algorithms allowed in integrity metadata to their
+// hashes, strongest first
+var sriHashes = []struct {
+	Name string
+	New  func() hash.Hash
+}{
+	{"sha512", sha512.New},
+	{"sha384", sha512.New384},
+	{"sha256", sha256.New},
+}
+
+// stripsIntegrity reports whether integrity attributes are removed from n
+func stripsIntegrity(n *html.Node) bool {
+	return sriMode != sriKeep && (n.Data == "script" || n.Data == "link")
+}
+
+// removeAttr removes every attribute named key from n
+func removeAttr(n *html.Node, key string) {
+	attrs := n.Attr[:0]
+	for _, attr := range n.Attr {
+		if attr.Key != key {
+			attrs = append(attrs, attr)
+		}
+	}
+	n.Attr = attrs
+}
+
+// integrityByURL returns the integrity metadata of the <script> and <link>
+// assets of a page, keyed by resolved asset URL
+func integrityByURL(htmlContent, baseURL string) (map[string]string, error) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return nil, err
+	}
+
+	integrity := make(map[string]string)
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "script" || n.Data == "link") {
+			if value := strings.TrimSpace(getAttr(n, "integrity")); value != "" {
+				if attrName := assetAttrName(n); attrName != "" {
+					if resolvedURL := resolveURL(baseURL, getAttr(n, attrName)); resolvedURL != "" {
+						integrity[resolvedURL] = value
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return integrity, nil
+}
+
+// checkIntegrity reports whether content matches integrity metadata such as
+// "sha384-<base64> sha512-<base64>". As in browsers, only hashes using the
+// strongest listed algorithm count. supported is false when no listed
+// algorithm is known.
+func checkIntegrity(content []byte, metadata string) (ok, supported bool) {
+	digests := make(map[string][]string)
+	for _, token := range strings.Fields(metadata) {
+		alg, value, found := strings.Cut(token, "-")
+		if !found {
+			continue
+		}
+		// Options such as "?foo" follow the hash and are ignored
+		value, _, _ = strings.Cut(value, "?")
+		digests[strings.ToLower(alg)] = append(digests[strings.ToLower(alg)], value)
+	}
+
+	for _, h := range sriHashes {
+		expected, listed := digests[h.Name]
+		if !listed {
+			continue
+		}
+		hasher := h.New()
+		hasher.Write(content)
+		actual := base64.StdEncoding.EncodeToString(hasher.Sum(nil))
+		for _, value := range expected {
+			if value == actual {
+				return true, true
+			}
+		}
+		return false, true
+	}
+	return false, false
+}
+
+// verifyAssetIntegrity checks the stored assets of a page against the
+// integrity metadata of the tags referencing them and logs mismatches
+func verifyAssetIntegrity(htmlContent, baseURL string, assets []models.Asset) {
+	integrity, err := integrityByURL(htmlContent, baseURL)
+	if err != nil || len(integrity) == 0 {
+		return
+	}
+
+	for _, asset := range assets {
+		metadata, ok := integrity[asset.URL]
+		if !ok || asset.FileName == "" {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(settings.AssetsDir, asset.FileName))
+		if err != nil {
+			fmt.Printf("Warning: failed to read asset '%s' for integrity check: %v\n", asset.URL, err)
+			continue
+		}
+		matched, supported := checkIntegrity(content, metadata)
+		switch {
+		case !supported:
+			fmt.Printf("Warning: unsupported integrity metadata '%s' for asset '%s'\n", metadata, asset.URL)
+		case !matched:
+			fmt.Printf("Warning: asset '%s' does not match its integrity metadata '%s'\n", asset.URL, metadata)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestCheckIntegrity(t *testing.T) {
+	content := []byte("alert(1)")
+	sum256 := sha256.Sum256(content)
+	sum384 := sha512.Sum384(content)
+	h256 := "sha256-" + base64.StdEncoding.EncodeToString(sum256[:])
+	h384 := "sha384-" + base64.StdEncoding.EncodeToString(sum384[:])
+
+	tests := []struct {
+		metadata      string
+		ok, supported bool
+	}{
+		{h256, true, true},
+		{h384 + "?ct=application/javascript", true, true},
+		{"sha256-bogus", false, true},
+		// Only the strongest algorithm counts
+		{h256 + " sha384-bogus", false, true},
+		{"sha384-bogus " + h384, true, true},
+		{"md5-abc", false, false},
+	}
+	for _, tt := range tests {
+		ok, supported := checkIntegrity(content, tt.metadata)
+		if ok != tt.ok || supported != tt.supported {
+			t.Errorf("checkIntegrity(%q) = %v, %v; want %v, %v", tt.metadata, ok, supported, tt.ok, tt.supported)
+		}
+	}
+}
+
+func TestModifyHTMLPathsStripsIntegrity(t *testing.T) {
+	page := `<html><head><script src="/app.js" integrity="sha256-x" crossorigin="anonymous"></script></head></html>`
+	got, err := modifyHTMLPaths(page, "00000000-0000-0000-0000-000000000000", "https://example.com/", nil)
+	if err != nil {
+		t.Fatalf("modifyHTMLPaths: %v", err)
+	}
+	if strings.Contains(got, "integrity") {
+		t.Errorf("integrity attribute kept: %s", got)
+	}
+}
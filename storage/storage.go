@@ -1,11 +1,15 @@
 package storage
 
 import (
+	"archive-lite/activity"
+	"archive-lite/config"
+	"archive-lite/metrics"
 	"archive-lite/models"
-	"compress/gzip"
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -21,17 +25,35 @@ import (
 )
 
 var (
-	rawHTMLDir      = "data/raw"
-	assetsDir       = "data/assets"
-	lastRequestTime time.Time
-	requestDelay    = 500 * time.Millisecond // Reduced delay for faster parallel downloads
-	httpClient      *http.Client
-	requestMutex    sync.Mutex // Mutex to protect lastRequestTime
+	// settings locates the stored files and paces requests to the same host.
+	// It holds config.Default until main calls Configure.
+	settings    = config.Default()
+	httpClient  *http.Client
+	assetClient *http.Client // httpClient with the asset redirect policy, and the HTTP cache when ARCHIVE_HTTP_CACHE_DIR is set
+
+	hostSlots   = make(map[string]*hostSlot) // Politeness state per host, see waitBetweenRequests
+	hostSlotsMu sync.Mutex
 )
 
+// hostSlot serializes requests to one host and remembers when the last one started
+type hostSlot struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
 // init initializes the HTTP client with cookie support
 func init() {
-	jar, err := cookiejar.New(nil)
+	var jar http.CookieJar
+	var err error
+	if cookieJarPath != "" {
+		jar, err = newPersistentJar(cookieJarPath)
+		if err != nil {
+			fmt.Printf("Warning: failed to load persistent cookie jar, falling back to in-memory cookies: %v\n", err)
+			jar, err = cookiejar.New(nil)
+		}
+	} else {
+		jar, err = cookiejar.New(nil)
+	}
 	if err != nil {
 		// Fallback to client without cookies if jar creation fails
 		httpClient = &http.Client{
@@ -39,47 +61,111 @@ func init() {
 		}
 	} else {
 		httpClient = &http.Client{
-			Jar:     jar,
+			Jar:     &clearableJar{jar: jar},
 			Timeout: 30 * time.Second,
 		}
 	}
+
+	assets := *httpClient
+	assets.CheckRedirect = checkAssetRedirect
+	assetClient = &assets
+	if httpCacheDir != "" {
+		cache, err := newCachingTransport(http.DefaultTransport, httpCacheDir, httpCacheMaxBytes)
+		if err != nil {
+			fmt.Printf("Warning: HTTP cache disabled: %v\n", err)
+			return
+		}
+		cached := assets
+		cached.Transport = cache
+		assetClient = &cached
+	}
+}
+
+// Configure sets the data directories and request delay used by the package.
+// It must be called before EnsureStorageDirs and any archiving.
+func Configure(cfg config.Config) {
+	settings = cfg
 }
 
 func SetStorageBaseDirsForTest(testRawHTMLDir, testAssetsDir string) {
-	rawHTMLDir = testRawHTMLDir
-	assetsDir = testAssetsDir
+	settings.RawHTMLDir = testRawHTMLDir
+	settings.AssetsDir = testAssetsDir
 }
 
-func RawHTMLDirForTest() string { return rawHTMLDir }
-func AssetsDirForTest() string  { return assetsDir }
+func RawHTMLDirForTest() string { return settings.RawHTMLDir }
+func AssetsDirForTest() string  { return settings.AssetsDir }
 
 func EnsureStorageDirs() error {
-	if err := os.MkdirAll(rawHTMLDir, 0755); err != nil {
-		return fmt.Errorf("failed to create raw HTML directory '%s': %w", rawHTMLDir, err)
+	if err := os.MkdirAll(settings.RawHTMLDir, 0755); err != nil {
+		return fmt.Errorf("failed to create raw HTML directory '%s': %w", settings.RawHTMLDir, err)
+	}
+	if err := os.MkdirAll(settings.AssetsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create assets directory '%s': %w", settings.AssetsDir, err)
 	}
-	if err := os.MkdirAll(assetsDir, 0755); err != nil {
-		return fmt.Errorf("failed to create assets directory '%s': %w", assetsDir, err)
+	if err := os.MkdirAll(settings.ScreenshotsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create screenshots directory '%s': %w", settings.ScreenshotsDir, err)
 	}
 	return nil
 }
 
-// waitBetweenRequests implements a simple rate limiting to avoid bot detection
-func waitBetweenRequests() {
-	requestMutex.Lock()
-	defer requestMutex.Unlock()
+// waitBetweenRequests implements a simple per-host rate limit to avoid bot
+// detection: requests to the same host start at least settings.RequestDelay apart,
+// while requests to different hosts don't wait for each other. Hosts
+// configured with ARCHIVE_NODELAY_HOSTS or ARCHIVE_NODELAY_PRIVATE are not
+// delayed.
+func waitBetweenRequests(targetURL string) {
+	if skipsDelay(targetURL) {
+		return
+	}
+
+	host := ""
+	if u, err := url.Parse(targetURL); err == nil {
+		host = strings.ToLower(u.Hostname())
+	}
+	hostSlotsMu.Lock()
+	slot, ok := hostSlots[host]
+	if !ok {
+		slot = &hostSlot{}
+		hostSlots[host] = slot
+	}
+	hostSlotsMu.Unlock()
+
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+
+	if !slot.last.IsZero() {
+		elapsed := time.Since(slot.last)
+		if elapsed < settings.RequestDelay {
+			time.Sleep(settings.RequestDelay - elapsed)
+		}
+	}
+	slot.last = time.Now()
+}
 
-	if !lastRequestTime.IsZero() {
-		elapsed := time.Since(lastRequestTime)
-		if elapsed < requestDelay {
-			time.Sleep(requestDelay - elapsed)
+// EvictIdleHosts forgets the rate-limit state of hosts whose last request
+// started more than settings.RequestDelay ago, so they would not be delayed anyway. It
+// returns how many hosts were forgotten and how many remain.
+func EvictIdleHosts() (int, int) {
+	hostSlotsMu.Lock()
+	defer hostSlotsMu.Unlock()
+	evicted := 0
+	for host, slot := range hostSlots {
+		if !slot.mu.TryLock() {
+			continue // A request to the host is waiting or starting
+		}
+		idle := time.Since(slot.last) >= settings.RequestDelay
+		slot.mu.Unlock()
+		if idle {
+			delete(hostSlots, host)
+			evicted++
 		}
 	}
-	lastRequestTime = time.Now()
+	return evicted, len(hostSlots)
 }
 
 // setProperHeaders sets headers to mimic a real browser
 func setProperHeaders(req *http.Request, referer ...string) {
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+	req.Header.Set("User-Agent", defaultUserAgent)
 	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,image/apng,*/*;q=0.8")
 	req.Header.Set("Accept-Language", "ja,en-US;q=0.9,en;q=0.8")
 	// Temporarily disable compression to avoid encoding issues
@@ -132,6 +218,15 @@ func resolveRedirectsWithReferer(originalURL, referer string) (string, error) {
 	return finalURL, nil
 }
 
+// isShortenerURL reports whether rawURL belongs to a redirect service whose
+// target is resolved before archiving
+func isShortenerURL(rawURL string) bool {
+	return strings.Contains(rawURL, "news.google.com") ||
+		strings.Contains(rawURL, "t.co") ||
+		strings.Contains(rawURL, "bit.ly") ||
+		strings.Contains(rawURL, "tinyurl.com")
+}
+
 // extractFinalURLFromGoogleNews extracts the actual URL from Google News redirect URLs
 func extractFinalURLFromGoogleNews(googleNewsURL string) (string, error) {
 	// Try to extract URL from Google News format
@@ -142,7 +237,7 @@ func extractFinalURLFromGoogleNews(googleNewsURL string) (string, error) {
 		}
 
 		// Wait before accessing Google News
-		waitBetweenRequests()
+		waitBetweenRequests(googleNewsURL)
 
 		// First try to follow redirects normally with proper referer
 		finalURL, err := resolveRedirectsWithReferer(googleNewsURL, "https://www.google.com")
@@ -170,104 +265,303 @@ func extractFinalURLFromGoogleNews(googleNewsURL string) (string, error) {
 }
 
 func FetchRawHTML(url string) (string, error) {
-	waitBetweenRequests()
+	content, _, err := fetchPage(url, ArchiveOptions{})
+	return content, err
+}
+
+// MissingLocationError is returned when a page answers with a redirect status
+// but no Location header, so there is nothing to follow. The body of such a
+// response is archived instead when ArchiveOptions.ArchiveNon200 is set.
+type MissingLocationError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *MissingLocationError) Error() string {
+	return fmt.Sprintf("failed to get URL '%s': redirect status %d without a Location header", e.URL, e.StatusCode)
+}
+
+// isRedirectStatus reports whether code is a redirect the HTTP client follows
+func isRedirectStatus(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// pageResponse describes the HTTP response a page was read from
+type pageResponse struct {
+	StatusCode  int
+	ContentType string // Media type without parameters; empty if not sent
+	Header      http.Header
+
+	URL           string        // URL of the final request, after redirects
+	RequestHeader http.Header   // Headers sent with the final request
+	Redirects     []RedirectHop // Redirects followed to the final response
+}
+
+// fetchPage fetches the HTML at url and returns it with the response status
+// and content type. Non-200 responses are an error unless opts.ArchiveNon200
+// is set, in which case their body is returned as the page. The User-Agent of
+// opts.Device and replayed negotiation headers are used when set.
+func fetchPage(url string, opts ArchiveOptions) (string, pageResponse, error) {
+	waitBetweenRequests(url)
 
 	client := httpClient
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request for '%s': %w", url, err)
+		return "", pageResponse{}, fmt.Errorf("failed to create request for '%s': %w", url, err)
 	}
-	setProperHeaders(req)
+	setProperHeaders(req, opts.Referer)
+	setNegotiationHeaders(req, opts)
+	opts.session.addCookies(req)
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to get URL '%s': %w", url, err)
+		return "", pageResponse{}, fmt.Errorf("failed to get URL '%s': %w", url, err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to get URL '%s': status code %d", url, resp.StatusCode)
+	page := pageResponse{
+		StatusCode:    resp.StatusCode,
+		Header:        resp.Header,
+		URL:           resp.Request.URL.String(),
+		RequestHeader: resp.Request.Header,
+		Redirects:     redirectChain(resp),
+	}
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+		page.ContentType = mediaType
 	}
 
-	// Handle gzip-compressed responses
-	var reader io.Reader = resp.Body
-	if resp.Header.Get("Content-Encoding") == "gzip" {
-		gzReader, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			return "", fmt.Errorf("failed to create gzip reader for '%s': %w", url, err)
+	if resp.StatusCode != http.StatusOK && !opts.ArchiveNon200 {
+		if isRedirectStatus(resp.StatusCode) && resp.Header.Get("Location") == "" {
+			return "", page, &MissingLocationError{URL: url, StatusCode: resp.StatusCode}
 		}
-		defer gzReader.Close()
-		reader = gzReader
+		return "", page, fmt.Errorf("failed to get URL '%s': status code %d", url, resp.StatusCode)
 	}
+	if opts.AllowedMainTypes != nil && !mainTypeAllowed(page.ContentType, opts.AllowedMainTypes) {
+		// Rejected before the body is read, which may be a large binary or a stream
+		return "", page, &UnsupportedMainTypeError{URL: page.URL, ContentType: page.ContentType}
+	}
+
+	// Handle compressed responses
+	reader, release, err := decodeContent(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return "", page, fmt.Errorf("failed to decode response body from '%s': %w", url, err)
+	}
+	defer release()
 
 	bodyBytes, err := io.ReadAll(reader)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body from '%s': %w", url, err)
+		return "", page, fmt.Errorf("failed to read response body from '%s': %w", url, err)
 	}
 
-	return string(bodyBytes), nil
+	return string(bodyBytes), page, nil
 }
 
 func FetchAsset(assetURL string) ([]byte, error) {
-	waitBetweenRequests()
+	content, _, err := fetchAsset(assetURL, "")
+	return content, err
+}
 
-	client := httpClient
+// assetResponse describes the HTTP response an asset was read from
+type assetResponse struct {
+	FinalURL    string
+	StatusCode  int
+	ContentType string
+	FetchedAt   time.Time // When the request was sent, after rate limiting
+	Disposition string    // Content-Disposition header of the response, see assetFileNameFor
+	ErrorBody   []byte    // Start of the body of a non-200 response, up to maxAssetErrorBodyBytes
+}
+
+// fetchAsset downloads an asset and reports details of the final response.
+// userAgent overrides the default User-Agent when not empty.
+func fetchAsset(assetURL, userAgent string) ([]byte, assetResponse, error) {
+	waitBetweenRequests(assetURL)
+
+	client := assetClient
+	info := assetResponse{FetchedAt: time.Now()}
 
 	req, err := http.NewRequest("GET", assetURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request for asset '%s': %w", assetURL, err)
+		return nil, info, fmt.Errorf("failed to create request for asset '%s': %w", assetURL, err)
 	}
 	setProperHeaders(req)
+	req.Header.Set("Accept-Encoding", assetAcceptEncoding)
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get asset '%s': %w", assetURL, err)
+		return nil, info, fmt.Errorf("failed to get asset '%s': %w", assetURL, err)
 	}
 	defer resp.Body.Close()
 
+	info.FinalURL = resp.Request.URL.String()
+	info.StatusCode = resp.StatusCode
+	info.ContentType = resp.Header.Get("Content-Type")
+	info.Disposition = resp.Header.Get("Content-Disposition")
+
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get asset '%s': status code %d", assetURL, resp.StatusCode)
+		// Kept for debugging when ARCHIVE_STORE_ASSET_ERROR_BODIES is set
+		info.ErrorBody, _ = io.ReadAll(io.LimitReader(resp.Body, maxAssetErrorBodyBytes))
+		return nil, info, fmt.Errorf("failed to get asset '%s': status code %d", assetURL, resp.StatusCode)
 	}
 
-	// Handle gzip-compressed responses
-	var reader io.Reader = resp.Body
-	if resp.Header.Get("Content-Encoding") == "gzip" {
-		gzReader, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create gzip reader for asset '%s': %w", assetURL, err)
+	// Handle compressed responses; servers may compress assets even when
+	// asked for identity, and compressed bytes would be stored as the asset
+	reader, release, err := decodeContent(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to decode asset '%s': %w", assetURL, err)
+	}
+	defer release()
+
+	content, err := io.ReadAll(reader)
+	return content, info, err
+}
+
+// getAttr returns the value of the named attribute on n, or "" if absent
+func getAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
 		}
-		defer gzReader.Close()
-		reader = gzReader
 	}
+	return ""
+}
+
+// linkRelTypes returns the lower-cased, space-separated rel tokens of a <link>
+func linkRelTypes(n *html.Node) []string {
+	return strings.Fields(strings.ToLower(getAttr(n, "rel")))
+}
 
-	return io.ReadAll(reader)
+// isArchivableLink reports whether a <link> element points at a resource that
+// is needed for offline rendering. Resource hints such as preload, prefetch and
+// modulepreload are handled explicitly; preconnect/dns-prefetch carry no
+// resource and preload as=fetch targets runtime-only data requests.
+func isArchivableLink(n *html.Node) bool {
+	if hasRel(n, "stylesheet") {
+		// Includes "alternate stylesheet"
+		return true
+	}
+	for _, rel := range linkRelTypes(n) {
+		switch rel {
+		case "preconnect", "dns-prefetch":
+			return false
+		case "canonical", "amphtml", "alternate":
+			// Page references, not resources; keep the original absolute URL
+			return false
+		case "preload", "prefetch":
+			as := strings.ToLower(getAttr(n, "as"))
+			switch as {
+			case "font", "style", "script", "image", "audio", "video", "track", "":
+				return true
+			default:
+				// as=fetch, as=document, etc. are fetched by scripts at runtime
+				return false
+			}
+		case "modulepreload":
+			return true
+		}
+	}
+	return true
+}
+
+// assetAttrName returns the attribute holding the asset URL for n, or "" if
+// the element does not reference an asset that should be archived
+func assetAttrName(n *html.Node) string {
+	switch n.Data {
+	case "link":
+		if isArchivableLink(n) {
+			return "href"
+		}
+	case "script", "img", "iframe", "source", "track":
+		return "src"
+	}
+	return ""
 }
 
 func extractAssetsFromHTML(htmlContent, baseURL string) ([]string, error) {
+	page, err := parseAssetPage(htmlContent, baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return page.assets, nil
+}
+
+// assetRef is an attribute of a parsed page that references assets: a URL
+// attribute such as src or href, or a srcset
+type assetRef struct {
+	node     *html.Node
+	attr     string
+	resolved string // Resolved URL of a URL attribute; "" for srcsets
+}
+
+// noscriptFragment is the fallback content of a <noscript>, which the parser
+// leaves as raw text, parsed so its assets can be found and rewritten
+type noscriptFragment struct {
+	node  *html.Node
+	nodes []*html.Node
+}
+
+// assetPage is a parsed page with the asset references found while walking
+// it. Once the assets are stored, rewrite points those same references at
+// the local copies without parsing or walking the page again.
+type assetPage struct {
+	doc       *html.Node
+	baseURL   string
+	assets    []string // Resolved asset URLs, in download order
+	refs      []assetRef
+	noscripts []noscriptFragment // Innermost first, as nested ones are rendered into their parent
+}
+
+// parseAssetPage parses htmlContent and collects the assets it references,
+// resolved against baseURL
+func parseAssetPage(htmlContent, baseURL string) (*assetPage, error) {
 	doc, err := html.Parse(strings.NewReader(htmlContent))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse HTML: %w", err)
 	}
 
-	var assets []string
+	page := &assetPage{doc: doc, baseURL: baseURL}
+	kinds := make(map[string]string) // Kind of each asset, for prioritizeAssets
 	var extractFunc func(*html.Node)
 	extractFunc = func(n *html.Node) {
 		if n.Type == html.ElementNode {
-			var attrName string
-			switch n.Data {
-			case "link":
-				attrName = "href"
-			case "script", "img", "iframe":
-				attrName = "src"
-			}
-
-			if attrName != "" {
+			if attrName := assetAttrName(n); attrName != "" {
 				for _, attr := range n.Attr {
 					if attr.Key == attrName {
 						assetURL := attr.Val
 						if resolvedURL := resolveURL(baseURL, assetURL); resolvedURL != "" {
-							assets = append(assets, resolvedURL)
+							page.refs = append(page.refs, assetRef{node: n, attr: attrName, resolved: resolvedURL})
+							if keepsExternal(resolvedURL) {
+								break
+							}
+							page.assets = append(page.assets, resolvedURL)
+							if _, ok := kinds[resolvedURL]; !ok {
+								kinds[resolvedURL] = elementAssetKind(n)
+							}
+						}
+						break
+					}
+				}
+			}
+			if srcsetAttr := srcsetAttrName(n); srcsetAttr != "" {
+				for _, attr := range n.Attr {
+					if attr.Key == srcsetAttr {
+						page.refs = append(page.refs, assetRef{node: n, attr: srcsetAttr})
+						for _, u := range srcsetURLs(attr.Val, baseURL) {
+							if keepsExternal(u) {
+								continue
+							}
+							page.assets = append(page.assets, u)
+							if _, ok := kinds[u]; !ok {
+								kinds[u] = assetKindImage
+							}
 						}
 						break
 					}
@@ -275,13 +569,22 @@ func extractAssetsFromHTML(htmlContent, baseURL string) ([]string, error) {
 			}
 		}
 
+		// Fallback content of <noscript> is raw text to the parser
+		if nodes := noscriptNodes(n); nodes != nil {
+			for _, c := range nodes {
+				extractFunc(c)
+			}
+			page.noscripts = append(page.noscripts, noscriptFragment{node: n, nodes: nodes})
+			return
+		}
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
 			extractFunc(c)
 		}
 	}
 
 	extractFunc(doc)
-	return assets, nil
+	prioritizeAssets(page.assets, kinds)
+	return page, nil
 }
 
 func resolveURL(baseURL, relativeURL string) string {
@@ -310,11 +613,16 @@ func resolveURL(baseURL, relativeURL string) string {
 	return base.ResolveReference(relative).String()
 }
 
+// assetHashLength is the number of hex characters of the URL hash used in
+// asset file names. 16 (64 bits) makes collisions between the assets of a
+// page practically impossible; 8 could collide on large pages.
+const assetHashLength = 16
+
 func generateAssetFileName(assetURL, entryUUID string) string {
 	// Create a hash of the URL to avoid filename conflicts
 	hasher := md5.New()
 	hasher.Write([]byte(assetURL))
-	hash := fmt.Sprintf("%x", hasher.Sum(nil))[:8]
+	hash := fmt.Sprintf("%x", hasher.Sum(nil))[:assetHashLength]
 
 	// Extract file extension
 	parsedURL, err := url.Parse(assetURL)
@@ -322,7 +630,9 @@ func generateAssetFileName(assetURL, entryUUID string) string {
 		return fmt.Sprintf("%s_%s", entryUUID, hash)
 	}
 
-	ext := filepath.Ext(parsedURL.Path)
+	// Only short alphanumeric extensions are kept; a long or odd last path
+	// segment must not end up in the file name
+	ext := assetExtension(filepath.Ext(parsedURL.Path))
 	if ext == "" {
 		// Try to guess extension from URL path
 		if strings.Contains(assetURL, ".css") {
@@ -336,68 +646,270 @@ func generateAssetFileName(assetURL, entryUUID string) string {
 		}
 	}
 
-	return fmt.Sprintf("%s_%s%s", entryUUID, hash, ext)
+	name := fmt.Sprintf("%s_%s%s", entryUUID, hash, ext)
+	if len(filepath.Join(settings.AssetsDir, name)) > maxPathBytes {
+		// Deep storage directories: the extension is the only optional part
+		name = fmt.Sprintf("%s_%s", entryUUID, hash)
+	}
+	return name
 }
 
-func modifyHTMLPaths(htmlContent, entryUUID, baseURL string) (string, error) {
-	doc, err := html.Parse(strings.NewReader(htmlContent))
+// modifyHTMLPaths points the asset references of htmlContent at their local
+// copies. names maps downloaded asset URLs to their stored file names (see
+// assetNameFunc); it may be nil.
+func modifyHTMLPaths(htmlContent, entryUUID, baseURL string, names map[string]string) (string, error) {
+	page, err := parseAssetPage(htmlContent, baseURL)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse HTML: %w", err)
+		return "", err
 	}
+	return page.rewrite(entryUUID, names)
+}
 
-	var modifyFunc func(*html.Node)
-	modifyFunc = func(n *html.Node) {
-		if n.Type == html.ElementNode {
-			var attrName string
-			switch n.Data {
-			case "link":
-				attrName = "href"
-			case "script", "img", "iframe":
-				attrName = "src"
+// rewrite points the asset references found by parseAssetPage at their
+// local copies, as modifyHTMLPaths does, and renders the page. Assets of
+// keepExternalHosts get their absolute URL, and their integrity is kept. It
+// modifies the parsed page, so it must only be called once.
+func (p *assetPage) rewrite(entryUUID string, names map[string]string) (string, error) {
+	assetName := assetNameFunc(names, entryUUID)
+	localPath := func(resolvedURL string) string {
+		if keepsExternal(resolvedURL) {
+			return resolvedURL
+		}
+		return fmt.Sprintf("/data/assets/%s", assetName(resolvedURL))
+	}
+	for _, ref := range p.refs {
+		n := ref.node
+		for i, attr := range n.Attr {
+			if attr.Key != ref.attr {
+				continue
 			}
-
-			if attrName != "" {
-				for i, attr := range n.Attr {
-					if attr.Key == attrName {
-						originalURL := attr.Val
-						if resolvedURL := resolveURL(baseURL, originalURL); resolvedURL != "" {
-							newPath := fmt.Sprintf("/data/assets/%s", generateAssetFileName(resolvedURL, entryUUID))
-							n.Attr[i].Val = newPath
-						}
-						break
-					}
-				}
+			if ref.resolved == "" {
+				n.Attr[i].Val = rewriteSrcset(attr.Val, p.baseURL, localPath)
+				break
 			}
+			n.Attr[i].Val = localPath(ref.resolved)
+			if stripsIntegrity(n) && !keepsExternal(ref.resolved) {
+				removeAttr(n, "integrity")
+			}
+			break
 		}
+	}
 
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			modifyFunc(c)
+	for _, fragment := range p.noscripts {
+		if err := setNoscriptNodes(fragment.node, fragment.nodes); err != nil {
+			return "", fmt.Errorf("failed to render <noscript> content: %w", err)
 		}
 	}
+	if promoteNoscript {
+		promoteNoscripts(p.doc)
+	}
 
-	modifyFunc(doc)
+	if htmlOutputMode == htmlOutputMinify {
+		minifyNode(p.doc)
+	}
 
 	// Convert back to HTML string
 	var buf strings.Builder
-	err = html.Render(&buf, doc)
-	if err != nil {
+	if err := html.Render(&buf, p.doc); err != nil {
 		return "", fmt.Errorf("failed to render modified HTML: %w", err)
 	}
 
 	return buf.String(), nil
 }
 
+// ArchiveOptions holds per-request settings for ArchiveURLWithOptions
+type ArchiveOptions struct {
+	// Canonicalize controls how CanonicalURL is derived. When true, tracking
+	// parameters are dropped and the query is sorted so trivially different
+	// URLs share a key; when false the resolved URL is stored verbatim and
+	// URLs differing only by query are kept as distinct archives.
+	Canonicalize bool
+
+	// SeriesID links the new entry to related entries, e.g. feed items
+	// archived from the same source page
+	SeriesID string
+
+	// ArchiveNon200 archives the body of non-200 responses (e.g. 404 or 403
+	// error pages) instead of failing; the status is stored in HTTPStatus
+	ArchiveNon200 bool
+
+	// Device is the profile whose User-Agent is sent for the page and its
+	// assets and whose viewport is used for the screenshot
+	Device models.DeviceProfile
+
+	// RenderDOM stores the DOM as rendered by headless Chrome after scripts
+	// ran, instead of the HTML served by the origin. The screenshot is taken
+	// in the same Chrome session, so both show the same page state.
+	RenderDOM bool
+
+	// DismissSelectors are CSS selectors of overlays (e.g. cookie banners)
+	// hidden, or clicked when prefixed with "click:", before capturing
+	DismissSelectors []string
+
+	// Accept, when set, replaces the browser-like Accept header of the page
+	// request (e.g. "text/html" for content-negotiated endpoints). The Accept
+	// header sent is recorded in the entry's Negotiation.
+	Accept string
+
+	// Referer, when set, is sent as the Referer header of the page request
+	// and recorded on the entry so refetches send it again. Chrome renders
+	// and asset requests don't send it.
+	Referer string
+
+	// Login, when set, is a form login performed in headless Chrome before
+	// the page is fetched; its session cookies are sent with the page fetch
+	// and set in Chrome for rendering and screenshots
+	Login *LoginConfig
+
+	// OnlyIfChanged skips storing a snapshot when the page's text matches the
+	// latest snapshot of the same URL; the result is an *UnchangedError
+	OnlyIfChanged bool
+
+	// MHTML also stores the page as a single MHTML file captured by headless
+	// Chrome, next to the HTML
+	MHTML bool
+
+	// ScreenshotTTL, in seconds, makes the screenshot expire and be
+	// regenerated (without refetching the HTML) that long after it was taken
+	ScreenshotTTL int64
+
+	// StoreAssetErrorBodies stores the body of assets answered with a non-200
+	// status next to the assets, named in the Asset record's ErrorBodyFile
+	StoreAssetErrorBodies bool
+
+	// AllowedMainTypes are the media type patterns (type/subtype, type/* or
+	// */*) the page's Content-Type must match; other responses fail with an
+	// UnsupportedMainTypeError before their body is read. nil allows any type.
+	AllowedMainTypes []string
+
+	// session holds the cookies of a completed Login
+	session *loginSession
+
+	// negotiation holds recorded negotiation headers replayed by a refetch
+	negotiation map[string]string
+
+	// previousTextHash makes captureURL return errContentUnchanged, before
+	// anything is written, when the fetched content has this change hash
+	previousTextHash string
+}
+
+// allowNon200 is the default for ArchiveOptions.ArchiveNon200 (ARCHIVE_ALLOW_NON_200)
+var allowNon200 = envBool("ARCHIVE_ALLOW_NON_200", false)
+
+// DefaultArchiveOptions returns the options used by ArchiveURL
+func DefaultArchiveOptions() ArchiveOptions {
+	return ArchiveOptions{
+		Canonicalize:          true,
+		ArchiveNon200:         allowNon200,
+		Device:                DefaultDeviceProfile(),
+		RenderDOM:             renderDOM,
+		DismissSelectors:      dismissSelectors,
+		MHTML:                 storeMHTML,
+		StoreAssetErrorBodies: storeAssetErrorBodies,
+		AllowedMainTypes:      allowedMainTypes,
+	}
+}
+
+// ArchiveURL archives urlToArchive using DefaultArchiveOptions
 func ArchiveURL(db *gorm.DB, urlToArchive string) (*models.ArchiveEntry, error) {
+	return ArchiveURLWithOptions(db, urlToArchive, DefaultArchiveOptions())
+}
+
+// ArchiveURLWithOptions fetches urlToArchive and its assets, stores them on
+// disk and records a new ArchiveEntry
+func ArchiveURLWithOptions(db *gorm.DB, urlToArchive string, opts ArchiveOptions) (*models.ArchiveEntry, error) {
+	started := time.Now()
+	var latest *models.ArchiveEntry
+	if opts.OnlyIfChanged {
+		var err error
+		if latest, err = latestSnapshot(db, urlToArchive, opts.Canonicalize); err != nil {
+			fmt.Printf("Warning: failed to find the latest snapshot of '%s', archiving unconditionally: %v\n", urlToArchive, err)
+		} else if latest != nil {
+			opts.previousTextHash = latest.TextHash
+		}
+	}
+
+	archiveEntry, assetRecords, err := captureURL(urlToArchive, uuid.New().String(), opts)
+	if errors.Is(err, errContentUnchanged) {
+		fmt.Printf("Content of '%s' unchanged since snapshot %s, not archiving\n", urlToArchive, latest.ID)
+		recordUnchanged("archive", urlToArchive, latest.ID, started)
+		return nil, &UnchangedError{URL: urlToArchive, Latest: latest}
+	}
+	if err != nil {
+		recordOperation("archive", urlToArchive, "", started, err)
+		return nil, err
+	}
+
+	result := db.Create(archiveEntry)
+	if result.Error != nil {
+		os.Remove(archiveEntry.StoragePath)
+		if archiveEntry.ScreenshotPath != "" {
+			os.Remove(archiveEntry.ScreenshotPath)
+		}
+		if archiveEntry.ThumbnailPath != "" {
+			os.Remove(archiveEntry.ThumbnailPath)
+		}
+		if archiveEntry.TextPath != "" {
+			os.Remove(archiveEntry.TextPath)
+		}
+		if archiveEntry.MHTMLPath != "" {
+			os.Remove(archiveEntry.MHTMLPath)
+		}
+		os.Remove(DebugPath(archiveEntry.ID))
+		err := fmt.Errorf("failed to create archive entry in database for '%s': %w", archiveEntry.URL, result.Error)
+		recordOperation("archive", urlToArchive, "", started, err)
+		return nil, err
+	}
+
+	saveAssetRecords(db, archiveEntry.URL, assetRecords)
+	appendAudit(db, "archive", archiveEntry)
+	pruneSnapshots(db, archiveEntry)
+	recordOperation("archive", urlToArchive, archiveEntry.ID, started, nil)
+	return archiveEntry, nil
+}
+
+// recordOperation reports a finished archive or refetch to the activity log and metrics
+func recordOperation(action, url, entryID string, started time.Time, err error) {
+	activity.Record(action, url, entryID, started, err)
+	metrics.RecordOperation(action, started, err)
+}
+
+// saveAssetRecords stores per-asset fetch metadata; a failure here doesn't fail the archive
+func saveAssetRecords(db *gorm.DB, pageURL string, records []models.Asset) {
+	if !recordAssets || len(records) == 0 {
+		return
+	}
+	if err := db.Create(&records).Error; err != nil {
+		fmt.Printf("Warning: failed to record assets for '%s': %v\n", pageURL, err)
+	}
+}
+
+// captureURL fetches urlToArchive and its assets and writes them to disk under
+// entryUUID, overwriting any files already stored for that ID. It returns the
+// (unsaved) entry describing the capture along with the asset fetch records.
+// A fragment in urlToArchive is recorded on the entry, not fetched or stored
+// in its URLs.
+func captureURL(urlToArchive, entryUUID string, opts ArchiveOptions) (*models.ArchiveEntry, []models.Asset, error) {
+	urlToArchive, fragment := splitFragment(urlToArchive)
 	if err := EnsureStorageDirs(); err != nil {
-		return nil, fmt.Errorf("failed to ensure storage directories: %w", err)
+		return nil, nil, fmt.Errorf("failed to ensure storage directories: %w", err)
+	}
+	if err := checkFreeSpace(settings.RawHTMLDir); err != nil {
+		return nil, nil, err
+	}
+
+	// Log in first so the page is fetched and rendered with the session's cookies
+	if opts.Login != nil {
+		session, err := performLogin(opts.Login, opts.Device)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts.session = session
 	}
 
 	// Resolve redirects to get the final URL
 	finalURL := urlToArchive
-	if strings.Contains(urlToArchive, "news.google.com") ||
-		strings.Contains(urlToArchive, "t.co") ||
-		strings.Contains(urlToArchive, "bit.ly") ||
-		strings.Contains(urlToArchive, "tinyurl.com") {
+	if isShortenerURL(urlToArchive) {
 		resolvedURL, err := extractFinalURLFromGoogleNews(urlToArchive)
 		if err != nil {
 			fmt.Printf("Warning: failed to resolve redirects for '%s': %v, using original URL\n", urlToArchive, err)
@@ -407,61 +919,275 @@ func ArchiveURL(db *gorm.DB, urlToArchive string) (*models.ArchiveEntry, error)
 		}
 	}
 
-	// Fetch raw HTML content from the final URL
-	htmlContent, err := FetchRawHTML(finalURL)
+	// Rotate the desktop User-Agent per host; device-specific and explicitly
+	// requested User-Agents are kept, as are those replayed by a refetch
+	if opts.Device.Name == "desktop" && opts.negotiation["User-Agent"] == "" {
+		if ua := nextUserAgent(finalURL); ua != "" {
+			opts.Device.UserAgent = ua
+		}
+	}
+
+	// Fetch HTML content from the final URL, falling back to other strategies if blocked
+	fetched, err := fetchWithStrategies(finalURL, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch HTML content for '%s': %w", finalURL, err)
+		return nil, nil, fmt.Errorf("failed to fetch HTML content for '%s': %w", finalURL, err)
+	}
+	htmlContent, page := fetched.HTML, fetched.Page
+	if fetched.Accept != "" {
+		// Record the Accept header of the retry that produced the page
+		opts.Accept = fetched.Accept
+	}
+	if page.StatusCode != http.StatusOK {
+		fmt.Printf("Archiving non-200 response for '%s': status code %d\n", finalURL, page.StatusCode)
 	}
 
-	// Generate unique filename
-	entryUUID := uuid.New().String()
-	// Extract and save assets using the final URL as base
-	assets, err := extractAssetsFromHTML(htmlContent, finalURL)
+	// Downloads are stored as served rather than parsed as HTML
+	if fileName, ok := attachmentName(page.Header, finalURL); ok {
+		switch attachmentPolicy {
+		case attachmentStore:
+			entry, records, err := captureAttachment(urlToArchive, finalURL, entryUUID, opts, fetched, fileName)
+			if entry != nil {
+				// e.g. #page=3 of a PDF
+				entry.Fragment = fragment
+			}
+			return entry, records, err
+		case attachmentReject:
+			return nil, nil, fmt.Errorf("%w: '%s'", ErrAttachmentRejected, finalURL)
+		}
+	}
+
+	// For AMP pages, optionally archive the richer canonical page instead
+	if isAMP, canonicalURL := detectAMP(htmlContent, finalURL); isAMP {
+		if preferCanonical && canonicalURL != "" && canonicalURL != finalURL {
+			// Same request settings as the page, but an error page doesn't
+			// replace the AMP page
+			canonicalOpts := opts
+			canonicalOpts.ArchiveNon200 = false
+			canonicalHTML, canonicalPage, err := fetchPage(canonicalURL, canonicalOpts)
+			if err != nil {
+				fmt.Printf("Warning: failed to fetch canonical page '%s' for AMP page '%s': %v, archiving AMP page\n", canonicalURL, finalURL, err)
+			} else {
+				fmt.Printf("AMP page detected, archiving canonical: %s -> %s\n", finalURL, canonicalURL)
+				finalURL = canonicalURL
+				htmlContent = canonicalHTML
+				page = canonicalPage
+				fetched = fetchResult{Strategy: strategyStatic}
+			}
+		} else {
+			fmt.Printf("AMP page detected: %s\n", finalURL)
+		}
+	}
+
+	// Optionally replace the served HTML with the DOM rendered by Chrome,
+	// taking the screenshot from the same page load. The browser fetch
+	// strategy already did both.
+	rendered := fetched.Strategy == strategyBrowser
+	renderedScreenshot := fetched.Screenshot
+	pageWidth, pageHeight := fetched.Width, fetched.Height
+	if opts.RenderDOM && !rendered {
+		captured, err := capturePage(finalURL, opts.Device, opts.DismissSelectors, opts.session, true, captureScreenshots)
+		if err != nil {
+			fmt.Printf("Warning: failed to render '%s': %v, archiving served HTML\n", finalURL, err)
+		} else {
+			htmlContent = captured.DOM
+			renderedScreenshot = captured.Screenshot
+			pageWidth, pageHeight = captured.Width, captured.Height
+			rendered = true
+		}
+	}
+
+	// Optionally normalize line endings so content hashes don't depend on them
+	htmlContent = normalizeNewlines(htmlContent)
+
+	// Pages served with 200 that look like not-found pages are flagged, or refused
+	softError := false
+	if detectSoft404 && page.StatusCode == http.StatusOK {
+		if reason := soft404Reason(htmlContent); reason != "" {
+			if rejectSoft404 {
+				return nil, nil, fmt.Errorf("%w: '%s' (%s)", ErrSoft404, finalURL, reason)
+			}
+			fmt.Printf("Warning: '%s' looks like a soft 404 (%s)\n", finalURL, reason)
+			softError = true
+		}
+	}
+
+	// Only-if-changed operations stop here, before anything is written
+	textHash := changeHash(htmlContent)
+	if opts.previousTextHash != "" && textHash == opts.previousTextHash {
+		return nil, nil, errContentUnchanged
+	}
+
+	// Record the page's language and text direction for i18n-aware browsing
+	lang, dir := documentLanguage(htmlContent)
+
+	// Extract and save assets using the final URL as base. The page is parsed
+	// once; the references found are rewritten after the downloads.
+	parsed, err := parseAssetPage(htmlContent, finalURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to extract assets from HTML for '%s': %w", urlToArchive, err)
+		return nil, nil, fmt.Errorf("failed to extract assets from HTML for '%s': %w", urlToArchive, err)
 	}
+	assets := parsed.assets
 
 	// Download assets in parallel (using 5 workers for good balance between speed and server load)
 	fmt.Printf("Found %d assets to download\n", len(assets))
+	var assetRecords []models.Asset
 	if len(assets) > 0 {
 		maxWorkers := 5
 		if len(assets) < maxWorkers {
 			maxWorkers = len(assets)
 		}
 		fmt.Printf("Starting parallel download with %d workers...\n", maxWorkers)
-		downloadedAssets := downloadAssetsParallel(assets, entryUUID, maxWorkers)
+		var downloadedAssets map[string]string
+		downloadedAssets, assetRecords = downloadAssetsParallel(assets, entryUUID, maxWorkers, opts.Device.UserAgent, opts.StoreAssetErrorBodies)
 		fmt.Printf("Download completed. %d assets downloaded successfully.\n", len(downloadedAssets))
+
+		// Check captured scripts and stylesheets before stylesheets are rewritten
+		if sriMode == sriVerify {
+			verifyAssetIntegrity(htmlContent, finalURL, assetRecords)
+		}
+
+		// Point stylesheets at local copies of the images and fonts they reference
+		assetRecords = append(assetRecords, rewriteStylesheets(assetRecords, entryUUID, maxWorkers, opts.Device.UserAgent, opts.StoreAssetErrorBodies)...)
 	}
+
+	// Keep an icon for list views, falling back to the origin's /favicon.ico
+	favicon := faviconURL(htmlContent, finalURL)
+	if favicon == "" && storeFavicon {
+		if favicon = defaultFaviconURL(finalURL); favicon != "" {
+			// A missing /favicon.ico is expected; its error page isn't worth keeping
+			_, records := downloadAssetsParallel([]string{favicon}, entryUUID, 1, opts.Device.UserAgent, false)
+			assetRecords = append(assetRecords, records...)
+		}
+	}
+
+	if bundleAssets {
+		if err := writeAssetBundle(entryUUID); err != nil {
+			fmt.Printf("Warning: failed to bundle assets for '%s', keeping loose files: %v\n", finalURL, err)
+		}
+	}
+
+	// Record where and when the page was archived, and by which build
+	archivedAt := time.Now()
+	if recordProvenance {
+		injectProvenance(parsed.doc, provenance(archivedAt, finalURL))
+	}
+
 	// Modify HTML to use local asset paths (use finalURL for proper resolution)
-	modifiedHTML, err := modifyHTMLPaths(htmlContent, entryUUID, finalURL)
+	modifiedHTML, err := parsed.rewrite(entryUUID, storedAssetNames(assetRecords))
 	if err != nil {
-		return nil, fmt.Errorf("failed to modify HTML paths for '%s': %w", finalURL, err)
+		return nil, nil, fmt.Errorf("failed to modify HTML paths for '%s': %w", finalURL, err)
 	}
 
 	// Save modified HTML content to file
 	htmlFileName := fmt.Sprintf("%s.html", entryUUID)
-	htmlFilePath := filepath.Join(rawHTMLDir, htmlFileName)
+	htmlFilePath := filepath.Join(settings.RawHTMLDir, htmlFileName)
 
-	if err := os.WriteFile(htmlFilePath, []byte(modifiedHTML), 0644); err != nil {
-		return nil, fmt.Errorf("failed to write HTML to '%s': %w", htmlFilePath, err)
+	if err := writeFileAtomic(htmlFilePath, []byte(modifiedHTML), 0644); err != nil {
+		return nil, nil, fmt.Errorf("failed to write HTML to '%s': %w", htmlFilePath, err)
 	}
-	// Create archive entry in database
-	// Store the original URL for reference, but the content comes from the final URL
-	archiveEntry := models.ArchiveEntry{
-		ID:          entryUUID, // Use the same UUID for both filename and database ID
-		URL:         finalURL,  // Store the resolved URL as the primary URL
-		Title:       "",
-		StoragePath: htmlFilePath,
-		ArchivedAt:  time.Now(),
+
+	// Store a plain-text rendition for search and summarizers; failures don't fail the archive
+	textPath := ""
+	if storeText {
+		path := filepath.Join(settings.RawHTMLDir, fmt.Sprintf("%s.txt", entryUUID))
+		if text, err := ExtractText(htmlContent); err != nil {
+			fmt.Printf("Warning: failed to extract text for '%s': %v\n", finalURL, err)
+		} else if err := writeFileAtomic(path, []byte(text), 0644); err != nil {
+			fmt.Printf("Warning: failed to write text to '%s': %v\n", path, err)
+		} else {
+			textPath = path
+		}
 	}
 
-	result := db.Create(&archiveEntry)
-	if result.Error != nil {
-		os.Remove(htmlFilePath)
-		return nil, fmt.Errorf("failed to create archive entry in database for '%s': %w", finalURL, result.Error)
+	// Capture a screenshot of the live page; failures don't fail the archive
+	screenshotPath, thumbnailPath := "", ""
+	var screenshotAt time.Time
+	if captureScreenshots {
+		path := filepath.Join(settings.ScreenshotsDir, fmt.Sprintf("%s.jpg", entryUUID))
+		var err error
+		if rendered {
+			err = writeFileAtomic(path, renderedScreenshot, 0644)
+		} else {
+			var captured pageCapture
+			captured, err = captureScreenshot(finalURL, path, opts.Device, opts.DismissSelectors, opts.session)
+			pageWidth, pageHeight = captured.Width, captured.Height
+		}
+		if err != nil {
+			fmt.Printf("Warning: failed to capture screenshot for '%s': %v\n", finalURL, err)
+		} else {
+			screenshotPath = path
+			screenshotAt = time.Now()
+			thumbnailPath = writeThumbnail(path)
+		}
+	}
+
+	// Keep a single-file MHTML copy rendered by Chrome; failures don't fail the archive
+	mhtmlPath := ""
+	if opts.MHTML {
+		path := filepath.Join(settings.RawHTMLDir, fmt.Sprintf("%s.mhtml", entryUUID))
+		if err := captureMHTML(finalURL, path, opts.Device, opts.DismissSelectors, opts.session); err != nil {
+			fmt.Printf("Warning: failed to capture MHTML for '%s': %v\n", finalURL, err)
+		} else {
+			mhtmlPath = path
+		}
 	}
 
-	return &archiveEntry, nil
+	// Describe the capture; the caller stores it in the database
+	canonicalURL := finalURL
+	if opts.Canonicalize {
+		canonicalURL = CanonicalizeURL(finalURL)
+	}
+
+	archiveEntry := &models.ArchiveEntry{
+		ID:             entryUUID, // Use the same UUID for both filename and database ID
+		URL:            finalURL,  // Store the resolved URL as the primary URL
+		RequestURL:     urlToArchive,
+		CanonicalURL:   canonicalURL,
+		Fragment:       fragment,
+		SeriesID:       opts.SeriesID,
+		Title:          "",
+		Lang:           lang,
+		Dir:            dir,
+		StoragePath:    htmlFilePath,
+		ScreenshotPath: screenshotPath,
+		ScreenshotAt:   screenshotAt,
+		ThumbnailPath:  thumbnailPath,
+		ScreenshotTTL:  opts.ScreenshotTTL,
+		TextPath:       textPath,
+		MHTMLPath:      mhtmlPath,
+		ContentHash:    hashContent([]byte(modifiedHTML)),
+		TextHash:       textHash,
+		HTTPStatus:     page.StatusCode,
+		SoftError:      softError,
+		ContentType:    page.ContentType,
+		Headers:        recordedHeaders(page.Header),
+		Negotiation:    negotiationContext(opts),
+		Referer:        opts.Referer,
+		Rendered:       rendered,
+		FetchStrategy:  fetched.Strategy,
+		Challenge:      fetched.Challenge,
+		Width:          pageWidth,
+		Height:         pageHeight,
+		FaviconURL:     favicon,
+		StructuredData: extractJSONLD(htmlContent),
+		Device:         opts.Device,
+		ArchivedAt:     archivedAt,
+	}
+
+	var storedAssets, failedAssets int
+	var storedAssetBytes int64
+	for _, record := range assetRecords {
+		if record.FileName != "" {
+			storedAssets++
+			storedAssetBytes += record.Size
+		} else {
+			failedAssets++
+		}
+	}
+	metrics.RecordCapture(int64(len(modifiedHTML)), storedAssets, failedAssets, storedAssetBytes)
+	writeDebugCapture(archiveEntry, fetched, page, assetRecords)
+
+	return archiveEntry, assetRecords, nil
 }
 
 // primeGoogleCookies visits Google's homepage to establish cookies before accessing Google News
@@ -533,18 +1259,52 @@ func min(a, b int) int {
 	return b
 }
 
+// recordAssets stores an Asset row for every asset fetch attempted while
+// archiving (ARCHIVE_RECORD_ASSETS)
+var recordAssets = envBool("ARCHIVE_RECORD_ASSETS", true)
+
 // AssetDownloadResult represents the result of downloading an asset
 type AssetDownloadResult struct {
 	URL      string
 	FileName string
 	Content  []byte
+	Response assetResponse
+	Duration time.Duration
 	Error    error
 }
 
-// downloadAssetsParallel downloads assets in parallel using worker goroutines
-func downloadAssetsParallel(assets []string, entryUUID string, maxWorkers int) map[string]string {
+// record converts the result into an Asset row for entryUUID
+func (r AssetDownloadResult) record(entryUUID string) models.Asset {
+	return models.Asset{
+		EntryID:     entryUUID,
+		URL:         r.URL,
+		FinalURL:    r.Response.FinalURL,
+		StatusCode:  r.Response.StatusCode,
+		ContentType: r.Response.ContentType,
+		DurationMs:  r.Duration.Milliseconds(),
+		FetchedAt:   r.Response.FetchedAt,
+	}
+}
+
+// storedAssetNames maps the URLs of the stored assets among records to
+// their file names
+func storedAssetNames(records []models.Asset) map[string]string {
+	names := make(map[string]string, len(records))
+	for _, record := range records {
+		if record.FileName != "" {
+			names[record.URL] = record.FileName
+		}
+	}
+	return names
+}
+
+// downloadAssetsParallel downloads assets in parallel using worker goroutines,
+// sending userAgent if set. It returns the stored file name of each downloaded
+// asset keyed by URL, and a record of every fetch attempt. With
+// keepErrorBodies, the bodies of non-200 responses are stored for debugging.
+func downloadAssetsParallel(assets []string, entryUUID string, maxWorkers int, userAgent string, keepErrorBodies bool) (map[string]string, []models.Asset) {
 	if len(assets) == 0 {
-		return make(map[string]string)
+		return make(map[string]string), nil
 	}
 
 	// Create channels for work distribution
@@ -566,11 +1326,13 @@ func downloadAssetsParallel(assets []string, entryUUID string, maxWorkers int) m
 			for assetURL := range assetChan {
 				fmt.Printf("Worker %d downloading: %s\n", workerID, assetURL)
 
-				assetContent, err := FetchAsset(assetURL)
+				assetContent, response, err := fetchAsset(assetURL, userAgent)
 				result := AssetDownloadResult{
 					URL:      assetURL,
-					FileName: generateAssetFileName(assetURL, entryUUID),
+					FileName: assetFileNameFor(assetURL, entryUUID, response),
 					Content:  assetContent,
+					Response: response,
+					Duration: time.Since(response.FetchedAt),
 					Error:    err,
 				}
 				resultChan <- result
@@ -586,31 +1348,52 @@ func downloadAssetsParallel(assets []string, entryUUID string, maxWorkers int) m
 
 	// Collect results and save files
 	downloadedAssets := make(map[string]string)
+	records := make([]models.Asset, 0, len(assets))
 	successCount := 0
 
 	for result := range resultChan {
+		record := result.record(entryUUID)
+
 		if result.Error != nil {
 			fmt.Printf("Warning: failed to fetch asset '%s': %v\n", result.URL, result.Error)
+			record.Error = result.Error.Error()
+			if keepErrorBodies && len(result.Response.ErrorBody) > 0 {
+				if name, err := writeAssetErrorBody(result, entryUUID); err != nil {
+					fmt.Printf("Warning: %v\n", err)
+				} else {
+					record.ErrorBodyFile = name
+				}
+			}
+			records = append(records, record)
 			continue
 		}
 
 		// Validate asset content
 		if !validateAssetContent(result.Content, result.URL) {
 			fmt.Printf("Warning: invalid asset content for '%s', skipping\n", result.URL)
+			record.Error = "invalid asset content"
+			records = append(records, record)
 			continue
 		}
 
-		assetFilePath := filepath.Join(assetsDir, result.FileName)
-		if err := os.WriteFile(assetFilePath, result.Content, 0644); err != nil {
+		// Written atomically so the static handler never serves a partial file
+		assetFilePath := filepath.Join(settings.AssetsDir, result.FileName)
+		if err := writeFileAtomic(assetFilePath, result.Content, 0644); err != nil {
 			fmt.Printf("Warning: failed to save asset '%s' to '%s': %v\n", result.URL, assetFilePath, err)
+			record.Error = err.Error()
+			records = append(records, record)
 			continue
 		}
 
+		record.FileName = result.FileName
+		record.Size = int64(len(result.Content))
+		records = append(records, record)
+
 		downloadedAssets[result.URL] = result.FileName
 		successCount++
 		fmt.Printf("Successfully saved asset: %s (%d bytes)\n", result.FileName, len(result.Content))
 	}
 
 	fmt.Printf("Parallel download completed: %d/%d assets downloaded successfully\n", successCount, len(assets))
-	return downloadedAssets
+	return downloadedAssets, records
 }
@@ -49,16 +49,16 @@ package storage
 // }
 
 // func TestEnsureStorageDirsFunctionality(t *testing.T) {
-// 	require.NoError(t, os.RemoveAll(rawHTMLDir))
-// 	require.NoError(t, os.RemoveAll(screenshotsDir))
+// 	require.NoError(t, os.RemoveAll(settings.RawHTMLDir))
+// 	require.NoError(t, os.RemoveAll(settings.ScreenshotsDir))
 // 	err := EnsureStorageDirs()
 // 	require.NoError(t, err)
-// 	assert.DirExists(t, rawHTMLDir)
-// 	assert.DirExists(t, screenshotsDir)
+// 	assert.DirExists(t, settings.RawHTMLDir)
+// 	assert.DirExists(t, settings.ScreenshotsDir)
 // 	err = EnsureStorageDirs()
 // 	require.NoError(t, err)
-// 	assert.DirExists(t, rawHTMLDir)
-// 	assert.DirExists(t, screenshotsDir)
+// 	assert.DirExists(t, settings.RawHTMLDir)
+// 	assert.DirExists(t, settings.ScreenshotsDir)
 // }
 
 // func TestFetchRawHTML(t *testing.T) {
@@ -91,12 +91,12 @@ package storage
 // func TestArchiveURL(t *testing.T) {
 // 	t.Cleanup(func() {
 // 		require.NoError(t, tests.ClearArchiveEntries(testDB))
-// 		if entries, err := os.ReadDir(rawHTMLDir); err == nil {
-// 			for _, entry := range entries { os.Remove(filepath.Join(rawHTMLDir, entry.Name())) }
+// 		if entries, err := os.ReadDir(settings.RawHTMLDir); err == nil {
+// 			for _, entry := range entries { os.Remove(filepath.Join(settings.RawHTMLDir, entry.Name())) }
 // 		}
 // 		// Also clean screenshots specific to this test if any were made directly by ArchiveURL
-// 		if entries, err := os.ReadDir(screenshotsDir); err == nil {
-// 			for _, entry := range entries { os.Remove(filepath.Join(screenshotsDir, entry.Name())) }
+// 		if entries, err := os.ReadDir(settings.ScreenshotsDir); err == nil {
+// 			for _, entry := range entries { os.Remove(filepath.Join(settings.ScreenshotsDir, entry.Name())) }
 // 		}
 // 	})
 
@@ -140,8 +140,8 @@ package storage
 // 	defer server.Close()
 
 // 	testScreenshotFileName := "test_spa_capture.jpg"
-// 	// Uses screenshotsDir which is set to a temp path in TestMain
-// 	testScreenshotPath := filepath.Join(screenshotsDir, testScreenshotFileName)
+// 	// Uses settings.ScreenshotsDir which is set to a temp path in TestMain
+// 	testScreenshotPath := filepath.Join(settings.ScreenshotsDir, testScreenshotFileName)
 
 // 	t.Cleanup(func() {
 // 		os.Remove(testScreenshotPath) // Clean up the specific file created by this test
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"os"
+	"path/filepath"
+)
+
+// FileInfo describes one stored file of an archive
+type FileInfo struct {
+	Path   string `json:"path"` // Absolute path, empty if the entry has no such file
+	Exists bool   `json:"exists"`
+	Size   int64  `json:"size"`
+}
+
+// AssetFilesInfo describes the local asset files referenced by an archive
+type AssetFilesInfo struct {
+	Dir        string     `json:"dir"` // Absolute path of the shared assets directory
+	Count      int        `json:"count"`
+	TotalBytes int64      `json:"totalBytes"`
+	Files      []FileInfo `json:"files"`
+}
+
+// StorageInfo lists where an archive's files live on disk
+type StorageInfo struct {
+	ID         string         `json:"id"`
+	HTML       FileInfo       `json:"html"`
+	Text       FileInfo       `json:"text"`
+	Screenshot FileInfo       `json:"screenshot"`
+	Thumbnail  FileInfo       `json:"thumbnail"`
+	MHTML      FileInfo       `json:"mhtml"`
+	Assets     AssetFilesInfo `json:"assets"`
+}
+
+// fileInfo stats path, resolving it to an absolute path
+func fileInfo(path string) FileInfo {
+	if path == "" {
+		return FileInfo{}
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	info := FileInfo{Path: path}
+	if stat, err := os.Stat(path); err == nil && !stat.IsDir() {
+		info.Exists = true
+		info.Size = stat.Size()
+	}
+	return info
+}
+
+// GetStorageInfo returns the absolute paths, existence and sizes of the
+// stored HTML, text, screenshot, thumbnail, MHTML and the asset files referenced by the HTML
+// and its stylesheets
+func GetStorageInfo(entry *models.ArchiveEntry) StorageInfo {
+	info := StorageInfo{
+		ID:         entry.ID,
+		HTML:       fileInfo(entry.StoragePath),
+		Text:       fileInfo(entry.TextPath),
+		Screenshot: fileInfo(entry.ScreenshotPath),
+		Thumbnail:  fileInfo(entry.ThumbnailPath),
+		MHTML:      fileInfo(entry.MHTMLPath),
+		Assets:     AssetFilesInfo{Dir: settings.AssetsDir, Files: []FileInfo{}},
+	}
+	if abs, err := filepath.Abs(settings.AssetsDir); err == nil {
+		info.Assets.Dir = abs
+	}
+
+	if content, err := os.ReadFile(entry.StoragePath); err == nil {
+		for _, name := range localAssetNames(string(content)) {
+			file := fileInfo(filepath.Join(settings.AssetsDir, name))
+			if !file.Exists {
+				// Bundled assets report the bundle's path
+				if size, path, err := statAsset(name); err == nil {
+					file = fileInfo(path)
+					file.Size = size
+				}
+			}
+			info.Assets.Files = append(info.Assets.Files, file)
+			info.Assets.TotalBytes += file.Size
+		}
+	}
+	info.Assets.Count = len(info.Assets.Files)
+	return info
+}
+
+// TotalBytes returns the combined size of the files described by info
+func (info StorageInfo) TotalBytes() int64 {
+	return info.HTML.Size + info.Text.Size + info.Screenshot.Size + info.Thumbnail.Size + info.MHTML.Size + info.Assets.TotalBytes
+}
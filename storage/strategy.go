@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Fetch strategies accepted in ARCHIVE_FETCH_STRATEGIES
+const (
+	strategyStatic  = "static"  // Plain HTTP request (fetchPage)
+	strategyBrowser = "browser" // DOM rendered by headless Chrome
+)
+
+var (
+	// fetchStrategies is the order in which strategies are tried when a fetch
+	// fails or returns a blocked page (ARCHIVE_FETCH_STRATEGIES)
+	fetchStrategies = envFetchStrategies("ARCHIVE_FETCH_STRATEGIES")
+	// minBodyBytes is the body size below which a page counts as blocked (ARCHIVE_MIN_BODY_BYTES)
+	minBodyBytes = envInt64("ARCHIVE_MIN_BODY_BYTES", 512)
+	// renderSmallPages retries a static fetch in headless Chrome when the
+	// page looks like an empty JavaScript shell (ARCHIVE_RENDER_SMALL_PAGES)
+	renderSmallPages = envBool("ARCHIVE_RENDER_SMALL_PAGES", false)
+	// minHTMLBytes is the HTML size below which renderSmallPages retries (ARCHIVE_MIN_HTML_BYTES)
+	minHTMLBytes = envInt64("ARCHIVE_MIN_HTML_BYTES", 2048)
+)
+
+// minBodyTextBytes is the extracted text size below which a page's <body>
+// counts as near-empty for renderSmallPages
+const minBodyTextBytes = 32
+
+// blockedMarkers are lower-case snippets of common bot-protection and CAPTCHA
+// interstitials. They are specific to the challenge pages themselves, since
+// scripts such as reCAPTCHA also appear on ordinary pages.
+var blockedMarkers = []string{
+	"<title>just a moment...</title>",  // Cloudflare challenge
+	"attention required! | cloudflare", // Cloudflare block
+	"cf-chl-",                          // Cloudflare challenge form
+	"captcha-delivery.com",             // DataDome
+	"px-captcha",                       // PerimeterX / HUMAN
+	"please verify you are a human",
+	"are you a robot",
+	"<title>access denied</title>",
+}
+
+// envFetchStrategies reads a comma-separated list of strategies, defaulting to static only
+func envFetchStrategies(key string) []string {
+	var strategies []string
+	seen := make(map[string]bool)
+	for _, s := range envList(key) {
+		s = strings.ToLower(s)
+		if s != strategyStatic && s != strategyBrowser {
+			log.Printf("Invalid %s entry '%s', expected '%s' or '%s'", key, s, strategyStatic, strategyBrowser)
+			continue
+		}
+		if !seen[s] {
+			seen[s] = true
+			strategies = append(strategies, s)
+		}
+	}
+	if len(strategies) == 0 {
+		return []string{strategyStatic}
+	}
+	return strategies
+}
+
+// fetchResult is the page fetched by one strategy
+type fetchResult struct {
+	HTML       string
+	Page       pageResponse
+	Strategy   string
+	Accept     string // Accept header that produced the page when it differs from the options' (see retryHTMLAccept)
+	Challenge  string // Why the static page was taken for an interstitial passed in Chrome (see passChallenge)
+	Screenshot []byte // Taken during the browser strategy when screenshots are enabled
+	Width      int64  // Rendered page dimensions, measured during the browser strategy
+	Height     int64
+}
+
+// fetchWithStrategies fetches url with each of fetchStrategies in turn until
+// one returns a page that doesn't look blocked. The last strategy's page is
+// used even if it looks blocked; if it fails, the first blocked page is used.
+// When every strategy fails, the first error is returned. A static page that
+// is a JavaScript challenge or looks empty is then fetched in Chrome, see
+// passChallenge and renderIfEmpty.
+func fetchWithStrategies(url string, opts ArchiveOptions) (fetchResult, error) {
+	result, triedBrowser, err := fetchWithStrategyChain(url, opts)
+	if err != nil || triedBrowser {
+		return result, err
+	}
+	if passed, ok := passChallenge(url, opts, result); ok {
+		return passed, nil
+	}
+	return renderIfEmpty(url, opts, result), nil
+}
+
+// fetchWithStrategyChain implements fetchWithStrategies' chain, also
+// reporting whether the browser strategy was tried
+func fetchWithStrategyChain(url string, opts ArchiveOptions) (fetchResult, bool, error) {
+	var fallback *fetchResult
+	var firstErr error
+	triedBrowser := false
+	for i, strategy := range fetchStrategies {
+		last := i == len(fetchStrategies)-1
+		triedBrowser = triedBrowser || strategy == strategyBrowser
+		result, err := fetchWithStrategy(strategy, url, opts)
+		var typeErr *UnsupportedMainTypeError
+		if errors.As(err, &typeErr) {
+			// Another strategy would fetch the same document
+			return fetchResult{}, triedBrowser, err
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			if !last {
+				fmt.Printf("Warning: %s fetch of '%s' failed: %v, trying next strategy\n", strategy, url, err)
+			}
+			continue
+		}
+		if !last {
+			if reason := blockedReason(result); reason != "" {
+				fmt.Printf("Warning: %s fetch of '%s' looks blocked (%s), trying next strategy\n", strategy, url, reason)
+				if fallback == nil {
+					fallback = &result
+				}
+				continue
+			}
+		}
+		return result, triedBrowser, nil
+	}
+	if fallback != nil {
+		return *fallback, triedBrowser, nil
+	}
+	return fetchResult{}, triedBrowser, firstErr
+}
+
+// renderIfEmpty retries a static fetch in headless Chrome when
+// renderSmallPages is set and the page looks like it needs JavaScript for its
+// content (see emptyPageReason). The rendered DOM keeps the static
+// response's status and headers; if Chrome fails, the static page is kept.
+func renderIfEmpty(url string, opts ArchiveOptions, result fetchResult) fetchResult {
+	if !renderSmallPages || result.Strategy != strategyStatic || !servedHTML(result) {
+		return result
+	}
+	reason := emptyPageReason(result.HTML)
+	if reason == "" {
+		return result
+	}
+	fmt.Printf("Static fetch of '%s' looks empty (%s), rendering in headless Chrome\n", url, reason)
+	rendered, err := fetchWithStrategy(strategyBrowser, url, opts)
+	if err != nil {
+		fmt.Printf("Warning: failed to render '%s': %v, archiving served HTML\n", url, err)
+		return result
+	}
+	rendered.Page = result.Page
+	return rendered
+}
+
+// servedHTML reports whether a fetched page is an HTML page rather than a
+// download or another media type
+func servedHTML(result fetchResult) bool {
+	if _, ok := attachmentName(result.Page.Header, ""); ok {
+		return false
+	}
+	switch result.Page.ContentType {
+	case "", "text/html", "application/xhtml+xml":
+		return true
+	}
+	return false
+}
+
+// emptyPageReason describes why a page looks like an empty shell filled in
+// by JavaScript, or returns "" if it doesn't
+func emptyPageReason(htmlContent string) string {
+	if size := len(htmlContent); int64(size) < minHTMLBytes {
+		return fmt.Sprintf("HTML of %d bytes", size)
+	}
+	if text, err := ExtractText(htmlContent); err == nil && len(text) < minBodyTextBytes {
+		return fmt.Sprintf("body text of %d bytes", len(text))
+	}
+	return ""
+}
+
+// fetchWithStrategy fetches url with a single strategy
+func fetchWithStrategy(strategy, url string, opts ArchiveOptions) (fetchResult, error) {
+	if strategy == strategyBrowser {
+		captured, err := capturePage(url, opts.Device, opts.DismissSelectors, opts.session, true, captureScreenshots)
+		if err != nil {
+			return fetchResult{}, err
+		}
+		// Chrome doesn't report the response here; the page loaded, so record it as OK
+		page := pageResponse{StatusCode: http.StatusOK, ContentType: "text/html"}
+		return fetchResult{
+			HTML:       captured.DOM,
+			Page:       page,
+			Strategy:   strategy,
+			Screenshot: captured.Screenshot,
+			Width:      captured.Width,
+			Height:     captured.Height,
+		}, nil
+	}
+
+	htmlContent, page, err := fetchPage(url, opts)
+	if err != nil {
+		return fetchResult{}, err
+	}
+	if retryHTMLAccept && opts.Accept == "" && isJSONMediaType(page.ContentType) {
+		// Content-negotiated endpoints may need an explicit request for HTML
+		strict := opts
+		strict.Accept = htmlOnlyAccept
+		fmt.Printf("'%s' was served as %s, retrying with Accept: %s\n", url, page.ContentType, htmlOnlyAccept)
+		retried, retriedPage, err := fetchPage(url, strict)
+		switch {
+		case err != nil:
+			fmt.Printf("Warning: retry of '%s' with Accept: %s failed: %v, archiving JSON response\n", url, htmlOnlyAccept, err)
+		case isJSONMediaType(retriedPage.ContentType):
+			fmt.Printf("Warning: '%s' is still served as %s, archiving JSON response\n", url, retriedPage.ContentType)
+		default:
+			return fetchResult{HTML: retried, Page: retriedPage, Strategy: strategy, Accept: htmlOnlyAccept}, nil
+		}
+	}
+	return fetchResult{HTML: htmlContent, Page: page, Strategy: strategy}, nil
+}
+
+// blockedReason describes why a fetched page looks like a bot-protection,
+// CAPTCHA or otherwise unusable page, or returns "" if it looks fine
+func blockedReason(result fetchResult) string {
+	if _, ok := attachmentName(result.Page.Header, ""); ok {
+		// Downloads are judged by their own content type, not as pages
+		return ""
+	}
+	switch result.Page.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return fmt.Sprintf("status code %d", result.Page.StatusCode)
+	}
+	if size := len(strings.TrimSpace(result.HTML)); int64(size) < minBodyBytes {
+		return fmt.Sprintf("body of %d bytes", size)
+	}
+	lower := strings.ToLower(result.HTML)
+	for _, marker := range blockedMarkers {
+		if strings.Contains(lower, marker) {
+			return fmt.Sprintf("contains %q", marker)
+		}
+	}
+	return ""
+}
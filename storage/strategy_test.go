@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chromedp/chromedp"
+)
+
+func TestEmptyPageReason(t *testing.T) {
+	orig := minHTMLBytes
+	defer func() { minHTMLBytes = orig }()
+	minHTMLBytes = 200
+
+	padding := "<!-- " + strings.Repeat("x", 300) + " -->"
+	article := "<p>" + strings.Repeat("Server-rendered article text. ", 5) + "</p>"
+	cases := []struct {
+		name, html string
+		empty      bool
+	}{
+		{"small", `<html><body><div id="root"></div></body></html>`, true},
+		{"js shell", `<html><head><script src="/app.js"></script>` + padding + `</head><body><div id="root"></div><noscript>Enable JavaScript</noscript></body></html>`, true},
+		{"content", `<html><head>` + padding + `</head><body>` + article + `</body></html>`, false},
+	}
+	for _, tc := range cases {
+		if got := emptyPageReason(tc.html) != ""; got != tc.empty {
+			t.Errorf("%s: empty = %v, want %v", tc.name, got, tc.empty)
+		}
+	}
+}
+
+func TestEnvFetchStrategies(t *testing.T) {
+	cases := []struct {
+		value string
+		want  []string
+	}{
+		{"", []string{strategyStatic}},
+		{"browser", []string{strategyBrowser}},
+		{"Browser, static,browser", []string{strategyBrowser, strategyStatic}},
+		{"ftp,static", []string{strategyStatic}},
+		{"ftp", []string{strategyStatic}},
+	}
+	for _, tc := range cases {
+		t.Setenv("ARCHIVE_TEST_FETCH_STRATEGIES", tc.value)
+		if got := envFetchStrategies("ARCHIVE_TEST_FETCH_STRATEGIES"); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: strategies = %v, want %v", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestBlockedReason(t *testing.T) {
+	orig := minBodyBytes
+	defer func() { minBodyBytes = orig }()
+	minBodyBytes = 100
+
+	page := "<html><body>" + strings.Repeat("<p>Real content.</p>", 10) + "</body></html>"
+	cases := []struct {
+		name    string
+		result  fetchResult
+		blocked bool
+	}{
+		{"ordinary page", fetchResult{HTML: page, Page: pageResponse{StatusCode: http.StatusOK}}, false},
+		{"forbidden", fetchResult{HTML: page, Page: pageResponse{StatusCode: http.StatusForbidden}}, true},
+		{"too many requests", fetchResult{HTML: page, Page: pageResponse{StatusCode: http.StatusTooManyRequests}}, true},
+		{"small body", fetchResult{HTML: "<html></html>", Page: pageResponse{StatusCode: http.StatusOK}}, true},
+		{"challenge marker", fetchResult{HTML: "<html><head><title>Just a moment...</title></head>" + page, Page: pageResponse{StatusCode: http.StatusOK}}, true},
+		{"small download", fetchResult{HTML: "%PDF", Page: pageResponse{StatusCode: http.StatusOK,
+			Header: http.Header{"Content-Disposition": {`attachment; filename="a.pdf"`}}}}, false},
+	}
+	for _, tc := range cases {
+		if got := blockedReason(tc.result) != ""; got != tc.blocked {
+			t.Errorf("%s: blocked = %v, want %v", tc.name, got, tc.blocked)
+		}
+	}
+}
+
+func TestFetchWithStrategyChain(t *testing.T) {
+	page := "<html><body>" + strings.Repeat("<p>Real content.</p>", 50) + "</body></html>"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/blocked" {
+			fmt.Fprint(w, "<html><head><title>Just a moment...</title></head><body>"+strings.Repeat(" ", 600)+"</body></html>")
+			return
+		}
+		fmt.Fprint(w, page)
+	}))
+	defer server.Close()
+
+	origStrategies, origNoDelay, origRunChrome := fetchStrategies, noDelayPrivate, runChrome
+	defer func() { fetchStrategies, noDelayPrivate, runChrome = origStrategies, origNoDelay, origRunChrome }()
+	noDelayPrivate = true
+	// Chrome is unavailable, so every browser fetch fails
+	chromeRuns := 0
+	runChrome = func(context.Context, ...chromedp.Action) error {
+		chromeRuns++
+		return errors.New("chrome not available")
+	}
+
+	cases := []struct {
+		name         string
+		strategies   []string
+		path         string
+		wantStrategy string
+		wantBlocked  bool
+		wantChrome   int
+	}{
+		{"static page is used as is", []string{strategyStatic, strategyBrowser}, "/ok", strategyStatic, false, 0},
+		{"blocked page falls back, keeping the blocked page when the browser fails", []string{strategyStatic, strategyBrowser}, "/blocked", strategyStatic, true, 1},
+		{"failed browser falls back to static", []string{strategyBrowser, strategyStatic}, "/ok", strategyStatic, false, 1},
+		{"last strategy's page is used even if blocked", []string{strategyStatic}, "/blocked", strategyStatic, true, 0},
+	}
+	for _, tc := range cases {
+		fetchStrategies, chromeRuns = tc.strategies, 0
+		result, triedBrowser, err := fetchWithStrategyChain(server.URL+tc.path, DefaultArchiveOptions())
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if result.Strategy != tc.wantStrategy || (blockedReason(result) != "") != tc.wantBlocked {
+			t.Errorf("%s: strategy %s, blocked %q; want %s, blocked %v", tc.name, result.Strategy, blockedReason(result), tc.wantStrategy, tc.wantBlocked)
+		}
+		if chromeRuns != tc.wantChrome || triedBrowser != (tc.wantChrome > 0) {
+			t.Errorf("%s: Chrome ran %d times (tried browser: %v), want %d", tc.name, chromeRuns, triedBrowser, tc.wantChrome)
+		}
+	}
+
+	// When every strategy fails, the first error is returned
+	fetchStrategies = []string{strategyBrowser}
+	if _, _, err := fetchWithStrategyChain(server.URL+"/ok", DefaultArchiveOptions()); err == nil || !strings.Contains(err.Error(), "chrome not available") {
+		t.Errorf("browser-only chain error = %v, want the Chrome failure", err)
+	}
+}
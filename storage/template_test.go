@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+// Template contents are inert until cloned by scripts. golang.org/x/net/html
+// parses them as the template element's children, including content that
+// would be misplaced elsewhere (rows inside a <table>'s template), and
+// declarative shadow roots are templates too.
+const templatePage = `<!DOCTYPE html>
+<html><body>
+<template id="card"><div class="card"><img src="/img/avatar.png" srcset="/img/avatar-2x.png 2x"></div></template>
+<my-widget><template shadowrootmode="open"><link rel="stylesheet" href="/css/widget.css"></template></my-widget>
+<table><template id="row"><tr><td><img src="/img/cell.png"></td></tr></template></table>
+</body></html>`
+
+func TestTemplateContentAssets(t *testing.T) {
+	const entryUUID = "00000000-0000-0000-0000-000000000000"
+	want := []string{
+		"https://example.com/img/avatar.png",
+		"https://example.com/img/avatar-2x.png",
+		"https://example.com/img/cell.png",
+		"https://example.com/css/widget.css",
+	}
+
+	assets, err := extractAssetsFromHTML(templatePage, "https://example.com/page")
+	if err != nil {
+		t.Fatalf("extractAssetsFromHTML: %v", err)
+	}
+	for _, u := range want {
+		found := false
+		for _, a := range assets {
+			if a == u {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("extractAssetsFromHTML = %v, missing template asset %s", assets, u)
+		}
+	}
+
+	got, err := modifyHTMLPaths(templatePage, entryUUID, "https://example.com/page", nil)
+	if err != nil {
+		t.Fatalf("modifyHTMLPaths: %v", err)
+	}
+	for _, u := range want {
+		local := localAssetPrefix + generateAssetFileName(u, entryUUID)
+		if !strings.Contains(got, local) {
+			t.Errorf("template asset %s not rewritten to %s:\n%s", u, local, got)
+		}
+	}
+	if !strings.Contains(got, `<template id="row"><tr><td><img src="`) {
+		t.Errorf("template content moved out of its template:\n%s", got)
+	}
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// storeText makes ArchiveURL store a plain-text rendition next to the HTML (ARCHIVE_STORE_TEXT)
+var storeText = envBool("ARCHIVE_STORE_TEXT", true)
+
+// textSkippedElements are never part of the visible text
+var textSkippedElements = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "template": true,
+	"svg": true, "canvas": true, "iframe": true, "object": true, "nav": true,
+	"button": true, "select": true, "textarea": true, "input": true,
+}
+
+// textBlockElements start a new line in the extracted text
+var textBlockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "dd": true,
+	"details": true, "div": true, "dl": true, "dt": true, "figcaption": true,
+	"figure": true, "footer": true, "form": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "header": true, "hr": true, "li": true,
+	"main": true, "ol": true, "p": true, "pre": true, "section": true, "summary": true,
+	"table": true, "tr": true, "ul": true, "br": true,
+}
+
+// ExtractText returns the visible text of an HTML document: scripts, styles,
+// navigation and form controls are dropped, block elements become line breaks
+// and whitespace is collapsed. Text inside <pre> keeps its line breaks.
+func ExtractText(htmlContent string) (string, error) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	var line strings.Builder
+	flush := func() {
+		if text := strings.TrimSpace(collapseWhitespace(line.String())); text != "" {
+			lines = append(lines, text)
+		}
+		line.Reset()
+	}
+
+	var walk func(n *html.Node, inPre bool)
+	walk = func(n *html.Node, inPre bool) {
+		switch n.Type {
+		case html.TextNode:
+			if inPre {
+				parts := strings.Split(n.Data, "\n")
+				for i, part := range parts {
+					if i > 0 {
+						flush()
+					}
+					line.WriteString(part)
+				}
+			} else {
+				line.WriteString(collapseWhitespace(n.Data))
+			}
+			return
+		case html.ElementNode:
+			if textSkippedElements[n.Data] {
+				return
+			}
+			if n.Data == "pre" {
+				inPre = true
+			}
+			if n.Data == "td" || n.Data == "th" {
+				line.WriteString(" ")
+			}
+		}
+
+		block := n.Type == html.ElementNode && textBlockElements[n.Data]
+		if block {
+			flush()
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, inPre)
+		}
+		if block {
+			flush()
+		}
+	}
+	walk(doc, false)
+	flush()
+
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// thumbnailMaxDim is the largest side, in pixels, of the thumbnail stored
+// next to each screenshot (ARCHIVE_THUMBNAIL_MAX_DIM); 0 disables thumbnails
+var thumbnailMaxDim = envInt64("ARCHIVE_THUMBNAIL_MAX_DIM", 320)
+
+// thumbnailQuality is the JPEG quality of thumbnails, which are only ever
+// shown small
+const thumbnailQuality = 75
+
+// thumbnailPathFor returns where the thumbnail of a screenshot is stored
+func thumbnailPathFor(screenshotPath string) string {
+	return strings.TrimSuffix(screenshotPath, filepath.Ext(screenshotPath)) + ".thumb.jpg"
+}
+
+// writeThumbnail stores a thumbnail of the screenshot at screenshotPath and
+// returns its path, or "" when thumbnails are disabled or it can't be made.
+// Like the contact sheet, it shows the top of tall full-page screenshots,
+// cropped to 4:3, scaled so neither side exceeds thumbnailMaxDim.
+func writeThumbnail(screenshotPath string) string {
+	if thumbnailMaxDim <= 0 || screenshotPath == "" {
+		return ""
+	}
+	img, err := decodeImageFile(screenshotPath)
+	if err != nil {
+		fmt.Printf("Warning: failed to create thumbnail of '%s': %v\n", screenshotPath, err)
+		return ""
+	}
+
+	src := img.Bounds()
+	cropHeight := min(src.Dx()*3/4, src.Dy())
+	srcRect := image.Rect(src.Min.X, src.Min.Y, src.Max.X, src.Min.Y+cropHeight)
+	w, h := fitWithin(srcRect.Dx(), srcRect.Dy(), int(thumbnailMaxDim))
+	thumb := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(thumb, thumb.Bounds(), img, srcRect, draw.Src, nil)
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, thumb, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		fmt.Printf("Warning: failed to encode thumbnail of '%s': %v\n", screenshotPath, err)
+		return ""
+	}
+	path := thumbnailPathFor(screenshotPath)
+	if err := writeFileAtomic(path, out.Bytes(), 0644); err != nil {
+		fmt.Printf("Warning: failed to write thumbnail '%s': %v\n", path, err)
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteThumbnail(t *testing.T) {
+	// A tall full-page screenshot
+	screenshot := image.NewRGBA(image.Rect(0, 0, 1280, 6000))
+	for y := 0; y < 6000; y++ {
+		for x := 0; x < 1280; x++ {
+			screenshot.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	path := filepath.Join(t.TempDir(), "entry.jpg")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(f, screenshot, nil); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	orig := thumbnailMaxDim
+	defer func() { thumbnailMaxDim = orig }()
+	thumbnailMaxDim = 320
+
+	thumb := writeThumbnail(path)
+	if thumb != filepath.Join(filepath.Dir(path), "entry.thumb.jpg") {
+		t.Fatalf("thumbnail path = %q", thumb)
+	}
+	f, err = os.Open(thumb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	config, err := jpeg.DecodeConfig(f)
+	if err != nil {
+		t.Fatalf("decode thumbnail: %v", err)
+	}
+	// The top of the page, cropped to 4:3
+	if config.Width != 320 || config.Height != 240 {
+		t.Errorf("thumbnail is %dx%d, want 320x240", config.Width, config.Height)
+	}
+
+	thumbnailMaxDim = 0
+	if thumb := writeThumbnail(path); thumb != "" {
+		t.Errorf("thumbnail written with ARCHIVE_THUMBNAIL_MAX_DIM=0: %q", thumb)
+	}
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+const trackPage = `<!DOCTYPE html>
+<html><body>
+<video controls src="/media/talk.mp4">
+<track kind="captions" srclang="en" label="English" src="/media/talk.en.vtt" default>
+<track kind="subtitles" srclang="ja" label="日本語" src="https://cdn.example.com/talk.ja.srt">
+</video>
+</body></html>`
+
+func TestExtractAssetsFromHTMLIncludesTracks(t *testing.T) {
+	assets, err := extractAssetsFromHTML(trackPage, "https://example.com/talk")
+	if err != nil {
+		t.Fatalf("extractAssetsFromHTML: %v", err)
+	}
+	for _, want := range []string{"https://example.com/media/talk.en.vtt", "https://cdn.example.com/talk.ja.srt"} {
+		found := false
+		for _, a := range assets {
+			if a == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("extractAssetsFromHTML = %v, missing track %s", assets, want)
+		}
+	}
+}
+
+func TestModifyHTMLPathsRewritesTracks(t *testing.T) {
+	const entryUUID = "00000000-0000-0000-0000-000000000000"
+	en := localAssetPrefix + generateAssetFileName("https://example.com/media/talk.en.vtt", entryUUID)
+	ja := localAssetPrefix + generateAssetFileName("https://cdn.example.com/talk.ja.srt", entryUUID)
+	if !strings.HasSuffix(en, ".vtt") || !strings.HasSuffix(ja, ".srt") {
+		t.Errorf("track extensions not kept: %s, %s", en, ja)
+	}
+
+	got, err := modifyHTMLPaths(trackPage, entryUUID, "https://example.com/talk", nil)
+	if err != nil {
+		t.Fatalf("modifyHTMLPaths: %v", err)
+	}
+	for _, want := range []string{
+		`<track kind="captions" srclang="en" label="English" src="` + en + `" default=""/>`,
+		`<track kind="subtitles" srclang="ja" label="日本語" src="` + ja + `"/>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("modifyHTMLPaths missing %s:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidArchiveURL is returned when a URL is not an absolute http(s) URL
+var ErrInvalidArchiveURL = errors.New("URL must be an absolute http or https URL")
+
+// ValidateArchiveURL checks that rawURL is an absolute http(s) URL with a host
+func ValidateArchiveURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidArchiveURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidArchiveURL
+	}
+	return nil
+}
+
+// wasCanonicalized reports whether entry's CanonicalURL was derived with
+// ArchiveOptions.Canonicalize, so corrections can keep the same behaviour
+func wasCanonicalized(entry *models.ArchiveEntry) bool {
+	return entry.CanonicalURL == CanonicalizeURL(entry.URL)
+}
+
+// UpdateEntryURL corrects the stored URL of entry without refetching. The
+// CanonicalURL is recomputed the same way it was when the entry was archived,
+// and a fragment in newURL replaces the entry's Fragment.
+func UpdateEntryURL(db *gorm.DB, entry *models.ArchiveEntry, newURL string) error {
+	if err := ValidateArchiveURL(newURL); err != nil {
+		return err
+	}
+	newURL, fragment := splitFragment(newURL)
+
+	canonicalURL := newURL
+	if wasCanonicalized(entry) {
+		canonicalURL = CanonicalizeURL(newURL)
+	}
+
+	result := db.Model(entry).Updates(map[string]interface{}{
+		"url":           newURL,
+		"canonical_url": canonicalURL,
+		"fragment":      fragment,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update URL of archive entry '%s': %w", entry.ID, result.Error)
+	}
+	entry.URL = newURL
+	entry.CanonicalURL = canonicalURL
+	entry.Fragment = fragment
+	appendAudit(db, "update-url", entry)
+	return nil
+}
+
+// RefetchEntry archives newURL again in place of entry, keeping its ID and
+// SeriesID. The stored HTML, assets and screenshot are replaced, asset files
+// no longer referenced are removed, and the asset records are rewritten.
+// With onlyIfChanged, entry is left untouched and an *UnchangedError returned
+// when the page's text matches the stored capture.
+func RefetchEntry(db *gorm.DB, entry *models.ArchiveEntry, newURL string, onlyIfChanged bool) error {
+	started := time.Now()
+	err := refetchEntry(db, entry, newURL, onlyIfChanged)
+	var unchanged *UnchangedError
+	if errors.As(err, &unchanged) {
+		recordUnchanged("refetch", newURL, entry.ID, started)
+		return err
+	}
+	if err == nil {
+		appendAudit(db, "refetch", entry)
+	}
+	recordOperation("refetch", newURL, entry.ID, started, err)
+	return err
+}
+
+// refetchEntry implements RefetchEntry
+func refetchEntry(db *gorm.DB, entry *models.ArchiveEntry, newURL string, onlyIfChanged bool) error {
+	if err := ValidateArchiveURL(newURL); err != nil {
+		return err
+	}
+
+	// Remember the assets of the current capture so stale files can be removed
+	var oldAssets []string
+	if oldHTML, err := os.ReadFile(entry.StoragePath); err == nil {
+		oldAssets = localAssetNames(string(oldHTML))
+	}
+
+	opts := DefaultArchiveOptions()
+	opts.Canonicalize = wasCanonicalized(entry)
+	opts.SeriesID = entry.SeriesID
+	if entry.Device.Name != "" {
+		opts.Device = entry.Device
+	}
+	if entry.Rendered {
+		opts.RenderDOM = true
+	}
+	if entry.MHTMLPath != "" {
+		opts.MHTML = true
+	}
+	opts.Referer = entry.Referer
+	if replayNegotiation && len(entry.Negotiation) > 0 {
+		opts.negotiation = entry.Negotiation
+		if ua := entry.Negotiation["User-Agent"]; ua != "" {
+			// Assets are fetched with the same User-Agent as the page
+			opts.Device.UserAgent = ua
+		}
+	}
+	if onlyIfChanged {
+		opts.previousTextHash = entry.TextHash
+	}
+
+	captured, assetRecords, err := captureURL(newURL, entry.ID, opts)
+	if errors.Is(err, errContentUnchanged) {
+		return &UnchangedError{URL: newURL, Latest: entry}
+	}
+	if err != nil {
+		return err
+	}
+
+	// A failed screenshot must not leave the previous capture's image behind
+	if captured.ScreenshotPath == "" && entry.ScreenshotPath != "" {
+		os.Remove(entry.ScreenshotPath)
+	}
+	if captured.ThumbnailPath == "" && entry.ThumbnailPath != "" {
+		os.Remove(entry.ThumbnailPath)
+	}
+	if captured.TextPath == "" && entry.TextPath != "" {
+		os.Remove(entry.TextPath)
+	}
+	if captured.MHTMLPath == "" && entry.MHTMLPath != "" {
+		os.Remove(entry.MHTMLPath)
+	}
+	if !debugCapture {
+		// The previous capture's debug file no longer describes the stored page
+		os.Remove(DebugPath(entry.ID))
+	}
+	// Downloads are stored under their own extension
+	if captured.StoragePath != entry.StoragePath {
+		os.Remove(entry.StoragePath)
+	}
+
+	entry.URL = captured.URL
+	entry.RequestURL = captured.RequestURL
+	entry.CanonicalURL = captured.CanonicalURL
+	entry.Fragment = captured.Fragment
+	entry.Lang = captured.Lang
+	entry.Dir = captured.Dir
+	entry.StoragePath = captured.StoragePath
+	entry.ScreenshotPath = captured.ScreenshotPath
+	entry.ScreenshotAt = captured.ScreenshotAt
+	entry.ThumbnailPath = captured.ThumbnailPath
+	entry.TextPath = captured.TextPath
+	entry.MHTMLPath = captured.MHTMLPath
+	entry.ContentHash = captured.ContentHash
+	entry.TextHash = captured.TextHash
+	entry.HTTPStatus = captured.HTTPStatus
+	entry.SoftError = captured.SoftError
+	entry.ContentType = captured.ContentType
+	entry.Headers = captured.Headers
+	entry.Negotiation = captured.Negotiation
+	entry.Referer = captured.Referer
+	entry.Rendered = captured.Rendered
+	entry.FetchStrategy = captured.FetchStrategy
+	entry.Challenge = captured.Challenge
+	entry.Width = captured.Width
+	entry.Height = captured.Height
+	entry.FaviconURL = captured.FaviconURL
+	entry.AttachmentName = captured.AttachmentName
+	entry.StructuredData = captured.StructuredData
+	entry.ArchivedAt = captured.ArchivedAt
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(entry).Error; err != nil {
+			return err
+		}
+		return tx.Where("entry_id = ?", entry.ID).Delete(&models.Asset{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update archive entry '%s' after refetch: %w", entry.ID, err)
+	}
+	saveAssetRecords(db, entry.URL, assetRecords)
+
+	if newHTML, err := os.ReadFile(entry.StoragePath); err == nil {
+		current := make(map[string]bool)
+		for _, name := range localAssetNames(string(newHTML)) {
+			current[name] = true
+		}
+		for _, name := range oldAssets {
+			// Only remove files that belong to this entry
+			if !current[name] && strings.HasPrefix(name, entry.ID) {
+				os.Remove(filepath.Join(settings.AssetsDir, name))
+			}
+		}
+	}
+
+	return nil
+}
+
+// ReresolveResult reports the outcome of ReresolveEntry
+type ReresolveResult struct {
+	RequestURL  string `json:"requestUrl"`  // URL the resolution started from
+	StoredURL   string `json:"storedUrl"`   // Entry's URL before the call
+	ResolvedURL string `json:"resolvedUrl"` // Where RequestURL resolves to now
+	Changed     bool   `json:"changed"`     // Whether ResolvedURL differs from StoredURL
+	Updated     bool   `json:"updated"`     // Whether the entry's URL was replaced by ResolvedURL
+}
+
+// ReresolveEntry resolves the entry's original request URL again, following
+// shortener and redirect chains, without refetching content. With update
+// set, a changed result replaces the entry's URL as UpdateEntryURL does.
+// Entries archived before request URLs were recorded start from their URL.
+func ReresolveEntry(db *gorm.DB, entry *models.ArchiveEntry, update bool) (ReresolveResult, error) {
+	result := ReresolveResult{RequestURL: entry.RequestURL, StoredURL: entry.URL}
+	if result.RequestURL == "" {
+		result.RequestURL = entry.URL
+	}
+
+	resolvedURL, err := extractFinalURLFromGoogleNews(result.RequestURL)
+	if err != nil {
+		return result, err
+	}
+	result.ResolvedURL = resolvedURL
+	result.Changed = resolvedURL != entry.URL
+
+	if update && result.Changed {
+		if err := UpdateEntryURL(db, entry, resolvedURL); err != nil {
+			return result, err
+		}
+		result.Updated = true
+	}
+	return result, nil
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"archive-lite/tests"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRefetchEntryRemovesStaleStylesheetAssets(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+
+	var version atomic.Int32
+	version.Store(1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/site.css":
+			w.Header().Set("Content-Type", "text/css")
+			if version.Load() == 1 {
+				fmt.Fprint(w, `body { background: url("/bg-old.png"); } h1 { background: url("/keep.png"); }`)
+			} else {
+				fmt.Fprint(w, `body { background: url("/bg-new.png"); } h1 { background: url("/keep.png"); }`)
+			}
+		case "/bg-old.png", "/bg-new.png", "/keep.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("\x89PNG\r\n\x1a\nfake"))
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, `<html><head><link rel="stylesheet" href="/site.css"></head><body><p>Version %d</p></body></html>`, version.Load())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	origRaw, origAssets, origNoDelay, origScreenshots := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate, captureScreenshots
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate, captureScreenshots = origNoDelay, origScreenshots
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate, captureScreenshots = true, false
+
+	pageURL := server.URL + "/page"
+	t.Cleanup(func() { db.Where("request_url = ?", pageURL).Delete(&models.ArchiveEntry{}) })
+
+	entry, err := ArchiveURLWithOptions(db, pageURL, DefaultArchiveOptions())
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	assetPath := func(rawURL string) string {
+		return filepath.Join(settings.AssetsDir, generateAssetFileName(server.URL+rawURL, entry.ID))
+	}
+	if _, err := os.Stat(assetPath("/bg-old.png")); err != nil {
+		t.Fatalf("stylesheet asset not stored: %v", err)
+	}
+
+	// The stylesheet's references count as the page's assets
+	html, err := os.ReadFile(entry.StoragePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check := verifyAssetFiles(string(html)); !check.OK || check.Checked != 3 {
+		t.Errorf("verifyAssetFiles = %+v, want 3 assets checked", check)
+	}
+	if info := GetStorageInfo(entry); info.Assets.Count != 3 {
+		t.Errorf("GetStorageInfo lists %d assets, want 3", info.Assets.Count)
+	}
+
+	version.Store(2)
+	if err := RefetchEntry(db, entry, pageURL, false); err != nil {
+		t.Fatalf("RefetchEntry: %v", err)
+	}
+
+	if _, err := os.Stat(assetPath("/bg-old.png")); !os.IsNotExist(err) {
+		t.Errorf("asset only referenced by the old stylesheet was not removed (stat: %v)", err)
+	}
+	for _, kept := range []string{"/site.css", "/keep.png", "/bg-new.png"} {
+		if _, err := os.Stat(assetPath(kept)); err != nil {
+			t.Errorf("asset %s of the new capture is missing: %v", kept, err)
+		}
+	}
+}
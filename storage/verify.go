@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// localAssetPrefix is the URL prefix rewritten asset references point at
+const localAssetPrefix = "/data/assets/"
+
+// ComponentCheck is the verification result of one part of an archive
+type ComponentCheck struct {
+	OK      bool   `json:"ok"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// HTMLCheck reports whether the stored HTML still matches its recorded hash
+type HTMLCheck struct {
+	ComponentCheck
+	ExpectedHash string `json:"expectedHash,omitempty"`
+	ActualHash   string `json:"actualHash,omitempty"`
+}
+
+// AssetsCheck reports on the local asset files referenced by the stored HTML
+type AssetsCheck struct {
+	ComponentCheck
+	Checked int      `json:"checked"`
+	Missing []string `json:"missing"`
+	Empty   []string `json:"empty"`
+}
+
+// VerifyReport is the per-component integrity report for an archive entry
+type VerifyReport struct {
+	ID         string         `json:"id"`
+	OK         bool           `json:"ok"`
+	HTML       HTMLCheck      `json:"html"`
+	Assets     AssetsCheck    `json:"assets"`
+	Screenshot ComponentCheck `json:"screenshot"`
+}
+
+// hashContent returns the hex-encoded SHA-256 of data
+func hashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyArchive checks that an entry's stored HTML matches its ContentHash,
+// that every local asset it references exists and is non-empty, and that its
+// screenshot (if any) still decodes as an image
+func VerifyArchive(entry *models.ArchiveEntry) VerifyReport {
+	report := VerifyReport{ID: entry.ID}
+
+	content, err := os.ReadFile(entry.StoragePath)
+	if err != nil {
+		report.HTML.Error = fmt.Sprintf("failed to read stored HTML: %v", err)
+		report.Assets.Skipped = true
+		report.Assets.Error = "stored HTML unavailable"
+	} else {
+		report.HTML.ActualHash = hashContent(content)
+		report.HTML.ExpectedHash = entry.ContentHash
+		switch {
+		case entry.ContentHash == "":
+			// Archived before hashes were recorded; only readability can be checked
+			report.HTML.OK = true
+			report.HTML.Skipped = true
+		case report.HTML.ActualHash == entry.ContentHash:
+			report.HTML.OK = true
+		default:
+			report.HTML.Error = "content hash mismatch"
+		}
+		report.Assets = verifyAssetFiles(string(content))
+	}
+
+	if entry.ScreenshotPath == "" {
+		report.Screenshot.OK = true
+		report.Screenshot.Skipped = true
+	} else if _, err := decodeImageFile(entry.ScreenshotPath); err != nil {
+		report.Screenshot.Error = fmt.Sprintf("screenshot does not decode: %v", err)
+	} else {
+		report.Screenshot.OK = true
+	}
+
+	report.OK = report.HTML.OK && report.Assets.OK && report.Screenshot.OK
+	return report
+}
+
+// verifyAssetFiles checks every /data/assets/ reference in htmlContent and in
+// the stylesheets it references
+func verifyAssetFiles(htmlContent string) AssetsCheck {
+	check := AssetsCheck{Missing: []string{}, Empty: []string{}}
+	for _, fileName := range localAssetNames(htmlContent) {
+		check.Checked++
+		size, _, err := statAsset(fileName)
+		if err != nil {
+			check.Missing = append(check.Missing, fileName)
+		} else if size == 0 {
+			check.Empty = append(check.Empty, fileName)
+		}
+	}
+	check.OK = len(check.Missing) == 0 && len(check.Empty) == 0
+	return check
+}
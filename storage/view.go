@@ -0,0 +1,11 @@
+package storage
+
+import "strings"
+
+// RelativizeAssetPaths rewrites references to entryID's own assets from
+// /data/assets/<entryID>_<name> to the relative <name>, so a page or
+// stylesheet served from /view/<entryID>/ loads them from under that prefix.
+// Assets of other entries keep their absolute paths.
+func RelativizeAssetPaths(content, entryID string) string {
+	return strings.ReplaceAll(content, localAssetPrefix+entryID+"_", "")
+}
@@ -0,0 +1,14 @@
+package storage
+
+import "testing"
+
+func TestRelativizeAssetPaths(t *testing.T) {
+	const id = "11111111-1111-1111-1111-111111111111"
+	in := `<img src="/data/assets/` + id + `_abc.png"><img src="/data/assets/22222222-2222-2222-2222-222222222222_def.png">` +
+		`<style>body{background:url("/data/assets/` + id + `_bg.jpg")}</style>`
+	want := `<img src="abc.png"><img src="/data/assets/22222222-2222-2222-2222-222222222222_def.png">` +
+		`<style>body{background:url("bg.jpg")}</style>`
+	if got := RelativizeAssetPaths(in, id); got != want {
+		t.Errorf("RelativizeAssetPaths = %s, want %s", got, want)
+	}
+}
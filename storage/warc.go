@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// warcField is one named header line of a WARC record
+type warcField struct {
+	Name, Value string
+}
+
+// warcWriter writes WARC/1.1 records to an underlying writer. With compress
+// set, every record is written as its own gzip member, which the WARC spec
+// permits and which lets readers seek to individual records of a .warc.gz.
+type warcWriter struct {
+	w        io.Writer
+	compress bool
+}
+
+// writeRecord writes a record with the given fields and a block of exactly
+// length bytes read from block
+func (ww *warcWriter) writeRecord(fields []warcField, length int64, block io.Reader) error {
+	out := ww.w
+	var gz *gzip.Writer
+	if ww.compress {
+		gz = gzip.NewWriter(ww.w)
+		out = gz
+	}
+
+	var head strings.Builder
+	head.WriteString("WARC/1.1\r\n")
+	for _, f := range fields {
+		fmt.Fprintf(&head, "%s: %s\r\n", f.Name, f.Value)
+	}
+	fmt.Fprintf(&head, "Content-Length: %d\r\n\r\n", length)
+
+	if _, err := io.WriteString(out, head.String()); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(out, block, length); err != nil {
+		return fmt.Errorf("failed to write WARC record block: %w", err)
+	}
+	if _, err := io.WriteString(out, "\r\n\r\n"); err != nil {
+		return err
+	}
+	if gz != nil {
+		return gz.Close()
+	}
+	return nil
+}
+
+// writeString writes a record whose block is content
+func (ww *warcWriter) writeString(fields []warcField, content string) error {
+	return ww.writeRecord(fields, int64(len(content)), strings.NewReader(content))
+}
+
+// writeFile writes a resource record for a stored file
+func (ww *warcWriter) writeFile(fields []warcField, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open '%s': %w", path, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat '%s': %w", path, err)
+	}
+	return ww.writeRecord(fields, info.Size(), f)
+}
+
+// writeAsset writes a resource record for a stored asset, loose or bundled
+func (ww *warcWriter) writeAsset(fields []warcField, name string) error {
+	path := filepath.Join(settings.AssetsDir, name)
+	if _, err := os.Stat(path); err == nil {
+		return ww.writeFile(fields, path)
+	}
+	content, err := ReadAsset(name)
+	if err != nil {
+		return fmt.Errorf("failed to read asset '%s': %w", name, err)
+	}
+	return ww.writeRecord(fields, int64(len(content)), bytes.NewReader(content))
+}
+
+// newWARCRecordID returns a fresh WARC-Record-ID value
+func newWARCRecordID() string {
+	return "<urn:uuid:" + uuid.New().String() + ">"
+}
+
+// warcDate formats t as a WARC-Date
+func warcDate(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// WriteWARC streams an entry's stored files to w as WARC/1.1 records: a
+// warcinfo record, the stored page and its recorded response headers, the
+// screenshot and every stored asset. Stored files are written as resource
+// records since they are the archived (rewritten) content rather than the
+// original responses. With compress set, each record is gzipped separately.
+func WriteWARC(w io.Writer, entry *models.ArchiveEntry, assets []models.Asset, compress bool) error {
+	ww := &warcWriter{w: w, compress: compress}
+
+	info := fmt.Sprintf("software: archive-lite %s\r\nformat: WARC File Format 1.1\r\n", Version)
+	if recordProvenance {
+		info += fmt.Sprintf("%s: %s\r\n", provenanceMetaName, provenance(entry.ArchivedAt, entry.URL))
+	}
+	if err := ww.writeString([]warcField{
+		{"WARC-Type", "warcinfo"},
+		{"WARC-Record-ID", newWARCRecordID()},
+		{"WARC-Date", warcDate(time.Now())},
+		{"WARC-Filename", entry.ID + ".warc"},
+		{"Content-Type", "application/warc-fields"},
+	}, info); err != nil {
+		return err
+	}
+
+	contentType := entry.ContentType
+	if contentType == "" {
+		contentType = "text/html"
+	}
+	pageRecordID := newWARCRecordID()
+	if err := ww.writeFile([]warcField{
+		{"WARC-Type", "resource"},
+		{"WARC-Record-ID", pageRecordID},
+		{"WARC-Date", warcDate(entry.ArchivedAt)},
+		{"WARC-Target-URI", entry.URL},
+		{"Content-Type", contentType},
+	}, entry.StoragePath); err != nil {
+		return err
+	}
+
+	if len(entry.Headers) > 0 {
+		if err := ww.writeString([]warcField{
+			{"WARC-Type", "metadata"},
+			{"WARC-Record-ID", newWARCRecordID()},
+			{"WARC-Date", warcDate(entry.ArchivedAt)},
+			{"WARC-Target-URI", entry.URL},
+			{"WARC-Concurrent-To", pageRecordID},
+			{"Content-Type", "application/warc-fields"},
+		}, responseHeaderFields(entry)); err != nil {
+			return err
+		}
+	}
+
+	if entry.ScreenshotPath != "" {
+		if err := ww.writeFile([]warcField{
+			{"WARC-Type", "resource"},
+			{"WARC-Record-ID", newWARCRecordID()},
+			{"WARC-Date", warcDate(entry.ArchivedAt)},
+			{"WARC-Target-URI", "urn:archive-lite:screenshot:" + entry.ID},
+			{"WARC-Concurrent-To", pageRecordID},
+			{"Content-Type", "image/jpeg"},
+		}, entry.ScreenshotPath); err != nil {
+			fmt.Printf("Warning: skipping screenshot in WARC for '%s': %v\n", entry.ID, err)
+		}
+	}
+
+	written := make(map[string]bool)
+	for _, asset := range assets {
+		if asset.FileName == "" || written[asset.FileName] {
+			continue
+		}
+		written[asset.FileName] = true
+
+		targetURI := asset.FinalURL
+		if targetURI == "" {
+			targetURI = asset.URL
+		}
+		fields := []warcField{
+			{"WARC-Type", "resource"},
+			{"WARC-Record-ID", newWARCRecordID()},
+			{"WARC-Date", warcDate(asset.FetchedAt)},
+			{"WARC-Target-URI", targetURI},
+		}
+		if asset.ContentType != "" {
+			fields = append(fields, warcField{"Content-Type", asset.ContentType})
+		}
+		if err := ww.writeAsset(fields, asset.FileName); err != nil {
+			fmt.Printf("Warning: skipping asset in WARC for '%s': %v\n", entry.ID, err)
+		}
+	}
+	return nil
+}
+
+// responseHeaderFields renders an entry's response status and headers as
+// application/warc-fields, in a stable order
+func responseHeaderFields(entry *models.ArchiveEntry) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "http-status: %d\r\n", entry.HTTPStatus)
+
+	names := make([]string, 0, len(entry.Headers))
+	for name := range entry.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range entry.Headers[name] {
+			fmt.Fprintf(&b, "http-header: %s: %s\r\n", name, value)
+		}
+	}
+	return b.String()
+}
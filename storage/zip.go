@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// localAssetRefPattern matches rewritten asset references in stored pages
+// and stylesheets, capturing the asset file name
+var localAssetRefPattern = regexp.MustCompile(regexp.QuoteMeta(localAssetPrefix) + `([^"'()\s<>]+)`)
+
+// zipAssetsDir is the directory of the assets in a ZIP export
+const zipAssetsDir = "assets/"
+
+// PortableAssetPaths rewrites local asset references (/data/assets/<name>)
+// in a stored page or stylesheet to prefix followed by <name>, e.g.
+// "assets/" for a page next to an assets directory or "" for a stylesheet
+// inside it, so the files work when opened from disk
+func PortableAssetPaths(content, prefix string) string {
+	return localAssetRefPattern.ReplaceAllString(content, strings.ReplaceAll(prefix, "$", "$$")+"${1}")
+}
+
+// WriteZIP writes entry as a ZIP archive that works when extracted and
+// opened from disk: index.html with its asset references rewritten to
+// relative assets/<name> paths, and the referenced assets (including those
+// referenced from stylesheets) under assets/. Downloads archived with
+// ARCHIVE_ATTACHMENT_POLICY=store are exported as the single stored file. Assets
+// that are missing on disk are left out.
+func WriteZIP(w io.Writer, entry *models.ArchiveEntry) error {
+	zw := zip.NewWriter(w)
+
+	content, err := os.ReadFile(entry.StoragePath)
+	if err != nil {
+		return fmt.Errorf("failed to read archived content '%s': %w", entry.StoragePath, err)
+	}
+	if entry.AttachmentName != "" {
+		if err := writeZIPFile(zw, path.Base(entry.AttachmentName), content); err != nil {
+			return err
+		}
+		return zw.Close()
+	}
+
+	if err := writeZIPFile(zw, "index.html", []byte(PortableAssetPaths(string(content), zipAssetsDir))); err != nil {
+		return err
+	}
+
+	for _, name := range localAssetNames(string(content)) {
+		asset, err := ReadAsset(name)
+		if err != nil {
+			fmt.Printf("Warning: asset '%s' of archive %s not exported: %v\n", name, entry.ID, err)
+			continue
+		}
+		if isStylesheetAsset(name) {
+			asset = []byte(PortableAssetPaths(string(asset), ""))
+		}
+		if err := writeZIPFile(zw, zipAssetsDir+name, asset); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// localAssetNames returns the distinct names of the assets referenced from a
+// stored page, including those referenced from its stored stylesheets (and
+// theirs, e.g. through @import), in discovery order
+func localAssetNames(content string) []string {
+	seen := make(map[string]bool)
+	names := localAssetRefs(content, seen)
+	for i := 0; i < len(names); i++ {
+		if !isStylesheetAsset(names[i]) {
+			continue
+		}
+		if css, err := ReadAsset(names[i]); err == nil {
+			names = append(names, localAssetRefs(string(css), seen)...)
+		}
+	}
+	return names
+}
+
+// isStylesheetAsset reports whether the stored asset name is a stylesheet
+func isStylesheetAsset(name string) bool {
+	return strings.EqualFold(path.Ext(name), ".css")
+}
+
+// localAssetRefs returns the asset names referenced in content that aren't
+// in seen yet, adding them to it
+func localAssetRefs(content string, seen map[string]bool) []string {
+	var names []string
+	for _, groups := range localAssetRefPattern.FindAllStringSubmatch(content, -1) {
+		name := groups[1]
+		if !seen[name] && !strings.Contains(name, "..") {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// writeZIPFile adds a file to zw
+func writeZIPFile(zw *zip.Writer, name string, content []byte) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add '%s' to ZIP: %w", name, err)
+	}
+	if _, err := f.Write(content); err != nil {
+		return fmt.Errorf("failed to write '%s' to ZIP: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"archive-lite/models"
+	"archive-lite/tests"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteZIPUsesRelativeAssetPaths(t *testing.T) {
+	db, err := tests.SetupTestDB()
+	if err != nil {
+		t.Fatalf("SetupTestDB: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/site.css":
+			w.Header().Set("Content-Type", "text/css")
+			fmt.Fprint(w, `body { background: url("/bg.png"); }`)
+		case "/bg.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("\x89PNG\r\n\x1a\nfake"))
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><head><link rel="stylesheet" href="/site.css"></head><body><p>Portable</p></body></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	origRaw, origAssets, origNoDelay, origScreenshots := settings.RawHTMLDir, settings.AssetsDir, noDelayPrivate, captureScreenshots
+	defer func() {
+		SetStorageBaseDirsForTest(origRaw, origAssets)
+		noDelayPrivate, captureScreenshots = origNoDelay, origScreenshots
+	}()
+	SetStorageBaseDirsForTest(t.TempDir(), t.TempDir())
+	noDelayPrivate, captureScreenshots = true, false
+
+	pageURL := server.URL + "/page"
+	t.Cleanup(func() { db.Where("request_url = ?", pageURL).Delete(&models.ArchiveEntry{}) })
+
+	entry, err := ArchiveURLWithOptions(db, pageURL, DefaultArchiveOptions())
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteZIP(&buf, entry); err != nil {
+		t.Fatalf("WriteZIP: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("reading ZIP: %v", err)
+	}
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("opening %s: %v", f.Name, err)
+		}
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(content)
+	}
+
+	cssName := "assets/" + generateAssetFileName(server.URL+"/site.css", entry.ID)
+	imageName := generateAssetFileName(server.URL+"/bg.png", entry.ID)
+	if !strings.Contains(files["index.html"], `href="`+cssName+`"`) {
+		t.Errorf("index.html does not link %s:\n%s", cssName, files["index.html"])
+	}
+	css, ok := files[cssName]
+	if !ok {
+		t.Fatalf("stylesheet %s missing from ZIP (files: %v)", cssName, len(files))
+	}
+	if !strings.Contains(css, `url("`+imageName+`")`) {
+		t.Errorf("stylesheet does not reference sibling %s: %s", imageName, css)
+	}
+	if _, ok := files["assets/"+imageName]; !ok {
+		t.Errorf("image referenced from the stylesheet missing from ZIP")
+	}
+	for name, content := range files {
+		if strings.Contains(content, localAssetPrefix) {
+			t.Errorf("%s still contains %s paths", name, localAssetPrefix)
+		}
+	}
+}
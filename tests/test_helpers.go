@@ -9,6 +9,7 @@ import (
 	"sync"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -31,7 +32,7 @@ func SetupTestDB() (*gorm.DB, error) {
 
 		log.Println("In-memory test database connection established.")
 
-		dbInitErr = testDB.AutoMigrate(&models.ArchiveEntry{})
+		dbInitErr = testDB.AutoMigrate(&models.ArchiveEntry{}, &models.Asset{}, &models.AuditRecord{})
 		if dbInitErr != nil {
 			log.Fatalf("Failed to auto-migrate test database schema: %v", dbInitErr)
 			return
@@ -53,6 +54,7 @@ func CreateTestApp() *fiber.App {
 			return ctx.Status(code).SendString(err.Error())
 		},
 	})
+	app.Use(recover.New(recover.Config{EnableStackTrace: true}))
 	return app
 }
 